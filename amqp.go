@@ -0,0 +1,579 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// amqpConn 实现了 AMQP 0-9-1 中 queue 命令用到的部分，只使用一个 channel：
+// 由一个 goroutine 读取服务器发来的帧，投递的消息放到 deliveries，其他方法的应答放到 replies；
+// 地址为 amqp://[用户名:密码@]host[:port][/vhost]，amqps:// 使用 TLS，没有用户名时使用 guest
+type amqpConn struct {
+	mu         sync.Mutex
+	conn       net.Conn
+	w          *bufio.Writer
+	frameMax   int
+	heartbeat  time.Duration
+	deliveries chan *amqpDelivery
+	replies    chan *amqpMethod
+	done       chan struct{}
+	err        error
+	closed     bool
+}
+
+// amqpMethod 为收到的一个方法帧，args 为方法的参数
+type amqpMethod struct {
+	class, method uint16
+	args          *amqpReader
+}
+
+// amqpDelivery 为 Basic.Deliver 投递的一条消息
+type amqpDelivery struct {
+	Tag  uint64
+	Body []byte
+}
+
+const (
+	amqpFrameMethod    = 1
+	amqpFrameHeader    = 2
+	amqpFrameBody      = 3
+	amqpFrameHeartbeat = 8
+	amqpFrameEnd       = 0xCE
+
+	amqpClassConnection = 10
+	amqpClassChannel    = 20
+	amqpClassQueue      = 50
+	amqpClassBasic      = 60
+	amqpClassConfirm    = 85
+)
+
+func errAMQPFormat() error {
+	return errors.New(trErr("AMQP 应答格式错误"))
+}
+
+func dialAMQP(rawurl string) (*amqpConn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil || (u.Scheme != "amqp" && u.Scheme != "amqps") || len(u.Hostname()) == 0 {
+		return nil, errors.New(trErr("无效的 AMQP 地址，格式为 amqp://[用户名:密码@]host[:port][/vhost]"))
+	}
+	port := u.Port()
+	if len(port) == 0 {
+		port = "5672"
+		if u.Scheme == "amqps" {
+			port = "5671"
+		}
+	}
+	vhost := "/"
+	if len(u.Path) > 1 {
+		vhost = u.Path[1:]
+	}
+	user, password := "guest", "guest"
+	if u.User != nil {
+		user = u.User.Username()
+		password, _ = u.User.Password()
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(u.Hostname(), port), 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "amqps" {
+		conn = tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	c := &amqpConn{
+		conn:       conn,
+		w:          bufio.NewWriter(conn),
+		frameMax:   131072,
+		deliveries: make(chan *amqpDelivery, 1024),
+		replies:    make(chan *amqpMethod, 16),
+		done:       make(chan struct{}),
+	}
+	r := bufio.NewReader(conn)
+	if err := c.handshake(r, vhost, user, password); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	go c.read(r)
+	if c.heartbeat > 0 {
+		go c.beat()
+	}
+
+	if _, err := c.call(amqpClassChannel, 10, amqpClassChannel, 11, func(w *amqpWriter) {
+		w.putShortstr("")
+	}); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// handshake 完成 Connection.Start、Tune 和 Open，使用 PLAIN 认证
+func (c *amqpConn) handshake(r *bufio.Reader, vhost, user, password string) error {
+	if _, err := c.conn.Write([]byte("AMQP\x00\x00\x09\x01")); err != nil {
+		return err
+	}
+	m, err := c.expect(r, amqpClassConnection, 10)
+	if err != nil {
+		return err
+	}
+	m.args.next(2) // version
+	m.args.table()
+	if mechanisms := string(m.args.longstr()); !strings.Contains(mechanisms, "PLAIN") {
+		return fmt.Errorf(trErr("AMQP 服务器不支持 PLAIN 认证：%s"), mechanisms)
+	}
+	c.send(0, amqpClassConnection, 11, func(w *amqpWriter) {
+		w.putTable(map[string]interface{}{
+			"product":         "email-sender",
+			"connection_name": "email-sender",
+			"capabilities": map[string]interface{}{
+				"authentication_failure_close": true,
+				"consumer_cancel_notify":       true,
+				"publisher_confirms":           true,
+			},
+		})
+		w.putShortstr("PLAIN")
+		w.putLongstr([]byte("\x00" + user + "\x00" + password))
+		w.putShortstr("en_US")
+	})
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+
+	// 认证失败时服务器直接关闭连接，或者返回 Connection.Close
+	if m, err = c.expect(r, amqpClassConnection, 30); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return errors.New(trErr("AMQP 认证失败"))
+		}
+		return err
+	}
+	channels := m.args.short()
+	if frameMax := int(m.args.long()); frameMax > 0 && frameMax < c.frameMax {
+		c.frameMax = frameMax
+	}
+	c.heartbeat = time.Duration(m.args.short()) * time.Second
+	c.send(0, amqpClassConnection, 31, func(w *amqpWriter) {
+		w.putShort(channels)
+		w.putLong(uint32(c.frameMax))
+		w.putShort(uint16(c.heartbeat / time.Second))
+	})
+	c.send(0, amqpClassConnection, 40, func(w *amqpWriter) {
+		w.putShortstr(vhost)
+		w.putShortstr("")
+		w.putOctet(0)
+	})
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	_, err = c.expect(r, amqpClassConnection, 41)
+	return err
+}
+
+// expect 在握手阶段读取下一个方法帧，服务器返回 Connection.Close 时转换为错误
+func (c *amqpConn) expect(r *bufio.Reader, class, method uint16) (*amqpMethod, error) {
+	for {
+		typ, _, payload, err := readAMQPFrame(r)
+		if err != nil {
+			return nil, err
+		}
+		if typ != amqpFrameMethod {
+			continue
+		}
+		m, err := parseAMQPMethod(payload)
+		if err != nil {
+			return nil, err
+		}
+		if m.class == amqpClassConnection && m.method == 50 {
+			return nil, amqpCloseError(m)
+		}
+		if m.class != class || m.method != method {
+			return nil, errAMQPFormat()
+		}
+		return m, nil
+	}
+}
+
+func readAMQPFrame(r *bufio.Reader) (byte, uint16, []byte, error) {
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[3:])
+	if size > 1<<24 {
+		return 0, 0, nil, errAMQPFormat()
+	}
+	payload := make([]byte, size+1)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, 0, nil, err
+	}
+	if payload[size] != amqpFrameEnd {
+		return 0, 0, nil, errAMQPFormat()
+	}
+	return header[0], binary.BigEndian.Uint16(header[1:]), payload[:size], nil
+}
+
+func parseAMQPMethod(payload []byte) (*amqpMethod, error) {
+	r := &amqpReader{data: payload}
+	m := &amqpMethod{class: r.short(), method: r.short(), args: r}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return m, nil
+}
+
+// amqpCloseError 把 Connection.Close 或 Channel.Close 的原因转换为错误，例如 404 NOT_FOUND - no queue 'mail'
+func amqpCloseError(m *amqpMethod) error {
+	code := m.args.short()
+	text := m.args.shortstr()
+	if m.args.err != nil {
+		return m.args.err
+	}
+	return fmt.Errorf(trErr("AMQP 服务器关闭了连接：%d %s"), code, text)
+}
+
+// send 写入一个方法帧，channel 0 用于连接本身，1 为唯一使用的 channel；调用者负责 Flush
+func (c *amqpConn) send(channel, class, method uint16, args func(w *amqpWriter)) {
+	var w amqpWriter
+	w.putShort(class)
+	w.putShort(method)
+	if args != nil {
+		args(&w)
+	}
+	c.writeFrame(amqpFrameMethod, channel, w.Bytes())
+}
+
+func (c *amqpConn) writeFrame(typ byte, channel uint16, payload []byte) {
+	header := make([]byte, 7)
+	header[0] = typ
+	binary.BigEndian.PutUint16(header[1:], channel)
+	binary.BigEndian.PutUint32(header[3:], uint32(len(payload)))
+	c.w.Write(header)
+	c.w.Write(payload)
+	c.w.WriteByte(amqpFrameEnd)
+}
+
+// call 在 channel 1 上发送一个同步方法并等待指定的应答
+func (c *amqpConn) call(class, method, replyClass, replyMethod uint16, args func(w *amqpWriter)) (*amqpMethod, error) {
+	if err := c.cast(class, method, args); err != nil {
+		return nil, err
+	}
+	return c.wait(replyClass, replyMethod)
+}
+
+// cast 在 channel 1 上发送一个不需要应答的方法
+func (c *amqpConn) cast(class, method uint16, args func(w *amqpWriter)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.send(1, class, method, args)
+	return c.w.Flush()
+}
+
+func (c *amqpConn) wait(class, method uint16) (*amqpMethod, error) {
+	select {
+	case m := <-c.replies:
+		if m.class != class || m.method != method {
+			return nil, errAMQPFormat()
+		}
+		return m, nil
+	case <-c.done:
+		return nil, c.err
+	case <-time.After(30 * time.Second):
+		return nil, errors.New(trErr("等待 AMQP 应答超时"))
+	}
+}
+
+// publish 通过 exchange 发布一条持久化的消息，expiration 不为 0 时为消息的过期时间；开启了 Confirm 时调用者需要等待 confirm
+func (c *amqpConn) publish(exchange, routingKey string, body []byte, expiration time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.send(1, amqpClassBasic, 40, func(w *amqpWriter) {
+		w.putShort(0)
+		w.putShortstr(exchange)
+		w.putShortstr(routingKey)
+		w.putOctet(0)
+	})
+	var w amqpWriter
+	w.putShort(amqpClassBasic)
+	w.putShort(0)
+	w.putLonglong(uint64(len(body)))
+	flags := uint16(0x8000 | 0x1000) // content-type、delivery-mode
+	if expiration > 0 {
+		flags |= 0x0100
+	}
+	w.putShort(flags)
+	w.putShortstr("application/json")
+	w.putOctet(2)
+	if expiration > 0 {
+		w.putShortstr(strconv.FormatInt(int64(expiration/time.Millisecond), 10))
+	}
+	c.writeFrame(amqpFrameHeader, 1, w.Bytes())
+	for size := c.frameMax - 8; len(body) > 0; {
+		n := len(body)
+		if n > size {
+			n = size
+		}
+		c.writeFrame(amqpFrameBody, 1, body[:n])
+		body = body[n:]
+	}
+	return c.w.Flush()
+}
+
+// confirm 等待服务器确认 publish 的消息已经保存
+func (c *amqpConn) confirm() error {
+	select {
+	case m := <-c.replies:
+		if m.class == amqpClassBasic && m.method == 120 {
+			return errors.New(trErr("AMQP 服务器拒绝保存消息"))
+		}
+		if m.class != amqpClassBasic || m.method != 80 {
+			return errAMQPFormat()
+		}
+		return nil
+	case <-c.done:
+		return c.err
+	case <-time.After(30 * time.Second):
+		return errors.New(trErr("等待 AMQP 应答超时"))
+	}
+}
+
+// beat 按协商的间隔发送心跳，服务器两个间隔内收不到数据会断开连接
+func (c *amqpConn) beat() {
+	ticker := time.NewTicker(c.heartbeat / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+		c.mu.Lock()
+		c.writeFrame(amqpFrameHeartbeat, 0, nil)
+		c.w.Flush()
+		c.mu.Unlock()
+	}
+}
+
+func (c *amqpConn) read(r *bufio.Reader) {
+	err := c.readLoop(r)
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.mu.Unlock()
+	close(c.done)
+}
+
+func (c *amqpConn) readLoop(r *bufio.Reader) error {
+	var delivery *amqpDelivery
+	var size uint64
+	for {
+		if c.heartbeat > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(3 * c.heartbeat))
+		}
+		typ, _, payload, err := readAMQPFrame(r)
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case amqpFrameMethod:
+			m, err := parseAMQPMethod(payload)
+			if err != nil {
+				return err
+			}
+			switch {
+			case m.class == amqpClassBasic && m.method == 60:
+				// Basic.Deliver：consumer-tag、delivery-tag，之后是消息头和消息体
+				m.args.shortstr()
+				delivery = &amqpDelivery{Tag: m.args.longlong()}
+				if m.args.err != nil {
+					return m.args.err
+				}
+			case m.class == amqpClassConnection && m.method == 50, m.class == amqpClassChannel && m.method == 40:
+				c.mu.Lock()
+				c.send(0, m.class, m.method+1, nil)
+				c.w.Flush()
+				c.mu.Unlock()
+				return amqpCloseError(m)
+			case m.class == amqpClassBasic && m.method == 30:
+				return errors.New(trErr("AMQP 服务器取消了消费，队列可能已被删除"))
+			default:
+				c.replies <- m
+			}
+		case amqpFrameHeader:
+			if delivery == nil || len(payload) < 12 {
+				return errAMQPFormat()
+			}
+			size = binary.BigEndian.Uint64(payload[4:])
+			if size == 0 {
+				c.deliveries <- delivery
+				delivery = nil
+			}
+		case amqpFrameBody:
+			if delivery == nil {
+				return errAMQPFormat()
+			}
+			delivery.Body = append(delivery.Body, payload...)
+			if uint64(len(delivery.Body)) >= size {
+				c.deliveries <- delivery
+				delivery = nil
+			}
+		}
+	}
+}
+
+// Close 发送 Connection.Close，最多等待一秒服务器关闭连接
+func (c *amqpConn) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	if c.err == nil {
+		c.send(0, amqpClassConnection, 50, func(w *amqpWriter) {
+			w.putShort(200)
+			w.putShortstr("")
+			w.putShort(0)
+			w.putShort(0)
+		})
+		c.w.Flush()
+	}
+	c.mu.Unlock()
+	select {
+	case <-c.done:
+	case <-time.After(time.Second):
+	}
+	return c.conn.Close()
+}
+
+type amqpWriter struct {
+	bytes.Buffer
+}
+
+func (w *amqpWriter) putOctet(v byte) {
+	w.WriteByte(v)
+}
+
+func (w *amqpWriter) putShort(v uint16) {
+	binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *amqpWriter) putLong(v uint32) {
+	binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *amqpWriter) putLonglong(v uint64) {
+	binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *amqpWriter) putShortstr(s string) {
+	w.WriteByte(byte(len(s)))
+	w.WriteString(s)
+}
+
+func (w *amqpWriter) putLongstr(b []byte) {
+	w.putLong(uint32(len(b)))
+	w.Write(b)
+}
+
+// putTable 写入字段表，值只支持 string、bool 和嵌套的字段表
+func (w *amqpWriter) putTable(t map[string]interface{}) {
+	var table amqpWriter
+	for k, v := range t {
+		table.putShortstr(k)
+		switch v := v.(type) {
+		case string:
+			table.putOctet('S')
+			table.putLongstr([]byte(v))
+		case bool:
+			table.putOctet('t')
+			if v {
+				table.putOctet(1)
+			} else {
+				table.putOctet(0)
+			}
+		case map[string]interface{}:
+			table.putOctet('F')
+			table.putTable(v)
+		}
+	}
+	w.putLongstr(table.Bytes())
+}
+
+// amqpReader 按顺序读取方法的参数，数据不够时记录错误，之后读取的字段都为零值
+type amqpReader struct {
+	data []byte
+	err  error
+}
+
+func (r *amqpReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data) {
+		r.err = errAMQPFormat()
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *amqpReader) octet() byte {
+	if b := r.next(1); len(b) == 1 {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *amqpReader) short() uint16 {
+	if b := r.next(2); len(b) == 2 {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *amqpReader) long() uint32 {
+	if b := r.next(4); len(b) == 4 {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *amqpReader) longlong() uint64 {
+	if b := r.next(8); len(b) == 8 {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *amqpReader) shortstr() string {
+	return string(r.next(int(r.octet())))
+}
+
+func (r *amqpReader) longstr() []byte {
+	return r.next(int(r.long()))
+}
+
+// table 跳过一个字段表，queue 命令不需要服务器返回的属性
+func (r *amqpReader) table() {
+	r.longstr()
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+)
+
+// testAMQPConn 返回一个只写入 buf 的连接，readLoop 投递的消息放到有缓冲的 deliveries
+func testAMQPConn(buf *bytes.Buffer) *amqpConn {
+	return &amqpConn{
+		w:          bufio.NewWriter(buf),
+		deliveries: make(chan *amqpDelivery, 10),
+		replies:    make(chan *amqpMethod, 10),
+	}
+}
+
+// testAMQPTable 解析 putTable 写入的字段表
+func testAMQPTable(t *testing.T, r *amqpReader) map[string]interface{} {
+	table := &amqpReader{data: r.longstr()}
+	v := map[string]interface{}{}
+	for len(table.data) > 0 && table.err == nil {
+		k := table.shortstr()
+		switch typ := table.octet(); typ {
+		case 'S':
+			v[k] = string(table.longstr())
+		case 't':
+			v[k] = table.octet() != 0
+		case 'F':
+			v[k] = testAMQPTable(t, table)
+		default:
+			t.Fatalf("field %s has type %q", k, typ)
+		}
+	}
+	if table.err != nil || r.err != nil {
+		t.Fatalf("table: %v %v", table.err, r.err)
+	}
+	return v
+}
+
+func TestAMQPMethodFrame(t *testing.T) {
+	var buf bytes.Buffer
+	c := testAMQPConn(&buf)
+	props := map[string]interface{}{
+		"product":      "email-sender",
+		"capabilities": map[string]interface{}{"publisher_confirms": true, "basic.nack": false},
+	}
+	c.send(0, amqpClassConnection, 11, func(w *amqpWriter) {
+		w.putTable(props)
+		w.putShortstr("PLAIN")
+		w.putLongstr([]byte("\x00guest\x00guest"))
+		w.putShortstr("en_US")
+		w.putLonglong(1 << 40)
+	})
+	c.w.Flush()
+
+	typ, channel, payload, err := readAMQPFrame(bufio.NewReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ != amqpFrameMethod || channel != 0 {
+		t.Fatalf("frame type %d channel %d, want %d 0", typ, channel, amqpFrameMethod)
+	}
+	m, err := parseAMQPMethod(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.class != amqpClassConnection || m.method != 11 {
+		t.Fatalf("method %d.%d, want 10.11", m.class, m.method)
+	}
+	if got := testAMQPTable(t, m.args); !reflect.DeepEqual(got, props) {
+		t.Fatalf("table = %v, want %v", got, props)
+	}
+	if got := m.args.shortstr(); got != "PLAIN" {
+		t.Fatalf("mechanism = %q", got)
+	}
+	if got := string(m.args.longstr()); got != "\x00guest\x00guest" {
+		t.Fatalf("response = %q", got)
+	}
+	if got := m.args.shortstr(); got != "en_US" {
+		t.Fatalf("locale = %q", got)
+	}
+	if got := m.args.longlong(); got != 1<<40 {
+		t.Fatalf("longlong = %d", got)
+	}
+	if m.args.err != nil || len(m.args.data) != 0 {
+		t.Fatalf("args: err %v, %d bytes left", m.args.err, len(m.args.data))
+	}
+
+	// 参数不够时之后读取的字段都为零值
+	if got := m.args.short(); got != 0 || m.args.err == nil {
+		t.Fatalf("short past end = %d, err %v", got, m.args.err)
+	}
+}
+
+func TestReadAMQPFrame(t *testing.T) {
+	frame := func(typ byte, channel uint16, payload string, end byte) []byte {
+		var w amqpWriter
+		w.putOctet(typ)
+		w.putShort(channel)
+		w.putLong(uint32(len(payload)))
+		w.WriteString(payload)
+		w.putOctet(end)
+		return w.Bytes()
+	}
+	tests := []struct {
+		name    string
+		data    []byte
+		typ     byte
+		channel uint16
+		payload string
+		err     error
+	}{
+		{name: "heartbeat", data: frame(amqpFrameHeartbeat, 0, "", amqpFrameEnd), typ: amqpFrameHeartbeat},
+		{name: "body", data: frame(amqpFrameBody, 1, "hello", amqpFrameEnd), typ: amqpFrameBody, channel: 1, payload: "hello"},
+		{name: "bad frame end", data: frame(amqpFrameBody, 1, "hello", 0), err: errAMQPFormat()},
+		{name: "too large", data: []byte{amqpFrameBody, 0, 1, 0x10, 0, 0, 0}, err: errAMQPFormat()},
+		{name: "truncated header", data: []byte{amqpFrameBody, 0, 1}, err: io.ErrUnexpectedEOF},
+		{name: "truncated payload", data: frame(amqpFrameBody, 1, "hello", amqpFrameEnd)[:10], err: io.ErrUnexpectedEOF},
+		{name: "eof", data: nil, err: io.EOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typ, channel, payload, err := readAMQPFrame(bufio.NewReader(bytes.NewReader(tt.data)))
+			if fmt.Sprint(err) != fmt.Sprint(tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err == nil && (typ != tt.typ || channel != tt.channel || string(payload) != tt.payload) {
+				t.Fatalf("frame = %d %d %q, want %d %d %q", typ, channel, payload, tt.typ, tt.channel, tt.payload)
+			}
+		})
+	}
+}
+
+func TestAMQPReadLoop(t *testing.T) {
+	var in bytes.Buffer
+	server := testAMQPConn(&in)
+	// Basic.Deliver，消息体分成两个帧，之后是一个空消息和 Basic.Qos-Ok
+	for _, body := range [][]string{{"hel", "lo"}, nil} {
+		server.send(1, amqpClassBasic, 60, func(w *amqpWriter) {
+			w.putShortstr("ctag")
+			w.putLonglong(7)
+			w.putOctet(0)
+			w.putShortstr("")
+			w.putShortstr("mail")
+		})
+		size := 0
+		for _, b := range body {
+			size += len(b)
+		}
+		var header amqpWriter
+		header.putShort(amqpClassBasic)
+		header.putShort(0)
+		header.putLonglong(uint64(size))
+		header.putShort(0)
+		server.writeFrame(amqpFrameHeader, 1, header.Bytes())
+		for _, b := range body {
+			server.writeFrame(amqpFrameBody, 1, []byte(b))
+		}
+	}
+	server.writeFrame(amqpFrameHeartbeat, 0, nil)
+	server.send(1, amqpClassBasic, 11, nil)
+	server.send(0, amqpClassConnection, 50, func(w *amqpWriter) {
+		w.putShort(320)
+		w.putShortstr("CONNECTION_FORCED - broker forced connection closure with reason 'shutdown'")
+		w.putShort(0)
+		w.putShort(0)
+	})
+	server.w.Flush()
+
+	var out bytes.Buffer
+	c := testAMQPConn(&out)
+	err := c.readLoop(bufio.NewReader(&in))
+	want := fmt.Sprintf(trErr("AMQP 服务器关闭了连接：%d %s"), 320, "CONNECTION_FORCED - broker forced connection closure with reason 'shutdown'")
+	if err == nil || err.Error() != want {
+		t.Fatalf("err = %v, want %s", err, want)
+	}
+
+	close(c.deliveries)
+	deliveries := []amqpDelivery{}
+	for d := range c.deliveries {
+		deliveries = append(deliveries, *d)
+	}
+	if wantDeliveries := []amqpDelivery{{Tag: 7, Body: []byte("hello")}, {Tag: 7}}; !reflect.DeepEqual(deliveries, wantDeliveries) {
+		t.Fatalf("deliveries = %+v, want %+v", deliveries, wantDeliveries)
+	}
+	if len(c.replies) != 1 {
+		t.Fatalf("%d replies, want 1", len(c.replies))
+	}
+	if m := <-c.replies; m.class != amqpClassBasic || m.method != 11 {
+		t.Fatalf("reply %d.%d, want 60.11", m.class, m.method)
+	}
+
+	// 收到 Connection.Close 后回复 Connection.Close-Ok
+	typ, channel, payload, err := readAMQPFrame(bufio.NewReader(&out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err := parseAMQPMethod(payload); err != nil || typ != amqpFrameMethod || channel != 0 || m.class != amqpClassConnection || m.method != 51 {
+		t.Fatalf("reply frame %d channel %d payload %x, want Connection.Close-Ok", typ, channel, payload)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	holdManifestFile = "manifest.json"
+	holdApprovalFile = "approval.json"
+	holdPreviewDir   = "preview"
+
+	// manifest 中列出的收件人数量
+	holdManifestRecipients = 20
+)
+
+// HoldManifest 记录等待审批的发送任务，digest 为配置和所有渲染后邮件的摘要，内容有变化时需要重新审批
+type HoldManifest struct {
+	Name       string     `json:"name"`
+	CampaignID string     `json:"campaign_id,omitempty"`
+	Count      int        `json:"count"`
+	Recipients []string   `json:"recipients"`
+	Digest     string     `json:"digest"`
+	StagedBy   string     `json:"staged_by"`
+	StagedAt   time.Time  `json:"staged_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	SentAt     *time.Time `json:"sent_at,omitempty"`
+}
+
+type HoldApproval struct {
+	ApprovedBy string    `json:"approved_by"`
+	ApprovedAt time.Time `json:"approved_at"`
+	Digest     string    `json:"digest"`
+}
+
+func currentUserName() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// campaignDigest 计算配置、收件人、标题和渲染后内容的摘要
+func campaignDigest(cfg *Config, list []*Send, contentProvider ContentProvider) (string, error) {
+	h := sha256.New()
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	h.Write(data)
+	for _, s := range list {
+		contentType, body, err := renderBody(s, contentProvider)
+		if err != nil {
+			return "", fmt.Errorf(trErr("第 %d 行 %s：%s"), s.Row, maskAddress(s.SendTo), redactText(err))
+		}
+		for _, v := range []string{strconv.Itoa(s.Row), s.SendTo, s.Subject, contentType} {
+			io.WriteString(h, v)
+			h.Write([]byte{0})
+		}
+		h.Write(body)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func readHoldFile(dir, name string, v interface{}) (bool, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+func writeHoldFile(dir, name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, name), data, 0644)
+}
+
+// holdForApproval 检查 --hold 目录中的审批：已审批且内容没有变化时返回 true 开始发送；
+// 否则把预览和 manifest 写入目录，等待 approve 命令审批，返回 false
+func holdForApproval(cfg *Config, dir, name string, list []*Send, contentProvider ContentProvider) (bool, error) {
+	digest, err := campaignDigest(cfg, list, contentProvider)
+	if err != nil {
+		return false, err
+	}
+
+	var manifest HoldManifest
+	found, err := readHoldFile(dir, holdManifestFile, &manifest)
+	if err != nil {
+		return false, fmt.Errorf(trErr("读取 %s 失败：%s"), holdManifestFile, err)
+	}
+	if found && manifest.Digest == digest && manifest.SentAt == nil {
+		var approval HoldApproval
+		approved, err := readHoldFile(dir, holdApprovalFile, &approval)
+		if err != nil {
+			return false, fmt.Errorf(trErr("读取 %s 失败：%s"), holdApprovalFile, err)
+		}
+		switch {
+		case time.Now().After(manifest.ExpiresAt):
+			log.Printf(tr("审批已于 %s 过期，重新生成预览"), manifest.ExpiresAt.Format("2006-01-02 15:04"))
+		case !approved:
+			log.Printf(tr("%s 还没有审批，等待其他人执行：email-sender.exe approve %s"), dir, dir)
+			return false, nil
+		case approval.Digest == digest:
+			log.Printf(tr("%s 已由 %s 于 %s 审批，开始发送"), dir, approval.ApprovedBy, approval.ApprovedAt.Format("2006-01-02 15:04"))
+			return true, nil
+		}
+	} else if found && manifest.SentAt == nil {
+		log.Printf(tr("邮件内容与之前提交审批的不同，重新生成预览，需要重新审批"))
+	}
+
+	// 重新提交审批
+	if err := os.RemoveAll(filepath.Join(dir, holdPreviewDir)); err != nil {
+		return false, err
+	}
+	if err := os.Remove(filepath.Join(dir, holdApprovalFile)); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if err := renderSendList(filepath.Join(dir, holdPreviewDir), list, contentProvider); err != nil {
+		return false, err
+	}
+	manifest = HoldManifest{
+		Name:       name,
+		CampaignID: campaignID,
+		Count:      len(list),
+		Recipients: []string{},
+		Digest:     digest,
+		StagedBy:   currentUserName(),
+		StagedAt:   time.Now(),
+		ExpiresAt:  time.Now().Add(holdExpiry),
+	}
+	for i, s := range list {
+		if i == holdManifestRecipients {
+			break
+		}
+		manifest.Recipients = append(manifest.Recipients, s.SendTo)
+	}
+	if err := writeHoldFile(dir, holdManifestFile, &manifest); err != nil {
+		return false, err
+	}
+	log.Printf(tr("已提交审批：%d 封邮件的预览在 %s，审批人执行 email-sender.exe approve %s 后，用同样的参数再运行一次开始发送；%s 前有效"),
+		len(list), filepath.Join(dir, holdPreviewDir), dir, manifest.ExpiresAt.Format("2006-01-02 15:04"))
+	return false, nil
+}
+
+// markHoldSent 记录已经按审批发送，同一个审批不能再次使用
+func markHoldSent(dir string) {
+	var manifest HoldManifest
+	if _, err := readHoldFile(dir, holdManifestFile, &manifest); err != nil {
+		log.Printf(tr("读取 %s 失败：%s"), holdManifestFile, err)
+		return
+	}
+	now := time.Now()
+	manifest.SentAt = &now
+	if err := writeHoldFile(dir, holdManifestFile, &manifest); err != nil {
+		log.Printf(tr("写入 %s 失败：%s"), holdManifestFile, err)
+	}
+}
+
+// approve 审批 --hold 目录中的发送任务，审批人不能是提交审批的人
+func approve(dir string) error {
+	var manifest HoldManifest
+	found, err := readHoldFile(dir, holdManifestFile, &manifest)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf(trErr("%s 中没有等待审批的任务"), dir)
+	}
+	if manifest.SentAt != nil {
+		return fmt.Errorf(trErr("%s 已于 %s 发送"), dir, manifest.SentAt.Format("2006-01-02 15:04"))
+	}
+	if time.Now().After(manifest.ExpiresAt) {
+		return fmt.Errorf(trErr("审批已于 %s 过期，需要重新提交"), manifest.ExpiresAt.Format("2006-01-02 15:04"))
+	}
+	name := approver
+	if len(name) == 0 {
+		name = currentUserName()
+	}
+	if len(name) == 0 {
+		return errors.New(trErr("无法确定审批人，请用 --approver 指定"))
+	}
+	if name == manifest.StagedBy {
+		return fmt.Errorf(trErr("%s 是提交审批的人，需要由其他人审批"), name)
+	}
+
+	fmt.Printf(tr("任务：%s\n"), manifest.Name)
+	if len(manifest.CampaignID) > 0 {
+		fmt.Printf(tr("任务标识：%s\n"), manifest.CampaignID)
+	}
+	fmt.Printf(tr("提交人：%s（%s）\n"), manifest.StagedBy, manifest.StagedAt.Format("2006-01-02 15:04"))
+	fmt.Printf(tr("邮件数量：%d\n"), manifest.Count)
+	fmt.Printf(tr("预览：%s\n"), filepath.Join(dir, holdPreviewDir))
+
+	return writeHoldFile(dir, holdApprovalFile, &HoldApproval{
+		ApprovedBy: name,
+		ApprovedAt: time.Now(),
+		Digest:     manifest.Digest,
+	})
+}
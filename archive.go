@@ -0,0 +1,83 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// messageArchive 把发送成功的邮件（包括最终的邮件头）保存到 --archive 目录或 zip 文件，每个任务单独一个
+type messageArchive struct {
+	mu   sync.Mutex
+	dir  string
+	file *os.File
+	zip  *zip.Writer
+}
+
+// openArchive 在 --archive 下为任务创建存档，以任务标识和开始时间命名；未指定 --archive 时返回 nil
+func openArchive(campaignID string) (*messageArchive, error) {
+	if len(archivePath) == 0 {
+		return nil, nil
+	}
+	name := time.Now().Format("20060102-150405")
+	if len(campaignID) > 0 {
+		name = unsafeFileChars.ReplaceAllString(campaignID, "_") + "-" + name
+	}
+
+	if strings.EqualFold(filepath.Ext(archivePath), ".zip") {
+		file := strings.TrimSuffix(archivePath, filepath.Ext(archivePath)) + "-" + name + ".zip"
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return nil, err
+		}
+		return &messageArchive{file: f, zip: zip.NewWriter(f)}, nil
+	}
+
+	dir := filepath.Join(archivePath, name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &messageArchive{dir: dir}, nil
+}
+
+// add 以行号和收件人命名保存一封邮件
+func (a *messageArchive) add(s *Send, data []byte) error {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	name := fmt.Sprintf("%05d-%s.eml", s.Row, unsafeFileChars.ReplaceAllString(s.SendTo, "_"))
+	if s.Seed {
+		name = "seed-" + name
+	}
+	if a.zip == nil {
+		return ioutil.WriteFile(filepath.Join(a.dir, name), data, 0600)
+	}
+	w, err := a.zip.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (a *messageArchive) Close() error {
+	if a == nil || a.zip == nil {
+		return nil
+	}
+	if err := a.zip.Close(); err != nil {
+		a.file.Close()
+		return err
+	}
+	return a.file.Close()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/gomail.v2"
+)
+
+const attachmentsColumn = "Attachments"
+
+// 发送时下载附件，同一个地址只下载一次
+var attachmentMu sync.Mutex
+
+// splitAttachments 拆分 Attachments 列，多个文件用 ; 或换行分隔
+func splitAttachments(val string) []string {
+	names := []string{}
+	for _, name := range strings.FieldsFunc(val, func(r rune) bool { return r == ';' || r == '\n' || r == '\r' }) {
+		if name = strings.TrimSpace(name); len(name) > 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// getAttachmentDecorator 附带 --attach 指定的文件和每一行 Attachments 列中的文件，
+// 可以是本地文件，也可以是 http(s)://、s3://、oss:// 地址，发送时下载
+func getAttachmentDecorator(cfg *Config) (Decorator, error) {
+	for _, name := range attachFiles {
+		if isRemoteFile(name) {
+			continue
+		}
+		if _, err := os.Stat(name); err != nil {
+			return nil, fmt.Errorf(trErr("附件 %s 不存在"), name)
+		}
+	}
+
+	return func(m *gomail.Message, send *Send) error {
+		names := append(append([]string{}, attachFiles...), splitAttachments(send.Meta[attachmentsColumn])...)
+		for _, name := range names {
+			local := name
+			if isRemoteFile(name) {
+				attachmentMu.Lock()
+				file, err := fetchRemoteFile(cfg, name)
+				attachmentMu.Unlock()
+				if err != nil {
+					return fmt.Errorf(trErr("下载附件 %s 失败：%s"), name, err)
+				}
+				local = file
+			}
+			data, err := ioutil.ReadFile(local)
+			if err != nil {
+				return fmt.Errorf(trErr("读取附件失败：%s"), err)
+			}
+			attachBytes(m, filepath.Base(local), data)
+		}
+		return nil
+	}, nil
+}
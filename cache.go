@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"io"
+	"sort"
+	"sync"
+	texttemplate "text/template"
+	"text/template/parse"
+)
+
+const maxBodyCacheSize = 64 << 20
+
+// bodyCache 保存渲染好的邮件内容，引用的字段相同的行只渲染一次；总大小超过 maxBodyCacheSize 时淘汰最久没有使用的内容
+type bodyCache struct {
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]*list.Element
+	lru     *list.List // 元素为 *cacheEntry，最近使用的在前面
+	size    int
+}
+
+type cacheEntry struct {
+	key  [sha256.Size]byte
+	body []byte
+}
+
+// cacheContent 包装 contentProvider，按模板 text 引用的字段缓存渲染结果；
+// 无法确定引用了哪些字段时按所有列缓存
+func cacheContent(contentProvider ContentProvider, text string) ContentProvider {
+	cache := &bodyCache{entries: map[[sha256.Size]byte]*list.Element{}, lru: list.New()}
+	fields, ok := cacheFields(text)
+	if ok {
+		logDebug("按模板引用的字段 %s 缓存邮件内容", fields)
+	}
+	return func(data interface{}) (string, func(w io.Writer) error) {
+		contentType, render := contentProvider(data)
+		meta, isMeta := data.(map[string]string)
+		if !isMeta {
+			return contentType, render
+		}
+		keys := fields
+		if !ok {
+			keys = make([]string, 0, len(meta))
+			for k := range meta {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+		}
+		key := metaKey(meta, keys)
+		return contentType, func(w io.Writer) error {
+			if body, ok := cache.get(key); ok {
+				_, err := w.Write(body)
+				return err
+			}
+			var buf bytes.Buffer
+			if err := render(&buf); err != nil {
+				return err
+			}
+			cache.put(key, buf.Bytes())
+			_, err := w.Write(buf.Bytes())
+			return err
+		}
+	}
+}
+
+func (c *bodyCache) get(key [sha256.Size]byte) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*cacheEntry).body, true
+}
+
+func (c *bodyCache) put(key [sha256.Size]byte, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok || len(body) > maxBodyCacheSize {
+		return
+	}
+	for c.size+len(body) > maxBodyCacheSize {
+		oldest := c.lru.Back()
+		entry := c.lru.Remove(oldest).(*cacheEntry)
+		delete(c.entries, entry.key)
+		c.size -= len(entry.body)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, body: body})
+	c.size += len(body)
+}
+
+// metaKey 返回 keys 中各列的哈希，区分没有这一列和值为空，--on-missing 下两者的渲染结果可能不同
+func metaKey(meta map[string]string, keys []string) [sha256.Size]byte {
+	h := sha256.New()
+	for _, k := range keys {
+		io.WriteString(h, k)
+		if v, ok := meta[k]; ok {
+			h.Write([]byte{1})
+			io.WriteString(h, v)
+		}
+		h.Write([]byte{0})
+	}
+	var key [sha256.Size]byte
+	copy(key[:], h.Sum(nil))
+	return key
+}
+
+// cacheFields 返回 Go 模板引用的行数据字段，按字段名排序；使用其他引擎，或者模板把整行数据（. 或 $）
+// 传给函数、变量和子模板时返回 false。与 goTemplateFields 不同，这里宁可多算也不能漏掉字段
+func cacheFields(text string) ([]string, bool) {
+	if engine != "" && engine != engineGo {
+		return nil, false
+	}
+	t, err := texttemplate.New("cache").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, false
+	}
+
+	fields := map[string]bool{}
+	ok := true
+	var walk func(node parse.Node, top bool)
+	walk = func(node parse.Node, top bool) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child, top)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe, top)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd, top)
+			}
+		case *parse.CommandNode:
+			if id, isIdent := n.Args[0].(*parse.IdentifierNode); isIdent && id.Ident == "index" && len(n.Args) == 3 && top {
+				_, isDot := n.Args[1].(*parse.DotNode)
+				if s, isString := n.Args[2].(*parse.StringNode); isDot && isString {
+					fields[s.Text] = true
+					return
+				}
+			}
+			for _, arg := range n.Args {
+				walk(arg, top)
+			}
+		case *parse.ChainNode:
+			walk(n.Node, top)
+		case *parse.DotNode:
+			if top {
+				ok = false
+			}
+		case *parse.FieldNode:
+			if top {
+				fields[n.Ident[0]] = true
+			}
+		case *parse.VariableNode:
+			// $ 始终指向行数据
+			if n.Ident[0] == "$" {
+				if len(n.Ident) > 1 {
+					fields[n.Ident[1]] = true
+				} else {
+					ok = false
+				}
+			}
+		case *parse.IfNode:
+			walk(n.Pipe, top)
+			walk(n.List, top)
+			walk(n.ElseList, top)
+		case *parse.RangeNode:
+			walk(n.Pipe, top)
+			walk(n.List, false)
+			walk(n.ElseList, top)
+		case *parse.WithNode:
+			walk(n.Pipe, top)
+			walk(n.List, false)
+			walk(n.ElseList, top)
+		case *parse.TemplateNode:
+			ok = false
+		}
+	}
+	if t.Tree != nil {
+		walk(t.Tree.Root, true)
+	}
+	if !ok {
+		return nil, false
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, true
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCacheFields(t *testing.T) {
+	tests := []struct {
+		text   string
+		fields []string
+		ok     bool
+	}{
+		{"Hello", []string{}, true},
+		{"{{ .Name }} {{ index . \"Order No\" }} {{ .Name }}", []string{"Name", "Order No"}, true},
+		{"{{ if .VIP }}{{ money .Amount .Currency }}{{ else }}{{ $.Plain }}{{ end }}", []string{"Amount", "Currency", "Plain", "VIP"}, true},
+		// range / with 中的 . 不是行数据
+		{"{{ with .Company }}{{ . }}{{ .Len }}{{ end }}", []string{"Company"}, true},
+		{"{{ $name := .Name }}{{ $name }}", []string{"Name"}, true},
+		// 整行数据传给了函数、变量或子模板时无法确定引用的字段
+		{"{{ printf \"%v\" . }}", nil, false},
+		{"{{ $row := . }}{{ index $row .Key }}", nil, false},
+		{"{{ with .Company }}{{ index $ .Field }}{{ end }}", nil, false},
+		{"{{ define \"x\" }}{{ .A }}{{ end }}{{ template \"x\" . }}", nil, false},
+		{"{{ .Name", nil, false},
+	}
+	for _, tt := range tests {
+		fields, ok := cacheFields(tt.text)
+		if ok != tt.ok || !reflect.DeepEqual(fields, tt.fields) {
+			t.Errorf("cacheFields(%q) = %q, %v, want %q, %v", tt.text, fields, ok, tt.fields, tt.ok)
+		}
+	}
+}
+
+func TestCacheContent(t *testing.T) {
+	renders := 0
+	text := "Hi {{ .Name }}"
+	provider := func(data interface{}) (string, func(writer io.Writer) error) {
+		return "text/plain", func(w io.Writer) error {
+			renders++
+			_, err := io.WriteString(w, "Hi "+data.(map[string]string)["Name"])
+			return err
+		}
+	}
+	render := func(p ContentProvider, meta map[string]string) string {
+		var buf bytes.Buffer
+		_, f := p(meta)
+		if err := f(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	// 没有引用的列不影响缓存
+	p := cacheContent(provider, text)
+	for _, meta := range []map[string]string{
+		{"Name": "A", "Email": "a@example.com"},
+		{"Name": "A", "Email": "b@example.com"},
+		{"Name": "B", "Email": "c@example.com"},
+		{"Name": "", "Email": "d@example.com"},
+		{"Email": "e@example.com"},
+	} {
+		if got, want := render(p, meta), "Hi "+meta["Name"]; got != want {
+			t.Fatalf("body = %q, want %q", got, want)
+		}
+	}
+	if renders != 4 {
+		t.Fatalf("%d renders, want 4", renders)
+	}
+
+	// 超过 maxBodyCacheSize 时淘汰最久没有使用的内容
+	renders = 0
+	p = cacheContent(func(data interface{}) (string, func(writer io.Writer) error) {
+		return "text/plain", func(w io.Writer) error {
+			renders++
+			_, err := io.WriteString(w, strings.Repeat(data.(map[string]string)["Name"], maxBodyCacheSize/2))
+			return err
+		}
+	}, text)
+	for _, name := range []string{"A", "B", "A", "C", "A", "B"} {
+		render(p, map[string]string{"Name": name})
+	}
+	// A、B、C 各渲染一次，放入 C 时淘汰 B，之后 B 重新渲染
+	if renders != 4 {
+		t.Fatalf("%d renders, want 4", renders)
+	}
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	campaignRunning   = "running"
+	campaignPaused    = "paused"
+	campaignCancelled = "cancelled"
+	campaignDone      = "done"
+	campaignFailed    = "failed"
+
+	maxRecentResults = 200
+	// 最多保留这么多个已结束的任务，queue 命令每一批都是一个任务，长时间运行时去掉最早的
+	maxFinishedCampaigns = 200
+
+	campaignIDColumn = "CampaignID"
+	campaignIDHeader = "X-Campaign-ID"
+)
+
+// Campaign 记录一次发送任务的进度，并支持暂停、取消
+type Campaign struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	// changed 在暂停、继续、取消时关闭并换成新的，等待中的 sleep 立即检查状态
+	changed chan struct{}
+
+	ID         string
+	Name       string
+	CampaignID string
+	Status     string
+	Error      string
+	Total      int
+	Started    time.Time
+	Finished   time.Time
+	Failures   []Result
+	Current    string
+	Recent     []Result
+
+	reporter *Reporter
+	failed   []*Send
+	done     map[*Send]bool // 已经发送（成功或失败）或跳过的收件人
+	retry    func(from *Campaign, list []*Send)
+	// retrying 为重试失败收件人的任务：不再插入监控邮件，也不再按预热的发送量截取或重复计入
+	retrying bool
+	// interval 为通过监控页面或 campaign rate 调整的发送间隔，发送下一封邮件前生效
+	interval    time.Duration
+	intervalSet bool
+}
+
+var campaigns = struct {
+	sync.Mutex
+	list []*Campaign
+	next int
+}{}
+
+func newCampaign(name string, list []*Send, reporter *Reporter) *Campaign {
+	c := &Campaign{
+		Name:       name,
+		CampaignID: campaignID,
+		Status:     campaignRunning,
+		Total:      len(list),
+		Started:    time.Now(),
+		reporter:   reporter,
+		done:       map[*Send]bool{},
+		changed:    make(chan struct{}),
+	}
+	c.cond = sync.NewCond(&c.mu)
+
+	campaigns.Lock()
+	campaigns.next++
+	c.ID = strconv.Itoa(campaigns.next)
+	campaigns.list = append(pruneCampaigns(campaigns.list), c)
+	campaigns.Unlock()
+
+	return c
+}
+
+// pruneCampaigns 已结束的任务超过 maxFinishedCampaigns 个时去掉最早结束的，调用时已经持有 campaigns 的锁
+func pruneCampaigns(list []*Campaign) []*Campaign {
+	finished := 0
+	for _, c := range list {
+		if c.finished() {
+			finished++
+		}
+	}
+	if finished < maxFinishedCampaigns {
+		return list
+	}
+	kept := []*Campaign{}
+	for _, c := range list {
+		if finished >= maxFinishedCampaigns && c.finished() {
+			finished--
+			continue
+		}
+		kept = append(kept, c)
+	}
+	return kept
+}
+
+func (c *Campaign) finished() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.Finished.IsZero()
+}
+
+func findCampaign(id string) *Campaign {
+	campaigns.Lock()
+	defer campaigns.Unlock()
+	for _, c := range campaigns.list {
+		if c.ID == id {
+			return c
+		}
+	}
+	return nil
+}
+
+func allCampaigns() []*Campaign {
+	campaigns.Lock()
+	defer campaigns.Unlock()
+	return append([]*Campaign{}, campaigns.list...)
+}
+
+// cancelAllCampaigns 取消所有未结束的任务，正在发送的邮件会发送完
+func cancelAllCampaigns() {
+	for _, c := range allCampaigns() {
+		c.Cancel()
+	}
+}
+
+// pauseAllCampaigns 暂停所有正在发送的任务，正在发送的邮件会发送完
+func pauseAllCampaigns() {
+	for _, c := range allCampaigns() {
+		c.Pause()
+	}
+}
+
+func resumeAllCampaigns() {
+	for _, c := range allCampaigns() {
+		c.Resume()
+	}
+}
+
+func (c *Campaign) Add(s *Send, err error) {
+	c.mu.Lock()
+	result := c.reporter.Add(c.CampaignID, s, err)
+	c.Current = ""
+	c.done[s] = true
+	c.Recent = append(c.Recent, result)
+	if len(c.Recent) > maxRecentResults {
+		c.Recent = c.Recent[len(c.Recent)-maxRecentResults:]
+	}
+	if err != nil {
+		c.Failures = append(c.Failures, result)
+		c.failed = append(c.failed, s)
+	}
+	c.mu.Unlock()
+
+	// 预热进度、发送记录和 Redis 各自加锁，写入较慢时不影响监控页面读取任务状态
+	if warmup != nil {
+		if err := warmup.record(s, err, !c.retrying); err != nil {
+			c.logf("保存预热进度失败：%s", err)
+		}
+	}
+	if err := history.record(result); err != nil {
+		c.logf("保存发送记录失败：%s", err)
+	}
+	if err := distributed.record(result); err != nil {
+		c.logf("保存分布式发送结果失败：%s", err)
+	}
+}
+
+// Skip 记录发送过程中跳过的收件人，不计入总数
+func (c *Campaign) Skip(s *Send, status, reason string) {
+	c.mu.Lock()
+	result := c.reporter.Skip(c.CampaignID, s, status, reason)
+	c.Total--
+	c.Current = ""
+	c.done[s] = true
+	c.Recent = append(c.Recent, result)
+	if len(c.Recent) > maxRecentResults {
+		c.Recent = c.Recent[len(c.Recent)-maxRecentResults:]
+	}
+	c.mu.Unlock()
+
+	if err := distributed.record(result); err != nil {
+		c.logf("保存分布式发送结果失败：%s", err)
+	}
+}
+
+// outcome 返回收件人的处理结果：done 为已经发送或跳过，发送失败时 failure 为失败的原因
+func (c *Campaign) outcome(s *Send) (done bool, failure string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, f := range c.failed {
+		if f == s {
+			return true, c.Failures[i].Error
+		}
+	}
+	return c.done[s], ""
+}
+
+// handOff 去掉分布式发送时由其他机器发送的收件人，不计入总数也不写入报告
+func (c *Campaign) handOff() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Total--
+}
+
+// skipRecipients 去掉不需要发送的收件人：之前已经发送成功的、开启 --skip-disposable 时的一次性邮箱，
+// 以及开启 --dedupe 时重复的收件人，跳过的收件人在报告中记录原因
+func (c *Campaign) skipRecipients(list []*Send) []*Send {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	remaining := []*Send{}
+	alreadySent, disposable, duplicate, unsubscribed := 0, 0, 0, 0
+	seen := map[string]bool{}
+	for _, s := range list {
+		key := dedupeKey(s.SendTo)
+		switch {
+		case dedupe && seen[key]:
+			c.reporter.Skip(c.CampaignID, s, statusDuplicate, trErr("重复的收件人"))
+			duplicate++
+		case sentBefore(c.CampaignID, s.SendTo, c.reporter.salt):
+			c.reporter.Skip(c.CampaignID, s, statusAlreadySent, trErr("之前已发送"))
+			alreadySent++
+		case isUnsubscribed(s.SendTo):
+			c.reporter.Skip(c.CampaignID, s, statusUnsubscribed, trErr("已退订"))
+			unsubscribed++
+		case skipDisposable && isDisposableAddress(s.SendTo):
+			c.reporter.Skip(c.CampaignID, s, statusDisposable, trErr("一次性邮箱"))
+			disposable++
+		default:
+			seen[key] = true
+			remaining = append(remaining, s)
+			continue
+		}
+		c.done[s] = true
+	}
+	if warmup != nil && !c.retrying {
+		var day, limit, sentToday int
+		remaining, day, limit, sentToday = warmup.take(remaining)
+		c.logf("%s 预热第 %d 天，今天最多发送 %d 封，已发送 %d 封，本次发送 %d 封",
+			c.Name, day, limit, sentToday, len(remaining))
+	}
+	c.Total = len(remaining)
+	if alreadySent > 0 {
+		c.logf("%s 跳过 %d 个之前已发送的收件人", c.Name, alreadySent)
+	}
+	if disposable > 0 {
+		c.logf("%s 跳过 %d 个一次性邮箱", c.Name, disposable)
+	}
+	if duplicate > 0 {
+		c.logf("%s 跳过 %d 个重复的收件人", c.Name, duplicate)
+	}
+	if unsubscribed > 0 {
+		c.logf("%s 跳过 %d 个已退订的收件人", c.Name, unsubscribed)
+	}
+	return remaining
+}
+
+func (c *Campaign) waiting(s *Send) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Current = fmt.Sprintf(tr("%s (等待到 %s)"), s.SendTo, s.SendAt.Format("2006-01-02 15:04 -07:00"))
+}
+
+func (c *Campaign) sending(s *Send) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Current = s.SendTo
+}
+
+// proceed 在发送下一封邮件前调用，暂停时阻塞，已取消时返回 false
+func (c *Campaign) proceed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.Status == campaignPaused {
+		c.cond.Wait()
+	}
+	return c.Status == campaignRunning
+}
+
+// sleep 等待 d，期间任务被取消时立即返回 false
+func (c *Campaign) sleep(d time.Duration) bool {
+	return c.wait(d, false)
+}
+
+// pause 为两封邮件之间的发送间隔，期间任务被暂停时立即返回 true，由 proceed 等待继续，被取消时返回 false
+func (c *Campaign) pause(d time.Duration) bool {
+	return c.wait(d, true)
+}
+
+func (c *Campaign) wait(d time.Duration, untilPaused bool) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	for {
+		c.mu.Lock()
+		status, changed := c.Status, c.changed
+		c.mu.Unlock()
+		if status == campaignCancelled {
+			return false
+		}
+		if untilPaused && status == campaignPaused {
+			return true
+		}
+		select {
+		case <-timer.C:
+			return true
+		case <-changed:
+		}
+	}
+}
+
+// notify 唤醒等待中的 proceed 和 sleep，调用时需要持有 mu
+func (c *Campaign) notify() {
+	c.cond.Broadcast()
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *Campaign) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Status == campaignRunning {
+		c.Status = campaignPaused
+		c.notify()
+	}
+}
+
+func (c *Campaign) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Status == campaignPaused {
+		c.Status = campaignRunning
+		c.notify()
+	}
+}
+
+func (c *Campaign) Cancel() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Status == campaignRunning || c.Status == campaignPaused {
+		c.Status = campaignCancelled
+		c.notify()
+	}
+}
+
+// SetInterval 调整正在发送的任务的发送间隔，自动降速增加的间隔同时恢复
+func (c *Campaign) SetInterval(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interval, c.intervalSet = d, true
+}
+
+// takeInterval 返回调整过的发送间隔，每次调整只返回一次
+func (c *Campaign) takeInterval() (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d, ok := c.interval, c.intervalSet
+	c.intervalSet = false
+	return d, ok
+}
+
+// Retry 用失败的收件人重新发起一次发送，只有结束的任务可以重试；发送失败的监控邮件不重试
+func (c *Campaign) Retry() bool {
+	c.mu.Lock()
+	failed := []*Send{}
+	for _, s := range c.failed {
+		if !s.Seed {
+			failed = append(failed, s)
+		}
+	}
+	ok := c.retry != nil && len(failed) > 0 && c.Finished.After(c.Started)
+	c.mu.Unlock()
+
+	if ok {
+		go c.retry(c, failed)
+	}
+	return ok
+}
+
+// retryFailures 返回重新发送失败收件人的函数，重试本身也是一个新的任务，结果追加到原来任务的报告中
+func retryFailures(cfg *Config, contentProvider ContentProvider, decorators []Decorator) func(from *Campaign, list []*Send) {
+	var retry func(from *Campaign, list []*Send)
+	retry = func(from *Campaign, list []*Send) {
+		reporter, err := from.reporter.reopen()
+		if err != nil {
+			from.logf("%s 重试失败：%s", from.Name, err)
+			return
+		}
+		defer reporter.Close()
+		c := newCampaign(from.Name+tr(" (重试)"), list, reporter)
+		c.CampaignID = from.CampaignID
+		c.retry = retry
+		c.retrying = true
+		if err := sendEmails(cfg, list, contentProvider, decorators, c); err != nil {
+			c.logf("%s 重试失败：%s", from.Name, err)
+		}
+	}
+	return retry
+}
+
+// logf 输出带 campaign ID 的日志；与 --campaign-id 相同时日志前缀中已经有了
+func (c *Campaign) logf(format string, v ...interface{}) {
+	format = tr(format)
+	if len(c.CampaignID) > 0 && c.CampaignID != campaignID {
+		format = "[" + c.CampaignID + "] " + format
+	}
+	log.Printf(format, v...)
+}
+
+// tagSendList 让模板可以通过 {{ .CampaignID }} 使用 campaign ID，例如用在跟踪链接中
+func tagSendList(list []*Send, id string) {
+	if len(id) == 0 {
+		return
+	}
+	for _, s := range list {
+		if s.Meta == nil {
+			s.Meta = map[string]string{}
+		}
+		s.Meta[campaignIDColumn] = id
+	}
+}
+
+func (c *Campaign) finish(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Finished = time.Now()
+	if err != nil {
+		c.Status = campaignFailed
+		c.Error = err.Error()
+	} else if c.Status != campaignCancelled {
+		c.Status = campaignDone
+	}
+}
+
+type CampaignStatus struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	CampaignID string     `json:"campaign_id,omitempty"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	Total      int        `json:"total"`
+	Sent       int        `json:"sent"`
+	Failed     int        `json:"failed"`
+	Started    time.Time  `json:"started"`
+	Finished   *time.Time `json:"finished,omitempty"`
+	Current    string     `json:"current,omitempty"`
+	Failures   []Result   `json:"failures"`
+	Recent     []Result   `json:"-"`
+}
+
+func (c *Campaign) Snapshot() CampaignStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var finished *time.Time
+	if !c.Finished.IsZero() {
+		t := c.Finished
+		finished = &t
+	}
+	return CampaignStatus{
+		ID:         c.ID,
+		Name:       c.Name,
+		CampaignID: c.CampaignID,
+		Status:     c.Status,
+		Error:      c.Error,
+		Total:      c.Total,
+		Sent:       c.reporter.Sent,
+		Failed:     c.reporter.Failed,
+		Started:    c.Started,
+		Finished:   finished,
+		Current:    c.Current,
+		Failures:   append([]Result{}, c.Failures...),
+		Recent:     append([]Result{}, c.Recent...),
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ImageUploadConfig 配置把模板中引用的本地图片上传到对象存储，并把地址替换为 base_url 下的公开地址
+type ImageUploadConfig struct {
+	Target  string `json:"target"`
+	BaseURL string `json:"base_url"`
+	ACL     string `json:"acl"`
+}
+
+var imageSrc = regexp.MustCompile(`(?i)(<img\b[^>]*?\bsrc\s*=\s*)("[^"]*"|'[^']*')`)
+
+var uploadedImages = struct {
+	sync.Mutex
+	m map[string]string
+}{m: map[string]string{}}
+
+// uploadImages 上传 data 中 <img src> 引用的本地图片，相对路径相对于 dir，返回替换地址后的内容；
+// 地址中有模板语法的图片保持不变
+func uploadImages(cfg *Config, dir string, data []byte) ([]byte, error) {
+	if cfg == nil || cfg.ImageUpload == nil {
+		return data, nil
+	}
+	var uploadErr error
+	result := imageSrc.ReplaceAllStringFunc(string(data), func(match string) string {
+		parts := imageSrc.FindStringSubmatch(match)
+		quote, src := parts[2][:1], parts[2][1:len(parts[2])-1]
+		if uploadErr != nil || !isLocalImage(src) {
+			return match
+		}
+		file := src
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, filepath.FromSlash(src))
+		}
+		u, err := uploadImage(cfg, file)
+		if err != nil {
+			uploadErr = fmt.Errorf(trErr("上传图片 %s 失败：%s"), src, err)
+			return match
+		}
+		return parts[1] + quote + u + quote
+	})
+	if uploadErr != nil {
+		return nil, uploadErr
+	}
+	return []byte(result), nil
+}
+
+func isLocalImage(src string) bool {
+	src = strings.TrimSpace(src)
+	if len(src) == 0 || strings.Contains(src, "{{") || strings.Contains(src, "{%") || strings.HasPrefix(src, "//") {
+		return false
+	}
+	u, err := url.Parse(src)
+	return err == nil && len(u.Scheme) == 0
+}
+
+// uploadImage 以内容的 SHA-256 命名上传，同一个文件只上传一次，返回公开地址
+func uploadImage(cfg *Config, file string) (string, error) {
+	uploadedImages.Lock()
+	defer uploadedImages.Unlock()
+	if u, ok := uploadedImages.m[file]; ok {
+		return u, nil
+	}
+
+	target, err := url.Parse(cfg.ImageUpload.Target)
+	if err != nil {
+		return "", err
+	}
+	bucket, prefix := target.Host, strings.Trim(target.Path, "/")
+	if (target.Scheme != "s3" && target.Scheme != "oss") || len(bucket) == 0 {
+		return "", fmt.Errorf(trErr("无效的上传地址 %s，格式为 s3://bucket/prefix 或 oss://bucket/prefix"), cfg.ImageUpload.Target)
+	}
+
+	data, err := readFileContent(file)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:8]) + "-" + filepath.Base(file)
+	key := path.Join(prefix, name)
+
+	contentType := mime.TypeByExtension(filepath.Ext(file))
+	if len(contentType) == 0 {
+		contentType = http.DetectContentType(data)
+	}
+	header := map[string]string{"Content-Type": contentType}
+
+	var req *http.Request
+	var storage ObjectStorageConfig
+	if target.Scheme == "s3" {
+		storage = s3Config(cfg.S3)
+		if len(cfg.ImageUpload.ACL) > 0 {
+			header["x-amz-acl"] = cfg.ImageUpload.ACL
+		}
+		req, err = newS3Request(storage, "PUT", bucket, key, data, header)
+	} else {
+		storage = ossConfig(cfg.OSS)
+		if len(cfg.ImageUpload.ACL) > 0 {
+			header["x-oss-object-acl"] = cfg.ImageUpload.ACL
+		}
+		req, err = newOSSRequest(storage, "PUT", bucket, key, data, header)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	logDebug("上传 %s: %s", file, req.URL)
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	u := objectURL(storage, bucket, key).String()
+	if len(cfg.ImageUpload.BaseURL) > 0 {
+		u = strings.TrimSuffix(cfg.ImageUpload.BaseURL, "/") + "/" + uriEncodePath(name)
+	}
+	log.Printf(tr("已上传图片 %s: %s"), file, u)
+	uploadedImages.m[file] = u
+	return u, nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// cliCommand 描述一个子命令的用法、说明和可以使用的选项，选项都注册在 flag.CommandLine 中，
+// 解析时只把这个命令用得到的选项放到单独的 FlagSet 里
+type cliCommand struct {
+	usage   string
+	summary string
+	flags   [][]string
+}
+
+var (
+	commonFlags = []string{"config", "debug", "redact", "lang", "error-lang", "workdir", "help"}
+
+	contentFlags = []string{
+		"content", "template", "subject", "force-subject", "subject-prefix", "subject-suffix", "content-type", "header", "no-header", "engine",
+		"pdf-template", "pdf-name", "attach", "vcard", "qrcode", "qrcode-size", "lang-pattern",
+		"transform", "on-missing", "sanitize", "campaign-id", "thread-report",
+	}
+
+	selectFlags = []string{
+		"skip-already-sent", "skip-disposable", "disposable-domains", "dedupe", "dedupe-gmail",
+		"skip-bad-rows", "rejected-out", "warmup", "send-local-time",
+	}
+
+	checkFlags = []string{"check-html", "estimate-size"}
+
+	outputFlags = []string{"report", "spool", "archive", "metrics-out"}
+
+	watchFlags = []string{"watch-interval", "http", "http-token"}
+)
+
+var cliCommands = map[string]*cliCommand{
+	"send": {
+		usage:   "send [选项] <数据文件>",
+		summary: "发送邮件，默认命令",
+		flags:   [][]string{commonFlags, contentFlags, selectFlags, checkFlags, outputFlags, {"hold", "hold-expiry", "http", "http-token", "tui", "render-out"}},
+	},
+	"validate": {
+		usage:   "validate [选项] <数据文件>",
+		summary: "只检查数据不发送邮件：校验所有收件人地址、必需的列，并用模板渲染每一行，按行列出所有问题",
+		flags:   [][]string{commonFlags, contentFlags, checkFlags, {"skip-bad-rows", "rejected-out"}},
+	},
+	"preview": {
+		usage:   "preview [选项] <数据文件>",
+		summary: "渲染邮件但不发送：指定 --render-out 时把每个收件人的邮件写入目录，否则输出 --row 指定的一行（默认第一行）完整的邮件",
+		flags:   [][]string{commonFlags, contentFlags, {"render-out", "row", "skip-bad-rows"}},
+	},
+	"report": {
+		usage:   "report [选项] <报告文件>...",
+		summary: "汇总 --report 生成的报告：各状态的数量、失败的 SMTP 响应码和失败的收件人，同一个收件人以最后一次结果为准",
+		flags:   [][]string{commonFlags},
+	},
+	"serve": {
+		usage:   "serve [选项] <目录>",
+		summary: "监视目录并自动发送，同时在 --http 指定的地址（默认 localhost:8080）提供监控页面",
+		flags:   [][]string{commonFlags, contentFlags, selectFlags, checkFlags, outputFlags, watchFlags},
+	},
+	"config": {
+		usage:   "config [show | check] [选项]",
+		summary: "show 输出合并 extends、替换环境变量之后的配置，密码等敏感字段以 **** 显示；check 只检查配置是否有效",
+		flags:   [][]string{commonFlags},
+	},
+	"setup": {
+		usage:   "setup [--config config.json]",
+		summary: "交互式地选择邮件服务商、输入账号和发件人，测试连接后写入配置文件",
+		flags:   [][]string{commonFlags},
+	},
+	"watch": {
+		usage:   "watch [选项] <目录>",
+		summary: "监视目录，出现新的数据文件后检查并发送",
+		flags:   [][]string{commonFlags, contentFlags, selectFlags, checkFlags, outputFlags, watchFlags},
+	},
+	"service": {
+		usage:   "service install | uninstall | run [选项] <目录>",
+		summary: "以系统服务方式运行 watch 命令",
+		flags:   [][]string{commonFlags, contentFlags, selectFlags, checkFlags, outputFlags, watchFlags},
+	},
+	"run": {
+		usage:   "run [选项] <任务文件>",
+		summary: "按任务文件执行多个发送任务",
+		flags:   [][]string{commonFlags, contentFlags, selectFlags, checkFlags, outputFlags, {"http", "http-token"}},
+	},
+	"replay": {
+		usage:   "replay [选项] <目录>",
+		summary: "重新发送 --spool 目录中保存的发送失败的邮件",
+		flags:   [][]string{commonFlags, {"report"}},
+	},
+	"import": {
+		usage:   "import [选项] <mbox 文件 | .eml 目录>",
+		summary: "把 mbox 文件或目录中的 .eml 邮件原样重新发给邮件头中的收件人",
+		flags:   [][]string{commonFlags, {"report", "spool", "campaign-id"}},
+	},
+	"resend-failures": {
+		usage:   "resend-failures [选项] <报告文件> <数据文件>",
+		summary: "只重新发送之前报告中失败的收件人",
+		flags:   [][]string{commonFlags, contentFlags, selectFlags, checkFlags, outputFlags, {"http", "http-token", "tui"}},
+	},
+	"follow-up": {
+		usage:   "follow-up [选项] <原来的报告文件> <数据文件>",
+		summary: "用另一个模板跟进原来的任务中发送成功、超过 --after-days 天且没有打开过邮件的收件人",
+		flags:   [][]string{commonFlags, contentFlags, selectFlags, checkFlags, outputFlags, {"http", "http-token", "tui", "engaged", "after-days"}},
+	},
+	"history": {
+		usage:   "history [lookup <邮件地址>] [选项]",
+		summary: "查询 history 发送记录数据库中所有任务的发送记录，可以按时间、状态和 --campaign-id 筛选；lookup 列出发给一个收件人的所有邮件",
+		flags:   [][]string{commonFlags, {"history", "since", "status", "campaign-id"}},
+	},
+	"campaign": {
+		usage:   "campaign [list | pause | resume | cancel | retry | rate] [选项] [<任务编号>]",
+		summary: "控制 serve 或 --http 中正在发送的任务：列出任务、暂停、继续、取消、重发失败的邮件或用 --interval 调整发送间隔",
+		flags:   [][]string{commonFlags, {"server", "http-token", "interval"}},
+	},
+	"queue": {
+		usage:   "queue [选项]",
+		summary: "作为发送服务运行：不停地从配置文件中 queue 指定的消息队列读取发送请求并发送，临时失败的请求稍后重试，永久失败的放到 dead_letter",
+		flags:   [][]string{commonFlags, contentFlags, selectFlags, checkFlags, outputFlags, {"http", "http-token"}},
+	},
+	"doctor": {
+		usage:   "doctor [选项]",
+		summary: "检查发件人域名的 SPF、DKIM 和 DMARC 记录",
+		flags:   [][]string{commonFlags, {"dkim-selector"}},
+	},
+	"test-send": {
+		usage:   "test-send [选项] [<数据文件>]",
+		summary: "用一行数据渲染邮件，只发送一封到 --to 指定的测试地址",
+		flags:   [][]string{commonFlags, contentFlags, {"to", "row", "fixture"}},
+	},
+	"approve": {
+		usage:   "approve [选项] <目录>",
+		summary: "审批 --hold 提交的发送任务",
+		flags:   [][]string{commonFlags, {"approver"}},
+	},
+	"lint": {
+		usage:   "lint [选项] <数据文件>",
+		summary: "检查模板中引用的字段与数据文件的列是否一致",
+		flags:   [][]string{commonFlags, contentFlags},
+	},
+}
+
+// commandFlagSet 返回只包含命令可用选项的 FlagSet，-h / --help 或选项错误时输出这个命令的帮助
+func commandFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	for _, group := range cliCommands[name].flags {
+		for _, flagName := range group {
+			if f := flag.CommandLine.Lookup(flagName); f != nil && fs.Lookup(flagName) == nil {
+				fs.Var(f.Value, f.Name, f.Usage)
+			}
+		}
+	}
+	fs.Usage = func() {
+		commandUsage(name, fs)
+	}
+	return fs
+}
+
+func commandUsage(name string, fs *flag.FlagSet) {
+	c := cliCommands[name]
+	out := fs.Output()
+	fmt.Fprintf(out, tr("用法：email-sender.exe %s\n\n"), tr(c.usage))
+	fmt.Fprintf(out, "%s\n\n", tr(c.summary))
+	fmt.Fprintln(out, tr("选项："))
+	fs.VisitAll(func(f *flag.Flag) {
+		line := "  --" + f.Name
+		if _, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok {
+			line += " " + tr("值")
+		}
+		usage := tr(f.Usage)
+		if len(f.DefValue) > 0 && f.DefValue != "false" && f.DefValue != "0" && f.DefValue != "[]" {
+			usage += fmt.Sprintf(tr("（默认 %s）"), f.DefValue)
+		}
+		fmt.Fprintf(out, "%s\n      %s\n", line, usage)
+	})
+	fmt.Fprintf(out, "\n%s\n", tr("email-sender.exe --help 查看完整的说明"))
+}
+
+// commandList 列出所有命令，用于 help 命令
+func commandList() string {
+	names := []string{}
+	for name := range cliCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "  %-16s %s\n", name, tr(cliCommands[name].summary))
+	}
+	return b.String()
+}
+
+// helpCommand 处理 email-sender.exe help [命令]
+func helpCommand(args []string) {
+	if len(args) > 0 {
+		if _, ok := cliCommands[args[0]]; ok {
+			fs := commandFlagSet(args[0])
+			fs.SetOutput(os.Stdout)
+			fs.Usage()
+			return
+		}
+	}
+	fmt.Printf(tr("用法：email-sender.exe <命令> [选项] <参数>\n\n命令：\n%s\nemail-sender.exe help <命令> 查看命令的选项，email-sender.exe --help 查看完整的说明\n"), commandList())
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// secretConfigKeys 为 config show 中隐藏的字段，在任何层级出现都会隐藏
+var secretConfigKeys = map[string]bool{
+	"password":          true,
+	"secret":            true,
+	"access_key_secret": true,
+	"security_token":    true,
+	"report_salt":       true,
+}
+
+// showConfig 输出合并 extends、替换环境变量之后的配置
+func showConfig(cfg *Config, w io.Writer) error {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	var values interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(maskSecrets(values))
+}
+
+func maskSecrets(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, value := range v {
+			if s, ok := value.(string); ok && secretConfigKeys[k] && len(s) > 0 {
+				v[k] = "****"
+			} else {
+				v[k] = maskSecrets(value)
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = maskSecrets(value)
+		}
+	}
+	return v
+}
+
+// checkConfig 检查 loadConfig 之外需要在发送时才会用到的配置，不连接服务器
+func checkConfig(cfg *Config) error {
+	if cfg.Sender != "fake" && len(cfg.Host) == 0 {
+		return fmt.Errorf(trErr("没有指定 host"))
+	}
+	if _, err := getFromSelector(cfg); err != nil {
+		return err
+	}
+	if _, err := getDecorators(cfg); err != nil {
+		return err
+	}
+	return nil
+}
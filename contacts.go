@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"mime/quotedprintable"
+	"strings"
+)
+
+// vCard 联系人转换为的列，Subject 为空，由 --subject 渲染
+var contactColumns = []string{"SendTo", "Subject", "Name", "FirstName", "LastName", "Org", "Title", "Phone", "Note"}
+
+type vcardProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// readVCFRows 把 .vcf 文件中的联系人转换为带表头的行：FN 为 Name，EMAIL 为 SendTo，ORG 为 Org；
+// 没有邮箱的联系人会被忽略，有多个邮箱时使用首选的一个
+func readVCFRows(file string) ([][]string, error) {
+	if len(subject) == 0 {
+		return nil, errors.New(trErr("vCard 中没有邮件标题，请用 --subject 指定"))
+	}
+	data, err := readFileContent(file)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := [][]string{contactColumns}
+	var card []vcardProperty
+	for _, line := range unfoldVCard(data) {
+		p, ok := parseVCardLine(line)
+		if !ok {
+			continue
+		}
+		switch p.name {
+		case "BEGIN":
+			card = []vcardProperty{}
+		case "END":
+			if row := contactRow(card); row != nil {
+				rows = append(rows, row)
+			}
+			card = nil
+		default:
+			if card != nil {
+				card = append(card, p)
+			}
+		}
+	}
+	return rows, nil
+}
+
+func contactRow(card []vcardProperty) []string {
+	fields := map[string]string{}
+	emailPref := false
+	for _, p := range card {
+		switch p.name {
+		case "EMAIL":
+			pref := strings.Contains(strings.ToUpper(p.params["TYPE"]), "PREF") || p.params["PREF"] == "1"
+			if _, ok := p.params["PREF"]; ok && len(p.params["PREF"]) == 0 {
+				// vCard 2.1 中 PREF 不带值
+				pref = true
+			}
+			if len(fields["SendTo"]) == 0 || pref && !emailPref {
+				fields["SendTo"] = p.value
+				emailPref = pref
+			}
+		case "FN":
+			fields["Name"] = p.value
+		case "N":
+			parts := splitVCardValue(p.value)
+			fields["LastName"] = parts[0]
+			if len(parts) > 1 {
+				fields["FirstName"] = parts[1]
+			}
+		case "ORG":
+			fields["Org"] = splitVCardValue(p.value)[0]
+		case "TITLE":
+			fields["Title"] = unescapeVCard(p.value)
+		case "NOTE":
+			fields["Note"] = unescapeVCard(p.value)
+		case "TEL":
+			if len(fields["Phone"]) == 0 {
+				fields["Phone"] = p.value
+			}
+		}
+	}
+	if len(fields["SendTo"]) == 0 {
+		logDebug("忽略没有邮箱的联系人 %s", fields["Name"])
+		return nil
+	}
+	if len(fields["Name"]) == 0 {
+		// 中文姓名姓在前
+		if isASCII(fields["FirstName"] + fields["LastName"]) {
+			fields["Name"] = strings.TrimSpace(fields["FirstName"] + " " + fields["LastName"])
+		} else {
+			fields["Name"] = fields["LastName"] + fields["FirstName"]
+		}
+	}
+	fields["Name"] = unescapeVCard(fields["Name"])
+
+	row := make([]string, len(contactColumns))
+	for i, column := range contactColumns {
+		row[i] = fields[column]
+	}
+	return row
+}
+
+// unfoldVCard 合并以空格或制表符开头的续行，以及 quoted-printable 以 = 结尾的软换行
+func unfoldVCard(data []byte) []string {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	lines := []string{}
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), "\r")
+		n := len(lines)
+		switch {
+		case n > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")):
+			lines[n-1] += line[1:]
+		case n > 0 && strings.HasSuffix(lines[n-1], "=") && strings.Contains(strings.ToUpper(lines[n-1]), "QUOTED-PRINTABLE"):
+			lines[n-1] += "\n" + line
+		default:
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// parseVCardLine 解析 [group.]NAME;PARAM=VALUE:VALUE 形式的一行
+func parseVCardLine(line string) (vcardProperty, bool) {
+	i := strings.Index(line, ":")
+	if i < 0 {
+		return vcardProperty{}, false
+	}
+	head, value := line[:i], line[i+1:]
+	parts := strings.Split(head, ";")
+	name := strings.ToUpper(parts[0])
+	if j := strings.LastIndex(name, "."); j >= 0 {
+		name = name[j+1:]
+	}
+
+	params := map[string]string{}
+	for _, param := range parts[1:] {
+		k, v := param, ""
+		if j := strings.Index(param, "="); j >= 0 {
+			k, v = param[:j], strings.Trim(param[j+1:], `"`)
+		} else if t := strings.ToUpper(param); t != "PREF" {
+			// vCard 2.1 的参数可以省略 TYPE=
+			k, v = "TYPE", param
+		}
+		k = strings.ToUpper(k)
+		if len(params[k]) > 0 {
+			v = params[k] + "," + v
+		}
+		params[k] = v
+	}
+
+	if strings.EqualFold(params["ENCODING"], "QUOTED-PRINTABLE") {
+		value = strings.ReplaceAll(value, "=\n", "")
+		if decoded, err := ioutil.ReadAll(quotedprintable.NewReader(strings.NewReader(value))); err == nil {
+			value = string(decoded)
+		}
+	}
+	return vcardProperty{name: name, params: params, value: strings.TrimSpace(value)}, true
+}
+
+// splitVCardValue 按未转义的 ; 拆分结构化的值
+func splitVCardValue(value string) []string {
+	parts := []string{}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && i+1 < len(value):
+			b.WriteByte(value[i])
+			b.WriteByte(value[i+1])
+			i++
+		case value[i] == ';':
+			parts = append(parts, unescapeVCard(b.String()))
+			b.Reset()
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return append(parts, unescapeVCard(b.String()))
+}
+
+func unescapeVCard(value string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const contentTypeColumn = "ContentType"
+
+func parseContentType(val string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(val)) {
+	case "text/plain", "plain", "text":
+		return "text/plain", nil
+	case "text/html", "html":
+		return "text/html", nil
+	default:
+		return "", fmt.Errorf(trErr("无效的内容类型 %s，只支持 text/plain 或 text/html"), val)
+	}
+}
+
+// rowContentType 优先使用该行 ContentType 列指定的类型
+func rowContentType(s *Send, detected string) string {
+	if ct := s.Meta[contentTypeColumn]; len(ct) > 0 {
+		return ct
+	}
+	return detected
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseInterval 解析 rate 的发送间隔：2s、500ms 这样的时长，或者与配置中的 interval 相同的毫秒数
+func parseInterval(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil && ms >= 0 {
+		return time.Millisecond * time.Duration(ms), nil
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return d, nil
+	}
+	return 0, fmt.Errorf(trErr("无效的发送间隔 %s，例如 2s、500ms 或毫秒数"), s)
+}
+
+var controlClient = &http.Client{Timeout: 10 * time.Second}
+
+// controlCampaign 调用 serve / --http 的 API：action 为空时列出所有任务，否则对任务 id 执行操作；
+// token 为 serve 的 --http-token，为空时不发送
+func controlCampaign(server, token, action, id string, interval string, w io.Writer) error {
+	server = strings.TrimRight(server, "/")
+	if !strings.Contains(server, "://") {
+		server = "http://" + server
+	}
+
+	var req *http.Request
+	var err error
+	if len(action) == 0 {
+		req, err = http.NewRequest(http.MethodGet, server+"/api/campaigns", nil)
+	} else {
+		form := url.Values{}
+		if action == "rate" {
+			form.Set("interval", interval)
+		}
+		req, err = http.NewRequest(http.MethodPost, server+"/api/campaigns/"+url.PathEscape(id)+"/"+action, strings.NewReader(form.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := controlClient.Do(req)
+	if err != nil {
+		return fmt.Errorf(trErr("连接 %s 失败：%s"), server, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return errors.New(trErr("访问令牌错误，请用 --http-token 或环境变量 EMAIL_SENDER_HTTP_TOKEN 指定 serve 使用的令牌"))
+	}
+	if resp.StatusCode == http.StatusNotFound && len(action) > 0 {
+		return fmt.Errorf(trErr("没有任务 %s"), id)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	statuses := []CampaignStatus{}
+	if len(action) == 0 {
+		err = json.NewDecoder(resp.Body).Decode(&statuses)
+	} else {
+		var s CampaignStatus
+		err = json.NewDecoder(resp.Body).Decode(&s)
+		statuses = append(statuses, s)
+	}
+	if err != nil {
+		return err
+	}
+	if len(statuses) == 0 {
+		fmt.Fprintln(w, tr("暂无任务"))
+	}
+	for _, s := range statuses {
+		name := s.Name
+		if len(s.CampaignID) > 0 {
+			name += " (" + s.CampaignID + ")"
+		}
+		fmt.Fprintf(w, tr("%-4s %-10s %d/%d  成功 %d  失败 %d  %s\n"), s.ID, s.Status, s.Sent+s.Failed, s.Total, s.Sent, s.Failed, name)
+	}
+	return nil
+}
+
+// controlActions 为 campaign 命令可以执行的操作
+var controlActions = map[string]bool{"list": true, "pause": true, "resume": true, "cancel": true, "retry": true, "rate": true}
+
+func checkControlArgs(action string, args []string, interval string) error {
+	if !controlActions[action] {
+		return errors.New(trErr("请指定 campaign 操作：list, pause, resume, cancel, retry, rate"))
+	}
+	if action != "list" && len(args) < 1 {
+		return errors.New(trErr("请提供任务编号，可以用 campaign list 查看"))
+	}
+	if action == "rate" {
+		if _, err := parseInterval(interval); err != nil {
+			return errors.New(trErr("rate 需要用 --interval 指定新的发送间隔，例如 --interval 2s"))
+		}
+	}
+	return nil
+}
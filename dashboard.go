@@ -0,0 +1,251 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	gotempalte "html/template"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+var dashboardTemplate = gotempalte.Must(gotempalte.New("dashboard").Funcs(gotempalte.FuncMap{
+	"time": func(s CampaignStatus) string {
+		if s.Finished == nil {
+			return s.Started.Format("2006-01-02 15:04:05") + " -"
+		}
+		return s.Started.Format("2006-01-02 15:04:05") + " - " + s.Finished.Format("15:04:05")
+	},
+	"tr": tr,
+	"active": func(s CampaignStatus) bool {
+		return s.Status == campaignRunning || s.Status == campaignPaused
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="3">
+<title>{{ tr "邮件发送任务" }}</title>
+<style>
+body { font-family: sans-serif; margin: 24px; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 16px; }
+th, td { border: 1px solid #ddd; padding: 6px 8px; text-align: left; }
+th { background: #f5f5f5; }
+form { display: inline; }
+details { margin: 4px 0 16px; }
+.failed, .cancelled { color: #c00; }
+.done { color: #080; }
+</style>
+</head>
+<body>
+<h1>{{ tr "邮件发送任务" }}</h1>
+{{ if not . }}<p>{{ tr "暂无任务" }}</p>{{ end }}
+{{ range . }}
+<table>
+<tr><th>#</th><th>{{ tr "任务" }}</th><th>{{ tr "状态" }}</th><th>{{ tr "进度" }}</th><th>{{ tr "成功" }}</th><th>{{ tr "失败" }}</th><th>{{ tr "时间" }}</th><th>{{ tr "操作" }}</th></tr>
+<tr>
+<td>{{ .ID }}</td>
+<td>{{ .Name }}{{ if .CampaignID }} ({{ .CampaignID }}){{ end }}</td>
+<td class="{{ .Status }}">{{ .Status }}{{ if .Error }}{{ tr "：" }}{{ .Error }}{{ end }}</td>
+<td><progress max="{{ .Total }}" value="{{ .Sent }}"></progress> {{ .Sent }} / {{ .Total }}</td>
+<td>{{ .Sent }}</td>
+<td>{{ .Failed }}</td>
+<td>{{ time . }}</td>
+<td>
+{{ if eq .Status "running" }}<form method="post" action="/campaigns/{{ .ID }}/pause"><button>{{ tr "暂停" }}</button></form>{{ end }}
+{{ if eq .Status "paused" }}<form method="post" action="/campaigns/{{ .ID }}/resume"><button>{{ tr "继续" }}</button></form>{{ end }}
+{{ if active . }}<form method="post" action="/campaigns/{{ .ID }}/cancel"><button>{{ tr "取消" }}</button></form>{{ end }}
+{{ if and (not (active .)) .Failures }}<form method="post" action="/campaigns/{{ .ID }}/retry"><button>{{ tr "重发失败邮件" }}</button></form>{{ end }}
+</td>
+</tr>
+</table>
+{{ if .Failures }}
+<details>
+<summary>{{ printf (tr "失败列表（%d）") (len .Failures) }}</summary>
+<table>
+<tr><th>{{ tr "行" }}</th><th>{{ tr "收件人" }}</th><th>{{ tr "标题" }}</th><th>{{ tr "错误" }}</th></tr>
+{{ range .Failures }}<tr><td>{{ .Row }}</td><td>{{ .SendTo }}</td><td>{{ .Subject }}</td><td>{{ .Error }}</td></tr>
+{{ end }}
+</table>
+</details>
+{{ end }}
+{{ end }}
+</body>
+</html>
+`))
+
+// dashboardCookie 保存浏览器中输入的 --http-token，之后的页面请求和表单提交不需要再带令牌
+const dashboardCookie = "email_sender_token"
+
+func serveDashboard(addr string) {
+	if len(httpToken) == 0 && !isLoopbackAddr(addr) {
+		log.Printf(tr("启动监控页面失败：%s"), fmt.Errorf(trErr("监听 %s 时必须用 --http-token 设置访问令牌"), addr))
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", requireToken(handleDashboard))
+	mux.HandleFunc("/api/campaigns", requireToken(handleCampaignsAPI))
+	mux.HandleFunc("/api/campaigns/", requireToken(handleCampaignActionAPI))
+	mux.HandleFunc("/campaigns/", requireToken(handleCampaignAction))
+	// 退订链接由收件人打开，本身带有 HMAC，不需要访问令牌
+	mux.HandleFunc("/unsubscribe", handleUnsubscribe)
+
+	log.Printf(tr("监控页面：http://%s"), addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf(tr("启动监控页面失败：%s"), err)
+	}
+}
+
+// isLoopbackAddr 判断监听地址是否只能从本机访问，主机名为空（例如 :8080）时监听所有网卡
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// requireToken 检查 --http-token：API 使用 Authorization: Bearer <令牌>，浏览器打开 /?token=<令牌> 后保存在 cookie 中
+func requireToken(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(httpToken) == 0 {
+			h(w, r)
+			return
+		}
+		if token := r.URL.Query().Get("token"); len(token) > 0 && r.Method == http.MethodGet && validToken(token) {
+			http.SetCookie(w, &http.Cookie{Name: dashboardCookie, Value: token, Path: "/", HttpOnly: true, SameSite: http.SameSiteStrictMode})
+			http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if c, err := r.Cookie(dashboardCookie); err == nil && len(r.Header.Get("Authorization")) == 0 {
+			token = c.Value
+		}
+		if !validToken(token) {
+			http.Error(w, tr("需要访问令牌"), http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
+
+func validToken(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(httpToken)) == 1
+}
+
+// sameOrigin 拒绝其他网站的页面提交的表单：浏览器发出的 POST 带有 Origin 或 Referer，必须与监控页面的地址相同；
+// campaign 命令等客户端不带这两个头
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if len(origin) == 0 {
+		origin = r.Header.Get("Referer")
+	}
+	if len(origin) == 0 {
+		return r.Header.Get("Sec-Fetch-Site") != "cross-site"
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+func campaignStatuses() []CampaignStatus {
+	list := allCampaigns()
+	statuses := make([]CampaignStatus, 0, len(list))
+	// 新的任务显示在前面
+	for i := len(list) - 1; i >= 0; i-- {
+		statuses = append(statuses, list[i].Snapshot())
+	}
+	return statuses
+}
+
+func handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, campaignStatuses()); err != nil {
+		logDebug("渲染监控页面失败：%s", err)
+	}
+}
+
+func handleCampaignsAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(campaignStatuses())
+}
+
+// handleCampaignAction 处理监控页面的 POST /campaigns/{id}/{pause|resume|cancel|retry|rate}，完成后回到监控页面
+func handleCampaignAction(w http.ResponseWriter, r *http.Request) {
+	c, ok := campaignAction(w, r, "/campaigns/")
+	if ok {
+		log.Printf(tr("任务 %s：%s"), c.Name, path.Base(r.URL.Path))
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	}
+}
+
+// handleCampaignActionAPI 处理 POST /api/campaigns/{id}/{action}，与监控页面相同，返回任务的 JSON 状态，
+// 供 campaign 命令等客户端使用
+func handleCampaignActionAPI(w http.ResponseWriter, r *http.Request) {
+	c, ok := campaignAction(w, r, "/api/campaigns/")
+	if ok {
+		log.Printf(tr("任务 %s：%s"), c.Name, path.Base(r.URL.Path))
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(c.Snapshot())
+	}
+}
+
+// campaignAction 执行 URL 中的操作，rate 的新发送间隔为 interval 参数，例如 interval=2s；出错时已经写入响应
+func campaignAction(w http.ResponseWriter, r *http.Request, prefix string) (*Campaign, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	if !sameOrigin(r) {
+		http.Error(w, tr("不接受其他网站提交的请求"), http.StatusForbidden)
+		return nil, false
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	c := findCampaign(parts[0])
+	if c == nil {
+		http.NotFound(w, r)
+		return nil, false
+	}
+
+	switch parts[1] {
+	case "pause":
+		c.Pause()
+	case "resume":
+		c.Resume()
+	case "cancel":
+		c.Cancel()
+	case "retry":
+		if !c.Retry() {
+			http.Error(w, tr("没有可以重试的失败邮件"), http.StatusConflict)
+			return nil, false
+		}
+	case "rate":
+		d, err := parseInterval(r.FormValue("interval"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, false
+		}
+		c.SetInterval(d)
+	default:
+		http.NotFound(w, r)
+		return nil, false
+	}
+	return c, true
+}
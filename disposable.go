@@ -0,0 +1,54 @@
+package main
+
+import (
+	_ "embed"
+	"strings"
+)
+
+//go:embed disposable_domains.txt
+var builtinDisposableDomains string
+
+var disposableDomains map[string]bool
+
+// loadDisposableDomains 读取一次性邮箱域名列表，file 为空时使用内置的列表
+func loadDisposableDomains(file string) error {
+	list := builtinDisposableDomains
+	if len(file) > 0 {
+		data, err := readFileContent(file)
+		if err != nil {
+			return err
+		}
+		list = string(data)
+	}
+
+	disposableDomains = map[string]bool{}
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.ToLower(strings.TrimSpace(line))
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		disposableDomains[line] = true
+	}
+	logDebug("加载了 %d 个一次性邮箱域名", len(disposableDomains))
+	return nil
+}
+
+// isDisposableAddress 判断收件人是否属于一次性邮箱域名，子域名同样算在内
+func isDisposableAddress(addr string) bool {
+	at := strings.LastIndex(addr, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(addr[at+1:]), ">"))
+	for len(domain) > 0 {
+		if disposableDomains[domain] {
+			return true
+		}
+		dot := strings.Index(domain, ".")
+		if dot < 0 {
+			break
+		}
+		domain = domain[dot+1:]
+	}
+	return false
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DistributedConfig 配置多台机器一起发送同一个任务：数据按 lease_size 行分成多段，每台机器从 Redis 领取还没有人发送的段，
+// lease_timeout 秒内没有续期的段（机器已经退出）由其他机器接着发送；max_per_second 为所有机器合计每秒最多发送的邮件数
+type DistributedConfig struct {
+	Redis        string `json:"redis"`
+	LeaseSize    int    `json:"lease_size"`
+	LeaseTimeout int64  `json:"lease_timeout"`
+	MaxPerSecond int    `json:"max_per_second"`
+}
+
+// Redis 中的数据保留一周，之后可以用同一个 campaign ID 重新发送
+const distributedKeyExpiry = 7 * 24 * time.Hour
+
+// distributedRun 记录这台机器领取的段，键都以 email-sender:<campaign ID>: 开头：
+// lease:<段> 为领取这一段的机器，finished 为已经发送完的段，rows 为已经发送过（成功或失败）的行，results 为所有机器的发送结果
+type distributedRun struct {
+	mu      sync.Mutex
+	cfg     DistributedConfig
+	client  *redisClient
+	prefix  string
+	worker  string
+	salt    string
+	claimed map[int]bool
+	leases  map[int]bool
+}
+
+var distributed *distributedRun
+
+func startDistributed(cfg *Config) error {
+	if cfg.Distributed == nil {
+		return nil
+	}
+	if len(campaignID) == 0 {
+		return errors.New(trErr("分布式发送需要用 --campaign-id 指定任务标识，所有机器使用相同的标识"))
+	}
+	c := *cfg.Distributed
+	if c.LeaseSize <= 0 {
+		c.LeaseSize = 50
+	}
+	if c.LeaseTimeout <= 0 {
+		c.LeaseTimeout = 60
+	}
+	client, err := dialRedis(c.Redis)
+	if err != nil {
+		return fmt.Errorf(trErr("连接 Redis 失败：%s"), err)
+	}
+	host, _ := os.Hostname()
+	distributed = &distributedRun{
+		cfg:     c,
+		client:  client,
+		prefix:  "email-sender:" + campaignID + ":",
+		worker:  host + ":" + strconv.Itoa(os.Getpid()),
+		salt:    cfg.ReportSalt,
+		claimed: map[int]bool{},
+		leases:  map[int]bool{},
+	}
+	go distributed.renew()
+	logDebug("分布式发送，机器标识 %s", distributed.worker)
+	return nil
+}
+
+func (d *distributedRun) chunk(s *Send) int {
+	return s.Row / d.cfg.LeaseSize
+}
+
+func (d *distributedRun) leaseKey(chunk int) string {
+	return d.prefix + "lease:" + strconv.Itoa(chunk)
+}
+
+func (d *distributedRun) ttl() string {
+	return strconv.FormatInt(d.cfg.LeaseTimeout*1000, 10)
+}
+
+// claim 判断这一行是否由这台机器发送，第一次遇到一段时尝试领取，领取不到的段由其他机器发送
+func (d *distributedRun) claim(s *Send) bool {
+	if d == nil {
+		return true
+	}
+	chunk := d.chunk(s)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if ok, seen := d.claimed[chunk]; seen {
+		return ok
+	}
+	ok := d.tryLease(chunk)
+	d.claimed[chunk] = ok
+	return ok
+}
+
+func (d *distributedRun) tryLease(chunk int) bool {
+	if finished, err := redisInt(d.client.do("SISMEMBER", d.prefix+"finished", strconv.Itoa(chunk))); err != nil || finished == 1 {
+		if err != nil {
+			logDebug("读取分布式发送进度失败：%s", err)
+		}
+		return false
+	}
+	v, err := d.client.do("SET", d.leaseKey(chunk), d.worker, "NX", "PX", d.ttl())
+	if err != nil {
+		logDebug("领取第 %d 段失败：%s", chunk, err)
+		return false
+	}
+	if v == nil {
+		return false
+	}
+	d.leases[chunk] = true
+	return true
+}
+
+// renew 定期给还没有发送完的段续期，续期失败（段已经被其他机器领取）时停止发送这一段
+func (d *distributedRun) renew() {
+	for {
+		time.Sleep(time.Duration(d.cfg.LeaseTimeout) * time.Second / 3)
+		d.mu.Lock()
+		for chunk := range d.leases {
+			n, err := redisInt(d.client.do("PEXPIRE", d.leaseKey(chunk), d.ttl()))
+			if err == nil && n == 0 {
+				logDebug("第 %d 段的领取已过期", chunk)
+				delete(d.leases, chunk)
+				d.claimed[chunk] = false
+			}
+		}
+		d.mu.Unlock()
+	}
+}
+
+// record 保存一条发送结果到 Redis，配置了 report_salt 时与报告一样只保存地址的哈希
+func (d *distributedRun) record(result Result) error {
+	if d == nil {
+		return nil
+	}
+	if !result.Seed {
+		if _, err := d.client.do("SADD", d.prefix+"rows", strconv.Itoa(result.Row)); err != nil {
+			return err
+		}
+	}
+	if len(d.salt) > 0 {
+		result = hashResult(d.salt, result)
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	_, err = d.client.do("RPUSH", d.prefix+"results", string(data))
+	return err
+}
+
+// wait 按 max_per_second 限制所有机器合计的发送速度，任务被取消时返回 false
+func (d *distributedRun) wait(c *Campaign) bool {
+	if d == nil || d.cfg.MaxPerSecond <= 0 {
+		return true
+	}
+	for {
+		now := time.Now()
+		key := d.prefix + "rate:" + strconv.FormatInt(now.Unix(), 10)
+		n, err := redisInt(d.client.do("INCR", key))
+		if err != nil {
+			logDebug("分布式限速失败：%s", err)
+			return true
+		}
+		if n == 1 {
+			d.client.do("EXPIRE", key, "5")
+		}
+		if n <= int64(d.cfg.MaxPerSecond) {
+			return true
+		}
+		if !c.sleep(now.Truncate(time.Second).Add(time.Second).Sub(now)) {
+			return false
+		}
+	}
+}
+
+// release 把这台机器领取的段标记为发送完
+func (d *distributedRun) release() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for chunk := range d.leases {
+		d.client.do("SADD", d.prefix+"finished", strconv.Itoa(chunk))
+		d.client.do("DEL", d.leaseKey(chunk))
+		delete(d.leases, chunk)
+	}
+}
+
+// finish 在本机的发送结束后调用：等待其他机器发送完，其他机器退出后没有发送完的段由这台机器领取，
+// 用 send 发送其中还没有发送过的行
+func (d *distributedRun) finish(list []*Send, send func(list []*Send) error) error {
+	if d == nil {
+		return nil
+	}
+	chunks := map[int][]*Send{}
+	for _, s := range list {
+		chunks[d.chunk(s)] = append(chunks[d.chunk(s)], s)
+	}
+	order := make([]int, 0, len(chunks))
+	for chunk := range chunks {
+		order = append(order, chunk)
+	}
+	sort.Ints(order)
+
+	waiting := false
+	for {
+		d.release()
+		pending := 0
+		recovered := []*Send{}
+		for _, chunk := range order {
+			d.mu.Lock()
+			ok := d.tryLease(chunk)
+			d.claimed[chunk] = ok
+			d.mu.Unlock()
+			if ok {
+				for _, s := range chunks[chunk] {
+					if sent, err := redisInt(d.client.do("SISMEMBER", d.prefix+"rows", strconv.Itoa(s.Row))); err != nil || sent == 0 {
+						recovered = append(recovered, s)
+					}
+				}
+				continue
+			}
+			if n, err := redisInt(d.client.do("EXISTS", d.leaseKey(chunk))); err != nil || n == 1 {
+				pending++
+			}
+		}
+
+		if len(recovered) > 0 {
+			log.Printf(tr("接着发送其他机器没有发送完的 %d 个收件人"), len(recovered))
+			if err := send(recovered); err != nil {
+				return err
+			}
+			continue
+		}
+		if pending == 0 {
+			break
+		}
+		if !waiting {
+			log.Printf(tr("等待其他机器发送完 %d 段"), pending)
+			waiting = true
+		}
+		time.Sleep(5 * time.Second)
+	}
+	d.release()
+
+	for _, key := range []string{"finished", "rows", "results"} {
+		d.client.do("PEXPIRE", d.prefix+key, strconv.FormatInt(int64(distributedKeyExpiry/time.Millisecond), 10))
+	}
+	return nil
+}
+
+// writeReport 用所有机器的发送结果重新写入报告文件
+func (d *distributedRun) writeReport(file string) error {
+	v, err := d.client.do("LRANGE", d.prefix+"results", "0", "-1")
+	if err != nil {
+		return err
+	}
+	items, _ := v.([]interface{})
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if line, ok := item.(string); ok {
+			if _, err := f.WriteString(line + "\n"); err != nil {
+				f.Close()
+				return err
+			}
+		}
+	}
+	return f.Close()
+}
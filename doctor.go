@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"sort"
+	"strings"
+)
+
+// SPF 最多允许 10 次需要 DNS 查询的机制（RFC 7208 4.6.4）
+const maxSPFLookups = 10
+
+var resolver = net.DefaultResolver
+
+// 没有用 --dkim-selector 指定时检查的常见 DKIM 选择器
+var commonDKIMSelectors = []string{"default", "dkim", "mail", "s1", "s2", "selector1", "selector2", "google", "k1", "k2", "smtp"}
+
+// 按两级后缀注册的域名，用于判断组织域名
+var secondLevelSuffixes = map[string]bool{
+	"com.cn": true, "net.cn": true, "org.cn": true, "gov.cn": true, "edu.cn": true,
+	"com.hk": true, "com.tw": true, "co.uk": true, "org.uk": true, "co.jp": true,
+	"com.au": true, "com.sg": true, "co.kr": true, "com.br": true,
+}
+
+// doctor 检查发件域名的 SPF、DKIM 和 DMARC 记录，输出检查结果，有问题时返回 false
+func doctor(cfg *Config, out io.Writer) bool {
+	domains := []string{}
+	seen := map[string]bool{}
+	addDomain := func(addr string) {
+		if domain := senderDomain(addr); len(domain) > 0 && !seen[domain] {
+			seen[domain] = true
+			domains = append(domains, domain)
+		}
+	}
+	addDomain(cfg.From)
+	for _, identity := range cfg.FromPool {
+		addDomain(identity.From)
+	}
+	if len(domains) == 0 {
+		fmt.Fprintln(out, tr("配置中没有发件人地址"))
+		return false
+	}
+
+	var hostIPs []net.IP
+	if len(cfg.Host) > 0 {
+		addrs, err := resolver.LookupIPAddr(context.Background(), cfg.Host)
+		if err != nil {
+			fmt.Fprintf(out, tr("警告：无法解析 SMTP 服务器 %s：%s，不检查 SPF 是否授权\n"), cfg.Host, err)
+		}
+		for _, addr := range addrs {
+			hostIPs = append(hostIPs, addr.IP)
+		}
+	}
+
+	// 信封发件人（Return-Path）决定 SPF 检查的域名
+	envelopeDomain := ""
+	if len(cfg.VERP) > 0 {
+		envelopeDomain = senderDomain(cfg.VERP)
+	}
+
+	ok := true
+	for _, domain := range domains {
+		fmt.Fprintf(out, "%s:\n", domain)
+		problems := 0
+		report := func(warning bool, format string, args ...interface{}) {
+			prefix := "\t"
+			if warning {
+				prefix += tr("警告：")
+				problems++
+			}
+			fmt.Fprintf(out, prefix+format+"\n", args...)
+		}
+
+		spfDomain := domain
+		if len(envelopeDomain) > 0 {
+			spfDomain = envelopeDomain
+		}
+		checkSPFRecord(cfg, spfDomain, hostIPs, report)
+		checkDKIMRecords(domain, report)
+		checkDMARCRecord(domain, envelopeDomain, report)
+
+		if problems > 0 {
+			ok = false
+		}
+	}
+	return ok
+}
+
+// senderDomain 返回发件人地址（可以带显示名）的域名
+func senderDomain(addr string) string {
+	a, err := mail.ParseAddress(addr)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(addressDomain(a.Address))
+}
+
+// organizationalDomain 取域名的注册部分，例如 mail.example.com.cn 为 example.com.cn
+func organizationalDomain(domain string) string {
+	labels := strings.Split(strings.TrimSuffix(domain, "."), ".")
+	n := 2
+	if len(labels) >= 3 && secondLevelSuffixes[strings.Join(labels[len(labels)-2:], ".")] {
+		n = 3
+	}
+	if len(labels) <= n {
+		return domain
+	}
+	return strings.Join(labels[len(labels)-n:], ".")
+}
+
+func lookupTXTRecord(name, prefix string) (string, error) {
+	records, err := resolver.LookupTXT(context.Background(), name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return "", nil
+		}
+		return "", err
+	}
+	for _, record := range records {
+		if strings.HasPrefix(strings.ToLower(record), strings.ToLower(prefix)) {
+			return record, nil
+		}
+	}
+	return "", nil
+}
+
+func checkSPFRecord(cfg *Config, domain string, hostIPs []net.IP, report func(bool, string, ...interface{})) {
+	record, err := lookupTXTRecord(domain, "v=spf1")
+	if err != nil {
+		report(true, tr("SPF：查询 %s 失败：%s"), domain, err)
+		return
+	}
+	if len(record) == 0 {
+		report(true, tr("SPF：%s 没有 SPF 记录，收件服务器无法确认 %s 可以代表该域名发信"), domain, cfg.Host)
+		return
+	}
+	report(false, tr("SPF：%s"), record)
+
+	if len(hostIPs) == 0 {
+		return
+	}
+	checker := &spfChecker{}
+	results := map[string]string{}
+	for _, ip := range hostIPs {
+		checker.lookups = 0
+		results[ip.String()] = checker.check(domain, ip, 0)
+	}
+	if checker.lookups > maxSPFLookups {
+		report(true, tr("SPF：需要 %d 次 DNS 查询，超过 %d 次时收件服务器会判定为 permerror"), checker.lookups, maxSPFLookups)
+	}
+
+	ips := []string{}
+	for ip := range results {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	for _, ip := range ips {
+		switch results[ip] {
+		case "pass":
+			report(false, tr("SPF：%s (%s) 已授权"), cfg.Host, ip)
+		case "unknown":
+			report(false, tr("SPF：无法判断 %s (%s) 是否授权（记录中有宏或 exists 机制）"), cfg.Host, ip)
+		default:
+			report(true, tr("SPF：%s (%s) 结果为 %s；服务商使用不同的出站 IP 时可以忽略，否则需要在 SPF 中加入该服务器，例如 include 服务商的 SPF"),
+				cfg.Host, ip, results[ip])
+		}
+	}
+}
+
+// spfChecker 按 RFC 7208 检查 ip 是否被 SPF 授权，只实现常用的机制，不支持宏
+type spfChecker struct {
+	lookups int
+}
+
+func (c *spfChecker) check(domain string, ip net.IP, depth int) string {
+	if depth > maxSPFLookups {
+		return "permerror"
+	}
+	record, err := lookupTXTRecord(domain, "v=spf1")
+	if err != nil {
+		return "temperror"
+	}
+	if len(record) == 0 {
+		return "none"
+	}
+
+	redirect := ""
+	for _, term := range strings.Fields(record)[1:] {
+		term = strings.ToLower(term)
+		if strings.HasPrefix(term, "redirect=") {
+			redirect = term[len("redirect="):]
+			continue
+		}
+		if strings.Contains(term, "=") {
+			continue
+		}
+
+		qualifier := "pass"
+		switch term[0] {
+		case '+':
+			term = term[1:]
+		case '-':
+			qualifier, term = "fail", term[1:]
+		case '~':
+			qualifier, term = "softfail", term[1:]
+		case '?':
+			qualifier, term = "neutral", term[1:]
+		}
+		if strings.Contains(term, "%") {
+			return "unknown"
+		}
+
+		name, arg := term, ""
+		if i := strings.IndexAny(term, ":/"); i >= 0 {
+			name, arg = term[:i], term[i:]
+		}
+		switch name {
+		case "all":
+			return qualifier
+		case "ip4", "ip6":
+			if matchCIDR(ip, strings.TrimPrefix(arg, ":"), "") {
+				return qualifier
+			}
+		case "a", "mx":
+			c.lookups++
+			target, cidr := domain, ""
+			if strings.HasPrefix(arg, ":") {
+				arg = arg[1:]
+				if i := strings.Index(arg, "/"); i >= 0 {
+					target, cidr = arg[:i], arg[i:]
+				} else {
+					target = arg
+				}
+			} else {
+				cidr = arg
+			}
+			hosts := []string{target}
+			if name == "mx" {
+				hosts = nil
+				mxs, _ := resolver.LookupMX(context.Background(), target)
+				for _, mx := range mxs {
+					hosts = append(hosts, mx.Host)
+				}
+			}
+			for _, host := range hosts {
+				addrs, _ := resolver.LookupIPAddr(context.Background(), host)
+				for _, addr := range addrs {
+					if matchCIDR(ip, addr.IP.String(), cidr) {
+						return qualifier
+					}
+				}
+			}
+		case "include":
+			c.lookups++
+			switch c.check(strings.TrimPrefix(arg, ":"), ip, depth+1) {
+			case "pass":
+				return qualifier
+			case "unknown":
+				return "unknown"
+			}
+		case "exists", "ptr":
+			c.lookups++
+			return "unknown"
+		}
+	}
+	if len(redirect) > 0 {
+		c.lookups++
+		return c.check(redirect, ip, depth+1)
+	}
+	return "neutral"
+}
+
+// matchCIDR 判断 ip 是否在 network 中，network 可以带 /前缀长度，cidr 为 a / mx 机制中单独指定的前缀长度
+func matchCIDR(ip net.IP, network, cidr string) bool {
+	// a / mx 可以写成 /24//64 分别指定 IPv4 和 IPv6 的前缀长度
+	if parts := strings.SplitN(cidr, "//", 2); len(parts) == 2 {
+		if ip.To4() != nil {
+			cidr = parts[0]
+		} else {
+			cidr = "/" + parts[1]
+		}
+	}
+	network += cidr
+	if !strings.Contains(network, "/") {
+		other := net.ParseIP(network)
+		return other != nil && other.Equal(ip)
+	}
+	_, ipnet, err := net.ParseCIDR(network)
+	return err == nil && ipnet.Contains(ip)
+}
+
+func checkDKIMRecords(domain string, report func(bool, string, ...interface{})) {
+	selectors := dkimSelectors
+	if len(selectors) == 0 {
+		selectors = commonDKIMSelectors
+	}
+	found := []string{}
+	for _, selector := range selectors {
+		record, err := lookupTXTRecord(selector+"._domainkey."+domain, "v=DKIM1")
+		if err != nil {
+			continue
+		}
+		if len(record) == 0 {
+			// 有的记录省略了 v=DKIM1
+			record, _ = lookupTXTRecord(selector+"._domainkey."+domain, "k=")
+		}
+		if len(record) > 0 {
+			if p, ok := parseRecordTags(record)["p"]; ok && len(p) == 0 {
+				report(true, tr("DKIM：选择器 %s 的公钥为空，表示已撤销"), selector)
+				continue
+			}
+			found = append(found, selector)
+		}
+	}
+	if len(found) == 0 {
+		report(true, tr("DKIM：没有找到选择器 %s 的 DKIM 记录，可以用 --dkim-selector 指定服务商使用的选择器"),
+			strings.Join(selectors, ", "))
+		return
+	}
+	report(false, tr("DKIM：找到选择器 %s；签名域名需要与 %s 一致（或为同一组织域名）才能通过 DMARC 对齐"), strings.Join(found, ", "), domain)
+}
+
+func checkDMARCRecord(domain, envelopeDomain string, report func(bool, string, ...interface{})) {
+	record, err := lookupTXTRecord("_dmarc."+domain, "v=DMARC1")
+	if err == nil && len(record) == 0 && organizationalDomain(domain) != domain {
+		record, err = lookupTXTRecord("_dmarc."+organizationalDomain(domain), "v=DMARC1")
+	}
+	if err != nil {
+		report(true, tr("DMARC：查询失败：%s"), err)
+		return
+	}
+	if len(record) == 0 {
+		report(true, tr("DMARC：没有 DMARC 记录，Gmail、Yahoo 等要求批量发件人配置 DMARC"))
+		return
+	}
+	report(false, tr("DMARC：%s"), record)
+
+	tags := parseRecordTags(record)
+	if strings.ToLower(tags["p"]) == "none" {
+		report(false, tr("DMARC：策略为 none，只监控不拦截"))
+	}
+	if len(envelopeDomain) > 0 && envelopeDomain != domain {
+		strict := strings.ToLower(tags["aspf"]) == "s"
+		if strict || organizationalDomain(envelopeDomain) != organizationalDomain(domain) {
+			report(true, tr("DMARC：信封发件人 %s 与 From 域名 %s 不一致，SPF 无法对齐，只能依靠 DKIM 通过 DMARC"), envelopeDomain, domain)
+		}
+	}
+}
+
+// parseRecordTags 解析 DKIM / DMARC 记录中 ; 分隔的 tag=value
+func parseRecordTags(record string) map[string]string {
+	tags := map[string]string{}
+	for _, tag := range strings.Split(record, ";") {
+		if kv := strings.SplitN(strings.TrimSpace(tag), "=", 2); len(kv) == 2 {
+			tags[strings.ToLower(strings.TrimSpace(kv[0]))] = strings.TrimSpace(kv[1])
+		}
+	}
+	return tags
+}
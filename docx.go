@@ -0,0 +1,272 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	docxWordNS = "http://schemas.openxmlformats.org/wordprocessingml/2006/main"
+	docxRelNS  = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+)
+
+// readTemplateFile 读取邮件内容或模板文件，.docx 文件转换为 HTML
+func readTemplateFile(file string) ([]byte, error) {
+	if strings.EqualFold(filepath.Ext(file), ".docx") {
+		return docxToHTML(file)
+	}
+	return readFileContent(file)
+}
+
+type docxRun struct {
+	text  string
+	style []string
+	link  string
+}
+
+// docxToHTML 把 Word 文档转换为 HTML，保留标题、段落、粗体、斜体、下划线、删除线、链接、换行和表格；
+// 图片等其他内容会被忽略。被 Word 拆分到不同格式中的 {{ }} 会合并，其中的中文引号还原为英文引号
+func docxToHTML(file string) ([]byte, error) {
+	r, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var document, rels []byte
+	for _, f := range r.File {
+		switch f.Name {
+		case "word/document.xml":
+			document, err = readZipFile(f)
+		case "word/_rels/document.xml.rels":
+			rels, err = readZipFile(f)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if document == nil {
+		return nil, errors.New(trErr("无效的 docx 文件，没有 word/document.xml"))
+	}
+
+	links := map[string]string{}
+	if rels != nil {
+		var relationships struct {
+			Relationship []struct {
+				ID     string `xml:"Id,attr"`
+				Target string `xml:"Target,attr"`
+			}
+		}
+		if err := xml.Unmarshal(rels, &relationships); err != nil {
+			return nil, err
+		}
+		for _, rel := range relationships.Relationship {
+			links[rel.ID] = rel.Target
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("<html>\n<body>\n")
+
+	d := xml.NewDecoder(bytes.NewReader(document))
+	var runs []docxRun
+	var run *docxRun
+	var style []string
+	inParagraph, inRunProps := false, false
+	tag, link := "p", ""
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space != docxWordNS {
+				break
+			}
+			switch t.Name.Local {
+			case "tbl":
+				out.WriteString("<table border=\"1\" cellspacing=\"0\" cellpadding=\"4\">\n")
+			case "tr":
+				out.WriteString("<tr>")
+			case "tc":
+				out.WriteString("<td>")
+			case "p":
+				inParagraph, tag, runs = true, "p", nil
+			case "pStyle":
+				if level := headingLevel(docxAttr(t, docxWordNS, "val")); level > 0 {
+					tag = fmt.Sprintf("h%d", level)
+				}
+			case "hyperlink":
+				link = links[docxAttr(t, docxRelNS, "id")]
+			case "r":
+				run, style = &docxRun{link: link}, nil
+			case "rPr":
+				inRunProps = true
+			case "b", "i", "u", "strike":
+				if inRunProps && docxAttr(t, docxWordNS, "val") != "0" && docxAttr(t, docxWordNS, "val") != "false" &&
+					docxAttr(t, docxWordNS, "val") != "none" {
+					style = append(style, map[string]string{"b": "b", "i": "i", "u": "u", "strike": "s"}[t.Name.Local])
+				}
+			case "br":
+				if run != nil {
+					run.text += "\n"
+				}
+			case "tab":
+				if run != nil {
+					run.text += "\t"
+				}
+			case "t":
+				if run != nil {
+					var text string
+					if err := d.DecodeElement(&text, &t); err != nil {
+						return nil, err
+					}
+					run.text += text
+				}
+			}
+
+		case xml.EndElement:
+			if t.Name.Space != docxWordNS {
+				break
+			}
+			switch t.Name.Local {
+			case "tbl":
+				out.WriteString("</table>\n")
+			case "tr":
+				out.WriteString("</tr>\n")
+			case "tc":
+				out.WriteString("</td>")
+			case "rPr":
+				inRunProps = false
+			case "r":
+				if run != nil && inParagraph {
+					run.style = style
+					runs = append(runs, *run)
+				}
+				run = nil
+			case "hyperlink":
+				link = ""
+			case "p":
+				out.WriteString("<" + tag + ">")
+				if len(runs) == 0 {
+					// 保留 Word 中用来分隔的空段落
+					out.WriteString("&nbsp;")
+				}
+				writeDocxRuns(&out, mergePlaceholderRuns(runs))
+				out.WriteString("</" + tag + ">\n")
+				inParagraph = false
+			}
+		}
+	}
+
+	out.WriteString("</body>\n</html>\n")
+	return out.Bytes(), nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+func docxAttr(e xml.StartElement, space, local string) string {
+	for _, a := range e.Attr {
+		if a.Name.Space == space && a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// headingLevel 识别 Heading1 ~ Heading6 及 Title 样式
+func headingLevel(style string) int {
+	s := strings.ToLower(style)
+	if s == "title" {
+		return 1
+	}
+	if strings.HasPrefix(s, "heading") && len(s) == len("heading")+1 {
+		if level := int(s[len(s)-1] - '0'); level >= 1 && level <= 6 {
+			return level
+		}
+	}
+	return 0
+}
+
+// mergePlaceholderRuns 把 {{ 与 }} 之间被拆分的文字合并到第一个片段中
+func mergePlaceholderRuns(runs []docxRun) []docxRun {
+	merged := []docxRun{}
+	for _, run := range runs {
+		if n := len(merged); n > 0 && openPlaceholder(merged[n-1].text) {
+			merged[n-1].text += run.text
+			continue
+		}
+		merged = append(merged, run)
+	}
+	return merged
+}
+
+func openPlaceholder(text string) bool {
+	return strings.LastIndex(text, "{{") > strings.LastIndex(text, "}}")
+}
+
+func writeDocxRuns(out *bytes.Buffer, runs []docxRun) {
+	for i, run := range runs {
+		if len(run.link) > 0 && (i == 0 || runs[i-1].link != run.link) {
+			out.WriteString(`<a href="` + html.EscapeString(run.link) + `">`)
+		}
+		for _, s := range run.style {
+			out.WriteString("<" + s + ">")
+		}
+		out.WriteString(escapeDocxText(run.text))
+		for j := len(run.style) - 1; j >= 0; j-- {
+			out.WriteString("</" + run.style[j] + ">")
+		}
+		if len(run.link) > 0 && (i == len(runs)-1 || runs[i+1].link != run.link) {
+			out.WriteString("</a>")
+		}
+	}
+}
+
+var docxQuotes = strings.NewReplacer("“", `"`, "”", `"`, "‘", "'", "’", "'")
+
+// escapeDocxText 转义 {{ }} 以外的文字，换行转换为 <br>
+func escapeDocxText(text string) string {
+	var b strings.Builder
+	for len(text) > 0 {
+		start := strings.Index(text, "{{")
+		if start < 0 {
+			start = len(text)
+		}
+		b.WriteString(strings.ReplaceAll(html.EscapeString(text[:start]), "\n", "<br>"))
+		text = text[start:]
+		if len(text) == 0 {
+			break
+		}
+		end := strings.Index(text, "}}")
+		if end < 0 {
+			end = len(text)
+		} else {
+			end += 2
+		}
+		b.WriteString(docxQuotes.Replace(text[:end]))
+		text = text[end:]
+	}
+	return b.String()
+}
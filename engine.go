@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	gotempalte "html/template"
+	"io"
+	texttemplate "text/template"
+
+	"github.com/aymerick/raymond"
+	"github.com/flosch/pongo2/v4"
+)
+
+const (
+	engineGo         = "go"
+	engineMustache   = "mustache"
+	engineHandlebars = "handlebars"
+	enginePongo2     = "pongo2"
+)
+
+// renderer 用模板渲染一行数据
+type renderer func(w io.Writer, data interface{}) error
+
+// compileTemplate 按 --engine 编译邮件模板和标题模板，html 为 false 时不转义；
+// dir 为模板所在目录，pongo2 的 extends / include 相对于该目录查找
+func compileTemplate(name, dir, text string, html bool) (renderer, error) {
+	switch engine {
+	case "", engineGo:
+		if html {
+			t, err := gotempalte.New(name).Funcs(templateFuncs).Option(missingKeyOption()).Parse(text)
+			if err != nil {
+				return nil, err
+			}
+			return t.Execute, nil
+		}
+		t, err := texttemplate.New(name).Funcs(templateFuncs).Option(missingKeyOption()).Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		return t.Execute, nil
+
+	case engineMustache, engineHandlebars:
+		t, err := raymond.Parse(text)
+		if err != nil {
+			return nil, err
+		}
+		render := func(w io.Writer, data interface{}) error {
+			result, err := t.Exec(handlebarsContext(data, html))
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(w, result)
+			return err
+		}
+		return checkedRenderer(render, text)
+
+	case enginePongo2:
+		loader, err := pongo2.NewLocalFileSystemLoader(dir)
+		if err != nil {
+			return nil, err
+		}
+		if !html {
+			text = "{% autoescape off %}" + text + "{% endautoescape %}"
+		}
+		t, err := pongo2.NewSet(name, loader).FromString(text)
+		if err != nil {
+			return nil, err
+		}
+		render := func(w io.Writer, data interface{}) error {
+			ctx := pongo2.Context{}
+			if meta, ok := data.(map[string]string); ok {
+				for k, v := range meta {
+					ctx[k] = v
+				}
+			}
+			return t.ExecuteWriter(ctx, w)
+		}
+		return checkedRenderer(render, text)
+
+	default:
+		return nil, fmt.Errorf(trErr("不支持的模板引擎 %s"), engine)
+	}
+}
+
+// handlebarsContext 转换模板数据，不需要转义时把值标记为安全的字符串
+func handlebarsContext(data interface{}, html bool) map[string]interface{} {
+	ctx := map[string]interface{}{}
+	if meta, ok := data.(map[string]string); ok {
+		for k, v := range meta {
+			if html {
+				ctx[k] = v
+			} else {
+				ctx[k] = raymond.SafeString(v)
+			}
+		}
+	}
+	return ctx
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+// ${NAME} 或 ${NAME:-默认值}，$${ 表示 ${ 本身
+var envReference = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandConfigEnv 替换配置文件字符串值中引用的环境变量，
+// 整个值只有一个引用且替换后是数字或 true / false 时按数字或布尔值处理，例如 "port": "${SMTP_PORT}"
+func expandConfigEnv(data []byte) ([]byte, error) {
+	if !envReference.Match(data) {
+		return data, nil
+	}
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	v, err := expandEnvValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func expandEnvValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			expanded, err := expandEnvValue(item)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = expanded
+		}
+		return v, nil
+	case []interface{}:
+		for i, item := range v {
+			expanded, err := expandEnvValue(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = expanded
+		}
+		return v, nil
+	case string:
+		return expandEnvString(v)
+	}
+	return v, nil
+}
+
+func expandEnvString(s string) (interface{}, error) {
+	var missing []string
+	expanded := envReference.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref[1] == '$' {
+			return ref[1:]
+		}
+		m := envReference.FindStringSubmatch(ref)
+		val, ok := os.LookupEnv(m[1])
+		if !ok || len(val) == 0 && len(m[2]) > 0 {
+			if len(m[2]) == 0 {
+				missing = append(missing, m[1])
+			}
+			return m[3]
+		}
+		return val
+	})
+	if len(missing) > 0 {
+		return nil, fmt.Errorf(trErr("环境变量 %s 未设置"), missing[0])
+	}
+
+	if loc := envReference.FindStringIndex(s); loc != nil && loc[0] == 0 && loc[1] == len(s) && s[1] != '$' {
+		if _, err := strconv.ParseFloat(expanded, 64); err == nil {
+			return json.Number(expanded), nil
+		}
+		if b, err := strconv.ParseBool(expanded); err == nil && (expanded == "true" || expanded == "false") {
+			return b, nil
+		}
+	}
+	return expanded, nil
+}
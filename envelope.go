@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/mail"
+	"strings"
+	texttemplate "text/template"
+)
+
+// DSNConfig 为 SMTP 投递状态通知（RFC 3461）的信封参数，只在服务器支持 DSN 扩展时使用
+type DSNConfig struct {
+	Ret    string `json:"ret"`
+	Notify string `json:"notify"`
+	EnvID  string `json:"envid"`
+}
+
+// envelope 是单封邮件的 SMTP 信封参数，gomail 只会根据邮件头生成收发件人
+type envelope struct {
+	// from 不为空时替代 From 头中的地址作为信封发件人
+	from string
+	// mailParams / rcptParams 为 DSN 参数，服务器不支持 DSN 时不使用
+	mailParams []string
+	rcptParams func(addr string) []string
+}
+
+// envelopeSender 可以为下一封邮件指定信封参数的 Sender
+type envelopeSender interface {
+	setEnvelope(e *envelope)
+}
+
+type envelopeBuilder func(s *Send) (*envelope, error)
+
+func getEnvelopeBuilder(cfg *Config) (envelopeBuilder, error) {
+	if cfg.DSN == nil && len(cfg.VERP) == 0 {
+		return nil, nil
+	}
+
+	verpLocal, verpDomain := "", ""
+	if len(cfg.VERP) > 0 {
+		at := strings.LastIndex(cfg.VERP, "@")
+		if at <= 0 || !validEmailAddress(cfg.VERP) {
+			return nil, fmt.Errorf(trErr("无效的 verp 地址 %s"), cfg.VERP)
+		}
+		verpLocal, verpDomain = cfg.VERP[:at], cfg.VERP[at+1:]
+	}
+
+	if cfg.DSN == nil {
+		return func(s *Send) (*envelope, error) {
+			return &envelope{from: verpAddress(verpLocal, verpDomain, s.SendTo)}, nil
+		}, nil
+	}
+
+	dsn := cfg.DSN
+	ret := strings.ToUpper(dsn.Ret)
+	if len(ret) > 0 && ret != "FULL" && ret != "HDRS" {
+		return nil, fmt.Errorf(trErr("无效的 DSN ret %s，只能是 FULL 或 HDRS"), dsn.Ret)
+	}
+	notify := strings.ToUpper(strings.ReplaceAll(dsn.Notify, " ", ""))
+	for _, n := range strings.Split(notify, ",") {
+		switch n {
+		case "", "NEVER", "SUCCESS", "FAILURE", "DELAY":
+		default:
+			return nil, fmt.Errorf(trErr("无效的 DSN notify %s，只能是 NEVER 或 SUCCESS、FAILURE、DELAY 的组合"), dsn.Notify)
+		}
+	}
+	envID := dsn.EnvID
+	if len(envID) == 0 {
+		envID = "{{ .CampaignID }}"
+	}
+	envIDTemplate, err := texttemplate.New("envid").Parse(envID)
+	if err != nil {
+		return nil, fmt.Errorf(trErr("解析 DSN envid 失败：%s"), err)
+	}
+
+	return func(s *Send) (*envelope, error) {
+		var id bytes.Buffer
+		if err := envIDTemplate.Execute(&id, s.Meta); err != nil {
+			return nil, fmt.Errorf(trErr("渲染 DSN envid 失败：%s"), err)
+		}
+
+		e := &envelope{}
+		if len(verpLocal) > 0 {
+			e.from = verpAddress(verpLocal, verpDomain, s.SendTo)
+		}
+		if len(ret) > 0 {
+			e.mailParams = append(e.mailParams, "RET="+ret)
+		}
+		if v := strings.TrimSpace(id.String()); len(v) > 0 && v != "<no value>" {
+			e.mailParams = append(e.mailParams, "ENVID="+xtext(v))
+		}
+		e.rcptParams = func(addr string) []string {
+			params := []string{}
+			if len(notify) > 0 {
+				params = append(params, "NOTIFY="+notify)
+			}
+			return append(params, "ORCPT=rfc822;"+xtext(addr))
+		}
+		return e, nil
+	}, nil
+}
+
+// verpAddress 生成 VERP 信封发件人，例如 bounce+user=example.com@ourdomain.com
+func verpAddress(local, domain, rcpt string) string {
+	if a, err := mail.ParseAddress(rcpt); err == nil {
+		rcpt = a.Address
+	}
+	return local + "+" + strings.Replace(rcpt, "@", "=", 1) + "@" + domain
+}
+
+// xtext 按 RFC 3461 编码 DSN 参数值
+func xtext(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 33 || c > 126 || c == '+' || c == '=' {
+			fmt.Fprintf(&b, "+%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
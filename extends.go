@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+)
+
+// readConfigData 读取配置文件，有 extends 时先读取被继承的配置，再用当前文件中的字段覆盖，
+// extends 可以是一个文件或多个文件的数组，相对路径相对于当前配置文件所在目录
+func readConfigData(file string, seen map[string]bool) (map[string]interface{}, error) {
+	abs, _ := filepath.Abs(file)
+	if seen[abs] {
+		return nil, fmt.Errorf(trErr("配置文件 %s 循环继承"), file)
+	}
+	seen[abs] = true
+	defer delete(seen, abs)
+
+	data, err := readFileContent(file)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = expandConfigEnv(data); err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var cfg map[string]interface{}
+	if err := d.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+
+	var bases []string
+	switch extends := cfg["extends"].(type) {
+	case nil:
+		return cfg, nil
+	case string:
+		bases = []string{extends}
+	case []interface{}:
+		for _, base := range extends {
+			name, ok := base.(string)
+			if !ok {
+				return nil, fmt.Errorf(trErr("%s: extends 只能是文件名或文件名数组"), file)
+			}
+			bases = append(bases, name)
+		}
+	default:
+		return nil, fmt.Errorf(trErr("%s: extends 只能是文件名或文件名数组"), file)
+	}
+	delete(cfg, "extends")
+
+	merged := map[string]interface{}{}
+	for _, base := range bases {
+		if !isRemoteFile(base) && !filepath.IsAbs(base) {
+			base = filepath.Join(filepath.Dir(file), base)
+		}
+		baseCfg, err := readConfigData(base, seen)
+		if err != nil {
+			return nil, err
+		}
+		mergeConfig(merged, baseCfg)
+	}
+	mergeConfig(merged, cfg)
+	return merged, nil
+}
+
+// mergeConfig 用 src 覆盖 dst，两边都是对象时逐个字段合并，其他值（包括数组）整个替换
+func mergeConfig(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if srcObj, ok := v.(map[string]interface{}); ok {
+			if dstObj, ok := dst[k].(map[string]interface{}); ok {
+				mergeConfig(dstObj, srcObj)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// loadFollowUpCandidates 读取原来任务的报告，返回最后一次结果为发送成功、且发送时间已经超过 days 天的收件人
+func loadFollowUpCandidates(file string, days int) ([]Result, error) {
+	type key struct {
+		row  int
+		addr string
+	}
+	last := map[key]Result{}
+	order := []key{}
+	err := readReport(file, func(result Result) {
+		if result.Seed {
+			return
+		}
+		k := key{result.Row, dedupeKey(normalizeAddress(result.SendTo))}
+		if len(result.SendToHash) > 0 {
+			k.addr = "#" + result.SendToHash
+		}
+		if _, ok := last[k]; !ok {
+			order = append(order, k)
+		}
+		last[k] = result
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -days)
+	candidates := []Result{}
+	recent := 0
+	for _, k := range order {
+		result := last[k]
+		if result.Status != statusSent {
+			continue
+		}
+		if result.Time.After(cutoff) {
+			recent++
+			continue
+		}
+		candidates = append(candidates, result)
+	}
+	if recent > 0 {
+		log.Printf(tr("%d 个收件人发送后还不到 %d 天，这次不发送"), recent, days)
+	}
+	return candidates, nil
+}
+
+// loadEngaged 读取打开过或点击过邮件的收件人，每行取第一个邮件地址，
+// 可以是每行一个地址的文本文件，也可以是打开跟踪、邮件服务商导出的 CSV
+func loadEngaged(files []string) (map[string]bool, error) {
+	engaged := map[string]bool{}
+	for _, file := range files {
+		data, err := readFileContent(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if addr := addressPattern.FindString(line); len(addr) > 0 {
+				engaged[dedupeKey(normalizeAddress(addr))] = true
+			}
+		}
+		logDebug("从 %s 读取了打开过邮件的收件人", file)
+	}
+	return engaged, nil
+}
+
+// selectFollowUpRows 从数据文件中找出原来发送成功、且没有打开过邮件的行
+func selectFollowUpRows(list []*Send, candidates []Result, engaged map[string]bool, salt string) ([]*Send, error) {
+	matched, err := matchReportRows(list, candidates, salt)
+	if err != nil {
+		return nil, err
+	}
+	remaining := []*Send{}
+	for _, s := range matched {
+		if !engaged[dedupeKey(normalizeAddress(s.SendTo))] {
+			remaining = append(remaining, s)
+		}
+	}
+	if len(remaining) == 0 {
+		return nil, fmt.Errorf(trErr("没有需要跟进的收件人"))
+	}
+	log.Printf(tr("报告中有 %d 个可以跟进的收件人，其中 %d 个打开过邮件，跟进其余 %d 个"),
+		len(candidates), len(matched)-len(remaining), len(remaining))
+	return remaining, nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// FooterConfig 为加在每封邮件正文后面的页脚模板文件，html 用于 HTML 邮件，text 用于纯文本邮件
+type FooterConfig struct {
+	HTML string `json:"html"`
+	Text string `json:"text"`
+}
+
+// footerBlock 为编译后的 html / text 模板，页脚和签名都使用
+type footerBlock struct {
+	html renderer
+	text renderer
+}
+
+type footer struct {
+	cfg        *Config
+	block      *footerBlock
+	signatures map[string]*footerBlock
+}
+
+// loadFooter 读取页脚和签名模板，没有配置页脚、签名和退订链接时返回 nil
+func loadFooter(cfg *Config) (*footer, error) {
+	signatures, err := loadSignatures(cfg)
+	if err != nil {
+		return nil, err
+	}
+	f := &footer{cfg: cfg, signatures: signatures}
+	if cfg.Footer != nil {
+		if f.block, err = loadFooterBlock(cfg.Footer); err != nil {
+			return nil, fmt.Errorf(trErr("页脚：%s"), err)
+		}
+	}
+	if f.block == nil && len(f.signatures) == 0 && (cfg.Unsubscribe == nil || len(cfg.Unsubscribe.URL) == 0) {
+		return nil, nil
+	}
+	return f, nil
+}
+
+func loadFooterBlock(c *FooterConfig) (*footerBlock, error) {
+	b := &footerBlock{}
+	for _, v := range []struct {
+		file   string
+		html   bool
+		target *renderer
+	}{
+		{c.HTML, true, &b.html},
+		{c.Text, false, &b.text},
+	} {
+		if len(v.file) == 0 {
+			continue
+		}
+		data, err := readTemplateFile(v.file)
+		if err != nil {
+			return nil, fmt.Errorf(trErr("读取 %s 失败：%s"), v.file, err)
+		}
+		if *v.target, err = compileTemplate("footer", filepath.Dir(v.file), string(data), v.html); err != nil {
+			return nil, fmt.Errorf(trErr("解析 %s 失败：%s"), v.file, err)
+		}
+	}
+	return b, nil
+}
+
+// render 渲染内容类型对应的模板，HTML 邮件没有配置 html 模板时使用转义后的 text 模板
+func (b *footerBlock) render(contentType string, data interface{}) ([]byte, error) {
+	if b == nil {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	if contentType == "text/html" {
+		if b.html != nil {
+			err := b.html(&buf, data)
+			return buf.Bytes(), err
+		}
+		if b.text == nil {
+			return nil, nil
+		}
+		if err := b.text(&buf, data); err != nil {
+			return nil, err
+		}
+		text := html.EscapeString(strings.TrimSpace(buf.String()))
+		return []byte("<p>" + strings.Replace(text, "\n", "<br>\n", -1) + "</p>\n"), nil
+	}
+	if b.text == nil {
+		return nil, nil
+	}
+	err := b.text(&buf, data)
+	return buf.Bytes(), err
+}
+
+// appendTo 把签名、页脚和退订链接依次加在正文后面，HTML 正文有 </body> 时放在 </body> 前面
+func (f *footer) appendTo(contentType string, body []byte, data interface{}) ([]byte, error) {
+	meta, _ := data.(map[string]string)
+	text, err := f.signatures[signatureName(f.signatures, meta)].render(contentType, data)
+	if err != nil {
+		return nil, fmt.Errorf(trErr("渲染签名失败：%s"), err)
+	}
+	footer, err := f.block.render(contentType, data)
+	if err != nil {
+		return nil, fmt.Errorf(trErr("渲染页脚失败：%s"), err)
+	}
+	text = append(text, footer...)
+	text = append(text, unsubscribeText(f.cfg, contentType, body, data)...)
+	if len(text) == 0 {
+		return body, nil
+	}
+	result := make([]byte, 0, len(body)+len(text)+1)
+	if contentType == "text/html" {
+		if i := bytes.LastIndex(bytes.ToLower(body), []byte("</body>")); i >= 0 {
+			result = append(result, body[:i]...)
+			result = append(result, text...)
+			return append(result, body[i:]...), nil
+		}
+	}
+	result = append(result, body...)
+	if len(body) > 0 && body[len(body)-1] != '\n' {
+		result = append(result, '\n')
+	}
+	return append(result, text...), nil
+}
+
+// applyFooter 给模板渲染的正文和 Content 列的正文都加上配置的签名和页脚，配置了退订链接时同时加上退订链接，
+// 返回的列表中去掉了签名不存在的行（--skip-bad-rows）
+func applyFooter(cfg *Config, list []*Send, contentProvider ContentProvider) ([]*Send, ContentProvider, error) {
+	tagUnsubscribe(cfg, list)
+	f, err := loadFooter(cfg)
+	if err != nil || f == nil {
+		return list, contentProvider, err
+	}
+	if list, err = enforceSignatures(list, f.signatures); err != nil {
+		return nil, nil, err
+	}
+
+	for _, s := range list {
+		if s.Content == nil {
+			continue
+		}
+		contentType := rowContentType(s, detectContentType([]byte(*s.Content)))
+		body, err := f.appendTo(contentType, []byte(*s.Content), s.Meta)
+		if err != nil {
+			return nil, nil, fmt.Errorf(trErr("第 %d 行 %s：%s"), s.Row, maskAddress(s.SendTo), redactText(err))
+		}
+		content := string(body)
+		s.Content = &content
+	}
+
+	return list, func(data interface{}) (string, func(w io.Writer) error) {
+		contentType, render := contentProvider(data)
+		return contentType, func(w io.Writer) error {
+			var buf bytes.Buffer
+			if err := render(&buf); err != nil {
+				return err
+			}
+			body, err := f.appendTo(contentType, buf.Bytes(), data)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(body)
+			return err
+		}
+	}, nil
+}
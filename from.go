@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+const segmentColumn = "Segment"
+
+// FromIdentity 为发件人池中的一个发件人，segment 不为空时只用于 Segment 列相同的行
+type FromIdentity struct {
+	From    string `json:"from"`
+	Segment string `json:"segment"`
+}
+
+type fromSelector func(s *Send) string
+
+// getFromSelector 没有配置 from_pool 时总是使用 from，否则按 Segment 列分组后轮流使用池中的发件人
+func getFromSelector(cfg *Config) (fromSelector, error) {
+	if len(cfg.FromPool) == 0 {
+		return func(s *Send) string {
+			return cfg.From
+		}, nil
+	}
+
+	domain := accountDomain(cfg)
+	segments := map[string][]string{}
+	for _, identity := range cfg.FromPool {
+		addr, err := mail.ParseAddress(identity.From)
+		if err != nil {
+			return nil, fmt.Errorf(trErr("无效的发件人 %s：%s"), identity.From, err)
+		}
+		if len(domain) > 0 && !strings.EqualFold(addressDomain(addr.Address), domain) {
+			return nil, fmt.Errorf(trErr("发件人 %s 与登录账号的域名 %s 不一致"), identity.From, domain)
+		}
+		segment := strings.TrimSpace(identity.Segment)
+		segments[segment] = append(segments[segment], identity.From)
+	}
+	if len(segments[""]) == 0 {
+		if len(cfg.From) == 0 {
+			return nil, fmt.Errorf(trErr("from_pool 中没有不限 segment 的发件人，需要配置 from"))
+		}
+		segments[""] = []string{cfg.From}
+	}
+
+	next := map[string]int{}
+	return func(s *Send) string {
+		segment := strings.TrimSpace(s.Meta[segmentColumn])
+		pool, ok := segments[segment]
+		if !ok {
+			segment, pool = "", segments[""]
+		}
+		from := pool[next[segment]%len(pool)]
+		next[segment]++
+		return from
+	}, nil
+}
+
+// accountDomain 返回登录账号的域名，用户名不是邮箱地址时使用 from 的域名
+func accountDomain(cfg *Config) string {
+	if domain := addressDomain(cfg.Username); len(domain) > 0 {
+		return domain
+	}
+	if addr, err := mail.ParseAddress(cfg.From); err == nil {
+		return addressDomain(addr.Address)
+	}
+	return ""
+}
+
+func addressDomain(addr string) string {
+	if at := strings.LastIndex(addr, "@"); at >= 0 {
+		return addr[at+1:]
+	}
+	return ""
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aymerick/raymond"
+	"github.com/tealeg/xlsx"
+)
+
+// templateFuncs 为 go 模板提供的辅助函数
+var templateFuncs = map[string]interface{}{
+	"date":   formatDate,
+	"number": formatNumber,
+	"fixed":  formatFixed,
+	"money":  formatMoney,
+}
+
+// 货币符号和小数位数
+var currencies = map[string]struct {
+	symbol   string
+	decimals int
+}{
+	"CNY": {"¥", 2}, "RMB": {"¥", 2}, "USD": {"$", 2}, "EUR": {"€", 2}, "GBP": {"£", 2},
+	"JPY": {"¥", 0}, "KRW": {"₩", 0}, "HKD": {"HK$", 2}, "TWD": {"NT$", 2}, "SGD": {"S$", 2},
+	"AUD": {"A$", 2}, "CAD": {"C$", 2},
+}
+
+func init() {
+	raymond.RegisterHelper("date", func(val, layout interface{}) string {
+		s, err := formatDate(fmt.Sprint(val), fmt.Sprint(layout))
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return s
+	})
+	raymond.RegisterHelper("number", func(val, decimals interface{}) string {
+		return helperResult(val, func(s string) (string, error) { return formatNumber(s, helperInt(decimals)) })
+	})
+	raymond.RegisterHelper("fixed", func(val, decimals interface{}) string {
+		return helperResult(val, func(s string) (string, error) { return formatFixed(s, helperInt(decimals)) })
+	})
+	raymond.RegisterHelper("money", func(val, currency interface{}) string {
+		return helperResult(val, func(s string) (string, error) { return formatMoney(s, fmt.Sprint(currency)) })
+	})
+}
+
+// handlebars 的 helper 不能返回错误，出错时原样输出
+func helperResult(val interface{}, format func(string) (string, error)) string {
+	s, err := format(fmt.Sprint(val))
+	if err != nil {
+		return fmt.Sprint(val)
+	}
+	return s
+}
+
+func helperInt(val interface{}) int {
+	n, _ := strconv.Atoi(fmt.Sprint(val))
+	return n
+}
+
+// formatDate 把日期文本或 Excel 日期数值按 layout 格式化，例如 {{ date .Expiry "2006年01月02日" }}
+func formatDate(val, layout string) (string, error) {
+	val = strings.TrimSpace(val)
+	if len(val) == 0 {
+		return "", nil
+	}
+	t, _, err := parseSendAt(val, time.Local)
+	if err != nil {
+		return "", fmt.Errorf(trErr("无效的日期: %s"), val)
+	}
+	return t.Format(layout), nil
+}
+
+// xlsxCellValue 日期单元格按单元格格式转换为 2006-01-02、15:04:05 或 2006-01-02 15:04:05，
+// 数值去掉浮点误差
+func xlsxCellValue(cell *xlsx.Cell, date1904 bool) string {
+	if cell.Type() != xlsx.CellTypeNumeric {
+		return cell.Value
+	}
+	if !cell.IsTime() {
+		if f, err := strconv.ParseFloat(cell.Value, 64); err == nil && strings.Contains(cell.Value, ".") {
+			return strconv.FormatFloat(trimFloatError(f), 'f', -1, 64)
+		}
+		return cell.Value
+	}
+	t, err := cell.GetTime(date1904)
+	if err != nil {
+		return cell.Value
+	}
+	t = t.Round(time.Second)
+
+	format := strings.ToLower(cell.GetNumberFormat())
+	// 去掉引号中的文字和 [Red] 之类的颜色，避免误判
+	for _, pair := range [][2]string{{`"`, `"`}, {"[", "]"}} {
+		for {
+			start := strings.Index(format, pair[0])
+			if start < 0 {
+				break
+			}
+			end := strings.Index(format[start+1:], pair[1])
+			if end < 0 {
+				break
+			}
+			format = format[:start] + format[start+1+end+1:]
+		}
+	}
+	hasDate := strings.ContainsAny(format, "yd")
+	hasTime := strings.ContainsAny(format, "hs")
+	switch {
+	case hasTime && !hasDate:
+		return t.Format("15:04:05")
+	case hasTime:
+		return t.Format("2006-01-02 15:04:05")
+	default:
+		return t.Format("2006-01-02")
+	}
+}
+
+func parseNumber(val string) (float64, error) {
+	f, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(val), ",", "", -1), 64)
+	if err != nil {
+		return 0, fmt.Errorf(trErr("无效的数字: %s"), val)
+	}
+	return f, nil
+}
+
+// formatFixed 保留 decimals 位小数，四舍五入，例如 {{ fixed .Rate 1 }}
+func formatFixed(val string, decimals int) (string, error) {
+	if len(strings.TrimSpace(val)) == 0 {
+		return "", nil
+	}
+	f, err := parseNumber(val)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatFloat(roundHalfUp(f, decimals), 'f', decimals, 64), nil
+}
+
+// formatNumber 保留 decimals 位小数并加千分位，例如 {{ number .Amount 2 }} 输出 1,234.56
+func formatNumber(val string, decimals int) (string, error) {
+	s, err := formatFixed(val, decimals)
+	if err != nil || len(s) == 0 {
+		return s, err
+	}
+	return groupThousands(s), nil
+}
+
+// formatMoney 按货币的小数位数加千分位和货币符号，例如 {{ money .Amount "CNY" }} 输出 ¥1,234.56，
+// 不认识的货币代码放在数字前面，例如 CHF 1,234.56
+func formatMoney(val, currency string) (string, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	c, ok := currencies[currency]
+	if !ok {
+		c.symbol, c.decimals = currency+" ", 2
+	}
+	s, err := formatNumber(val, c.decimals)
+	if err != nil || len(s) == 0 {
+		return s, err
+	}
+	if strings.HasPrefix(s, "-") {
+		return "-" + c.symbol + s[1:], nil
+	}
+	return c.symbol + s, nil
+}
+
+func roundHalfUp(f float64, decimals int) float64 {
+	f = trimFloatError(f)
+	pow := math.Pow(10, float64(decimals))
+	return math.Round(f*pow) / pow
+}
+
+// trimFloatError 按 15 位有效数字取整，去掉 1234.5600000000001 这种二进制浮点误差
+func trimFloatError(f float64) float64 {
+	f, _ = strconv.ParseFloat(strconv.FormatFloat(f, 'g', 15, 64), 64)
+	return f
+}
+
+func groupThousands(s string) string {
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	integer, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		integer, fraction = s[:i], s[i:]
+	}
+	var b strings.Builder
+	for i, r := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	return sign + b.String() + fraction
+}
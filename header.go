@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/base64"
+	"mime"
+	"net/mail"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/gomail.v2"
+)
+
+// 单个 encoded-word 最多编码的字节数，编码后约 60 字符，保证 gomail 折行时每个 word 都能放进一行
+const maxEncodedWordBytes = 36
+
+// encodeHeaderText 按 RFC 2047 编码邮件头中的文本。
+// 非 ASCII 内容拆分成多个以空格分隔的 B 编码 encoded-word，拆分时不会切开多字节字符（包括 emoji）。
+// mime 包生成的 encoded-word 最长 75 字符，加上 "Subject: " 后超过一行的长度，
+// gomail 只能在后面的空格处折行，导致长标题在部分服务器上被截断或显示乱码。
+func encodeHeaderText(s string) string {
+	s = sanitizeHeaderText(s)
+	if isASCII(s) {
+		return s
+	}
+
+	words := []string{}
+	start := 0
+	for i, r := range s {
+		if i+utf8.RuneLen(r)-start > maxEncodedWordBytes {
+			words = append(words, encodeWord(s[start:i]))
+			start = i
+		}
+	}
+	words = append(words, encodeWord(s[start:]))
+
+	return strings.Join(words, " ")
+}
+
+func encodeWord(s string) string {
+	return "=?UTF-8?b?" + base64.StdEncoding.EncodeToString([]byte(s)) + "?="
+}
+
+// sanitizeHeaderText 修正无效的 UTF-8，并去掉换行等控制字符，避免邮件头被截断或注入
+func sanitizeHeaderText(s string) string {
+	s = strings.ToValidUTF8(s, "�")
+	s = strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' || r == '\t' {
+			return ' '
+		}
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+	return strings.TrimSpace(s)
+}
+
+func setAddressHeader(m *gomail.Message, field, value string) {
+	addr, err := mail.ParseAddress(value)
+	if err != nil {
+		m.SetHeader(field, value)
+		return
+	}
+	// SetHeader 会把非 ASCII 的地址整体编码，SetAddressHeader 在 name 为空时原样写入
+	m.SetAddressHeader(field, formatAddress(addr), "")
+}
+
+// formatAddress 只编码显示名称，地址部分保持原样，
+// 国际化地址(SMTPUTF8)不能被编码成 encoded-word
+func formatAddress(addr *mail.Address) string {
+	if len(addr.Name) == 0 {
+		return addr.Address
+	}
+	name := sanitizeHeaderText(addr.Name)
+	if isASCII(name) {
+		if strings.ContainsAny(name, "()<>[]:;@\\,.\"") {
+			name = `"` + quoteEscaper.Replace(name) + `"`
+		}
+	} else {
+		name = encodeHeaderText(name)
+	}
+	return name + " <" + addr.Address + ">"
+}
+
+// quoteEscaper 转义 quoted-string 中的 " 和 \
+var quoteEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// fileNameHeaders 为非 ASCII 的附件名同时提供 RFC 2231 和 RFC 2047 两种形式，兼容新旧客户端；
+// 包含 " 或 \ 的 ASCII 文件名在 quoted-string 中转义
+func fileNameHeaders(name string) gomail.FileSetting {
+	h := map[string][]string{}
+	if isASCII(name) && !strings.ContainsAny(name, "\"\\") {
+		return gomail.SetHeader(h)
+	}
+
+	mediaType := mime.TypeByExtension(filepath.Ext(name))
+	if mediaType == "" {
+		mediaType = "application/octet-stream"
+	}
+	encoded := encodeHeaderText(name)
+	if isASCII(name) {
+		encoded = quoteEscaper.Replace(sanitizeHeaderText(name))
+	}
+	extended := "UTF-8''" + strings.ReplaceAll(url.QueryEscape(name), "+", "%20")
+
+	h["Content-Type"] = []string{mediaType + `; name="` + encoded + `"`}
+	h["Content-Disposition"] = []string{`attachment; filename="` + encoded + `"; filename*=` + extended}
+	return gomail.SetHeader(h)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"testing"
+
+	"gopkg.in/gomail.v2"
+)
+
+// TestFileNameHeaders 检查附件名经过编码或转义后，客户端解析出的文件名与原来一致
+func TestFileNameHeaders(t *testing.T) {
+	for _, name := range []string{"report.pdf", `say "hi".pdf`, `a\b.pdf`, "发票 2024.pdf", `"发票".pdf`} {
+		t.Run(name, func(t *testing.T) {
+			m := gomail.NewMessage()
+			m.SetHeader("From", "a@example.com")
+			m.SetBody("text/plain", "hi")
+			attachBytes(m, name, []byte("data"))
+			var buf bytes.Buffer
+			if _, err := m.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			msg, err := mail.ReadMessage(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := multipart.NewReader(msg.Body, params["boundary"])
+			if _, err := r.NextPart(); err != nil {
+				t.Fatal(err)
+			}
+			part, err := r.NextPart()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := part.FileName(); got != name {
+				t.Fatalf("filename = %q, want %q\n%s", got, name, part.Header.Get("Content-Disposition"))
+			}
+			_, params, err = mime.ParseMediaType(part.Header.Get("Content-Type"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := new(mime.WordDecoder).DecodeHeader(params["name"])
+			if err != nil || decoded != name {
+				t.Fatalf("name = %q, %v, want %q", decoded, err, name)
+			}
+		})
+	}
+}
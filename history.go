@@ -0,0 +1,266 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// FrequencyCap 限制同一个收件人在 days 天内最多收到 max 封邮件，所有使用同一个 history 数据库的任务一起计算
+type FrequencyCap struct {
+	Max  int `json:"max"`
+	Days int `json:"days"`
+}
+
+const statusFrequencyCapped = "frequency_capped"
+
+// historySchema 为发送记录数据库的表，recipient 为比较收件人使用的地址，配置了 report_salt 时为 # 加地址的哈希，
+// time 为 Unix 纳秒；frequency_caps 和 history 命令按收件人、任务、状态和时间查询，都有索引
+const historySchema = `
+CREATE TABLE IF NOT EXISTS sends (
+	id INTEGER PRIMARY KEY,
+	time INTEGER NOT NULL,
+	campaign_id TEXT NOT NULL,
+	recipient TEXT NOT NULL,
+	send_to TEXT NOT NULL,
+	send_to_hash TEXT NOT NULL,
+	row INTEGER NOT NULL,
+	subject TEXT NOT NULL,
+	message_id TEXT NOT NULL,
+	status TEXT NOT NULL,
+	error TEXT NOT NULL,
+	seed INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS sends_recipient ON sends (recipient, status, time);
+CREATE INDEX IF NOT EXISTS sends_campaign ON sends (campaign_id, time);
+CREATE INDEX IF NOT EXISTS sends_status ON sends (status, time);
+CREATE INDEX IF NOT EXISTS sends_time ON sends (time);
+`
+
+// sendHistory 为 history 配置的 SQLite 发送记录数据库，每发送一封邮件插入一条记录，
+// 配置了 report_salt 时同样只保存地址的哈希；同一台机器上的多个进程可以同时使用同一个数据库，
+// 检查 frequency_caps 时按收件人的索引查询，其他进程插入的记录也会计算在内
+type sendHistory struct {
+	db   *sql.DB
+	salt string
+	caps []FrequencyCap
+}
+
+var history *sendHistory
+
+// openHistoryDB 打开 file 指定的数据库，不存在时创建；使用 WAL，其他进程正在写入时最多等待 10 秒
+func openHistoryDB(file string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", file+"?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// 同一个进程中的写入排队使用一个连接，不会互相等待锁
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func openSendHistory(cfg *Config) error {
+	if len(cfg.History) == 0 {
+		if len(cfg.FrequencyCaps) > 0 {
+			return errors.New(trErr("配置了 frequency_caps 时必须配置 history"))
+		}
+		return nil
+	}
+	for _, c := range cfg.FrequencyCaps {
+		if c.Max < 1 || c.Days < 1 {
+			return errors.New(trErr("无效的 frequency_caps：max 和 days 必须大于 0"))
+		}
+	}
+	db, err := openHistoryDB(cfg.History)
+	if err != nil {
+		return fmt.Errorf(trErr("打开发送记录失败：%s"), err)
+	}
+	history = &sendHistory{db: db, salt: cfg.ReportSalt, caps: cfg.FrequencyCaps}
+	return nil
+}
+
+func (h *sendHistory) key(addr string) string {
+	if len(h.salt) > 0 {
+		return "#" + addressHash(h.salt, addr)
+	}
+	return dedupeKey(normalizeAddress(addr))
+}
+
+// capped 检查收件人是否已经达到 frequency_caps 中的某个限制，返回的原因用于报告
+func (h *sendHistory) capped(addr string) (bool, string) {
+	if h == nil || len(h.caps) == 0 {
+		return false, ""
+	}
+	now := time.Now()
+	days := 0
+	for _, c := range h.caps {
+		if c.Days > days {
+			days = c.Days
+		}
+	}
+	rows, err := h.db.Query("SELECT time FROM sends WHERE recipient = ? AND time > ? AND status = ? AND seed = 0",
+		h.key(addr), now.AddDate(0, 0, -days).UnixNano(), statusSent)
+	if err != nil {
+		logDebug("读取发送记录失败：%s", err)
+		return false, ""
+	}
+	defer rows.Close()
+	times := []int64{}
+	for rows.Next() {
+		var t int64
+		if err := rows.Scan(&t); err != nil {
+			logDebug("读取发送记录失败：%s", err)
+			return false, ""
+		}
+		times = append(times, t)
+	}
+	if err := rows.Err(); err != nil {
+		logDebug("读取发送记录失败：%s", err)
+	}
+
+	for _, c := range h.caps {
+		cutoff := now.AddDate(0, 0, -c.Days).UnixNano()
+		count := 0
+		for _, t := range times {
+			if t > cutoff {
+				count++
+			}
+		}
+		if count >= c.Max {
+			return true, fmt.Sprintf(trErr("%d 天内已收到 %d 封邮件，达到限制 %d 封"), c.Days, count, c.Max)
+		}
+	}
+	return false, ""
+}
+
+// record 插入一条发送结果，配置了 report_salt 时与报告相同只保存地址的哈希，不保存标题
+func (h *sendHistory) record(result Result) error {
+	if h == nil {
+		return nil
+	}
+	key := h.key(result.SendTo)
+	if len(h.salt) > 0 {
+		result = hashResult(h.salt, result)
+	}
+	seed := 0
+	if result.Seed {
+		seed = 1
+	}
+	_, err := h.db.Exec("INSERT INTO sends (time, campaign_id, recipient, send_to, send_to_hash, row, subject, message_id, status, error, seed) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		result.Time.UnixNano(), result.CampaignID, key, result.SendTo, result.SendToHash, result.Row, result.Subject, result.MessageID, result.Status, result.Error, seed)
+	return err
+}
+
+// historyQuery 为 history 命令的查询条件，字段为空时不限制；
+// recipient 为 history lookup 查询的收件人，记录中的地址已哈希时用 salt 比较
+type historyQuery struct {
+	since     time.Time
+	statuses  map[string]bool
+	campaign  string
+	recipient string
+	salt      string
+}
+
+// parseSince 解析 --since：30d、12h 这样的时长，或者 2006-01-02 格式的日期
+func parseSince(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(s, "d")); err == nil && days >= 0 {
+			return time.Now().AddDate(0, 0, -days), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf(trErr("无效的 --since %s，格式为 30d、12h 或 2006-01-02"), s)
+}
+
+// where 返回查询条件的 SQL 和参数；lookup 同时比较地址和哈希，report_salt 是之后才配置的也能找到之前的记录
+func (q historyQuery) where() (string, []interface{}) {
+	conditions := []string{}
+	args := []interface{}{}
+	if !q.since.IsZero() {
+		conditions = append(conditions, "time >= ?")
+		args = append(args, q.since.UnixNano())
+	}
+	if len(q.statuses) > 0 {
+		marks := []string{}
+		for status := range q.statuses {
+			marks = append(marks, "?")
+			args = append(args, status)
+		}
+		conditions = append(conditions, "status IN ("+strings.Join(marks, ", ")+")")
+	}
+	if len(q.campaign) > 0 {
+		conditions = append(conditions, "campaign_id = ?")
+		args = append(args, q.campaign)
+	}
+	if len(q.recipient) > 0 {
+		args = append(args, dedupeKey(normalizeAddress(q.recipient)))
+		if len(q.salt) > 0 {
+			conditions = append(conditions, "recipient IN (?, ?)")
+			args = append(args, "#"+addressHash(q.salt, q.recipient))
+		} else {
+			conditions = append(conditions, "recipient = ?")
+		}
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// queryHistory 按时间顺序列出符合条件的发送记录，最后汇总各状态的数量
+func queryHistory(db *sql.DB, q historyQuery, w io.Writer) error {
+	where, args := q.where()
+	rows, err := db.Query("SELECT time, campaign_id, send_to, send_to_hash, subject, status, error FROM sends"+where+" ORDER BY time, id", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	byStatus := map[string]int{}
+	total := 0
+	for rows.Next() {
+		var t int64
+		var result Result
+		if err := rows.Scan(&t, &result.CampaignID, &result.SendTo, &result.SendToHash, &result.Subject, &result.Status, &result.Error); err != nil {
+			return err
+		}
+		total++
+		byStatus[result.Status]++
+		line := fmt.Sprintf("%s  %-16s %s", time.Unix(0, t).Format("2006-01-02 15:04"), result.Status, resultAddress(result))
+		if len(result.CampaignID) > 0 {
+			line += "  [" + result.CampaignID + "]"
+		}
+		if len(result.Subject) > 0 {
+			line += "  " + result.Subject
+		}
+		if len(result.Error) > 0 {
+			line += "  " + redactText(result.Error)
+		}
+		fmt.Fprintln(w, line)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, tr("共 %d 条记录\n"), total)
+	for _, status := range sortedKeys(byStatus) {
+		fmt.Fprintf(w, "  %-16s %d\n", status, byStatus[status])
+	}
+	return nil
+}
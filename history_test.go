@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSendHistory(t *testing.T, salt string, caps []FrequencyCap) *sendHistory {
+	db, err := openHistoryDB(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &sendHistory{db: db, salt: salt, caps: caps}
+}
+
+func TestSendHistoryCapped(t *testing.T) {
+	h := testSendHistory(t, "", []FrequencyCap{{Max: 2, Days: 7}, {Max: 3, Days: 30}})
+	now := time.Now()
+	for _, r := range []Result{
+		{SendTo: "User@Example.com", Status: statusSent, Time: now.AddDate(0, 0, -1)},
+		{SendTo: "user@example.com", Status: statusFailed, Time: now.AddDate(0, 0, -1)},
+		{SendTo: "user@example.com", Status: statusSent, Seed: true, Time: now.AddDate(0, 0, -1)},
+		{SendTo: "user@example.com", Status: statusSent, Time: now.AddDate(0, 0, -10)},
+		{SendTo: "user@example.com", Status: statusSent, Time: now.AddDate(0, 0, -40)},
+		{SendTo: "other@example.com", Status: statusSent, Time: now.AddDate(0, 0, -1)},
+	} {
+		if err := h.record(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 失败、seed 和 30 天之前的记录不计算
+	if capped, reason := h.capped("user@example.com"); capped {
+		t.Fatalf("capped after 2 sends in 30 days: %s", reason)
+	}
+	if err := h.record(Result{SendTo: "user@example.com", Status: statusSent, Time: now.AddDate(0, 0, -20)}); err != nil {
+		t.Fatal(err)
+	}
+	if capped, _ := h.capped("USER@example.com"); !capped {
+		t.Fatal("not capped after 3 sends in 30 days")
+	}
+	if capped, _ := h.capped("other@example.com"); capped {
+		t.Fatal("other recipient capped")
+	}
+	if err := h.record(Result{SendTo: "other@example.com", Status: statusSent, Time: now}); err != nil {
+		t.Fatal(err)
+	}
+	if capped, _ := h.capped("other@example.com"); !capped {
+		t.Fatal("not capped after 2 sends in 7 days")
+	}
+
+	// 检查限制时按收件人的索引查询
+	var id, parent, notused int
+	var plan string
+	row := h.db.QueryRow("EXPLAIN QUERY PLAN SELECT time FROM sends WHERE recipient = ? AND time > ? AND status = ? AND seed = 0", "user@example.com", 0, statusSent)
+	if err := row.Scan(&id, &parent, &notused, &plan); err != nil || !strings.Contains(plan, "sends_recipient") {
+		t.Fatalf("query plan = %q, %v", plan, err)
+	}
+}
+
+func TestQueryHistory(t *testing.T) {
+	h := testSendHistory(t, "salt", nil)
+	now := time.Now()
+	for _, r := range []Result{
+		{CampaignID: "spring", SendTo: "a@example.com", Subject: "hi", Status: statusSent, MessageID: "<1@x>", Time: now.Add(-3 * time.Hour)},
+		{CampaignID: "spring", SendTo: "b@example.com", Status: statusFailed, Error: "550 no such user b@example.com", Time: now.Add(-2 * time.Hour)},
+		{CampaignID: "summer", SendTo: "a@example.com", Status: statusFrequencyCapped, Time: now.Add(-time.Hour)},
+		{CampaignID: "summer", SendTo: "a@example.com", Status: statusSent, Time: now.AddDate(0, 0, -40)},
+	} {
+		if err := h.record(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mode string
+	if err := h.db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil || mode != "wal" {
+		t.Fatalf("journal_mode = %q, %v", mode, err)
+	}
+
+	tests := []struct {
+		name  string
+		query historyQuery
+		want  []string
+	}{
+		{"all", historyQuery{}, []string{"[summer]", "[spring]", "[spring]", "[summer]"}},
+		{"since", historyQuery{since: now.AddDate(0, 0, -30)}, []string{"[spring]", "[spring]", "[summer]"}},
+		{"status", historyQuery{statuses: map[string]bool{statusFailed: true, statusFrequencyCapped: true}}, []string{"failed", "frequency_capped"}},
+		{"campaign", historyQuery{campaign: "summer"}, []string{"[summer]", "[summer]"}},
+		{"lookup", historyQuery{recipient: "A@example.com", salt: "salt"}, []string{"[summer]", "[spring]", "[summer]"}},
+		{"lookup without salt", historyQuery{recipient: "a@example.com"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := queryHistory(h.db, tt.query, &out); err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(out.String(), "\n")
+			if len(lines) < len(tt.want)+1 {
+				t.Fatalf("output:\n%s", out.String())
+			}
+			for i, want := range tt.want {
+				if !strings.Contains(lines[i], want) {
+					t.Fatalf("line %d = %q, want %s", i, lines[i], want)
+				}
+				// 配置了 report_salt 时记录中没有地址和标题，错误信息中的地址换成哈希
+				if strings.Contains(lines[i], "example.com") || strings.Contains(lines[i], "hi") {
+					t.Fatalf("line %d = %q has personal data", i, lines[i])
+				}
+			}
+			if want := fmt.Sprintf(tr("共 %d 条记录\n"), len(tt.want)); !strings.HasPrefix(strings.Join(lines[len(tt.want):], "\n"), want) {
+				t.Fatalf("output:\n%s\nwant %q after the records", out.String(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const defaultPreSendHookTimeout = 10
+
+// hookInput 是通过标准输入传给 pre_send_hook 命令的 JSON
+type hookInput struct {
+	CampaignID string            `json:"campaign_id,omitempty"`
+	Row        int               `json:"row"`
+	SendTo     string            `json:"send_to"`
+	From       string            `json:"from"`
+	Subject    string            `json:"subject"`
+	Meta       map[string]string `json:"meta"`
+}
+
+// preSendHook 在发送每封邮件前执行 pre_send_hook 命令，命令以非 0 状态退出、
+// 超时或无法执行时跳过该邮件，返回跳过的原因
+type preSendHook func(s *Send, from, campaignID string) (bool, string)
+
+func getPreSendHook(cfg *Config) preSendHook {
+	if len(cfg.PreSendHook) == 0 {
+		return nil
+	}
+	timeout := time.Duration(cfg.PreSendHookTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultPreSendHookTimeout * time.Second
+	}
+
+	return func(s *Send, from, campaignID string) (bool, string) {
+		input, err := json.Marshal(hookInput{
+			CampaignID: campaignID,
+			Row:        s.Row,
+			SendTo:     s.SendTo,
+			From:       from,
+			Subject:    s.Subject,
+			Meta:       s.Meta,
+		})
+		if err != nil {
+			return false, err.Error()
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		var output bytes.Buffer
+		cmd := exec.CommandContext(ctx, cfg.PreSendHook[0], cfg.PreSendHook[1:]...)
+		cmd.Stdin = bytes.NewReader(input)
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		err = cmd.Run()
+		logDebug("pre_send_hook %s: %v %s", s.SendTo, err, output.String())
+		if err == nil {
+			return true, ""
+		}
+
+		reason := strings.TrimSpace(output.String())
+		if ctx.Err() == context.DeadlineExceeded {
+			reason = fmt.Sprintf(trErr("执行超过 %s"), timeout)
+		} else if len(reason) == 0 {
+			reason = err.Error()
+		}
+		return false, reason
+	}
+}
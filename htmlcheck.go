@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const maxLinkChecks = 8
+
+// 不需要结束标签的元素
+var optionalEndTags = map[atom.Atom]bool{
+	atom.Area: true, atom.Base: true, atom.Br: true, atom.Col: true, atom.Embed: true,
+	atom.Hr: true, atom.Img: true, atom.Input: true, atom.Link: true, atom.Meta: true,
+	atom.Param: true, atom.Source: true, atom.Track: true, atom.Wbr: true,
+	atom.Li: true, atom.P: true, atom.Td: true, atom.Th: true, atom.Tr: true,
+	atom.Option: true, atom.Dt: true, atom.Dd: true,
+	atom.Thead: true, atom.Tbody: true, atom.Tfoot: true,
+}
+
+// checkHTML 检查未闭合或不匹配的标签、没有 alt 的图片，返回问题列表和页面中的链接
+func checkHTML(body []byte) ([]string, []string) {
+	problems := []string{}
+	links := []string{}
+	seen := map[string]bool{}
+	addLink := func(link string) {
+		link = strings.TrimSpace(link)
+		if len(link) > 0 && !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+
+	type openTag struct {
+		name string
+		line int
+	}
+	stack := []openTag{}
+	line := 1
+
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		raw := z.Raw()
+		t := z.Token()
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			var alt *string
+			for i, attr := range t.Attr {
+				switch {
+				case attr.Key == "alt":
+					alt = &t.Attr[i].Val
+				case attr.Key == "href" && (t.DataAtom == atom.A || t.DataAtom == atom.Link),
+					attr.Key == "src" && t.DataAtom == atom.Img,
+					attr.Key == "background":
+					addLink(attr.Val)
+				}
+			}
+			if t.DataAtom == atom.Img && alt == nil {
+				problems = append(problems, fmt.Sprintf(trErr("第 %d 行的图片没有 alt 属性"), line))
+			}
+			if tt == html.StartTagToken && !optionalEndTags[t.DataAtom] {
+				stack = append(stack, openTag{t.Data, line})
+			}
+		case html.EndTagToken:
+			if optionalEndTags[t.DataAtom] {
+				break
+			}
+			i := len(stack) - 1
+			for i >= 0 && stack[i].name != t.Data {
+				i--
+			}
+			if i < 0 {
+				problems = append(problems, fmt.Sprintf(trErr("第 %d 行的 </%s> 没有对应的开始标签"), line, t.Data))
+				break
+			}
+			for _, tag := range stack[i+1:] {
+				problems = append(problems, fmt.Sprintf(trErr("第 %d 行的 <%s> 没有闭合"), tag.line, tag.name))
+			}
+			stack = stack[:i]
+		}
+		line += bytes.Count(raw, []byte("\n"))
+	}
+	for _, tag := range stack {
+		problems = append(problems, fmt.Sprintf(trErr("第 %d 行的 <%s> 没有闭合"), tag.line, tag.name))
+	}
+	return problems, links
+}
+
+// checkLinks 并发检查 http(s) 链接，先用 HEAD，服务器不支持时用 GET，返回失败的链接
+func checkLinks(links []string) []string {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	problems := []string{}
+	limit := make(chan struct{}, maxLinkChecks)
+	client := &http.Client{Timeout: preflightTimeout}
+
+	for _, link := range links {
+		u, err := url.Parse(link)
+		if err != nil {
+			mu.Lock()
+			problems = append(problems, fmt.Sprintf(trErr("无效的链接 %s"), link))
+			mu.Unlock()
+			continue
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			continue
+		}
+		wg.Add(1)
+		go func(link string) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			status, err := checkLink(client, link)
+			if err == nil && status < 400 {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %s", link, err))
+			} else {
+				problems = append(problems, fmt.Sprintf("%s: %d %s", link, status, http.StatusText(status)))
+			}
+		}(link)
+	}
+	wg.Wait()
+	sort.Strings(problems)
+	return problems
+}
+
+func checkLink(client *http.Client, link string) (int, error) {
+	resp, err := client.Head(link)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed && resp.StatusCode != http.StatusNotImplemented {
+		return resp.StatusCode, nil
+	}
+	resp, err = client.Get(link)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1024))
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// uiLang 为提示、日志和使用说明的语言，errorLang 为写入报告、通知和统计数据的错误信息的语言，
+// 监控系统按错误信息匹配告警规则时可以固定 --error-lang，不受操作人员使用的语言影响
+var (
+	uiLang    string
+	errorLang string
+)
+
+// catalogs 按语言保存翻译，键为中文原文，没有翻译的文本使用原文
+var catalogs = map[string]map[string]string{
+	"zh": {},
+	"en": englishMessages,
+}
+
+// tr 返回界面语言的文本
+func tr(text string) string {
+	return translate(uiLang, text)
+}
+
+// trErr 返回错误信息语言的文本
+func trErr(text string) string {
+	return translate(errorLang, text)
+}
+
+func translate(lang, text string) string {
+	if t, ok := catalogs[lang][text]; ok {
+		return t
+	}
+	return text
+}
+
+// defaultLang 按 LC_ALL、LC_MESSAGES、LANG 环境变量选择语言，en 开头时使用英文，其他情况使用中文
+func defaultLang() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(name); len(value) > 0 {
+			if strings.HasPrefix(strings.ToLower(value), "en") {
+				return "en"
+			}
+			return "zh"
+		}
+	}
+	return "zh"
+}
+
+func checkLang(name, lang string) error {
+	if _, ok := catalogs[lang]; !ok {
+		return fmt.Errorf(tr("无效的 %s %s，只能是 zh 或 en"), name, lang)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/gomail.v2"
+)
+
+const inviteTimeLayout = "2006-01-02 15:04"
+
+type Invite struct {
+	Summary     string `json:"summary"`
+	Description string `json:"description"`
+	Location    string `json:"location"`
+	Start       string `json:"start"`
+	End         string `json:"end"`
+	Timezone    string `json:"timezone"`
+	Organizer   string `json:"organizer"`
+}
+
+func getInviteDecorator(cfg *Config) (Decorator, error) {
+	invite := cfg.Invite
+
+	if len(invite.Summary) == 0 || len(invite.Start) == 0 || len(invite.End) == 0 {
+		return nil, errors.New(trErr("日历邀请必须配置 summary, start, end"))
+	}
+
+	loc := time.Local
+	if len(invite.Timezone) > 0 {
+		l, err := time.LoadLocation(invite.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf(trErr("无效的时区 %s：%s"), invite.Timezone, err)
+		}
+		loc = l
+	}
+
+	organizer := invite.Organizer
+	if len(organizer) == 0 {
+		organizer = cfg.From
+	}
+	org, err := mail.ParseAddress(organizer)
+	if err != nil {
+		return nil, fmt.Errorf(trErr("无效的日历邀请组织者 %s：%s"), organizer, err)
+	}
+
+	templates, err := compileInvite(invite)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(m *gomail.Message, send *Send) error {
+		values := map[string]string{}
+		for name, t := range templates {
+			var buf bytes.Buffer
+			if err := t(&buf, send.Meta); err != nil {
+				return fmt.Errorf(trErr("渲染日历邀请 %s 失败：%s"), name, err)
+			}
+			values[name] = buf.String()
+		}
+
+		start, err := time.ParseInLocation(inviteTimeLayout, values["start"], loc)
+		if err != nil {
+			return fmt.Errorf(trErr("无效的日历邀请开始时间 %s"), values["start"])
+		}
+		end, err := time.ParseInLocation(inviteTimeLayout, values["end"], loc)
+		if err != nil {
+			return fmt.Errorf(trErr("无效的日历邀请结束时间 %s"), values["end"])
+		}
+		if !end.After(start) {
+			return errors.New(trErr("日历邀请结束时间必须晚于开始时间"))
+		}
+
+		attendee, err := mail.ParseAddress(send.SendTo)
+		if err != nil {
+			return err
+		}
+
+		uid := fmt.Sprintf("%x@email-sender", sha1.Sum([]byte(attendee.Address+"|"+values["start"]+"|"+values["summary"])))
+
+		var ics bytes.Buffer
+		writeICSLine(&ics, "BEGIN:VCALENDAR")
+		writeICSLine(&ics, "PRODID:-//email-sender//EN")
+		writeICSLine(&ics, "VERSION:2.0")
+		writeICSLine(&ics, "CALSCALE:GREGORIAN")
+		writeICSLine(&ics, "METHOD:REQUEST")
+		writeICSLine(&ics, "BEGIN:VEVENT")
+		writeICSLine(&ics, "UID:"+uid)
+		writeICSLine(&ics, "DTSTAMP:"+icsTime(time.Now()))
+		writeICSLine(&ics, "DTSTART:"+icsTime(start))
+		writeICSLine(&ics, "DTEND:"+icsTime(end))
+		writeICSLine(&ics, "SUMMARY:"+icsText(values["summary"]))
+		if len(values["location"]) > 0 {
+			writeICSLine(&ics, "LOCATION:"+icsText(values["location"]))
+		}
+		if len(values["description"]) > 0 {
+			writeICSLine(&ics, "DESCRIPTION:"+icsText(values["description"]))
+		}
+		writeICSLine(&ics, fmt.Sprintf("ORGANIZER;CN=%s:mailto:%s", icsParam(org.Name, org.Address), org.Address))
+		writeICSLine(&ics, fmt.Sprintf("ATTENDEE;CN=%s;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:%s",
+			icsParam(attendee.Name, attendee.Address), attendee.Address))
+		writeICSLine(&ics, "SEQUENCE:0")
+		writeICSLine(&ics, "STATUS:CONFIRMED")
+		writeICSLine(&ics, "END:VEVENT")
+		writeICSLine(&ics, "END:VCALENDAR")
+
+		data := ics.Bytes()
+		m.AddAlternative("text/calendar; method=REQUEST", string(data))
+		attachBytes(m, "invite.ics", data, gomail.SetHeader(map[string][]string{
+			"Content-Type": {`application/ics; name="invite.ics"`},
+		}))
+		return nil
+	}, nil
+}
+
+// compileInvite 与邮件模板一样按 --engine 和 --on-missing 编译日历邀请的各个字段
+func compileInvite(invite *Invite) (map[string]renderer, error) {
+	fields := map[string]string{
+		"summary":     invite.Summary,
+		"description": invite.Description,
+		"location":    invite.Location,
+		"start":       invite.Start,
+		"end":         invite.End,
+	}
+	templates := map[string]renderer{}
+	for name, text := range fields {
+		t, err := compileTemplate(name, ".", text, false)
+		if err != nil {
+			return nil, fmt.Errorf(trErr("解析日历邀请 %s 失败：%s"), name, err)
+		}
+		templates[name] = t
+	}
+	return templates, nil
+}
+
+func icsTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+func icsText(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, ";", "\\;")
+	s = strings.ReplaceAll(s, ",", "\\,")
+	s = strings.ReplaceAll(s, "\r\n", "\\n")
+	return strings.ReplaceAll(s, "\n", "\\n")
+}
+
+func icsParam(name, fallback string) string {
+	if len(name) == 0 {
+		name = fallback
+	}
+	return `"` + strings.ReplaceAll(name, `"`, "'") + `"`
+}
+
+// writeICSLine 按 RFC 5545 将超过 75 字节的行折叠，不拆分多字节字符
+func writeICSLine(buf *bytes.Buffer, line string) {
+	limit := 75
+	for len(line) > limit {
+		i := limit
+		for i > 0 && !utf8.RuneStart(line[i]) {
+			i--
+		}
+		buf.WriteString(line[:i])
+		buf.WriteString("\r\n ")
+		line = line[i:]
+		limit = 74
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/mail"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// asciiDomainAddress 把地址中的国际化域名转为 punycode（例如 user@例え.jp 转为 user@xn--r8jz45g.jp），
+// local part 保持不变，无法转换时原样返回
+func asciiDomainAddress(addr string) string {
+	at := strings.LastIndex(addr, "@")
+	if at < 0 || isASCII(addr[at+1:]) {
+		return addr
+	}
+	domain, err := idna.Lookup.ToASCII(addr[at+1:])
+	if err != nil {
+		return addr
+	}
+	return addr[:at+1] + domain
+}
+
+// validAddressDomain 检查国际化域名能否转为 punycode
+func validAddressDomain(addr string) bool {
+	at := strings.LastIndex(addr, "@")
+	if at < 0 || isASCII(addr[at+1:]) {
+		return true
+	}
+	_, err := idna.Lookup.ToASCII(addr[at+1:])
+	return err == nil
+}
+
+// utf8Sender 可以判断服务器是否支持 SMTPUTF8 的 Sender
+type utf8Sender interface {
+	supportsSMTPUTF8() bool
+}
+
+// unicodeHeaders 判断邮件头中能否使用 Unicode 域名，服务器不支持 SMTPUTF8 时只能使用 punycode
+func unicodeHeaders(sender interface{}) bool {
+	s, ok := sender.(utf8Sender)
+	return !ok || s.supportsSMTPUTF8()
+}
+
+// headerAddress 返回写入邮件头的地址：不能使用 Unicode 时把 ASCII 地址的域名转为 punycode，
+// local part 不是 ASCII 的地址本来就需要 SMTPUTF8，保持不变
+func headerAddress(value string, unicode bool) string {
+	if unicode {
+		return value
+	}
+	a, err := mail.ParseAddress(value)
+	if err != nil || isASCII(a.Address) {
+		return value
+	}
+	at := strings.LastIndex(a.Address, "@")
+	if at < 0 || !isASCII(a.Address[:at]) {
+		return value
+	}
+	a.Address = asciiDomainAddress(a.Address)
+	if len(a.Name) == 0 {
+		return a.Address
+	}
+	return a.String()
+}
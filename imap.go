@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+type IMAPConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Folder   string `json:"folder"`
+}
+
+// imapClient 只实现了保存已发送邮件需要的 LOGIN / APPEND / LOGOUT
+type imapClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+}
+
+func dialIMAP(cfg *Config) (*imapClient, error) {
+	c := cfg.IMAP
+	if c.Port == 0 {
+		c.Port = 993
+	}
+	if len(c.Username) == 0 {
+		c.Username, c.Password = cfg.Username, cfg.Password
+	}
+	if len(c.Folder) == 0 {
+		c.Folder = "Sent"
+	}
+
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{ServerName: c.Host, InsecureSkipVerify: true}
+	if c.Port == 993 {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client := &imapClient{conn: conn, r: bufio.NewReader(conn)}
+
+	greeting, err := client.readLine()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf(trErr("IMAP 服务器拒绝连接：%s"), greeting)
+	}
+
+	if c.Port != 993 && client.hasCapability("STARTTLS") {
+		if err := client.command("STARTTLS"); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		client.conn, client.r = tlsConn, bufio.NewReader(tlsConn)
+	}
+
+	if err := client.command("LOGIN " + imapQuote(c.Username) + " " + imapQuote(c.Password)); err != nil {
+		client.conn.Close()
+		return nil, fmt.Errorf(trErr("IMAP 登录失败：%s"), err)
+	}
+
+	return client, nil
+}
+
+func (c *imapClient) Append(folder string, msg []byte) error {
+	tag := c.nextTag()
+	if _, err := fmt.Fprintf(c.conn, "%s APPEND %s (\\Seen) {%d}\r\n", tag, imapQuote(imapUTF7(folder)), len(msg)); err != nil {
+		return err
+	}
+
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "+") {
+		return errors.New(line)
+	}
+
+	if _, err := c.conn.Write(msg); err != nil {
+		return err
+	}
+	if _, err := c.conn.Write([]byte("\r\n")); err != nil {
+		return err
+	}
+	return c.waitTagged(tag)
+}
+
+func (c *imapClient) Close() error {
+	c.command("LOGOUT")
+	return c.conn.Close()
+}
+
+func (c *imapClient) hasCapability(name string) bool {
+	tag := c.nextTag()
+	if _, err := fmt.Fprintf(c.conn, "%s CAPABILITY\r\n", tag); err != nil {
+		return false
+	}
+	found := false
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return false
+		}
+		if strings.HasPrefix(line, "* CAPABILITY ") {
+			for _, capability := range strings.Fields(line)[2:] {
+				if strings.EqualFold(capability, name) {
+					found = true
+				}
+			}
+		}
+		if strings.HasPrefix(line, tag+" ") {
+			return found
+		}
+	}
+}
+
+func (c *imapClient) command(cmd string) error {
+	tag := c.nextTag()
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, cmd); err != nil {
+		return err
+	}
+	return c.waitTagged(tag)
+}
+
+func (c *imapClient) waitTagged(tag string) error {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, tag+" ") {
+			continue
+		}
+		status := strings.TrimPrefix(line, tag+" ")
+		if strings.HasPrefix(status, "OK") {
+			return nil
+		}
+		return errors.New(status)
+	}
+}
+
+func (c *imapClient) readLine() (string, error) {
+	c.conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (c *imapClient) nextTag() string {
+	c.tag++
+	return fmt.Sprintf("a%03d", c.tag)
+}
+
+func imapQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// imapUTF7 按 RFC 3501 5.1.3 编码邮箱名称（modified UTF-7），例如 "已发送" -> "&XfJT0ZAB-"
+func imapUTF7(s string) string {
+	var b strings.Builder
+	var pending []rune
+
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		units := utf16.Encode(pending)
+		raw := make([]byte, len(units)*2)
+		for i, u := range units {
+			raw[i*2] = byte(u >> 8)
+			raw[i*2+1] = byte(u)
+		}
+		encoded := base64.RawStdEncoding.EncodeToString(raw)
+		b.WriteString("&" + strings.ReplaceAll(encoded, "/", ",") + "-")
+		pending = nil
+	}
+
+	for _, r := range s {
+		if r >= 0x20 && r <= 0x7e {
+			flush()
+			if r == '&' {
+				b.WriteString("&-")
+			} else {
+				b.WriteRune(r)
+			}
+		} else {
+			pending = append(pending, r)
+		}
+	}
+	flush()
+
+	return b.String()
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// importedMessage 为从 mbox 或 .eml 文件中读取的一封邮件，source 为文件名，mbox 中加上序号
+type importedMessage struct {
+	Source string
+	Data   []byte
+}
+
+// importDropHeaders 为重新发送前去掉的邮件头：Bcc 不能发给其他收件人，其他的是原来投递时加上的，
+// 保留时收件服务器可能认为邮件循环投递
+var importDropHeaders = map[string]bool{
+	"bcc":           true,
+	"return-path":   true,
+	"delivered-to":  true,
+	"x-original-to": true,
+}
+
+// readImport 读取 .eml 目录、单个 .eml 文件或 mbox 文件，以 "From " 开头的文件按 mbox 解析
+func readImport(path string, fn func(m *importedMessage) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		files, err := filepath.Glob(filepath.Join(path, "*.eml"))
+		if err != nil {
+			return err
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			if err := fn(&importedMessage{Source: file, Data: data}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	if head, _ := r.Peek(5); string(head) != "From " {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		return fn(&importedMessage{Source: path, Data: data})
+	}
+	return readMbox(path, r, fn)
+}
+
+// readMbox 按 "From " 开头的分隔行拆分 mbox，去掉 >From 转义中的一个 >，兼容 mboxo 和 mboxrd
+func readMbox(path string, r *bufio.Reader, fn func(m *importedMessage) error) error {
+	var buf bytes.Buffer
+	n := 0
+	flush := func() error {
+		if n == 0 {
+			return nil
+		}
+		// 分隔行之前的空行不属于邮件
+		data := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+		data = bytes.TrimSuffix(data, []byte("\r"))
+		m := &importedMessage{Source: fmt.Sprintf("%s:%d", path, n), Data: append([]byte(nil), data...)}
+		buf.Reset()
+		return fn(m)
+	}
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			switch {
+			case bytes.HasPrefix(line, []byte("From ")):
+				if err := flush(); err != nil {
+					return err
+				}
+				n++
+			case n > 0:
+				if trimmed := bytes.TrimLeft(line, ">"); len(trimmed) < len(line) && bytes.HasPrefix(trimmed, []byte("From ")) {
+					line = line[1:]
+				}
+				buf.Write(line)
+			}
+		}
+		if err == io.EOF {
+			return flush()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// prepareImported 读取邮件头中的收件人（To、Cc、Bcc），去掉 importDropHeaders，换行统一为 CRLF，最后一行也以 CRLF 结束
+func prepareImported(data []byte) ([]byte, []string, *mail.Message, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	to := []string{}
+	seen := map[string]bool{}
+	for _, key := range []string{"To", "Cc", "Bcc"} {
+		list, err := msg.Header.AddressList(key)
+		if err == mail.ErrHeaderNotPresent {
+			continue
+		}
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf(trErr("无法解析 %s 中的地址：%s"), key, err)
+		}
+		for _, addr := range list {
+			if k := strings.ToLower(addr.Address); !seen[k] {
+				seen[k] = true
+				to = append(to, addr.Address)
+			}
+		}
+	}
+	if len(to) == 0 {
+		return nil, nil, nil, errors.New(trErr("邮件中没有收件人"))
+	}
+
+	var out bytes.Buffer
+	header := true
+	drop := false
+	lines := strings.SplitAfter(string(data), "\n")
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r\n")
+		if header {
+			if len(line) == 0 {
+				header = false
+			} else if line[0] == ' ' || line[0] == '\t' {
+				if drop {
+					continue
+				}
+			} else {
+				name := line
+				if i := strings.IndexByte(line, ':'); i >= 0 {
+					name = line[:i]
+				}
+				if drop = importDropHeaders[strings.ToLower(strings.TrimSpace(name))]; drop {
+					continue
+				}
+			}
+		}
+		out.WriteString(line + "\r\n")
+	}
+	return out.Bytes(), to, msg, nil
+}
+
+// importMessages 把 mbox 或 .eml 中的邮件原样发给邮件头中的收件人，信封发件人为配置的 from，没有配置时使用邮件的 From；
+// 返回是否全部成功，配置了 --spool 时失败的邮件保存到 spool 目录，之后可以用 replay 重新发送
+func importMessages(cfg *Config, path string) bool {
+	sender, err := getSender(cfg)
+	if err != nil {
+		log.Printf(tr("创建 Sender 失败：%s"), err)
+		return false
+	}
+	defer func() {
+		if closer, ok := sender.(io.Closer); ok {
+			closer.Close()
+		}
+	}()
+
+	reporter, err := newReporter(reportFile, cfg.ReportSalt)
+	if err != nil {
+		log.Printf(tr("创建报告文件失败：%s"), err)
+		return false
+	}
+	defer reporter.Close()
+
+	var envelopeFrom string
+	if addr, err := mail.ParseAddress(cfg.From); err == nil {
+		envelopeFrom = addr.Address
+	}
+
+	row := 0
+	err = readImport(path, func(m *importedMessage) error {
+		row++
+		if row > 1 && cfg.Interval > 0 {
+			time.Sleep(time.Millisecond * time.Duration(cfg.Interval))
+		}
+		data, to, msg, err := prepareImported(m.Data)
+		if err != nil {
+			log.Printf(tr("跳过 %s：%s"), m.Source, err)
+			reporter.Failed++
+			return nil
+		}
+		from := envelopeFrom
+		if len(from) == 0 {
+			if addr, err := mail.ParseAddress(msg.Header.Get("From")); err == nil {
+				from = addr.Address
+			}
+		}
+		if len(from) == 0 {
+			log.Printf(tr("跳过 %s：%s"), m.Source, trErr("没有发件人地址，请在配置文件中指定 from"))
+			reporter.Failed++
+			return nil
+		}
+
+		subject, err := (&mime.WordDecoder{}).DecodeHeader(msg.Header.Get("Subject"))
+		if err != nil {
+			subject = msg.Header.Get("Subject")
+		}
+		s := &Send{Row: row, SendTo: strings.Join(to, ", "), Subject: subject, MessageID: msg.Header.Get("Message-ID")}
+		err = sender.Send(from, to, bytes.NewBuffer(data))
+		reporter.Add(campaignID, s, err)
+		if err != nil {
+			log.Printf(tr("重新发送失败 %s: %s"), maskAddresses(to), redactText(err))
+			if len(spoolDir) > 0 {
+				spoolImported(s, from, to, data, err)
+			}
+			return nil
+		}
+		logDebug("重新发送成功 %s", m.Source)
+		return nil
+	})
+	if err != nil {
+		log.Printf(tr("读取 %s 失败：%s"), path, err)
+		return false
+	}
+	if row == 0 {
+		log.Printf(tr("%s 中没有需要重新发送的邮件"), path)
+	}
+	log.Printf(tr("重新发送完成，成功 %d 封，失败 %d 封"), reporter.Sent, reporter.Failed)
+	return reporter.Failed == 0
+}
+
+func spoolImported(s *Send, from string, to []string, data []byte, err error) {
+	spoolMu.Lock()
+	defer spoolMu.Unlock()
+	if err := os.MkdirAll(spoolDir, 0700); err != nil {
+		log.Printf(tr("保存失败的邮件失败：%s"), err)
+		return
+	}
+	name := fmt.Sprintf("%s-%05d-%s", time.Now().Format("20060102-150405.000"), s.Row, unsafeFileChars.ReplaceAllString(to[0], "_"))
+	msg := SpooledMessage{
+		CampaignID: campaignID,
+		Row:        s.Row,
+		SendTo:     s.SendTo,
+		Subject:    s.Subject,
+		From:       from,
+		To:         to,
+		Error:      err.Error(),
+		Attempts:   1,
+		Time:       time.Now(),
+	}
+	if err := writeSpooled(filepath.Join(spoolDir, name), data, &msg); err != nil {
+		log.Printf(tr("保存失败的邮件失败：%s"), err)
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"net"
+	"time"
+)
+
+// kafkaConn 只实现了 queue 命令用到的几个 Kafka 协议请求，使用 Kafka 0.11 之后的 broker 都支持的版本；
+// 配置了用户名时用 SASL PLAIN 认证
+type kafkaConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	corr int32
+}
+
+const (
+	kafkaProduce          = 0
+	kafkaFetch            = 1
+	kafkaListOffsets      = 2
+	kafkaMetadata         = 3
+	kafkaOffsetCommit     = 8
+	kafkaOffsetFetch      = 9
+	kafkaFindCoordinator  = 10
+	kafkaSaslHandshake    = 17
+	kafkaSaslAuthenticate = 36
+)
+
+func errKafkaFormat() error {
+	return errors.New(trErr("Kafka 应答格式错误"))
+}
+
+func dialKafka(addr string, useTLS bool, user, password string) (*kafkaConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if useTLS {
+		host, _, _ := net.SplitHostPort(addr)
+		conn = tls.Client(conn, &tls.Config{ServerName: host})
+	}
+	c := &kafkaConn{conn: conn, r: bufio.NewReader(conn)}
+	if len(user) > 0 {
+		if err := c.authenticate(user, password); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf(trErr("Kafka 认证失败：%s"), err)
+		}
+	}
+	return c, nil
+}
+
+func (c *kafkaConn) authenticate(user, password string) error {
+	var w kafkaWriter
+	w.putString("PLAIN")
+	r, err := c.call(kafkaSaslHandshake, 1, w.Bytes(), 0)
+	if err != nil {
+		return err
+	}
+	if code := r.int16(); code != 0 {
+		return kafkaError(code)
+	}
+
+	w.Reset()
+	w.putBytes([]byte("\x00" + user + "\x00" + password))
+	if r, err = c.call(kafkaSaslAuthenticate, 0, w.Bytes(), 0); err != nil {
+		return err
+	}
+	if code := r.int16(); code != 0 {
+		if message := r.str(); len(message) > 0 {
+			return errors.New(message)
+		}
+		return kafkaError(code)
+	}
+	return r.err
+}
+
+// call 发送一个请求并返回应答中 correlation id 之后的部分，wait 为 broker 最多等待的时间
+func (c *kafkaConn) call(key, version int16, body []byte, wait time.Duration) (*kafkaReader, error) {
+	c.corr++
+	var w kafkaWriter
+	w.putInt32(0)
+	w.putInt16(key)
+	w.putInt16(version)
+	w.putInt32(c.corr)
+	w.putString("email-sender")
+	w.Write(body)
+	data := w.Bytes()
+	binary.BigEndian.PutUint32(data, uint32(len(data)-4))
+
+	c.conn.SetDeadline(time.Now().Add(wait + 30*time.Second))
+	if _, err := c.conn.Write(data); err != nil {
+		return nil, err
+	}
+	var size int32
+	if err := binary.Read(c.r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	if size < 4 {
+		return nil, errKafkaFormat()
+	}
+	resp := make([]byte, size)
+	if _, err := io.ReadFull(c.r, resp); err != nil {
+		return nil, err
+	}
+	r := &kafkaReader{data: resp}
+	if r.int32() != c.corr {
+		return nil, errKafkaFormat()
+	}
+	return r, nil
+}
+
+func (c *kafkaConn) Close() error {
+	return c.conn.Close()
+}
+
+// kafkaError 为 broker 返回的错误码
+type kafkaError int16
+
+func (e kafkaError) Error() string {
+	return fmt.Sprintf(trErr("Kafka 错误码 %d"), int16(e))
+}
+
+const (
+	kafkaOffsetOutOfRange = 1
+	kafkaNotLeader        = 6
+)
+
+type kafkaWriter struct {
+	bytes.Buffer
+}
+
+func (w *kafkaWriter) putInt8(v int8) {
+	w.WriteByte(byte(v))
+}
+
+func (w *kafkaWriter) putInt16(v int16) {
+	binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *kafkaWriter) putInt32(v int32) {
+	binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *kafkaWriter) putInt64(v int64) {
+	binary.Write(w, binary.BigEndian, v)
+}
+
+func (w *kafkaWriter) putString(s string) {
+	w.putInt16(int16(len(s)))
+	w.WriteString(s)
+}
+
+func (w *kafkaWriter) putBytes(b []byte) {
+	w.putInt32(int32(len(b)))
+	w.Write(b)
+}
+
+func (w *kafkaWriter) putVarint(v int64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	w.Write(buf[:binary.PutVarint(buf, v)])
+}
+
+// kafkaReader 按顺序读取应答中的字段，数据不够时记录错误，之后读取的字段都为零值
+type kafkaReader struct {
+	data []byte
+	err  error
+}
+
+func (r *kafkaReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data) {
+		r.err = errKafkaFormat()
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *kafkaReader) int8() int8 {
+	if b := r.next(1); len(b) == 1 {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (r *kafkaReader) int16() int16 {
+	if b := r.next(2); len(b) == 2 {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (r *kafkaReader) int32() int32 {
+	if b := r.next(4); len(b) == 4 {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (r *kafkaReader) int64() int64 {
+	if b := r.next(8); len(b) == 8 {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (r *kafkaReader) str() string {
+	n := r.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(r.next(int(n)))
+}
+
+func (r *kafkaReader) blob() []byte {
+	n := r.int32()
+	if n < 0 {
+		return nil
+	}
+	return r.next(int(n))
+}
+
+func (r *kafkaReader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.err = errKafkaFormat()
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+// kafkaRecord 为消息中的一条记录
+type kafkaRecord struct {
+	Offset int64
+	Value  []byte
+}
+
+var kafkaCRC = crc32.MakeTable(crc32.Castagnoli)
+
+// parseKafkaRecords 解析 Fetch 应答中的 record batch（magic 2），最后一个不完整的 batch 留到下次读取，
+// 只支持不压缩和 gzip 压缩
+func parseKafkaRecords(data []byte) ([]kafkaRecord, error) {
+	records := []kafkaRecord{}
+	for len(data) >= 12 {
+		base := int64(binary.BigEndian.Uint64(data))
+		length := int(int32(binary.BigEndian.Uint32(data[8:])))
+		if length < 0 || 12+length > len(data) {
+			break
+		}
+		batch := &kafkaReader{data: data[12 : 12+length]}
+		data = data[12+length:]
+
+		batch.int32() // partition leader epoch
+		if magic := batch.int8(); magic != 2 {
+			return records, fmt.Errorf(trErr("不支持的 Kafka 消息格式 %d"), magic)
+		}
+		batch.int32() // crc
+		attributes := batch.int16()
+		batch.next(4 + 8 + 8 + 8 + 2 + 4)
+		count := batch.int32()
+		if batch.err != nil {
+			return records, batch.err
+		}
+		// 事务的控制消息
+		if attributes&0x20 != 0 {
+			continue
+		}
+		switch attributes & 7 {
+		case 0:
+		case 1:
+			z, err := gzip.NewReader(bytes.NewReader(batch.data))
+			if err != nil {
+				return records, err
+			}
+			if batch.data, err = ioutil.ReadAll(z); err != nil {
+				return records, err
+			}
+		default:
+			return records, fmt.Errorf(trErr("不支持的 Kafka 压缩格式 %d，只支持 gzip"), attributes&7)
+		}
+
+		for i := int32(0); i < count; i++ {
+			rec := &kafkaReader{data: batch.next(int(batch.varint()))}
+			rec.int8()   // attributes
+			rec.varint() // timestamp delta
+			offset := base + rec.varint()
+			if n := rec.varint(); n > 0 {
+				rec.next(int(n)) // key
+			}
+			var value []byte
+			if n := rec.varint(); n > 0 {
+				value = rec.next(int(n))
+			}
+			if batch.err != nil || rec.err != nil {
+				return records, errKafkaFormat()
+			}
+			records = append(records, kafkaRecord{Offset: offset, Value: value})
+		}
+	}
+	return records, nil
+}
+
+// kafkaRecordBatch 把一条消息编码为 Produce 请求使用的 record batch
+func kafkaRecordBatch(value []byte) []byte {
+	var rec kafkaWriter
+	rec.putInt8(0)
+	rec.putVarint(0)  // timestamp delta
+	rec.putVarint(0)  // offset delta
+	rec.putVarint(-1) // key
+	rec.putVarint(int64(len(value)))
+	rec.Write(value)
+	rec.putVarint(0) // headers
+
+	ms := time.Now().UnixNano() / int64(time.Millisecond)
+	var body kafkaWriter
+	body.putInt16(0) // attributes
+	body.putInt32(0) // last offset delta
+	body.putInt64(ms)
+	body.putInt64(ms)
+	body.putInt64(-1) // producer id
+	body.putInt16(-1) // producer epoch
+	body.putInt32(-1) // base sequence
+	body.putInt32(1)
+	body.putVarint(int64(rec.Len()))
+	body.Write(rec.Bytes())
+
+	var batch kafkaWriter
+	batch.putInt64(0)
+	batch.putInt32(int32(4 + 1 + 4 + body.Len()))
+	batch.putInt32(-1) // partition leader epoch
+	batch.putInt8(2)
+	batch.putInt32(int32(crc32.Checksum(body.Bytes(), kafkaCRC)))
+	batch.Write(body.Bytes())
+	return batch.Bytes()
+}
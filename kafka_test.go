@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"hash/crc32"
+	"reflect"
+	"testing"
+)
+
+// testKafkaBatch 编码一个 record batch，记录的 offset 从 base 开始，attributes 的低 3 位为 1 时用 gzip 压缩记录
+func testKafkaBatch(t *testing.T, base int64, attributes int16, values ...string) []byte {
+	var records kafkaWriter
+	for i, v := range values {
+		var rec kafkaWriter
+		rec.putInt8(0)
+		rec.putVarint(0)
+		rec.putVarint(int64(i))
+		rec.putVarint(-1)
+		rec.putVarint(int64(len(v)))
+		rec.WriteString(v)
+		rec.putVarint(0)
+		records.putVarint(int64(rec.Len()))
+		records.Write(rec.Bytes())
+	}
+	data := records.Bytes()
+	if attributes&7 == 1 {
+		var buf bytes.Buffer
+		z := gzip.NewWriter(&buf)
+		if _, err := z.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := z.Close(); err != nil {
+			t.Fatal(err)
+		}
+		data = buf.Bytes()
+	}
+
+	var body kafkaWriter
+	body.putInt16(attributes)
+	body.putInt32(int32(len(values) - 1))
+	body.putInt64(0)
+	body.putInt64(0)
+	body.putInt64(-1)
+	body.putInt16(-1)
+	body.putInt32(-1)
+	body.putInt32(int32(len(values)))
+	body.Write(data)
+
+	var batch kafkaWriter
+	batch.putInt64(base)
+	batch.putInt32(int32(4 + 1 + 4 + body.Len()))
+	batch.putInt32(-1)
+	batch.putInt8(2)
+	batch.putInt32(int32(crc32.Checksum(body.Bytes(), kafkaCRC)))
+	batch.Write(body.Bytes())
+	return batch.Bytes()
+}
+
+func TestKafkaRecordBatch(t *testing.T) {
+	batch := kafkaRecordBatch([]byte(`{"to":"user@example.com"}`))
+	if length := int(binary.BigEndian.Uint32(batch[8:])); length != len(batch)-12 {
+		t.Fatalf("batch length = %d, want %d", length, len(batch)-12)
+	}
+	if got, want := binary.BigEndian.Uint32(batch[17:]), crc32.Checksum(batch[21:], kafkaCRC); got != want {
+		t.Fatalf("crc = %08x, want %08x", got, want)
+	}
+
+	records, err := parseKafkaRecords(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []kafkaRecord{{Offset: 0, Value: []byte(`{"to":"user@example.com"}`)}}
+	if !reflect.DeepEqual(records, want) {
+		t.Fatalf("records = %+v, want %+v", records, want)
+	}
+}
+
+func TestParseKafkaRecords(t *testing.T) {
+	join := func(batches ...[]byte) []byte {
+		return bytes.Join(batches, nil)
+	}
+	full := join(testKafkaBatch(t, 10, 0, "a", "b"), testKafkaBatch(t, 12, 0, "c"))
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []kafkaRecord
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			data: nil,
+			want: []kafkaRecord{},
+		},
+		{
+			name: "two batches",
+			data: full,
+			want: []kafkaRecord{{10, []byte("a")}, {11, []byte("b")}, {12, []byte("c")}},
+		},
+		{
+			// 最后一个 batch 不完整时留到下次读取
+			name: "truncated last batch",
+			data: full[:len(full)-1],
+			want: []kafkaRecord{{10, []byte("a")}, {11, []byte("b")}},
+		},
+		{
+			name: "truncated header",
+			data: full[:8],
+			want: []kafkaRecord{},
+		},
+		{
+			name: "gzip",
+			data: join(testKafkaBatch(t, 5, 1, "x", "y"), testKafkaBatch(t, 7, 0, "z")),
+			want: []kafkaRecord{{5, []byte("x")}, {6, []byte("y")}, {7, []byte("z")}},
+		},
+		{
+			name: "control batch",
+			data: join(testKafkaBatch(t, 3, 0x20, "commit"), testKafkaBatch(t, 4, 0, "d")),
+			want: []kafkaRecord{{4, []byte("d")}},
+		},
+		{
+			name:    "snappy",
+			data:    join(testKafkaBatch(t, 1, 0, "a"), testKafkaBatch(t, 2, 2, "b")),
+			want:    []kafkaRecord{{1, []byte("a")}},
+			wantErr: true,
+		},
+		{
+			name: "old magic",
+			data: func() []byte {
+				b := testKafkaBatch(t, 0, 0, "a")
+				b[16] = 1
+				return b
+			}(),
+			want:    []kafkaRecord{},
+			wantErr: true,
+		},
+		{
+			name: "record count too large",
+			data: func() []byte {
+				b := testKafkaBatch(t, 0, 0, "a")
+				binary.BigEndian.PutUint32(b[len(b)-len("a")-11:], 2)
+				return b
+			}(),
+			want:    []kafkaRecord{{0, []byte("a")}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := parseKafkaRecords(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(records, tt.want) {
+				t.Fatalf("records = %+v, want %+v", records, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultLangPattern = "{dir}/{name}.{lang}{ext}"
+	langColumn         = "Lang"
+)
+
+// langValue 为 Lang 列允许的值，例如 en、zh-CN、pt_BR；其他字符（/、.. 等）可能让模板路径指向其他目录
+var langValue = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func getLangContentProvider(cfg *Config, fallback ContentProvider, content, template string, list []*Send) (ContentProvider, error) {
+	file := template
+	if len(content) > 0 {
+		file = content
+	}
+
+	providers := map[string]ContentProvider{}
+
+	for _, s := range list {
+		lang := strings.TrimSpace(s.Meta[langColumn])
+		if _, ok := providers[lang]; ok || len(lang) == 0 {
+			continue
+		}
+
+		if !langValue.MatchString(lang) {
+			log.Printf(tr("无效的语言 %q，使用默认模板"), lang)
+			providers[lang] = fallback
+			continue
+		}
+
+		variant := langVariant(file, lang)
+		if _, err := os.Stat(variant); err != nil {
+			logDebug("%s 语言的模板 %s 不存在，使用默认模板", lang, variant)
+			providers[lang] = fallback
+			continue
+		}
+
+		var provider ContentProvider
+		var err error
+		if len(content) > 0 {
+			provider, err = getContentProvider(cfg, variant, "")
+		} else {
+			provider, err = getContentProvider(cfg, "", variant)
+		}
+		if err != nil {
+			return nil, fmt.Errorf(trErr("加载 %s 语言的模板失败：%s"), lang, err)
+		}
+		providers[lang] = provider
+	}
+
+	if len(providers) == 0 {
+		return fallback, nil
+	}
+
+	return func(data interface{}) (string, func(writer io.Writer) error) {
+		if meta, ok := data.(map[string]string); ok {
+			if provider, ok := providers[strings.TrimSpace(meta[langColumn])]; ok {
+				return provider(data)
+			}
+		}
+		return fallback(data)
+	}, nil
+}
+
+func langVariant(file, lang string) string {
+	ext := filepath.Ext(file)
+	name := strings.TrimSuffix(filepath.Base(file), ext)
+
+	r := strings.NewReplacer(
+		"{dir}", filepath.Dir(file),
+		"{name}", name,
+		"{lang}", lang,
+		"{ext}", ext,
+	)
+	return filepath.Clean(r.Replace(langPattern))
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"text/template/parse"
+)
+
+// mustache / handlebars / pongo2 模板中引用的变量，跳过 if、each 等关键字和以 . 开头的上下文
+var (
+	handlebarsFieldPattern = regexp.MustCompile(`{{[{#^/&]?\s*(?:(?:if|unless|with|each)\s+)?([A-Za-z_]\w*)`)
+	pongo2FieldPattern     = regexp.MustCompile(`(?:{{-?|{%-?\s*(?:if|elif|for\s+\w+\s+in|with))\s*([A-Za-z_]\w*)`)
+	templateKeywords       = map[string]bool{"else": true, "end": true, "this": true, "true": true, "false": true, "not": true, "endif": true, "none": true, "None": true,
+		"if": true, "unless": true, "with": true, "each": true, "elif": true, "for": true, "endfor": true}
+)
+
+// 在程序中使用而不一定会在模板中引用的列
+var reservedColumns = map[string]bool{
+	"SendTo": true, "Subject": true, "Content": true,
+	contentTypeColumn: true, sendAtColumn: true, timezoneColumn: true, langColumn: true,
+	segmentColumn: true, attachmentsColumn: true, campaignIDColumn: true, signatureColumn: true, ownerColumn: true,
+	inReplyToColumn: true, referencesColumn: true,
+}
+
+// goTemplateFields 遍历 Go 模板的语法树，返回引用的 .Xxx 和 index . "Xxx"，range / with 内部的 . 不是行数据，不计入
+func goTemplateFields(text string) ([]string, error) {
+	t, err := texttemplate.New("lint").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	fields := []string{}
+	var walk func(node parse.Node, top bool)
+	walk = func(node parse.Node, top bool) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child, top)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe, top)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd, top)
+			}
+		case *parse.CommandNode:
+			if id, ok := n.Args[0].(*parse.IdentifierNode); ok && id.Ident == "index" && len(n.Args) >= 3 && top {
+				if _, ok := n.Args[1].(*parse.DotNode); ok {
+					if s, ok := n.Args[2].(*parse.StringNode); ok {
+						fields = append(fields, s.Text)
+					}
+				}
+			}
+			for _, arg := range n.Args {
+				walk(arg, top)
+			}
+		case *parse.FieldNode:
+			if top {
+				fields = append(fields, n.Ident[0])
+			}
+		case *parse.VariableNode:
+			// $.Xxx 始终指向行数据
+			if n.Ident[0] == "$" && len(n.Ident) > 1 {
+				fields = append(fields, n.Ident[1])
+			}
+		case *parse.IfNode:
+			walk(n.Pipe, top)
+			walk(n.List, top)
+			walk(n.ElseList, top)
+		case *parse.RangeNode:
+			walk(n.Pipe, top)
+			walk(n.List, false)
+			walk(n.ElseList, top)
+		case *parse.WithNode:
+			walk(n.Pipe, top)
+			walk(n.List, false)
+			walk(n.ElseList, top)
+		case *parse.TemplateNode:
+			walk(n.Pipe, top)
+		}
+	}
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree != nil {
+			walk(tmpl.Tree.Root, true)
+		}
+	}
+	return fields, nil
+}
+
+// templateFields 按 --engine 返回模板中引用的字段，go 以外的引擎用正则表达式查找，只是近似结果
+func templateFields(text string, goTemplate bool) ([]string, error) {
+	pattern := handlebarsFieldPattern
+	switch {
+	case goTemplate || engine == "" || engine == engineGo:
+		return goTemplateFields(text)
+	case engine == enginePongo2:
+		pattern = pongo2FieldPattern
+	}
+	fields := []string{}
+	for _, match := range pattern.FindAllStringSubmatch(text, -1) {
+		if _, ok := templateFuncs[match[1]]; !ok && !templateKeywords[match[1]] {
+			fields = append(fields, match[1])
+		}
+	}
+	return fields, nil
+}
+
+type lintSource struct {
+	name       string
+	text       string
+	goTemplate bool
+}
+
+// lint 检查模板中引用的字段与数据文件的列是否一致，有引用了不存在的列时返回 false
+func lint(cfg *Config, file, template string, out io.Writer) bool {
+	rows, err := readRows(file)
+	if err != nil {
+		fmt.Fprintf(out, tr("处理 Excel 文件失败：%s\n"), err)
+		return false
+	}
+	if len(rows) == 0 {
+		fmt.Fprintln(out, tr("空表格"))
+		return false
+	}
+	header := []string{}
+	if detectHeaderRow(rows[0]) {
+		for _, cell := range rows[0] {
+			header = append(header, strings.TrimSpace(cell))
+		}
+	} else {
+		fmt.Fprintln(out, tr("数据文件没有表头，只有 SendTo、Subject、Content 三列"))
+	}
+
+	available := map[string]bool{}
+	for _, column := range header {
+		if column != "SendTo" && column != "Subject" && column != "Content" {
+			available[column] = true
+		}
+	}
+	for column := range cfg.Defaults {
+		available[column] = true
+	}
+	if len(campaignID) > 0 {
+		available[campaignIDColumn] = true
+	}
+
+	sources := []lintSource{}
+	addFile := func(name string, goTemplate bool) error {
+		if len(name) == 0 {
+			return nil
+		}
+		data, err := readTemplateFile(name)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, lintSource{name, string(data), goTemplate})
+		return nil
+	}
+	for _, f := range []struct {
+		name       string
+		goTemplate bool
+	}{{template, false}, {pdfTemplate, true}, {vcard, true}} {
+		if err := addFile(f.name, f.goTemplate); err != nil {
+			fmt.Fprintf(out, tr("读取模板 %s 失败：%s\n"), f.name, err)
+			return false
+		}
+	}
+	if len(subject) > 0 {
+		sources = append(sources, lintSource{"--subject", subject, false})
+	}
+	if len(sources) == 0 {
+		fmt.Fprintln(out, tr("没有需要检查的模板，请用 --template 指定"))
+		return false
+	}
+
+	ok := true
+	used := map[string]bool{}
+	for _, source := range sources {
+		fields, err := templateFields(source.text, source.goTemplate)
+		if err != nil {
+			fmt.Fprintf(out, tr("%s: 解析模板失败：%s\n"), source.name, err)
+			ok = false
+			continue
+		}
+		reported := map[string]bool{}
+		for _, field := range fields {
+			used[field] = true
+			if available[field] || reported[field] {
+				continue
+			}
+			reported[field] = true
+			ok = false
+			problem := fmt.Sprintf(tr("%s: 引用的 %s 没有对应的列，会渲染为空或 <no value>"), source.name, field)
+			switch field {
+			case "SendTo", "Subject", "Content":
+				problem = fmt.Sprintf(tr("%s: %s 列不会传给模板"), source.name, field)
+			default:
+				if similar := similarColumn(field, header); len(similar) > 0 {
+					problem += fmt.Sprintf(tr("，是否是 %s？"), similar)
+				}
+			}
+			fmt.Fprintln(out, problem)
+		}
+	}
+
+	unused := []string{}
+	for _, column := range header {
+		if !used[column] && !reservedColumns[column] && len(column) > 0 {
+			unused = append(unused, column)
+		}
+	}
+	sort.Strings(unused)
+	if len(unused) > 0 {
+		fmt.Fprintf(out, tr("模板中没有使用的列：%s\n"), strings.Join(unused, ", "))
+	}
+	if ok {
+		fmt.Fprintln(out, tr("模板中引用的字段都有对应的列"))
+	}
+	return ok
+}
+
+// similarColumn 返回与 field 只有大小写不同或编辑距离不超过 2 的列
+func similarColumn(field string, header []string) string {
+	best, bestDistance := "", 3
+	for _, column := range header {
+		if strings.EqualFold(column, field) {
+			return column
+		}
+		if d := editDistance(strings.ToLower(column), strings.ToLower(field)); d < bestDistance {
+			best, bestDistance = column, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = cur[j-1] + 1
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if prev[j-1]+cost < cur[j] {
+				cur[j] = prev[j-1] + cost
+			}
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/extrame/xls"
+	"github.com/tealeg/xlsx"
+)
+
+// readRows 读取数据文件中第一个工作表的所有行，按扩展名选择格式
+func readRows(file string) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv":
+		return readCSVRows(file)
+	case ".xls":
+		return readXLSRows(file)
+	case ".ods":
+		return readODSRows(file)
+	case ".vcf":
+		return readVCFRows(file)
+	default:
+		return readXLSXRows(file)
+	}
+}
+
+func readXLSXRows(file string) ([][]string, error) {
+	excel, err := xlsx.OpenFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(excel.Sheets) == 0 {
+		return nil, nil
+	}
+
+	rows := [][]string{}
+	for _, row := range excel.Sheets[0].Rows {
+		cells := make([]string, len(row.Cells))
+		for i, cell := range row.Cells {
+			cells[i] = xlsxCellValue(cell, excel.Date1904)
+		}
+		rows = append(rows, cells)
+	}
+	return rows, nil
+}
+
+// readXLSRows 读取 Excel 97-2003 (BIFF) 格式的文件
+func readXLSRows(file string) (rows [][]string, err error) {
+	// 格式有问题的文件可能让 xls 解析时 panic
+	defer func() {
+		if r := recover(); r != nil {
+			rows, err = nil, fmt.Errorf(trErr("无法解析 xls 文件：%v"), r)
+		}
+	}()
+
+	excel, closer, err := xls.OpenWithCloser(file, "utf-8")
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	sheet := excel.GetSheet(0)
+	if sheet == nil {
+		return nil, nil
+	}
+
+	rows = [][]string{}
+	for i := 0; i <= int(sheet.MaxRow); i++ {
+		row := sheet.Row(i)
+		if row == nil {
+			rows = append(rows, []string{})
+			continue
+		}
+		// LastCol 为最后一列的下一列
+		cells := make([]string, row.LastCol())
+		for j := range cells {
+			cells[j] = row.ColExact(j)
+		}
+		rows = append(rows, cells)
+	}
+	return rows, nil
+}
+
+func readCSVRows(file string) ([][]string, error) {
+	data, err := readFileContent(file)
+	if err != nil {
+		return nil, err
+	}
+	// Excel 导出的 UTF-8 CSV 带有 BOM
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	return r.ReadAll()
+}
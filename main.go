@@ -2,40 +2,84 @@ package main
 
 import (
 	"bytes"
-	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
-	gotempalte "html/template"
 	"io"
 	"log"
 	"net/mail"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/tealeg/xlsx"
 	"gopkg.in/gomail.v2"
 )
 
 type ContentProvider func(data interface{}) (string, func(writer io.Writer) error)
 
+type Decorator func(m *gomail.Message, send *Send) error
+
 type Send struct {
+	Row int
 	SendTo string
 	Subject string
 	Content *string
+	SendAt time.Time
 	Meta map[string]string
+	// Seed 为插入的监控收件人
+	Seed bool
+	// MessageID 为发送时生成的 Message-ID，写入报告，跟进邮件用 --thread-report 引用
+	MessageID string
 }
 
 type Config struct {
 	Host string `json:"host"`
 	Port int `json:"port"`
+	LocalAddr string `json:"local_addr"`
+	LocalName string `json:"local_name"`
+	Pool *PoolConfig `json:"pool"`
+	Notify []*NotifyConfig `json:"notify"`
 	Username string `json:"username"`
 	Password string `json:"password"`
 	From string `json:"from"`
+	FromPool []FromIdentity `json:"from_pool"`
 	Interval int64 `json:"interval"`
 	Sender string `json:"sender"`
+	PdfConverter []string `json:"pdf_converter"`
+	Invite *Invite `json:"invite"`
+	IMAP *IMAPConfig `json:"imap"`
+	DSN *DSNConfig `json:"dsn"`
+	VERP string `json:"verp"`
+	BccArchive string `json:"bcc_archive"`
+	WarmupSchedule []int `json:"warmup_schedule"`
+	GreylistDelay int64 `json:"greylist_delay"`
+	GreylistRetries int `json:"greylist_retries"`
+	Throttle *ThrottleConfig `json:"throttle"`
+	PreSendHook []string `json:"pre_send_hook"`
+	PreSendHookTimeout int64 `json:"pre_send_hook_timeout"`
+	SpamCheck *SpamCheckConfig `json:"spam_check"`
+	ImageUpload *ImageUploadConfig `json:"image_upload"`
+	MaxMessageSize int64 `json:"max_message_size"`
+	ZipAttachments *ZipConfig `json:"zip_attachments"`
+	S3 *ObjectStorageConfig `json:"s3"`
+	OSS *ObjectStorageConfig `json:"oss"`
+	HTTPHeaders map[string]map[string]string `json:"http_headers"`
+	Defaults map[string]string `json:"defaults"`
+	SeedList *SeedConfig `json:"seed_list"`
+	ReportSalt string `json:"report_salt"`
+	SubjectPrefix string `json:"subject_prefix"`
+	SubjectSuffix string `json:"subject_suffix"`
+	Footer *FooterConfig `json:"footer"`
+	Signatures map[string]*FooterConfig `json:"signatures"`
+	Unsubscribe *UnsubscribeConfig `json:"unsubscribe"`
+	Distributed *DistributedConfig `json:"distributed"`
+	Queue *QueueConfig `json:"queue"`
+	History string `json:"history"`
+	FrequencyCaps []FrequencyCap `json:"frequency_caps"`
+	Require []string `json:"require"`
+	ColumnTypes map[string]string `json:"column_types"`
 }
 
 var (
@@ -44,13 +88,117 @@ var (
 	content string
 	template string
 
+	subject string
+	forceSubject bool
+	subjectPrefix string
+	subjectSuffix string
+
+	contentType string
+
+	forceHeader bool
+	noHeader bool
+
+	pdfTemplate string
+	pdfName string
+
+	vcard string
+
+	qrcodeContent string
+	qrcodeSize int
+
+	langPattern string
+
+	renderOut string
+
+	reportFile string
+
+	campaignID string
+
+	skipAlreadySent stringList
+
+	threadReports stringList
+
+	engagedFiles stringList
+	followUpDays int
+	historyFile string
+	historySince string
+	historyStatus string
+	controlServer string
+	controlInterval string
+
+	attachFiles stringList
+
+	spoolDir string
+
+	archivePath string
+
+	metricsFile string
+
+	onMissing string
+
+	holdDir string
+	holdExpiry time.Duration
+	approver string
+
+	dkimSelectors stringList
+
+	testTo string
+	testRow int
+	testFixture string
+
+	skipDisposable bool
+	disposableFile string
+
+	dedupe bool
+	dedupeGmail bool
+
+	skipBadRows bool
+	rejectedFile string
+
+	warmupFile string
+
+	sendLocalTime string
+
+	engine string
+
+	transformFile string
+
+	checkHTMLLinks bool
+
+	sanitize bool
+
+	estimateSize bool
+
+	watchInterval time.Duration
+
+	httpAddr string
+
+	httpToken string
+
+	tui bool
+
+	workdir string
+
 	debug bool
+	redact bool
 	help bool
 )
 
+// stringList 用于可以指定多次的选项
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
 func logDebug(format string, v ...interface{}) {
 	if debug {
-		log.Printf(fmt.Sprintf("[DEBUG] %s", format), v...)
+		log.Printf(fmt.Sprintf("[DEBUG] %s", tr(format)), v...)
 	}
 }
 
@@ -60,61 +208,699 @@ func init() {
 	flag.StringVar(&content, "content", "", "邮件内容")
 	flag.StringVar(&template, "template", "", "邮件模板")
 
-	flag.BoolVar(&debug, "debug", false, "debug mode print detail log")
-	flag.BoolVar(&help, "help", false, "print help info")
-}
+	flag.StringVar(&pdfTemplate, "pdf-template", "", "PDF 附件模板")
+	flag.StringVar(&pdfName, "pdf-name", "attachment.pdf", "PDF 附件文件名")
+
+	flag.Var(&attachFiles, "attach", "所有邮件都附带的文件，可以是 http(s):// 地址，可以指定多次")
+
+	flag.StringVar(&vcard, "vcard", "", "vCard 名片附件")
+
+	flag.StringVar(&qrcodeContent, "qrcode", "", "二维码内容模板")
+	flag.IntVar(&qrcodeSize, "qrcode-size", 256, "二维码图片尺寸(像素)")
+
+	flag.StringVar(&langPattern, "lang-pattern", defaultLangPattern, "多语言模板文件命名规则")
+
+	flag.StringVar(&subject, "subject", "", "Subject 列为空或没有时使用的邮件标题，支持模板语法")
+	flag.BoolVar(&forceSubject, "force-subject", false, "忽略 Subject 列，全部使用 --subject")
+	flag.StringVar(&subjectPrefix, "subject-prefix", "", "加在所有邮件标题前面的文本，例如 \"[TEST] \"")
+	flag.StringVar(&subjectSuffix, "subject-suffix", "", "加在所有邮件标题后面的文本")
+
+	flag.StringVar(&contentType, "content-type", "", "邮件内容类型：text/plain 或 text/html，默认自动判断")
+
+	flag.BoolVar(&forceHeader, "header", false, "数据文件第一行是表头")
+	flag.BoolVar(&noHeader, "no-header", false, "数据文件没有表头")
+
+	flag.StringVar(&engine, "engine", engineGo, "模板引擎：go, mustache, handlebars, pongo2")
+
+	flag.StringVar(&transformFile, "transform", "", "处理每一行数据的 Lua 脚本")
+
+	flag.BoolVar(&checkHTMLLinks, "check-html", false, "发送前检查 HTML 和链接")
+
+	flag.BoolVar(&estimateSize, "estimate-size", false, "发送前统计所有邮件的大小")
+
+	flag.BoolVar(&sanitize, "sanitize", false, "发送前清理 HTML 中的脚本、事件属性和危险链接")
+
+	flag.StringVar(&renderOut, "render-out", "", "渲染邮件到指定目录，不发送")
+
+	flag.StringVar(&reportFile, "report", "", "发送结果报告文件(JSON Lines)")
+
+	flag.StringVar(&spoolDir, "spool", "", "保存发送失败邮件的目录")
+
+	flag.StringVar(&archivePath, "archive", "", "保存发送成功邮件的目录或 .zip 文件")
+
+	flag.StringVar(&onMissing, "on-missing", onMissingDefault, "模板引用的字段不存在时的处理方式：error、empty 或 default")
+
+	flag.StringVar(&metricsFile, "metrics-out", "", "发送结束后写入统计数据的 JSON 文件")
+
+	flag.StringVar(&holdDir, "hold", "", "发送前需要审批，预览和审批记录保存在该目录")
+	flag.DurationVar(&holdExpiry, "hold-expiry", 24*time.Hour, "审批的有效期")
+	flag.StringVar(&approver, "approver", "", "approve 命令的审批人，默认为当前用户")
+
+	flag.Var(&dkimSelectors, "dkim-selector", "doctor 命令检查的 DKIM 选择器，可以指定多次")
+
+	flag.StringVar(&testTo, "to", "", "test-send 命令的测试收件人")
+	flag.IntVar(&testRow, "row", 0, "test-send 和 preview 命令使用的行号，默认第一行数据")
+	flag.StringVar(&testFixture, "fixture", "", "test-send 命令使用的 JSON 数据文件，替代 Excel")
+
+	flag.StringVar(&campaignID, "campaign-id", "", "任务标识，写入邮件头、日志和报告")
+
+	flag.Var(&skipAlreadySent, "skip-already-sent", "跳过报告文件中已发送成功的收件人，可以指定多次")
+
+	flag.Var(&engagedFiles, "engaged", "follow-up 命令使用的打开过邮件的收件人列表，可以指定多次")
+	flag.IntVar(&followUpDays, "after-days", 3, "follow-up 命令只跟进发送后超过这么多天的收件人")
+	flag.StringVar(&historyFile, "history", "", "history 命令读取的发送记录数据库，默认为配置文件中的 history")
+	flag.StringVar(&historySince, "since", "", "history 命令只列出这个时间之后的记录，例如 30d、12h 或 2026-01-02")
+	flag.StringVar(&controlServer, "server", "localhost:8080", "campaign 命令连接的 serve / --http 地址")
+	flag.StringVar(&controlInterval, "interval", "", "campaign rate 设置的新发送间隔，例如 2s、500ms 或毫秒数")
+	flag.StringVar(&historyStatus, "status", "", "history 命令只列出这些状态的记录，多个状态用逗号分隔，例如 failed,frequency_capped")
+
+	flag.Var(&threadReports, "thread-report", "之前任务的报告文件，跟进邮件回复其中发给同一个收件人的邮件，可以指定多次")
+
+	flag.BoolVar(&skipDisposable, "skip-disposable", false, "跳过一次性邮箱")
+	flag.StringVar(&disposableFile, "disposable-domains", "", "一次性邮箱域名列表文件")
+
+	flag.BoolVar(&dedupe, "dedupe", false, "跳过重复的收件人")
+	flag.BoolVar(&dedupeGmail, "dedupe-gmail", false, "判断重复时忽略 Gmail 地址中的 . 和 + 后缀")
+
+	flag.BoolVar(&skipBadRows, "skip-bad-rows", false, "跳过有问题的行继续发送")
+	flag.StringVar(&rejectedFile, "rejected-out", "", "没有发送的行及原因写入的文件(.csv 或 .xlsx)")
+
+	flag.StringVar(&warmupFile, "warmup", "", "预热进度文件，按每天的发送量逐步增加")
+
+	flag.StringVar(&sendLocalTime, "send-local-time", "", "按收件人所在时区的时间发送，例如 09:00")
+
+	flag.DurationVar(&watchInterval, "watch-interval", 5*time.Second, "监视目录的检查间隔")
+
+	flag.StringVar(&httpAddr, "http", "", "监控页面监听地址")
+	flag.StringVar(&httpToken, "http-token", "", "监控页面和 API 的访问令牌，默认为环境变量 EMAIL_SENDER_HTTP_TOKEN")
+
+	flag.BoolVar(&tui, "tui", false, "终端交互界面")
+
+	flag.StringVar(&workdir, "workdir", "", "工作目录")
+
+	flag.BoolVar(&debug, "debug", false, "调试模式，输出详细日志")
+	flag.BoolVar(&redact, "redact", false, "日志中隐藏收件人地址")
+	flag.BoolVar(&help, "help", false, "显示帮助信息")
+
+	flag.StringVar(&uiLang, "lang", defaultLang(), "界面语言：zh 或 en")
+	flag.StringVar(&errorLang, "error-lang", "", "报告和通知中错误信息的语言，默认与 --lang 相同")
+}
+
+func main() {
+	logDebug("参数列表: %s", os.Args[1:])
+
+	command, args := "send", os.Args[1:]
+	if len(args) > 0 && args[0] == "help" {
+		helpCommand(args[1:])
+		return
+	}
+	// 没有指定命令时为 send，--help 显示完整的说明
+	explicit := len(args) > 0 && cliCommands[args[0]] != nil
+	if explicit {
+		command, args = args[0], args[1:]
+	}
+
+	serviceAction := ""
+	if command == "service" && len(args) > 0 {
+		serviceAction, args = args[0], args[1:]
+	}
+
+	configAction := "show"
+	if command == "config" && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		configAction, args = args[0], args[1:]
+	}
+
+	historyAction := ""
+	if command == "history" && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		historyAction, args = args[0], args[1:]
+	}
+
+	controlAction := "list"
+	if command == "campaign" && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		controlAction, args = args[0], args[1:]
+	}
+
+	fs := commandFlagSet(command)
+	fs.Parse(args)
+
+	if len(errorLang) == 0 {
+		errorLang = uiLang
+	}
+	if len(httpToken) == 0 {
+		httpToken = os.Getenv("EMAIL_SENDER_HTTP_TOKEN")
+	}
+	if err := checkLang("--lang", uiLang); err != nil {
+		log.Fatal(err)
+	}
+	if err := checkLang("--error-lang", errorLang); err != nil {
+		log.Fatal(err)
+	}
+
+	if help {
+		if explicit {
+			fs.SetOutput(os.Stdout)
+			fs.Usage()
+		} else {
+			usage()
+		}
+		return
+	}
+
+	if len(campaignID) > 0 {
+		log.SetPrefix("[" + campaignID + "] ")
+	}
+
+	if len(workdir) > 0 {
+		if err := os.Chdir(workdir); err != nil {
+			log.Fatalf(tr("切换工作目录失败：%s"), err)
+		}
+	}
+
+	if command == "service" {
+		switch serviceAction {
+		case "install":
+			if err := installService(args); err != nil {
+				log.Fatalf(tr("安装服务失败：%s"), err)
+			}
+			log.Printf(tr("服务 %s 已安装"), serviceName)
+			return
+		case "uninstall":
+			if err := uninstallService(); err != nil {
+				log.Fatalf(tr("卸载服务失败：%s"), err)
+			}
+			log.Printf(tr("服务 %s 已卸载"), serviceName)
+			return
+		case "run":
+		default:
+			log.Fatal(tr("请指定 service 操作：install, uninstall, run"))
+		}
+	}
+
+	if command == "approve" {
+		if fs.NArg() < 1 {
+			log.Fatal(tr("请提供 --hold 指定的目录"))
+		}
+		if err := approve(fs.Arg(0)); err != nil {
+			log.Fatalf(tr("审批失败：%s"), err)
+		}
+		log.Printf(tr("已审批，提交人用同样的参数再运行一次开始发送"))
+		return
+	}
+
+	if command == "report" {
+		if fs.NArg() < 1 {
+			log.Fatal(tr("请提供报告文件"))
+		}
+		if err := summarizeReports(fs.Args(), os.Stdout); err != nil {
+			log.Fatalf(tr("读取报告文件失败：%s"), err)
+		}
+		return
+	}
+
+	if command == "campaign" {
+		if err := checkControlArgs(controlAction, fs.Args(), controlInterval); err != nil {
+			log.Fatal(err)
+		}
+		action := controlAction
+		if action == "list" {
+			action = ""
+		}
+		if err := controlCampaign(controlServer, httpToken, action, fs.Arg(0), controlInterval, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if command == "history" {
+		// 指定了 --history 时配置文件只用于读取 report_salt，可以没有
+		cfg := &Config{}
+		if _, err := os.Stat(config); len(historyFile) == 0 || err == nil {
+			if cfg, err = loadConfig(config); err != nil {
+				log.Fatalf(tr("读取配置文件失败：%s"), err)
+			}
+		}
+		file := historyFile
+		if len(file) == 0 {
+			file = cfg.History
+		}
+		if len(file) == 0 {
+			log.Fatal(tr("请用 --history 或配置文件中的 history 指定发送记录文件"))
+		}
+		query := historyQuery{campaign: campaignID, salt: cfg.ReportSalt}
+		switch historyAction {
+		case "":
+		case "lookup":
+			if fs.NArg() < 1 {
+				log.Fatal(tr("请提供要查询的收件人地址"))
+			}
+			query.recipient = fs.Arg(0)
+		default:
+			log.Fatalf(tr("未知的 history 操作 %s，只能是 lookup"), historyAction)
+		}
+		if len(historySince) > 0 {
+			since, err := parseSince(historySince)
+			if err != nil {
+				log.Fatal(err)
+			}
+			query.since = since
+		}
+		if len(historyStatus) > 0 {
+			query.statuses = map[string]bool{}
+			for _, status := range strings.Split(historyStatus, ",") {
+				query.statuses[strings.TrimSpace(status)] = true
+			}
+		}
+		// 查询时不创建数据库，文件名写错时直接报错
+		if _, err := os.Stat(file); err != nil {
+			log.Fatalf(tr("读取发送记录失败：%s"), err)
+		}
+		db, err := openHistoryDB(file)
+		if err != nil {
+			log.Fatalf(tr("读取发送记录失败：%s"), err)
+		}
+		defer db.Close()
+		if err := queryHistory(db, query, os.Stdout); err != nil {
+			log.Fatalf(tr("读取发送记录失败：%s"), err)
+		}
+		return
+	}
+
+	if command == "setup" {
+		if err := runSetup(os.Stdin, os.Stdout, config); err != nil {
+			log.Fatalf(tr("配置失败：%s"), err)
+		}
+		return
+	}
+
+	if fs.NArg() < 1 && command != "doctor" && command != "config" && command != "queue" && !(command == "test-send" && len(testFixture) > 0) {
+		log.Fatal(tr("请提供 Excel 数据文件"))
+	}
+
+	if len(config) == 0 {
+		log.Fatal(tr("请指定配置文件"))
+	}
+
+	cfg, err := loadConfig(config)
+	if err != nil {
+		log.Fatalf(tr("读取配置文件失败：%s"), err)
+	}
+
+	if command == "config" {
+		switch configAction {
+		case "show":
+			if err := showConfig(cfg, os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+		case "check":
+			if err := checkConfig(cfg); err != nil {
+				log.Fatalf(tr("配置无效：%s"), err)
+			}
+			log.Printf(tr("配置文件 %s 有效"), config)
+		default:
+			log.Fatal(tr("请指定 config 操作：show, check"))
+		}
+		return
+	}
+
+	if command == "doctor" {
+		if !doctor(cfg, os.Stdout) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == "replay" {
+		if !replay(cfg, fs.Arg(0)) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == "import" {
+		if !importMessages(cfg, fs.Arg(0)) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := loadDisposableDomains(disposableFile); err != nil {
+		log.Fatalf(tr("读取一次性邮箱域名列表失败：%s"), err)
+	}
+
+	if err := loadUnsubscribeList(cfg); err != nil {
+		log.Fatal(err)
+	}
+
+	if len(transformFile) > 0 {
+		if transform, err = loadTransform(transformFile); err != nil {
+			log.Fatalf(tr("加载脚本失败：%s"), err)
+		}
+	}
+
+	if len(rejectedFile) > 0 {
+		rejected = &rejectedRows{reasons: map[int]string{}}
+	}
+
+	if len(metricsFile) > 0 {
+		metrics = newRunMetrics()
+	}
+
+	if err := compileSubject(); err != nil {
+		log.Fatal(err)
+	}
+
+	switch onMissing {
+	case onMissingError, onMissingEmpty, onMissingDefault:
+	default:
+		log.Fatalf(tr("无效的 --on-missing %s，只能是 error、empty 或 default"), onMissing)
+	}
+
+	if forceHeader && noHeader {
+		log.Fatal(tr("--header 和 --no-header 不能同时使用"))
+	}
+
+	if len(contentType) > 0 {
+		if contentType, err = parseContentType(contentType); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if len(sendLocalTime) > 0 {
+		if _, err := time.Parse("15:04", sendLocalTime); err != nil {
+			log.Fatalf(tr("无效的 --send-local-time %s，格式为 15:04"), sendLocalTime)
+		}
+	}
+
+	if err := openSendHistory(cfg); err != nil {
+		log.Fatal(err)
+	}
+
+	watchPauseSignals()
+
+	if len(warmupFile) > 0 {
+		if warmup, err = loadWarmupState(warmupFile, cfg.WarmupSchedule); err != nil {
+			log.Fatalf(tr("读取预热进度失败：%s"), err)
+		}
+	}
+
+	// 在创建新的报告之前读取，报告文件可以与之前的相同
+	if err := loadSentHistory(skipAlreadySent); err != nil {
+		log.Fatalf(tr("读取发送记录失败：%s"), err)
+	}
+	if err := loadThreadHistory(threadReports); err != nil {
+		log.Fatalf(tr("读取发送记录失败：%s"), err)
+	}
+
+	file := fs.Arg(0)
+	var failures []Result
+	if command == "resend-failures" {
+		if fs.NArg() < 2 {
+			log.Fatal(tr("请提供之前的报告文件和 Excel 数据文件"))
+		}
+		// 在创建新的报告之前读取，新报告可以与之前的相同
+		if failures, err = loadFailures(fs.Arg(0)); err != nil {
+			log.Fatalf(tr("读取报告文件失败：%s"), err)
+		}
+		if len(failures) == 0 {
+			log.Printf(tr("%s 中没有失败的收件人"), fs.Arg(0))
+			return
+		}
+		file = fs.Arg(1)
+	}
+	var followUps []Result
+	var engaged map[string]bool
+	if command == "follow-up" {
+		if fs.NArg() < 2 {
+			log.Fatal(tr("请提供原来任务的报告文件和 Excel 数据文件"))
+		}
+		if followUps, err = loadFollowUpCandidates(fs.Arg(0), followUpDays); err != nil {
+			log.Fatalf(tr("读取报告文件失败：%s"), err)
+		}
+		if len(followUps) == 0 {
+			log.Printf(tr("%s 中没有需要跟进的收件人"), fs.Arg(0))
+			return
+		}
+		if engaged, err = loadEngaged(engagedFiles); err != nil {
+			log.Fatalf(tr("读取打开过邮件的收件人失败：%s"), err)
+		}
+		file = fs.Arg(1)
+	}
+	name := file
+
+	defer removeRemoteFiles()
+	defer closePdfBrowser()
+	if err := fetchRemoteInputs(cfg, &file, &content, &template, &pdfTemplate, &vcard); err != nil {
+		log.Fatal(err)
+	}
+
+	// 任务文件中的每个任务可以指定自己的邮件内容，--content / --template 只作为默认值
+	if command == "run" {
+		ok := runCampaigns(cfg, file)
+		saveMetrics()
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	}
+
+	contentProvider, err := getContentProvider(cfg, content, template)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch command {
+	case "validate":
+		ok := validate(cfg, file, content, template, contentProvider, nil, os.Stdout)
+		saveRejectedRows()
+		if !ok {
+			os.Exit(1)
+		}
+		return
+	case "lint":
+		if !lint(cfg, file, template, os.Stdout) {
+			os.Exit(1)
+		}
+		return
+	case "test-send":
+		if err := testSend(cfg, file, content, template, contentProvider); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "watch":
+		watch(cfg, file, contentProvider, nil)
+		return
+	case "serve":
+		if len(httpAddr) == 0 {
+			httpAddr = "localhost:8080"
+		}
+		watch(cfg, file, contentProvider, nil)
+		return
+	case "service":
+		if err := runService(func(stop <-chan struct{}) {
+			watch(cfg, file, contentProvider, stop)
+		}); err != nil {
+			log.Fatalf(tr("运行服务失败：%s"), err)
+		}
+		return
+	case "queue":
+		decorators, err := getDecorators(cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		reporter, err := newReporter(reportFile, cfg.ReportSalt)
+		if err != nil {
+			log.Fatalf(tr("创建报告文件失败：%s"), err)
+		}
+		// 一个请求有问题不影响同一批的其他请求
+		skipBadRows = true
+		if len(httpAddr) > 0 {
+			go serveDashboard(httpAddr)
+		}
+		err = runQueue(cfg, content, template, contentProvider, decorators, reporter, stopSignal())
+		reporter.Close()
+		saveMetrics()
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf(tr("发送完成，成功 %d 封，失败 %d 封"), reporter.Sent, reporter.Failed)
+		return
+	}
+
+	list, contentProvider, err := prepareSendList(cfg, file, content, template, contentProvider, nil)
+	if err != nil {
+		saveRejectedRows()
+		log.Fatal(err)
+	}
+
+	if failures != nil {
+		if list, err = selectFailedRows(list, failures, cfg.ReportSalt); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if followUps != nil {
+		if list, err = selectFollowUpRows(list, followUps, engaged, cfg.ReportSalt); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if command == "preview" && len(renderOut) == 0 {
+		if err := previewMessage(cfg, list, contentProvider, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if len(renderOut) > 0 {
+		tagSendList(list, campaignID)
+		if err := renderSendList(renderOut, list, contentProvider); err != nil {
+			log.Fatalf(tr("渲染邮件失败：%s"), err)
+		}
+		return
+	}
+
+	if len(holdDir) > 0 {
+		tagSendList(list, campaignID)
+		ok, err := holdForApproval(cfg, holdDir, name, list, contentProvider)
+		if err != nil {
+			log.Fatalf(tr("提交审批失败：%s"), err)
+		}
+		if !ok {
+			return
+		}
+		// 开始发送前就记为已使用，发送中途出错也不能再用同一个审批重新发送
+		markHoldSent(holdDir)
+	}
 
-func main() {
-	logDebug("参数列表: %s", os.Args[1:])
+	decorators, err := getDecorators(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	flag.Parse()
+	reporter, err := newReporter(reportFile, cfg.ReportSalt)
+	if err != nil {
+		log.Fatalf(tr("创建报告文件失败：%s"), err)
+	}
 
-	if help {
-		usage()
-		return
+	if err := startDistributed(cfg); err != nil {
+		log.Fatal(err)
 	}
 
-	if flag.NArg() < 1 {
-		log.Fatal("请提供 Excel 数据文件")
+	campaign := newCampaign(name, list, reporter)
+	if len(httpAddr) > 0 {
+		campaign.retry = retryFailures(cfg, contentProvider, decorators)
+		go serveDashboard(httpAddr)
 	}
 
-	if len(config) == 0 {
-		log.Fatal("请指定配置文件")
+	run := func() error {
+		if err := sendEmails(cfg, list, contentProvider, decorators, campaign); err != nil {
+			return err
+		}
+		// 分布式发送时等待其他机器发送完，接着发送退出的机器没有发送完的行
+		return distributed.finish(list, func(rest []*Send) error {
+			return sendEmails(cfg, rest, contentProvider, decorators, newCampaign(name, rest, reporter))
+		})
+	}
+	if tui {
+		err = runTUI(campaign, run)
+	} else {
+		watchPauseKeys(campaign)
+		err = run()
+	}
+	reporter.Close()
+	if distributed != nil && len(reportFile) > 0 && err == nil {
+		if err := distributed.writeReport(reportFile); err != nil {
+			log.Printf(tr("写入合并的报告失败：%s"), err)
+		}
+	}
+	saveRejectedRows()
+	saveMetrics()
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	data, err := readFileContent(config)
+	log.Printf(tr("发送完成，成功 %d 封，失败 %d 封"), reporter.Sent, reporter.Failed)
+}
+
+func loadConfig(file string) (*Config, error) {
+	values, err := readConfigData(file, map[string]bool{})
 	if err != nil {
-		log.Fatalf("读取配置文件失败：%s", err)
+		return nil, err
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
 	}
 	var cfg Config
 	if err = json.Unmarshal(data, &cfg); err != nil {
-		log.Fatalf("读取配置文件失败：%s", err)
+		return nil, err
+	}
+
+	if cfg.GreylistRetries == 0 {
+		cfg.GreylistRetries = 2
+	}
+
+	if err := checkSchemaConfig(&cfg); err != nil {
+		return nil, err
+	}
+
+	if err := checkNotifyConfig(&cfg); err != nil {
+		return nil, err
 	}
 
 	logDebug("解析完配置内容：%+v", &cfg)
 
-	contentProvider, err := getContentProvider(content, template)
+	return &cfg, nil
+}
+
+func prepareSendList(cfg *Config, file, content, template string, contentProvider ContentProvider, opts *listOptions) ([]*Send, ContentProvider, error) {
+	list, err := loadSendList(cfg, file, opts)
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, fmt.Errorf(trErr("处理 Excel 文件失败：%s"), err)
 	}
 
-	file := flag.Arg(0)
+	return prepareList(cfg, list, content, template, contentProvider, opts)
+}
 
-	list, err := loadSendList(file)
+// prepareList 检查数据并加上页脚、标题前后缀等，返回最终发送的列表和正文模板；opts 为 nil 时使用 --campaign-id
+func prepareList(cfg *Config, list []*Send, content, template string, contentProvider ContentProvider, opts *listOptions) ([]*Send, ContentProvider, error) {
+	list, err := enforceSchema(cfg, list)
 	if err != nil {
-		log.Fatalf("处理 Excel 文件失败：%s", err)
+		return nil, nil, err
 	}
 
 	logDebug("处理完成，有 %d 条待发送邮件", len(list))
 
-	sendEmails(&cfg, list, contentProvider)
+	contentProvider, err = getLangContentProvider(cfg, contentProvider, content, template, list)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 模板中可能用到 {{ .CampaignID }}，检查前先加上
+	tagSendList(list, opts.id())
+	if list, contentProvider, err = applyFooter(cfg, list, contentProvider); err != nil {
+		return nil, nil, err
+	}
+	if list, err = enforceTemplateFields(cfg, list, contentProvider); err != nil {
+		return nil, nil, err
+	}
+	tagSubjects(cfg, list)
+	return list, contentProvider, nil
 }
 
-func sendEmails(cfg *Config, list []*Send, contentProvider ContentProvider) {
+func sendEmails(cfg *Config, list []*Send, contentProvider ContentProvider, decorators []Decorator, campaign *Campaign) (err error) {
+	defer func() {
+		campaign.finish(err)
+		if campaign.Snapshot().Status == campaignDone {
+			notifyCampaign(cfg, campaign, notifyComplete)
+		} else {
+			notifyCampaign(cfg, campaign, notifyAbort)
+		}
+	}()
 
 	sender, err := getSender(cfg)
 	if err != nil {
-		log.Fatalf("创建 Sender 失败：%s", err)
+		return fmt.Errorf(trErr("创建 Sender 失败：%s"), err)
 	}
 
 	defer func() {
@@ -123,101 +909,389 @@ func sendEmails(cfg *Config, list []*Send, contentProvider ContentProvider) {
 		}
 	}()
 
+	var sentFolder *imapClient
+	if cfg.IMAP != nil {
+		sentFolder, err = dialIMAP(cfg)
+		if err != nil {
+			return fmt.Errorf(trErr("连接 IMAP 服务器失败：%s"), err)
+		}
+		defer sentFolder.Close()
+	}
+
+	archive, err := openArchive(campaign.CampaignID)
+	if err != nil {
+		return fmt.Errorf(trErr("创建邮件存档失败：%s"), err)
+	}
+	defer func() {
+		if err := archive.Close(); err != nil {
+			campaign.logf("保存邮件存档失败：%v", err)
+		}
+	}()
+
+	capture := &captureSender{sender: sender, limit: messageSizeLimit(cfg, sender)}
+	if len(cfg.BccArchive) > 0 {
+		if !validEmailAddress(cfg.BccArchive) {
+			return fmt.Errorf(trErr("无效的 bcc_archive 地址 %s"), cfg.BccArchive)
+		}
+		capture.bcc = []string{normalizeAddress(cfg.BccArchive)}
+	}
+
+	selectFrom, err := getFromSelector(cfg)
+	if err != nil {
+		return err
+	}
+
+	buildEnvelope, err := getEnvelopeBuilder(cfg)
+	if err != nil {
+		return err
+	}
+	envelopes, _ := sender.(envelopeSender)
+	unicode := unicodeHeaders(sender)
+
+	hook := getPreSendHook(cfg)
+
+	throttle := newThrottle(cfg)
+
+	tagSendList(list, campaign.CampaignID)
+	list = campaign.skipRecipients(list)
+	list = campaign.insertSeeds(cfg.SeedList, list)
+	sortBySendAt(list)
+
+	if err := preflight(cfg, list, contentProvider, decorators); err != nil {
+		return err
+	}
+
+	notifyCampaign(cfg, campaign, notifyStart)
+
 	m := gomail.NewMessage()
 
-	for _, s := range list {
-		m.SetHeader("From", cfg.From)
-		m.SetHeader("To", s.SendTo)
-		m.SetHeader("Subject", s.Subject)
+	// 被灰名单暂时拒绝的收件人在本轮结束后等待 greylist_delay 秒再重试
+	deferred := map[*Send]error{}
+	pending := list
 
-		if s.Content != nil {
-			m.SetBody(detectContentType([]byte(*s.Content)), *s.Content)
-		} else {
-			ct, content := contentProvider(s.Meta)
-			m.AddAlternativeWriter(ct, content)
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			campaign.logf("%s 等待 %d 秒后重试 %d 个被灰名单拒绝的收件人", campaign.Name, cfg.GreylistDelay, len(pending))
+			if !campaign.sleep(time.Second * time.Duration(cfg.GreylistDelay)) {
+				break
+			}
 		}
+		retry := []*Send{}
+
+		for _, s := range pending {
+			if !waitSendAt(campaign, s) || !campaign.proceed() {
+				break
+			}
+			if !distributed.claim(s) {
+				campaign.handOff()
+				continue
+			}
+			if !distributed.wait(campaign) {
+				break
+			}
+
+			from := selectFrom(s)
+			if hook != nil {
+				if ok, reason := hook(s, from, campaign.CampaignID); !ok {
+					campaign.logf("pre_send_hook 跳过 %s: %s", maskAddress(s.SendTo), redactText(reason))
+					campaign.Skip(s, statusHookRejected, reason)
+					continue
+				}
+			}
+			if !s.Seed {
+				if capped, reason := history.capped(s.SendTo); capped {
+					campaign.logf("跳过 %s: %s", maskAddress(s.SendTo), reason)
+					campaign.Skip(s, statusFrequencyCapped, reason)
+					continue
+				}
+			}
+
+			setAddressHeader(m, "From", headerAddress(from, unicode))
+			setAddressHeader(m, "To", headerAddress(s.SendTo, unicode))
+			m.SetHeader("Subject", encodeHeaderText(s.Subject))
+			s.MessageID = newMessageID(from)
+			m.SetHeader("Message-ID", s.MessageID)
+			if len(campaign.CampaignID) > 0 {
+				m.SetHeader(campaignIDHeader, campaign.CampaignID)
+			}
+
+			if s.Content != nil {
+				ct := rowContentType(s, detectContentType([]byte(*s.Content)))
+				if sanitize && ct == "text/html" {
+					m.SetBody(ct, string(sanitizeHTML([]byte(*s.Content))))
+				} else {
+					m.SetBody(ct, *s.Content)
+				}
+			} else {
+				ct, content := contentProvider(s.Meta)
+				ct = rowContentType(s, ct)
+				m.AddAlternativeWriter(ct, sanitizeContent(ct, content))
+			}
 
-		if err := gomail.Send(sender, m); err != nil {
-			log.Printf("发送失败 %s -> %s: %v", s.SendTo, s.Content, err)
+			if err := decorate(m, s, decorators); err != nil {
+				campaign.logf("处理邮件失败 %s: %s", maskAddress(s.SendTo), redactText(err))
+				campaign.Add(s, err)
+				m.Reset()
+				continue
+			}
+
+			var e *envelope
+			if buildEnvelope != nil && envelopes != nil {
+				if e, err = buildEnvelope(s); err != nil {
+					campaign.logf("处理邮件失败 %s: %s", maskAddress(s.SendTo), redactText(err))
+					campaign.Add(s, err)
+					m.Reset()
+					continue
+				}
+			}
+
+			campaign.sending(s)
+			for retries := 0; ; retries++ {
+				if e != nil {
+					envelopes.setEnvelope(e)
+				}
+				capture.err, capture.data = nil, nil
+				err = gomail.Send(capture, m)
+				if err == nil || !throttle.limited(capture.err, retries) {
+					break
+				}
+				wait := throttle.slowDown()
+				campaign.logf("服务器限制发送速度，发送间隔增加到 %v，稍后重试 %s: %s", wait, maskAddress(s.SendTo), redactText(err))
+				if !campaign.sleep(wait) {
+					break
+				}
+			}
+			if throttle.record(err) {
+				campaign.logf("发送间隔恢复到 %v", throttle.delay())
+			}
+			delete(deferred, s)
+			var tooLarge *MessageTooLargeError
+			if errors.As(capture.err, &tooLarge) {
+				campaign.logf("跳过 %s: %s", maskAddress(s.SendTo), redactText(tooLarge))
+				campaign.Skip(s, statusTooLarge, tooLarge.Error())
+				m.Reset()
+				continue
+			}
+			if err != nil && isGreylisted(capture.err) && attempt < cfg.GreylistRetries && cfg.GreylistDelay > 0 {
+				campaign.logf("暂时无法发送 %s，稍后重试: %s", maskAddress(s.SendTo), redactText(err))
+				deferred[s] = err
+				retry = append(retry, s)
+				m.Reset()
+				continue
+			}
+			if err != nil {
+				campaign.logf("发送失败 %s: %s", maskAddress(s.SendTo), redactText(err))
+				if s.Content != nil {
+					logDebug("To: %s, 邮件内容：%s", s.SendTo, *s.Content)
+				}
+				spoolFailure(campaign.CampaignID, s, capture, err)
+			} else {
+				logDebug("To: %s, 发送成功", s.SendTo)
+				if sentFolder != nil {
+					if err := sentFolder.Append(cfg.IMAP.Folder, capture.data); err != nil {
+						campaign.logf("保存到已发送邮件夹失败 %s: %s", maskAddress(s.SendTo), redactText(err))
+					}
+				}
+				if err := archive.add(s, capture.data); err != nil {
+					campaign.logf("保存邮件存档失败 %s: %s", maskAddress(s.SendTo), redactText(err))
+				}
+			}
+			campaign.Add(s, err)
+			m.Reset()
+
+			if d, ok := campaign.takeInterval(); ok {
+				throttle.setInterval(d)
+				campaign.logf("%s 发送间隔调整为 %v", campaign.Name, d)
+			}
+			if d := throttle.delay(); d > 0 && !campaign.pause(d) {
+				break
+			}
 		}
-		logDebug("To: %s, 发送成功", s.SendTo)
-		m.Reset()
+		pending = retry
+	}
+
+	if status := campaign.Snapshot().Status; status == campaignCancelled {
+		campaign.logf("%s 已取消", campaign.Name)
+	}
 
-		if cfg.Interval > 0 {
-			time.Sleep(time.Millisecond * time.Duration(cfg.Interval))
+	// 取消时还在等待重试的收件人记为失败
+	for _, s := range pending {
+		if err, ok := deferred[s]; ok {
+			campaign.Add(s, err)
 		}
 	}
+
+	return nil
 }
 
-func loadSendList(file string) ([]*Send, error) {
-	excel, err := xlsx.OpenFile(file)
+type RowError struct {
+	Row int
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf(trErr("解析第 %d 行出错，%s"), e.Row, e.Err)
+}
+
+func loadSendList(cfg *Config, file string, opts *listOptions) ([]*Send, error) {
+	list, rowErrors, err := parseSendList(cfg, file, opts)
 	if err != nil {
 		return nil, err
 	}
+	if len(rowErrors) > 0 {
+		if !skipBadRows {
+			return nil, rowErrors[0]
+		}
+		for _, e := range rowErrors {
+			log.Printf(tr("跳过：%s"), e)
+		}
+		log.Printf(tr("共跳过 %d 行有问题的数据，继续发送其余 %d 行"), len(rowErrors), len(list))
+	}
+	return list, nil
+}
 
-	if len(excel.Sheets) == 0 || len(excel.Sheets[0].Rows) == 0 {
-		return nil, errors.New("空表格")
+func parseSendList(cfg *Config, file string, opts *listOptions) ([]*Send, []*RowError, error) {
+	rows, err := readRows(file)
+	if err != nil {
+		return nil, nil, err
 	}
+	return parseRows(cfg, rows, opts)
+}
 
-	rows := excel.Sheets[0].Rows
+// parseRows 按表头解析每一行，queue 命令把队列中的发送请求转换成同样的表格后解析
+func parseRows(cfg *Config, rows [][]string, opts *listOptions) ([]*Send, []*RowError, error) {
+	if len(rows) == 0 {
+		return nil, nil, errors.New(trErr("空表格"))
+	}
 
 	maybeHeader := rows[0]
 	skipHeader, rowParser, err := getRowParser(maybeHeader)
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	offset := 1
 	if skipHeader {
+		rejected.source(rows[0], rows[1:], 2)
 		rows = rows[1:]
+		offset = 2
+	} else {
+		rejected.source(nil, rows, 1)
 	}
 
 	list := []*Send{}
+	rowErrors := []*RowError{}
 
 	for i, row := range rows {
 		send, err := rowParser(row)
 		if err != nil {
-			return nil, errors.New(fmt.Sprintf("解析第 %d 行出错，%s", i + 1, err))
+			rowErrors = append(rowErrors, &RowError{Row: i + offset, Err: err})
+			rejected.add(i+offset, err.Error())
+			continue
+		}
+		send.Row = i + offset
+		applyDefaults(cfg, send)
+		if err := opts.applySubject(send); err != nil {
+			rowErrors = append(rowErrors, &RowError{Row: send.Row, Err: err})
+			rejected.add(send.Row, err.Error())
+			continue
+		}
+		if transform != nil {
+			keep, err := transform.apply(send)
+			var abort *TransformAbort
+			if errors.As(err, &abort) {
+				return nil, nil, &RowError{Row: send.Row, Err: err}
+			}
+			if err != nil {
+				rowErrors = append(rowErrors, &RowError{Row: send.Row, Err: err})
+				rejected.add(send.Row, err.Error())
+				continue
+			}
+			if !keep {
+				logDebug("脚本跳过第 %d 行", send.Row)
+				rejected.add(send.Row, trErr("脚本跳过"))
+				continue
+			}
+		}
+		// 默认值和脚本都可能提供 SendAt、Timezone，所以最后计算发送时间
+		if err := scheduleSend(send); err != nil {
+			rowErrors = append(rowErrors, &RowError{Row: send.Row, Err: err})
+			rejected.add(send.Row, err.Error())
+			continue
 		}
 		list = append(list, send)
 	}
 
-	return list, nil
+	return list, rowErrors, nil
 }
 
-func getRowParser(first *xlsx.Row) (bool, func(row *xlsx.Row) (*Send, error), error) {
-	if len(first.Cells) < 2 {
-		return false, nil, errors.New("最少需要两列(SendTo, Subject)")
+// detectHeaderRow 第一行同时有 SendTo 和 Subject 列时作为表头，可以用 --header / --no-header 指定
+func detectHeaderRow(first []string) bool {
+	if forceHeader || noHeader {
+		return forceHeader
 	}
 
-	headerRow := false
-
-	for _, cell := range first.Cells {
-		if strings.Contains("SendTo, Subject, Content", cell.Value) {
-			headerRow = true
-			break
+	columns := map[string]bool{}
+	similar := []string{}
+	for _, cell := range first {
+		cell = strings.TrimSpace(cell)
+		switch cell {
+		case "SendTo", "Subject", "Content":
+			columns[cell] = true
+			similar = append(similar, cell)
+		default:
+			switch strings.ToLower(cell) {
+			case "sendto", "subject", "content":
+				similar = append(similar, cell)
+			}
 		}
 	}
 
-	if headerRow {
+	headerRow := columns["SendTo"] && (columns["Subject"] || len(subject) > 0)
+	if !headerRow && len(similar) > 0 {
+		log.Printf(tr("警告：第一行包含 %s，但没有 SendTo 和 Subject 列，按没有表头处理，可以用 --header 或 --no-header 指定"), strings.Join(similar, ", "))
+	}
+	return headerRow
+}
+
+func getRowParser(first []string) (bool, func(row []string) (*Send, error), error) {
+	// 指定了 --subject 时可以没有 Subject 列
+	minColumns := 2
+	if len(subject) > 0 {
+		minColumns = 1
+	}
+	if len(first) < minColumns {
+		return false, nil, errors.New(trErr("最少需要两列(SendTo, Subject)"))
+	}
+
+	if detectHeaderRow(first) {
 		logDebug("Header Excel")
 
 		handlers := map[int]func(val string, send *Send) error {}
 
-		for i, cell := range first.Cells {
-			switch cell.Value {
+		columns := map[string]bool{}
+		for _, cell := range first {
+			columns[cell] = true
+		}
+		for _, required := range []string{"SendTo", "Subject"} {
+			if !columns[required] && !(required == "Subject" && len(subject) > 0) {
+				return false, nil, errors.New(fmt.Sprintf(trErr("缺少必需的列 %s"), required))
+			}
+		}
+
+		for i, cell := range first {
+			switch cell {
 			case "SendTo":
 				handlers[i] = func(val string, send *Send) error {
+					val = normalizeAddress(val)
 					if !validEmailAddress(val) {
-						return errors.New(fmt.Sprintf("无效的收件人: %s", val))
+						return errors.New(fmt.Sprintf(trErr("无效的收件人: %s"), val))
 					}
 					send.SendTo = val
 					return nil
 				}
 			case "Subject":
 				handlers[i] = func(val string, send *Send) error {
-					if len(val) == 0 {
-						return errors.New("标题不能为空")
-					}
 					send.Subject = val
 					return nil
 				}
@@ -228,9 +1302,24 @@ func getRowParser(first *xlsx.Row) (bool, func(row *xlsx.Row) (*Send, error), er
 					}
 					return nil
 				}
+			case contentTypeColumn:
+				handlers[i] = func(val string, send *Send) error {
+					if len(val) == 0 {
+						return nil
+					}
+					ct, err := parseContentType(val)
+					if err != nil {
+						return err
+					}
+					if send.Meta == nil {
+						send.Meta = map[string]string{}
+					}
+					send.Meta[contentTypeColumn] = ct
+					return nil
+				}
 			default:
-				logDebug("Meta Cell: %s", cell.Value)
-				key := cell.Value
+				logDebug("Meta Cell: %s", cell)
+				key := cell
 				handlers[i] = func(val string, send *Send) error {
 					if len(val) > 0 {
 						if send.Meta == nil {
@@ -243,58 +1332,63 @@ func getRowParser(first *xlsx.Row) (bool, func(row *xlsx.Row) (*Send, error), er
 			}
 		}
 
-		return true, func(row *xlsx.Row) (*Send, error) {
+		return true, func(row []string) (*Send, error) {
 			var send Send
-			for i, cell := range row.Cells {
+			for i, cell := range row {
 				if handler, ok := handlers[i]; ok {
-					if err := handler(cell.Value, &send); err != nil {
+					if err := handler(cell, &send); err != nil {
 						return nil, err
 					}
 				} else {
-					return nil, errors.New("数据与表头对不上")
+					return nil, errors.New(trErr("数据与表头对不上"))
 				}
 			}
+			if len(send.SendTo) == 0 {
+				return nil, errors.New(trErr("收件人不能为空"))
+			}
 			return &send, nil
 		}, nil
 
 	} else {
-		return false, func(row *xlsx.Row) (*Send, error) {
+		return false, func(row []string) (*Send, error) {
 
-			if len(row.Cells) < 2 {
-				return nil, errors.New("最少需要两列(SendTo, Subject)")
+			if len(row) < minColumns {
+				return nil, errors.New(trErr("最少需要两列(SendTo, Subject)"))
 			}
-			sendTo := row.Cells[0].Value
+			sendTo := normalizeAddress(row[0])
 			if len(sendTo) == 0 || !validEmailAddress(sendTo) {
-				return nil, errors.New(fmt.Sprintf("无效的收件人: %s", sendTo))
+				return nil, errors.New(fmt.Sprintf(trErr("无效的收件人: %s"), sendTo))
 			}
-			subject := row.Cells[1].Value
-			if len(subject) == 0 {
-				return nil, errors.New("邮件标题不能为空")
+			var subject string
+			if len(row) > 1 {
+				subject = row[1]
 			}
-
 			var content *string
 
-			if len(row.Cells) > 2 {
-				content = &row.Cells[2].Value
+			if len(row) > 2 {
+				content = &row[2]
 			}
 			return &Send{SendTo: sendTo, Subject: subject, Content: content}, nil
 		}, nil
 	}
 }
 
-func getContentProvider(content, template string) (ContentProvider, error) {
+func getContentProvider(cfg *Config, content, template string) (ContentProvider, error) {
 
 	if len(content) == 0 && len(template) == 0 {
-		return nil, errors.New("邮件内容或邮件模板必须指定一个")
+		return nil, errors.New(trErr("邮件内容或邮件模板必须指定一个"))
 	} else if len(content) != 0 && len(template) != 0 {
-		return nil, errors.New("邮件内容或邮件模板只能指定一个")
+		return nil, errors.New(trErr("邮件内容或邮件模板只能指定一个"))
 	}
 
 	if len(content) > 0 {
 		logDebug("从 %s 中读取邮件内容", content)
-		data, err := readFileContent(content)
+		data, err := readTemplateFile(content)
 		if err != nil {
-			log.Fatalf("读取邮件内容文件失败：%s", err)
+			return nil, fmt.Errorf(trErr("读取邮件内容文件失败：%s"), err)
+		}
+		if data, err = uploadImages(cfg, filepath.Dir(content), data); err != nil {
+			return nil, err
 		}
 		contentType := detectContentType(data)
 
@@ -302,32 +1396,132 @@ func getContentProvider(content, template string) (ContentProvider, error) {
 
 		return func(_data interface{}) (s string, f func(writer io.Writer) error) {
 			return contentType, func(w io.Writer) error {
-				_, err := io.WriteString(w, string(data))
+				_, err := w.Write(data)
 				return err
 			}
 		}, nil
 
 	} else {
 		logDebug("从 %s 中读取邮件内容", template)
-		data, err := readFileContent(template)
+		data, err := readTemplateFile(template)
 		if err != nil {
-			log.Fatalf("读取邮件模板文件失败：%s", err)
+			return nil, fmt.Errorf(trErr("读取邮件模板文件失败：%s"), err)
+		}
+		if data, err = uploadImages(cfg, filepath.Dir(template), data); err != nil {
+			return nil, err
 		}
-		t, err := gotempalte.New("email").Parse(string(data))
+		render, err := compileTemplate("email", filepath.Dir(template), string(data), true)
 		if err != nil {
-			log.Fatalf("解析邮件模板失败：%s", err)
+			return nil, fmt.Errorf(trErr("解析邮件模板失败：%s"), err)
 		}
 		contentType := detectContentType(data)
 
 		logDebug("使用邮件模板 %s: %s", contentType, string(data))
 
-		return func(data interface{}) (s string, f func(writer io.Writer) error) {
+		return cacheContent(func(data interface{}) (s string, f func(writer io.Writer) error) {
 			logDebug("Template Data: %+v", data)
 			return contentType, func(w io.Writer) error {
-				return t.Execute(w, data)
+				return render(w, data)
 			}
-		}, nil
+		}, string(data)), nil
+	}
+}
+
+func getDecorators(cfg *Config) ([]Decorator, error) {
+	decorators := []Decorator{}
+
+	if len(pdfTemplate) > 0 {
+		d, err := getPdfDecorator(cfg, pdfTemplate, pdfName)
+		if err != nil {
+			return nil, err
+		}
+		decorators = append(decorators, d)
+	}
+
+	if len(vcard) > 0 {
+		d, err := getVCardDecorator(vcard)
+		if err != nil {
+			return nil, err
+		}
+		decorators = append(decorators, d)
+	}
+
+	if len(qrcodeContent) > 0 {
+		d, err := getQRCodeDecorator(qrcodeContent, qrcodeSize)
+		if err != nil {
+			return nil, err
+		}
+		decorators = append(decorators, d)
+	}
+
+	if cfg.Invite != nil {
+		d, err := getInviteDecorator(cfg)
+		if err != nil {
+			return nil, err
+		}
+		decorators = append(decorators, d)
+	}
+
+	decorators = append(decorators, getThreadDecorator(cfg))
+
+	if cfg.Unsubscribe != nil && len(cfg.Unsubscribe.URL) > 0 {
+		decorators = append(decorators, getUnsubscribeDecorator(cfg))
+	}
+
+	d, err := getAttachmentDecorator(cfg)
+	if err != nil {
+		return nil, err
 	}
+	decorators = append(decorators, d)
+
+	return decorators, nil
+}
+
+func decorate(m *gomail.Message, send *Send, decorators []Decorator) error {
+	for _, d := range decorators {
+		if err := d(m, send); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func attachBytes(m *gomail.Message, name string, data []byte, settings ...gomail.FileSetting) {
+	settings = append([]gomail.FileSetting{fileNameHeaders(name)}, settings...)
+	settings = append(settings, gomail.SetCopyFunc(func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	}))
+	m.Attach(name, settings...)
+}
+
+// captureSender 保存实际发送的邮件内容，供发送成功后使用；err 为 Sender 返回的原始错误，gomail.Send 会把它转成字符串；
+// 邮件超过 limit 时不发送；bcc 只作为信封收件人，不出现在邮件头中
+type captureSender struct {
+	sender gomail.Sender
+	limit int64
+	bcc []string
+	data []byte
+	from string
+	to []string
+	err error
+}
+
+func (c *captureSender) Send(from string, to []string, msg io.WriterTo) error {
+	var buf bytes.Buffer
+	if _, err := msg.WriteTo(&buf); err != nil {
+		return err
+	}
+	if len(c.bcc) > 0 {
+		to = append(to[:len(to):len(to)], c.bcc...)
+	}
+	c.data, c.from, c.to = buf.Bytes(), from, to
+	if c.limit > 0 && int64(buf.Len()) > c.limit {
+		c.err = &MessageTooLargeError{Size: int64(buf.Len()), Limit: c.limit}
+		return c.err
+	}
+	c.err = c.sender.Send(from, to, &buf)
+	return c.err
 }
 
 func getSender(cfg *Config) (gomail.Sender, error) {
@@ -338,13 +1532,18 @@ func getSender(cfg *Config) (gomail.Sender, error) {
 			if _, err := msg.WriteTo(&buffer); err != nil {
 				return err
 			}
+			if redact && !debug {
+				log.Printf("%s Send email to %s: %d bytes", from, maskAddresses(to), buffer.Len())
+				return nil
+			}
 			log.Printf("%s Send email to %s: %s", from, to, buffer.String())
 			return nil
 		}), nil
 	default:
-		d := gomail.NewDialer(cfg.Host, cfg.Port, cfg.Username, cfg.Password)
-		d.TLSConfig = &tls.Config{InsecureSkipVerify: true}
-		return d.Dial()
+		if cfg.Pool != nil {
+			return newPooledSender(cfg)
+		}
+		return dialSMTP(cfg)
 	}
 }
 
@@ -356,7 +1555,12 @@ func readFileContent(filename string) (data []byte, err error) {
 	return io.ReadAll(file)
 }
 
+// detectContentType 判断内容是否为 html，指定了 --content-type 时直接使用
 func detectContentType(data []byte) string {
+	if len(contentType) > 0 {
+		return contentType
+	}
+
 	idx1 := bytes.IndexByte(data, '<')
 	idx2 := bytes.IndexByte(data, '>')
 
@@ -369,19 +1573,160 @@ func detectContentType(data []byte) string {
 
 func validEmailAddress(addr string) bool {
 	a, err := mail.ParseAddress(addr)
-	return err == nil && a != nil
+	return err == nil && a != nil && validAddressDomain(a.Address)
 }
 
 func usage() {
+	if uiLang == "en" {
+		fmt.Print(usageEnglish)
+		return
+	}
 	fmt.Print(`
 	批量邮件发送助手 v0.1
 
 	使用方式：
-		email-sender.exe [--debug] --config config.json [--content content.txt | --template template.tpl] test.xlsx
+		email-sender.exe [send | validate | preview | report | history | serve | queue | campaign | config | setup | watch | service | run | replay | import | resend-failures | follow-up | doctor | test-send | approve | lint] [--debug] --config config.json [--content content.txt | --template template.tpl] test.xlsx
+		email-sender.exe help [命令]
+
+	命令说明（命令写在选项前面，每个命令只接受自己用得到的选项，email-sender.exe <命令> --help 或 help <命令> 查看）：
+
+	send 发送邮件，默认命令
+
+	validate 只检查数据不发送邮件：校验所有收件人地址、必需的列，并用模板渲染每一行，按行列出所有问题
+
+	preview 渲染邮件但不发送，例如 email-sender.exe preview --config config.json --template t.tpl --row 5 list.xlsx
+	  输出 --row 指定的一行（默认第一行数据）完整的邮件（包括邮件头和附件），与发送时的内容相同；
+	  指定 --render-out 目录时与 send --render-out 相同，把每个收件人的邮件写入目录
+
+	report 汇总 --report 生成的报告，例如 email-sender.exe report list.report.jsonl retry.report.jsonl
+	  列出各状态的收件人数量、失败的 SMTP 响应码和失败的收件人；同一个收件人在多个报告中出现时以后面的结果为准，
+	  重新发送的报告放在后面即可得到最终结果
+
+	history 查询配置文件中 history 的发送记录，包括所有任务，例如 email-sender.exe history --config config.json --since 30d --status failed
+	  --since 为 30d、12h 这样的时长或 2006-01-02 格式的日期，--status 为一个或多个用逗号分隔的状态，
+	  --campaign-id 只列出这个任务的记录；--history 可以直接指定发送记录数据库，不需要配置文件；
+	  每条记录一行，包括时间、状态、收件人、任务、标题和错误信息，最后汇总各状态的数量
+	  history lookup 列出发给一个收件人的所有邮件，用于处理收件人的投诉和个人数据查询请求，例如
+	  email-sender.exe history lookup --config config.json user@example.com
+	  配置了 report_salt 时记录中只有地址的哈希，用配置中的 report_salt 计算后比较，这时记录中没有标题
+
+	serve 与 watch 相同，同时提供监控页面，--http 默认为 localhost:8080，只能从本机访问，例如 email-sender.exe serve --config config.json --template t.tpl inbox/
+
+	queue 作为发送服务运行，不停地从配置文件中 queue 指定的消息队列读取发送请求并发送，例如
+	  email-sender.exe queue --config config.json --template t.tpl --report queue.jsonl
+	  应用服务器把发送请求（JSON）放入队列，id 可以省略，data 为模板使用的字段，可以包括 SendAt、Attachments 等列：
+	  {"id": "order-1001", "to": "user@example.com", "subject": "订单已发货", "data": {"Name": "张三", "OrderID": 1001}}
+	  content 不为空时代替模板作为正文；每次最多取出 batch 个请求作为一个任务，与数据文件一样检查和发送，
+	  发送成功后确认；连接失败、4xx 等临时失败的请求等待 retry_delay 秒后重试，之后每次等待加倍，
+	  永久失败（5xx、数据有问题、无法解析）或超过 retries 次的请求加上 attempts 和 error 放到 dead_letter；
+	  收到 Ctrl+C / SIGTERM 时发送完当前这一批再退出，没有确认的请求下次启动时重新发送
+
+	campaign 控制 serve 或 --http 中正在发送的任务，--server 为其监听地址，默认为 localhost:8080，
+	  serve 设置了 --http-token 时用同样的 --http-token 或环境变量 EMAIL_SENDER_HTTP_TOKEN 指定令牌，例如
+	  email-sender.exe campaign list
+	  email-sender.exe campaign pause 3
+	  email-sender.exe campaign rate --interval 2s 3
+	  list 列出任务的编号、状态和进度；pause 发送完当前的邮件后暂停，resume 继续，cancel 取消，retry 重发已结束任务中失败的邮件
+	  （结果追加到原来任务的报告中，不再插入监控邮件，预热时不重复计入当天的发送量）；
+	  rate 调整发送间隔，--interval 为 2s、500ms 这样的时长或毫秒数，自动降速增加的间隔同时恢复，发送下一封邮件前生效；
+	  同样的操作可以直接调用 API：POST /api/campaigns/<任务编号>/<操作>，rate 的间隔为 interval 参数，返回任务的 JSON 状态
+
+	config 查看或检查配置文件，例如 email-sender.exe config show --config config.json
+	  show 输出合并 extends、替换环境变量之后的配置，password、secret、report_salt 等字段显示为 ****，默认为 show；
+	  check 只检查配置是否有效（发件人、附件等配置），不连接服务器
+
+	setup 第一次使用时交互式地生成配置文件，例如 email-sender.exe setup --config config.json
+	  可以选择 163、QQ、Gmail、Office365 的预设服务器和端口，或者输入其他服务器；输入用户名、密码后测试连接和认证，
+	  失败时给出该服务商的提示（例如 163 / QQ 邮箱需要使用授权码）并可以重新输入；
+	  密码可以不写入配置文件，改为从环境变量 EMAIL_SENDER_PASSWORD 读取；配置文件只有当前用户可以读写
+
+	watch 监视目录，例如 email-sender.exe watch --config config.json --template template.tpl inbox/
+	  目录中出现新的 .xlsx / .xls / .ods / .csv 文件（且大小不再变化）后，先检查数据，再发送邮件，
+	  同名的 .json 文件（例如 list.xlsx 与 list.json）作为该文件的配置，没有时使用 --config 指定的配置；
+	  处理完成后文件移动到 done/ 目录，检查不通过或无法连接服务器时移动到 failed/ 目录，
+	  报告文件与数据文件放在一起；
+	  --content / --template 指定的文件修改后会在处理下一个数据文件前重新加载，解析失败时继续使用原来的版本，
+	  多语言模板、--pdf-template、--vcard 每个数据文件处理时都会重新读取
+
+	service 以系统服务方式运行 watch 命令，Linux 使用 systemd，Windows 使用系统服务，日志写入 journal / 事件日志
+	  email-sender.exe service install --config config.json --template template.tpl inbox/ 安装并启动服务，
+	    参数与 watch 命令相同，安装时的当前目录作为服务的工作目录
+	  email-sender.exe service uninstall 停止并卸载服务
+	  email-sender.exe service run ... 由服务管理器调用，停止服务时会等当前邮件发送完再退出
+
+	run 按任务文件执行多个发送任务，例如 email-sender.exe run --config config.json monthly.json
+	  所有任务先加载并检查，全部通过后才开始发送；parallel 为 true 时各任务同时发送，否则按顺序发送：
+	  {
+	    "parallel": false,
+	    "campaigns": [
+	      {
+	        "name": "月度账单",
+	        "data": "bill.xlsx",
+	        "template": "bill.tpl",
+	        "subject": "{{ .Month }} 月账单",
+	        "config": "",
+	        "report": "bill.report.jsonl",
+	        "start_at": "2024-05-01 09:00",
+	        "campaign_id": "2024-05-bill"
+	      }
+	    ]
+	  }
+	  相对路径相对于任务文件所在目录；content / template 未指定时使用命令行的 --content / --template，
+	  config 未指定时使用 --config；subject 支持模板语法，指定时替代 Excel 中的标题；
+	  report 默认为任务文件所在目录中与数据文件同名的 .report.jsonl 文件；start_at 为开始发送的时间，
+	  指定 --http 时等待中的任务也显示在监控页面中，可以取消；campaign_id 未指定时使用 --campaign-id
+
+	replay 重新发送 --spool 目录中保存的发送失败的邮件，例如 email-sender.exe replay --config config.json failed-mail/
+	  邮件按原来渲染好的内容和信封地址发送，不需要原来的 Excel 和模板；发送成功的从目录中删除，
+	  仍然失败的保留并更新失败原因和次数；可以用 --report 记录结果
+
+	import 把 mbox 文件或目录中的 .eml 邮件原样重新发送，例如 email-sender.exe import --config config.json export.mbox
+	  收件人为邮件头 To、Cc 和 Bcc 中的地址，发送前去掉 Bcc、Return-Path、Delivered-To 和 X-Original-To，其他内容不变；
+	  信封发件人为配置的 from，没有配置时使用邮件的 From；可以用 --report 记录结果，
+	  指定了 --spool 时仍然失败的邮件保存到目录中，之后用 replay 重新发送
+
+	resend-failures 只重新发送之前报告中失败的收件人，数据文件和模板与原来相同，例如
+	  email-sender.exe resend-failures --config config.json --template t.tpl --report retry.report.jsonl list.report.jsonl list.xlsx
+	  按行号和收件人在数据文件中查找失败的行，数据文件修改过导致行号变化时按收件人查找；
+	  报告中的收件人之后重试成功的不会重新发送
+
+	follow-up 用另一个模板跟进原来的任务中没有打开邮件的收件人，数据文件与原来相同，例如
+	  email-sender.exe follow-up --config config.json --template reminder.tpl --engaged opened.csv --after-days 3 --report followup.report.jsonl may.report.jsonl list.xlsx
+	  只发送给原来的报告中发送成功、发送后超过 --after-days 天（默认 3 天）、且不在 --engaged 列表中的收件人；
+	  --engaged 为打开过或点击过邮件的收件人，可以是每行一个地址的文本文件，也可以是打开跟踪或邮件服务商导出的 CSV，
+	  每行取第一个邮件地址，可以指定多次；加上 --thread-report may.report.jsonl 可以让跟进邮件与原来的邮件显示在同一个会话中
+
+	doctor 检查配置中发件人（from 和 from_pool）域名的 DNS 记录，例如 email-sender.exe doctor --config config.json
+	  SPF：是否有记录、是否授权了配置的 SMTP 服务器（按服务器解析出的 IP 检查，服务商使用其他出站 IP 时可能误报）、
+	    DNS 查询次数是否超过 10 次；配置了 verp 时检查信封发件人的域名
+	  DKIM：常见的选择器（default、selector1、google 等）下是否有 DKIM 公钥，可以用 --dkim-selector 指定
+	  DMARC：是否有记录、策略，以及信封发件人与 From 域名是否能对齐
+	  有问题时以非 0 状态退出
+
+	test-send 用一行数据渲染邮件，只发送一封到 --to 指定的测试地址，不使用 SendTo 列，例如
+	  email-sender.exe test-send --config config.json --template t.tpl --to me@example.com --row 5 list.xlsx
+	  --row 为 Excel 中的行号，默认第一行数据；也可以用 --fixture 指定 JSON 文件作为这一行的数据，不需要 Excel：
+	  email-sender.exe test-send --config config.json --template t.tpl --to me@example.com --fixture sample.json
+	  sample.json 例如 {"Subject": "5 月账单", "Name": "张三", "Month": 5}，Subject 也可以用 --subject 指定
+
+	lint 检查模板中引用的字段与数据文件的列是否一致，列出没有对应列的字段（例如拼错的 {{ .Nmae }}，
+	  发送时会渲染为 <no value>）和模板中没有使用的列，例如
+	  email-sender.exe lint --config config.json --template t.tpl --subject "{{ .Name }} 的账单" list.xlsx
+	  检查 --template、--subject、--pdf-template 和 --vcard；mustache / handlebars / pongo2 模板按文本查找，结果只供参考
+
+	approve 审批 --hold 提交的发送任务，需要由提交人以外的人执行，例如
+	  email-sender.exe approve --approver lisi staged/2024-05/
+	  --approver 默认为当前登录的用户；审批前可以查看目录中 preview 下每个收件人的邮件预览和 manifest.json
 
 	选项说明：
 	
-	--debug 打印详细信息
+	--debug 打印详细信息，包括完整的 SMTP 会话（EHLO、认证结果、每个收件人的响应），认证凭据和邮件内容不会打印
+	--redact 日志中隐藏收件人地址，例如 alice@example.com 显示为 a***@example.com，服务器返回的错误中的地址也会隐藏；
+	  邮件内容只在 --debug 时打印，fake 发送时只打印收件人和邮件大小。报告、失败邮件目录等文件中保留完整地址
+
+	--lang 指定提示、日志和帮助信息的语言，zh 或 en；默认 LC_ALL / LC_MESSAGES / LANG 以 en 开头时为 en，否则为 zh
+	--error-lang 指定写入报告、通知、统计数据和 --rejected-out 的错误信息的语言，zh 或 en，默认与 --lang 相同；
+	  监控系统按错误信息匹配告警时可以固定这个语言，不受操作人员使用的语言影响
 	
 	--help 显示此帮助信息
 
@@ -390,6 +1735,147 @@ func usage() {
 	--content 指定邮件内容文件路径，文件内容可以包含 html； 与 --template 选项冲突，只能使用一个
 	
 	--template 指定邮件内容模板文件路径，文件内容可以包含 html； 与 --content 选项冲突，只能使用一个
+	  --content / --template 也可以是 Word 文档(.docx)，会转换为 HTML 作为邮件内容，保留标题、粗体、斜体、下划线、
+	  链接和表格，文档中可以直接写 {{ .Name }} 这样的占位符；图片等其他内容会被忽略
+
+	--header / --no-header 指定数据文件第一行是否为表头，默认第一行同时有 SendTo 和 Subject 列时作为表头，
+	  第一行看起来像表头但不完整时（例如只有 Subject 或列名大小写不对）会给出警告
+
+	--content-type 指定邮件内容类型 text/plain 或 text/html，默认内容中有 < 和 > 时作为 html；
+	  Excel 中的 ContentType 列可以为每一行单独指定
+
+	--subject 指定默认的邮件标题，Excel 中 Subject 列为空或没有 Subject 列时使用，支持模板语法，
+	  例如 --subject "{{ .Name }}，您的 {{ .Month }} 月账单"；数据文件为联系人文件(.vcf)时必须指定
+
+	--force-subject 忽略 Excel 中的 Subject 列，所有邮件都使用 --subject 的标题
+
+	--subject-prefix / --subject-suffix 加在所有邮件标题前面 / 后面的文本，例如 --subject-prefix "[TEST] " 标记测试发送，
+	  不需要修改 Excel；标题已经以这段文本开头 / 结尾时不重复添加；预览、审批和报告中的标题同样带有前缀和后缀，
+	  没有指定时使用配置中的 subject_prefix / subject_suffix
+
+	--pdf-template 指定 PDF 附件的 HTML 模板文件路径，每个收件人单独渲染并转换为 PDF 附件
+
+	--pdf-name 指定 PDF 附件文件名，支持模板语法，默认 attachment.pdf
+
+	--attach 指定所有邮件都附带的文件，可以指定多次；可以是 http:// 或 https:// 地址（以及 s3:// / oss://），
+	  第一次发送时下载，之后的邮件使用下载好的文件，地址后加 #sha256=<十六进制校验值> 时校验下载的内容，
+	  Excel 中的 Attachments 列可以为每一行指定附件，格式相同，多个文件用 ; 分隔，
+	  例如 https://bucket.example.com/invoices/1001.pdf#sha256=9f86d0...;./terms.pdf
+
+	--vcard 指定 vCard 名片文件路径(.vcf)，作为附件发送；文件内容支持模板语法，可以访问 Excel 中自定义的列
+
+	--qrcode 指定二维码内容，支持模板语法，如 "https://example.com/checkin/{{ .TicketID }}"；
+	  生成的二维码以内嵌图片的方式附带，在 html 模板中使用 <img src="cid:qrcode.png"> 引用
+
+	--qrcode-size 指定二维码图片尺寸，默认 256 像素
+
+	--lang-pattern 指定多语言模板文件的命名规则，默认 {dir}/{name}.{lang}{ext}；
+	  Excel 中有 Lang 列时，例如 Lang 为 en，则 --template mail/welcome.tpl 会使用 mail/welcome.en.tpl，
+	  文件不存在时使用 --content / --template 指定的默认文件
+
+	--engine 指定 --template、run 任务中的标题以及 --pdf-template、--pdf-name、--vcard、--qrcode 和 invite
+	  使用的模板引擎，默认 go，可选 mustache、handlebars、pongo2；
+	  mustache / handlebars 模板中用 {{ Name }} 访问 Excel 中的列，例如 {{#if Company}}{{ Company }}{{/if}}；
+	  pongo2 使用 Django / Jinja 风格的语法，支持过滤器和 {% extends "base.html" %} 继承，
+	  继承和引用的模板相对于 --template 所在目录查找，例如 {{ Name|title }}、{% if Company %}{{ Company }}{% endif %}
+
+	--transform 指定 Lua 脚本，在渲染前用脚本中的 transform(row) 函数处理每一行；
+	  row 的字段为 SendTo、Subject、Content 和 Excel 中自定义的列，可以直接修改或新增字段，
+	  返回 false 跳过该行，调用 error("原因") 中止发送；脚本中可以用 parse_time(value) 把日期转换为 Unix 时间戳，例如
+	    function transform(row)
+	      if row.Expire == nil then return false end
+	      row.DaysLeft = math.floor((parse_time(row.Expire) - os.time()) / 86400)
+	    end
+
+	--check-html 发送前（以及 validate 时）检查第一个收件人的 HTML 邮件：未闭合的标签、没有 alt 的图片，
+	  并用 HEAD 请求检查其中所有的链接和图片地址，有问题（例如 404）时不发送
+
+	--estimate-size 发送前（以及 validate 时）渲染所有邮件，输出大小分布（最小、中位数、95%、最大）和总传输量，
+	  并列出比中位数大 3 倍以上且超过 100 KB 的邮件，例如不小心嵌入了一张很大的图片
+
+	--sanitize 发送前清理 HTML 邮件内容（包括 Content 列和渲染后的模板）：删除 script、iframe、object 等元素，
+	  删除 onclick 之类的事件属性，以及 javascript:、vbscript:、data: 等危险链接，适用于内容来自用户提交的数据
+
+	--render-out 指定目录，把每个收件人渲染后的标题和邮件内容各写入一个文件，用于发送前审核，不会发送邮件
+
+	--spool 指定目录，发送失败的邮件（包括邮件头）保存为 .eml 文件，旁边的 .json 文件记录信封地址和失败原因，
+	  解决问题（例如额度、认证）后用 replay 命令重新发送
+
+	--archive 指定目录，发送成功的邮件（包括最终的邮件头）保存为 .eml 文件，每次发送在目录下单独建一个以任务标识
+	  和开始时间命名的子目录，文件以行号和收件人命名，用于审计某个收件人实际收到的内容；
+	  以 .zip 结尾时保存为 zip 文件，例如 --archive archive/sent.zip 会生成 archive/sent-<任务标识>-<开始时间>.zip
+
+	--report 指定发送结果报告文件路径，每个收件人一行 JSON，记录行号、收件人、状态和错误信息
+
+	--hold 指定目录，发送前需要其他人审批：第一次运行时只把每封邮件的预览和 manifest.json 写入目录，不发送；
+	  审批人执行 approve 命令后，用同样的参数再运行一次才开始发送，例如
+	  email-sender.exe --config config.json --template t.tpl --hold staged/2024-05/ list.xlsx
+	  审批后数据、模板或配置有变化（渲染出的邮件不同）时需要重新审批；每个审批只能发送一次，
+	  --hold-expiry 指定审批的有效期，默认 24h，过期后重新提交
+
+	--on-missing 指定模板引用的字段不存在（没有这一列或单元格为空）时的处理方式：
+	  default 为默认行为，--subject 等文本模板输出 <no value>，HTML 邮件模板和其他模板引擎输出空；empty 都输出空；
+	  error 时这一行渲染失败，发送前会先检查所有行的正文和附件模板，有问题时不发送，
+	  指定了 --skip-bad-rows 时跳过这些行并记入 --rejected-out；
+	  validate 命令中列出这些行
+
+	--metrics-out 指定 JSON 文件，发送结束后写入整体的统计数据，供报表程序读取：开始和结束时间、总耗时、
+	  各状态的数量(by_status)、失败的 SMTP 响应码分布(error_codes)、每分钟的发送量(throughput)和平均速度；
+	  run 命令中所有任务汇总到同一个文件
+
+	--campaign-id 指定任务标识，写入每封邮件的 X-Campaign-ID 邮件头、每行日志和报告，
+	  模板中可以通过 {{ .CampaignID }} 使用，例如 https://example.com/track?c={{ .CampaignID }}
+
+	--skip-already-sent 指定之前的报告文件，跳过其中同一个 campaign ID 已发送成功的收件人，可以指定多次；
+	  跳过的收件人在新报告中记为 already_sent，新报告可以与之前的报告是同一个文件，
+	  例如 --campaign-id 2024-05 --skip-already-sent report.jsonl --report report.jsonl
+
+	--thread-report 指定之前任务的报告文件，跟进邮件的 In-Reply-To 和 References 设为之前发给同一个收件人的邮件的
+	  Message-ID，在收件人的邮箱中显示在同一个会话里，可以指定多次，后面的报告优先；
+	  每封邮件发送时生成 Message-ID，发送成功的记录在报告的 message_id 中，例如
+	  email-sender.exe send --config config.json --template followup.tpl --subject "Re: 5 月活动" --thread-report may.report.jsonl list.xlsx
+
+	--skip-disposable 跳过一次性邮箱（mailinator.com 等）的收件人，报告中记为 disposable
+
+	--disposable-domains 指定一次性邮箱域名列表文件，每行一个域名，# 开头为注释，替代内置的列表
+
+	--dedupe 同一个收件人只发送一次，后面重复的行在报告中记为 duplicate；
+	  收件人地址读取时会去掉首尾空白并把域名转为小写，判断重复时不区分大小写，
+	  国际化域名与对应的 punycode 视为相同（例如 user@例え.jp 与 user@xn--r8jz45g.jp）
+
+	--skip-bad-rows 数据文件中有问题的行（例如邮箱地址无效、标题为空）不再中止整个发送，
+	  跳过这些行并在日志中列出，其余的行照常发送；不指定时遇到第一行有问题的数据就停止
+
+	--rejected-out 指定文件（.csv 或 .xlsx），把没有发送的行按原来的列写入，并增加一列 Reason 说明原因：
+	  解析出错的行、--transform 脚本跳过的行、一次性邮箱、pre_send_hook 拒绝的和超过大小限制的邮件，
+	  修改后可以只用这个文件重新发送；validate 时只写入解析出错的行
+
+	--warmup 指定预热进度文件，用于新的发件域名或 IP：每天只发送 warmup_schedule 中当天的数量（默认 50, 100, 250, 500, 1000, 2000, 5000，
+	  之后保持最后一个数量），达到上限后停止；之后每天用同样的参数再运行一次，会跳过已经发送成功的收件人继续发送，
+	  例如 email-sender.exe --config config.json --template t.tpl --warmup warmup.json list.xlsx
+	  发送过程中每封邮件在 warmup.json.log 中追加一行，下次运行时合并到 warmup.json；seed_list 的监控邮件不计入发送量
+
+	--send-local-time 指定按收件人所在时区（Timezone 列，没有时为本机时区）的时间发送，例如 09:00：
+	  没有 SendAt 的行在收件人时区的下一个 9 点发送，SendAt 只有日期时在当天 9 点发送
+
+	--dedupe-gmail 判断重复时把 Gmail 地址中的 . 和 + 之后的部分去掉，例如 John.Doe+news@gmail.com 与 johndoe@gmail.com 视为同一个收件人
+
+	--watch-interval 指定 watch 命令检查目录的间隔，默认 5s
+
+	--http 指定监控页面的监听地址，例如 localhost:8080；页面显示进行中和已完成的发送任务、进度和失败列表，
+	  可以暂停、继续、取消任务，或重新发送失败的邮件；主要用于 watch 命令
+
+	--http-token 指定监控页面和 API 的访问令牌，也可以用环境变量 EMAIL_SENDER_HTTP_TOKEN 指定；
+	  监听 :8080 这样其他机器可以访问的地址时必须设置，浏览器打开 http://<地址>/?token=<令牌> 后令牌保存在 cookie 中，
+	  API 使用 Authorization: Bearer <令牌> 头；/unsubscribe 不需要令牌；暂停、取消等操作只接受 POST，并拒绝其他网站提交的表单
+
+	--tui 使用终端交互界面显示发送进度、最近发送的收件人和失败列表，
+	  按 p 暂停/继续，q 取消，↑/↓ 滚动失败列表；不使用 --tui 时在终端中输入 p 回车暂停/继续
+
+	暂停发送：发送过程中向进程发送 SIGUSR1 信号（kill -USR1 <pid>），发送完当前的邮件后暂停所有任务，
+	  发送 SIGUSR2 继续；进程和报告都不受影响，暂停期间服务器断开的连接在继续后自动重新连接（Windows 上使用 p 键或监控页面）
+
+	--workdir 指定工作目录，相对路径都相对于该目录
 
 	配置文件参考：
 	{
@@ -401,13 +1887,261 @@ func usage() {
 	  "interval": 200,
 	  "sender": "fake"
 	}
+
+	* local_addr 指定连接 SMTP 服务器使用的本机 IP 地址或网卡名称，用于有多个出口 IP 的服务器，
+	  例如 "local_addr": "203.0.113.10" 或 "local_addr": "eth1"，SPF 中只授权了其中一个地址时需要指定
+
+	* local_name 指定 EHLO / HELO 中使用的主机名，默认为 localhost；有的服务器要求它与连接 IP 的正向、反向 DNS 一致，
+	  例如 "local_name": "mail.ourdomain.com"
+
+	* 配置 pool 后使用连接池：同时运行的发送任务（例如 run 命令中 parallel 的任务、watch 模式的多批邮件）
+	  连到同一个服务器时共用连接，不会每个任务各自重新连接：
+	  "pool": {"min": 1, "max_idle": 2, "max": 4, "idle_timeout": 300, "max_messages": 100}
+	  min 为一直保持的连接数；max 为最多同时打开的连接数（默认 4），达到后等待其他任务用完；
+	  max_idle 为最多保留的空闲连接数；空闲超过 idle_timeout 秒（默认 300）的连接会关闭，空闲较久的连接使用前先用 NOOP 检查；
+	  每个连接发送 max_messages 封后重新连接，不指定时不限制
+
+	* 配置 notify 后，任务开始、完成和中止（取消或出错）时发送通知到 Slack、钉钉、企业微信的机器人或其他 webhook：
+	  "notify": [
+	    {"type": "dingtalk", "url": "https://oapi.dingtalk.com/robot/send?access_token=...", "secret": "SEC..."},
+	    {"type": "wecom", "url": "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=...", "events": ["complete", "abort"]},
+	    {"type": "slack", "url": "https://hooks.slack.com/services/...", "report_url": "https://files.ourcorp.com/reports/{{ .Report }}"}
+	  ]
+	  通知内容包括任务名称、数量、成功、失败、跳过的数量、用时和报告文件；events 可以是 start、complete、abort，默认全部；
+	  secret 为钉钉机器人的加签密钥；report_url 指定报告的链接，{{ .Report }} 为报告文件名；
+	  type 为空时 POST 包含 event、status、total、sent、failed、skipped、report 等字段的 JSON；
+	  type 为 email 时用同样的 SMTP 配置把通知发给 to 中的邮箱（多个用 , 分隔），任务结束时附带报告文件：
+	  {"type": "email", "to": "ops@ourcorp.com, manager@ourcorp.com", "events": ["complete", "abort"]}
+
+	* 配置 dsn 后，服务器支持 DSN 扩展时在 MAIL FROM / RCPT TO 中附带投递状态通知参数，不支持时忽略：
+	  "dsn": {
+	    "ret": "HDRS",
+	    "notify": "FAILURE,DELAY",
+	    "envid": "{{ .CampaignID }}"
+	  }
+	  ret 为 FULL 或 HDRS；notify 为 NEVER 或 SUCCESS、FAILURE、DELAY 的组合；
+	  envid 支持模板语法，默认为 --campaign-id，退信通知中会带上 envid 和原收件人地址
+
+	* 配置 from_pool 后发件人在池中轮流使用，指定了 segment 的发件人只用于 Excel 中 Segment 列相同的行，
+	  其他行轮流使用没有 segment 的发件人（都有 segment 时使用 from）：
+	  "from_pool": [
+	    {"from": "客户经理 <vip@example.com>", "segment": "vip"},
+	    {"from": "资讯 <news1@example.com>"},
+	    {"from": "资讯 <news2@example.com>"}
+	  ]
+	  池中的地址必须与登录账号(username，不是邮箱地址时为 from)的域名一致
+
+	* 配置 verp 后每封邮件使用单独的信封发件人（Return-Path），退信会发回包含收件人地址的地址，
+	  例如 "verp": "bounce@ourdomain.com" 时发给 user@example.com 的邮件信封发件人为
+	  bounce+user=example.com@ourdomain.com，邮件的 From 不变；收退信的邮箱需要支持 + 后缀
+
+	* bcc_archive 指定后每封邮件都会密送一份到该地址存档，例如 "bcc_archive": "archive@ourcorp.com"；
+	  存档地址只作为信封收件人，不会出现在收件人看到的邮件头中；服务器拒绝存档地址时这封邮件记为失败
+
+	* throttle 指定后根据服务器的响应自动调整发送间隔：收到 421 / 450 / 451（通常表示发送太快）时，
+	  等待后重试这封邮件，并把发送间隔增加 backoff 倍（默认 2 倍，最少 1 秒，最多 max_interval 毫秒，默认 60000），
+	  同一封邮件最多重试 retries 次（默认 3 次）；之后每连续成功 recover 封（默认 20 封）把间隔减少 backoff 倍，
+	  直到恢复为 interval：
+	  "throttle": {"max_interval": 60000, "backoff": 2, "recover": 20, "retries": 3}
+
+	* greylist_delay 指定后，被服务器以 450 / 451 暂时拒绝（灰名单）的收件人不会记为失败，
+	  而是在其他收件人发送完后等待 greylist_delay 秒再重试，最多重试 greylist_retries 次（默认 2 次）：
+	  "greylist_delay": 300,
+	  "greylist_retries": 2
+
+	* 配置 pre_send_hook 后每封邮件发送前都会执行该命令，收件人、发件人、标题和 Excel 中的列以 JSON 通过标准输入传给命令，
+	  命令以非 0 状态退出时跳过该邮件，输出的内容作为原因记录在报告中，例如检查实时的退订状态：
+	  "pre_send_hook": ["/usr/local/bin/check-unsubscribed", "--list", "news"],
+	  "pre_send_hook_timeout": 10
+	  标准输入的内容例如 {"row": 2, "send_to": "user@example.com", "from": "me@example.com", "subject": "...", "meta": {"Name": "..."}}；
+	  命令执行超过 pre_send_hook_timeout 秒（默认 10 秒）或无法执行时同样跳过
+
+	* 配置 spam_check 后开始发送前会把第一个收件人的邮件交给 SpamAssassin(spamd) 或 Rspamd 评分，
+	  输出分数和命中的规则，指定 threshold 时分数达到阈值则不发送，validate 时同样会检查：
+	  "spam_check": {"spamd": "127.0.0.1:783", "threshold": 5}
+	  "spam_check": {"rspamd": "http://127.0.0.1:11333", "password": "", "threshold": 6}
+
+	* max_message_size 指定邮件（包括附件）的最大字节数，服务器在 EHLO 中声明了 SIZE 时取两者中较小的，
+	  超过限制的邮件不会发送，在报告中记为 too_large；validate 时也会按 max_message_size 检查：
+	  "max_message_size": 20971520
+
+	* 配置 zip_attachments 后，超过 threshold 字节的 PDF 附件会压缩为同名的 .zip 再附带，
+	  指定 password 时压缩包使用密码保护（ZipCrypto），password 支持模板语法，可以为每个收件人使用不同的密码：
+	  "zip_attachments": {"threshold": 1048576, "password": "{{ .IDCard }}"}
+	  threshold 为 0 时总是压缩
+
+	* --pdf-template 默认使用本机安装的 Chrome / Chromium（headless）转换 PDF，第一次生成 PDF 时启动，
+	  之后的收件人共用同一个浏览器；没有安装 Chrome 时可以配置 pdf_converter 改用外部命令，
+	  {input} / {output} 会被替换为 HTML 与 PDF 文件路径，例如 wkhtmltopdf：
+	  "pdf_converter": ["wkhtmltopdf", "--quiet", "{input}", "{output}"]
+
+	* 配置 invite 后每封邮件都会附带日历邀请，各字段都支持模板语法，可以访问 Excel 中自定义的列：
+	  "invite": {
+	    "summary": "{{ .Course }} 培训",
+	    "start": "2024-05-01 09:00",
+	    "end": "2024-05-01 11:00",
+	    "timezone": "Asia/Shanghai",
+	    "location": "{{ .Room }}",
+	    "description": "",
+	    "organizer": ""
+	  }
+	  organizer 默认为 from，timezone 默认为本地时区
+
+	* 配置 imap 后每封发送成功的邮件都会通过 IMAP 保存一份到已发送邮件夹：
+	  "imap": {
+	    "host": "imap.163.com",
+	    "port": 993,
+	    "username": "",
+	    "password": "",
+	    "folder": "已发送"
+	  }
+	  username / password 默认与 SMTP 相同，port 默认 993，folder 默认 Sent
+
+	* 配置 image_upload 后 --content / --template 中 <img src="..."> 引用的本地图片（相对于模板所在目录）会在发送前上传到对象存储，
+	  src 替换为 base_url 下的公开地址，代替内嵌图片以减小邮件大小；文件以内容的 SHA-256 命名，修改后会上传为新的文件：
+	  "image_upload": {
+	    "target": "s3://bucket/mail-images",
+	    "base_url": "https://cdn.example.com/mail-images",
+	    "acl": "public-read"
+	  }
+	  target 也可以是 oss://bucket/prefix，访问密钥使用 s3 / oss 的配置；未指定 base_url 时使用对象存储的地址，
+	  地址中有模板语法的图片不会上传
+
+	* extends 指定继承的配置文件，例如共用的 SMTP 设置放在 base.json 中，每个任务的配置只写不同的字段：
+	  {"extends": "base.json", "from": "news@example.com", "interval": 500}
+	  也可以是多个文件 ["smtp.json", "dkim.json"]，按顺序合并，后面的覆盖前面的；对象中的字段逐个合并，数组整个替换，
+	  相对路径相对于当前配置文件所在目录
+
+	* 配置文件中的字符串可以引用环境变量，${NAME} 替换为环境变量 NAME 的值，未设置时报错，
+	  ${NAME:-默认值} 在未设置或为空时使用默认值，$${ 表示 ${ 本身，例如：
+	  "host": "${SMTP_HOST:-smtp.example.com}",
+	  "port": "${SMTP_PORT:-465}",
+	  "password": "${SMTP_PASSWORD}"
+	  整个值只有一个引用且是数字或 true / false 时按数字或布尔值处理，这样配置文件中可以不包含密码
+
+	* seed_list 为内部的监控邮箱，用来观察邮件在 Gmail、Outlook、QQ 等邮箱中是否进入收件箱：
+	  "seed_list": {"addresses": ["seed@gmail.com", "seed@outlook.com", "seed@qq.com"], "every": 1000}
+	  第一封邮件以及之后每 every 封邮件后，用同一行的数据给每个监控邮箱各发一封，every 为 0 时只在开始时发送一次；
+	  报告中这些邮件的 seed 为 true，resend-failures 不会重新发送
+
+	* report_salt 为报告使用的 salt，配置后报告中不写入收件人地址和标题，send_to_hash 为
+	  HMAC-SHA256(report_salt, 小写的收件人地址) 的十六进制值，错误信息中的地址同样换成哈希，
+	  报告可以交给数据分析服务商，持有 salt 的一方可以计算哈希后与自己的数据关联；
+	  --skip-already-sent 和 resend-failures 读取这类报告时需要配置同一个 report_salt
+
+	* subject_prefix / subject_suffix 加在所有邮件标题前面 / 后面的文本，例如内部要求的 "[营销] " 标签，
+	  命令行的 --subject-prefix / --subject-suffix 优先
+
+	* footer 为加在每封邮件正文后面的页脚，例如法律声明，不需要复制到每个模板中：
+	  "footer": {"html": "footer.html", "text": "footer.txt"}
+	  html 用于 HTML 邮件，放在 </body> 前面，text 用于纯文本邮件，只配置了 text 时 HTML 邮件使用转义后的 text；
+	  页脚本身也是模板，可以使用与邮件模板相同的字段，例如 {{ .Name }}、{{ .CampaignID }}；
+	  Content 列指定的正文同样会加上页脚
+
+	* signatures 为多个签名，每一行按 Signature 列选择签名，没有 Signature 列或为空时按 Owner 列（例如客户经理），
+	  都为空时使用名为 default 的签名，没有 default 时不加签名；名称找不到时忽略大小写再找一次：
+	  "signatures": {"zhangsan": {"html": "sig/zhangsan.html", "text": "sig/zhangsan.txt"}, "default": {"text": "sig/team.txt"}}
+	  签名与 footer 一样是模板，加在正文后面、页脚前面；选择的签名不存在的行不发送，可以用 --skip-bad-rows 跳过
+
+	* unsubscribe 为退订链接和退订名单：
+	  "unsubscribe": {"url": "https://mail.example.com", "secret": "随机字符串", "list": "unsubscribed.txt", "text": "退订"}
+	  每个收件人的退订链接为 url/unsubscribe?e=...&t=...，t 为 secret 对收件人地址和 --campaign-id 的 HMAC，不能伪造；
+	  链接加在页脚中（模板中已经用 {{ .UnsubscribeURL }} 放了链接时不再添加），同时设置 List-Unsubscribe 和
+	  List-Unsubscribe-Post 邮件头支持邮箱客户端的一键退订；text 为页脚中链接的文字，默认为 "退订"；
+	  url 指向 serve 命令或 --http 的监控页面，只需要把其中的 /unsubscribe 开放给收件人（这时 --http 需要监听其他机器
+	  可以访问的地址，必须用 --http-token 保护监控页面和 API），打开链接时显示确认按钮，
+	  确认后地址追加到 list 文件（每行一个地址）；发送时跳过 list 中的收件人，报告中状态为 unsubscribed，
+	  只配置 list 时只跳过名单中的收件人，不生成链接
+
+	* history 为本机的 SQLite 发送记录数据库，不存在时自动创建，每发送一封邮件插入一条记录，包括任务、收件人、
+	  Message-ID、状态和时间（配置了 report_salt 时只保存地址的哈希），按任务、收件人、状态和时间建立了索引；
+	  同一台机器上的不同任务可以使用同一个数据库，SQLite 不支持在共享目录中由多台机器同时写入；
+	  frequency_caps 按发送记录限制每个收件人收到的邮件数，例如
+	  "history": "C:/mail/history.db", "frequency_caps": [{"max": 2, "days": 7}, {"max": 5, "days": 30}]
+	  表示所有任务一起计算，每个收件人 7 天内最多 2 封、30 天内最多 5 封；发送每一封邮件前检查，同时运行的其他
+	  进程发送的邮件也会计算在内，超过限制的收件人不发送，报告中状态为 frequency_capped
+
+	* distributed 让多台机器一起发送同一个任务，每台机器使用相同的配置、数据文件和 --campaign-id 运行 send 命令：
+	  "distributed": {"redis": "redis://:密码@10.0.0.5:6379/0", "lease_size": 50, "lease_timeout": 60, "max_per_second": 20}
+	  数据按 lease_size 行（默认 50）分成多段，每台机器从 Redis 领取还没有人发送的段；机器退出后 lease_timeout 秒
+	  （默认 60）内没有续期的段由其他机器接着发送其中还没有发送的行；max_per_second 为所有机器合计每秒最多发送的邮件数，
+	  interval 仍然是每台机器自己的发送间隔；每台机器发送完后等待其他机器发送完才退出，指定了 --report 时报告中是
+	  所有机器的发送结果（不包括发送前就跳过的收件人）；Redis 中的进度保留一周，重新发送同一个任务需要使用新的 --campaign-id
+
+	* queue 为 queue 命令读取发送请求的消息队列，url 支持 Redis、Kafka、NATS、AMQP 和 SQS：
+	  "queue": {"url": "redis://:密码@10.0.0.5:6379/0", "name": "mail", "batch": 20, "retries": 3, "retry_delay": 60, "dead_letter": "mail:dead"}
+	  生产者用 LPUSH 把请求放入列表 name；batch 默认 20，retries 默认 3，retry_delay 默认 60，dead_letter 默认为 name:dead；
+	  取出的请求放在 name:processing:<consumer> 直到确认，consumer 默认为主机名，多台机器可以同时处理同一个队列；
+	  等待重试的请求放在有序集合 name:delayed；url 为 kafka://[用户名:密码@]host:9092[,host:9092...] 时从 topic name 读取
+	  （kafkas:// 使用 TLS，配置了用户名时使用 SASL PLAIN 认证），offset 提交到 consumer group（group，默认为 email-sender），
+	  新的 group 从最新的消息开始，每条消息确认后才提交到它之后，退出后从最早的没有确认的消息重新读取；
+	  多台机器处理同一个 topic 时用 partitions 为每台机器指定不同的分区，例如 "partitions": [0, 1]；
+	  等待重试的消息保存在内存中，dead_letter 为另一个 topic，默认为 name.dead；只支持不压缩和 gzip 压缩的消息；
+	  url 为 nats://[用户名:密码@]host:4222 时订阅 subject name（tls:// 使用 TLS），group 为 queue group，
+	  同一个 group 的多台机器分担消息，等待重试的消息保存在内存中；配置了 stream 时使用 JetStream，
+	  从 stream 中创建名为 group 的 durable consumer 读取 subject name，确认后才删除消息，重试由服务器延迟重新投递；
+	  dead_letter 为发布失败请求的 subject，默认为 name.dead；url 为 amqp://[用户名:密码@]host:5672[/vhost] 时
+	  从 RabbitMQ 队列 name 读取（amqps:// 使用 TLS），处理完才确认，prefetch 为最多未确认的消息数，默认与 batch 相同，最多 1000；
+	  等待重试的请求放到队列 name.retry，过期后由 RabbitMQ 放回 name，dead_letter 为另一个队列，默认为 name.dead，
+	  这两个队列不存在时自动创建；连接断开后没有确认的请求由 RabbitMQ 重新投递；
+	  url 为 sqs://[access key id:secret@]region/account id 时从 AWS SQS 队列 name 读取，没有密钥时使用 AWS_ACCESS_KEY_ID、
+	  AWS_SECRET_ACCESS_KEY、AWS_SESSION_TOKEN，AWS_ENDPOINT_URL 可以指定兼容 SQS 的服务；发送期间自动延长 visibility timeout，
+	  发送完成后删除消息，重试时修改 visibility timeout（最长 12 小时），重试次数为 SQS 的接收次数，
+	  队列配置的 redrive policy 的 maxReceiveCount 应大于 retries；dead_letter 为另一个队列的名字或 queue url，默认为 name-dead
+
+	* defaults 指定列的默认值，单元格为空或没有这一列时使用，在渲染模板和检查 require 之前生效，例如：
+	  "defaults": {"Name": "贵宾", "Discount": "10%"}
+
+	* require 指定不能为空的列，column_types 指定列的格式（int, number, date, email, url），例如：
+	  "require": ["Name", "OrderID"],
+	  "column_types": {"OrderID": "int", "Amount": "number", "Expire": "date"}
+	  发送前检查所有行，有问题时列出每一行为空的列和格式不对的值，不发送；开启 --skip-bad-rows 时跳过这些行，
+	  validate 同样会检查
+
+	* 数据文件和 --content / --template / --pdf-template / --vcard 指定的文件可以是对象存储中的文件，
+	  例如 s3://bucket/lists/today.xlsx 或 oss://bucket/mail/welcome.tpl，发送前下载到临时目录：
+	  "s3": {
+	    "region": "cn-north-1",
+	    "endpoint": "",
+	    "access_key_id": "",
+	    "access_key_secret": "",
+	    "security_token": "",
+	    "path_style": false
+	  },
+	  "oss": {
+	    "endpoint": "oss-cn-hangzhou.aliyuncs.com",
+	    "access_key_id": "",
+	    "access_key_secret": ""
+	  }
+	  未配置的字段从环境变量读取：s3 使用 AWS_ACCESS_KEY_ID、AWS_SECRET_ACCESS_KEY、AWS_SESSION_TOKEN、AWS_REGION、AWS_ENDPOINT_URL，
+	  oss 使用 OSS_ACCESS_KEY_ID、OSS_ACCESS_KEY_SECRET、OSS_SESSION_TOKEN、OSS_ENDPOINT；
+	  endpoint 可以带 http:// 前缀，使用 MinIO 等兼容服务时一般需要开启 path_style；
+	  多语言模板需要放在本地
+
+	* 这些文件也可以是 http:// 或 https:// 地址，http_headers 按主机名配置下载时附带的请求头，例如认证信息：
+	  "http_headers": {
+	    "templates.example.com": {"Authorization": "Bearer --TOKEN--"}
+	  }
+	  地址后加 #sha256=<十六进制校验值> 时会校验下载的内容，不一致则不发送，
+	  例如 --template "https://templates.example.com/welcome.tpl#sha256=9f86d0..."；s3:// / oss:// 地址同样支持
 	
 	邮件内容文件：
 	
 	邮件模板文件：
 	模板文件中可以使用 {{ .Xxxx }} 的语法访问 Excel 文件中自定义的其他列
+	{{ date .Expiry "2006年01月02日" }} 按 Go 的时间格式输出日期列，可以是 2024-05-01 这样的文本或 Excel 的日期数值，
+	handlebars / mustache 中为 {{date Expiry "2006-01-02"}}
+	{{ money .Amount "CNY" }} 输出 ¥1,234.56（USD、EUR、GBP、JPY、HKD 等，JPY / KRW 没有小数），
+	{{ number .Amount 2 }} 输出 1,234.56，{{ fixed .Rate 1 }} 输出 3.5，都是四舍五入
 
 	Excel 源文件说明：
+	数据文件可以是 .xlsx、.xls（Excel 97-2003）、.ods（LibreOffice）或 .csv，读取第一个工作表；
+	.ods 中的日期单元格按 2006-01-02 15:04:05 格式读取，与显示格式无关；
+	.xlsx 中的日期单元格按单元格格式读取为 2006-01-02、15:04:05 或 2006-01-02 15:04:05，而不是 45231 这样的数值
+
+	数据文件也可以是从手机或 Outlook 导出的联系人文件(.vcf)，每个有邮箱的联系人为一行，邮件标题用 --subject 指定（可以使用下面的列）：
+	  EMAIL 为 SendTo（有多个时使用首选的），FN 为 Name，N 为 LastName / FirstName，ORG 为 Org，
+	  TITLE 为 Title，TEL 为 Phone，NOTE 为 Note，可以在模板中使用，例如 {{ .Name }}、{{ .Org }}
 	目前支持两种格式
 	固定格式：
 	SendTo, Subject, Content
@@ -430,5 +2164,18 @@ func usage() {
 	* 表格头（SendTo，Subject，Content）为内置名称，除了 Content 外，都必须提供，顺序无所谓
 	* Content 是可以选的，如果内容不为空则替代 --content / --template 选项指定的内容
 	* Xxx 可以是任意的，并且可以有多个，可以在模板文件中访问
+	* Lang 列用于选择多语言模板，参考 --lang-pattern 选项
+	* Segment 列用于从 from_pool 中选择发件人
+	* Attachments 列指定该行邮件的附件，多个文件用 ; 分隔，可以是 https:// 地址，参考 --attach 选项
+	* ContentType 列指定该行邮件的内容类型 text/plain 或 text/html（也可以写 plain / html），替代自动判断和 --content-type
+	* SendTo 可以使用国际化域名，例如 user@例え.jp，SMTP 信封中自动转为 punycode；
+	  服务器支持 SMTPUTF8 时邮件头保留 Unicode 形式，否则同样使用 punycode；local part 包含中文等字符的地址需要服务器支持 SMTPUTF8
+
+	* SendAt 列指定该行邮件的发送时间，例如 2024-05-01 09:00，也可以是 Excel 的日期单元格；
+	  邮件按发送时间排序，时间未到时等待，没有 SendAt 的行立即发送；watch / service 命令中每个文件单独等待，不影响其他文件
+	* Timezone 列指定收件人所在时区，例如 Asia/Shanghai、America/New_York 或 +08:00，SendAt 按该时区解析，
+	  参考 --send-local-time 选项
+	* InReplyTo 列指定该行邮件回复的 Message-ID，References 列指定会话中之前的 Message-ID（空格分隔），
+	  设置 In-Reply-To 和 References 邮件头，优先于 --thread-report
 `)
 }
@@ -0,0 +1,762 @@
+package main
+
+// englishMessages 为 --lang en / --error-lang en 使用的翻译，键为代码中的中文原文，
+// 格式化参数的个数和顺序需要与原文一致
+var englishMessages = map[string]string{
+	// 通用
+	"第 %d 行 %s：%s":          "row %d %s: %s",
+	"读取 %s 失败：%s":           "failed to read %s: %s",
+	"写入 %s 失败：%s":           "failed to write %s: %s",
+	"%s 第 %d 行：%s":          "%s line %d: %s",
+	"%s 失败：%s":              "%s failed: %s",
+	"%s 已存在":                "%s already exists",
+	"已写入 %s":                "wrote %s",
+	"创建目录失败：%s":             "failed to create directory: %s",
+	"创建报告文件失败：%s":           "failed to create report file: %s",
+	"写入报告失败：%s":             "failed to write report: %s",
+	"无效的 %s %s，只能是 zh 或 en": "invalid %s %s, must be zh or en",
+	"；":                     "; ",
+	"：":                     ": ",
+
+	// 审批
+	"审批已于 %s 过期，重新生成预览":                            "approval expired at %s, generating the preview again",
+	"%s 还没有审批，等待其他人执行：email-sender.exe approve %s": "%s is not approved yet, waiting for someone else to run: email-sender.exe approve %s",
+	"%s 已由 %s 于 %s 审批，开始发送":                        "%s was approved by %s at %s, sending",
+	"邮件内容与之前提交审批的不同，重新生成预览，需要重新审批":                 "the emails differ from the ones staged for approval, generating the preview again, it needs to be approved again",
+	"已提交审批：%d 封邮件的预览在 %s，审批人执行 email-sender.exe approve %s 后，用同样的参数再运行一次开始发送；%s 前有效": "staged for approval: previews of %d emails are in %s; after the approver runs email-sender.exe approve %s, run again with the same arguments to start sending; valid until %s",
+	"%s 中没有等待审批的任务":            "no campaign waiting for approval in %s",
+	"%s 已于 %s 发送":              "%s was already sent at %s",
+	"审批已于 %s 过期，需要重新提交":        "approval expired at %s, the campaign needs to be staged again",
+	"无法确定审批人，请用 --approver 指定": "cannot determine the approver, use --approver",
+	"%s 是提交审批的人，需要由其他人审批":      "%s staged the campaign, it must be approved by someone else",
+	"任务：%s\n":          "Campaign: %s\n",
+	"任务标识：%s\n":        "Campaign ID: %s\n",
+	"提交人：%s（%s）\n":     "Staged by: %s (%s)\n",
+	"邮件数量：%d\n":        "Emails: %d\n",
+	"预览：%s\n":          "Preview: %s\n",
+	"请提供 --hold 指定的目录": "please provide the directory given to --hold",
+	"审批失败：%s":          "approval failed: %s",
+	"已审批，提交人用同样的参数再运行一次开始发送": "approved, the person who staged it can now run again with the same arguments to start sending",
+	"提交审批失败：%s": "failed to stage for approval: %s",
+
+	// 附件
+	"附件 %s 不存在":              "attachment %s does not exist",
+	"下载附件 %s 失败：%s":          "failed to download attachment %s: %s",
+	"读取附件失败：%s":              "failed to read attachment: %s",
+	"解析压缩包密码失败：%s":           "failed to parse the archive password: %s",
+	"渲染压缩包密码失败：%s":           "failed to render the archive password: %s",
+	"压缩附件失败：%s":              "failed to compress attachment: %s",
+	"附件 %s 从 %d 字节压缩为 %d 字节": "attachment %s compressed from %d bytes to %d bytes",
+
+	// 发送任务
+	"保存预热进度失败：%s": "failed to save warm-up progress: %s",
+	"重复的收件人":      "duplicate recipient",
+	"之前已发送":       "already sent",
+	"一次性邮箱":       "disposable address",
+	"%s 预热第 %d 天，今天最多发送 %d 封，已发送 %d 封，本次发送 %d 封": "%s warm-up day %d, at most %d emails today, %d already sent, sending %d now",
+	"%s 跳过 %d 个之前已发送的收件人":                        "%s skipped %d recipients already sent",
+	"%s 跳过 %d 个一次性邮箱":                            "%s skipped %d disposable addresses",
+	"%s 跳过 %d 个重复的收件人":                           "%s skipped %d duplicate recipients",
+	"%s (等待到 %s)":                                "%s (waiting until %s)",
+	" (重试)":                                      " (retry)",
+	"%s 重试失败：%s":                                 "%s retry failed: %s",
+	"预热第 %d 天，还有 %d 个收件人未发送":                     "warm-up day %d, %d recipients not sent yet",
+	"%s 插入 %d 封监控邮件":                             "%s added %d seed emails",
+	"%s 已取消":                                     "%s cancelled",
+	"发送完成，成功 %d 封，失败 %d 封":                       "finished, %d sent, %d failed",
+	"%s 发送完成，成功 %d 封，失败 %d 封":                    "%s finished, %d sent, %d failed",
+	"%s 等待 %d 秒后重试 %d 个被灰名单拒绝的收件人":               "%s waiting %d seconds to retry %d greylisted recipients",
+	"pre_send_hook 跳过 %s: %s":                    "pre_send_hook skipped %s: %s",
+	"处理邮件失败 %s: %s":                              "failed to prepare email %s: %s",
+	"服务器限制发送速度，发送间隔增加到 %v，稍后重试 %s: %s":           "the server is rate limiting, interval increased to %v, retrying %s later: %s",
+	"发送间隔恢复到 %v":                                 "interval back to %v",
+	"跳过 %s: %s":                                  "skipped %s: %s",
+	"暂时无法发送 %s，稍后重试: %s":                         "%s temporarily rejected, retrying later: %s",
+	"发送失败 %s: %s":                                "failed to send %s: %s",
+	"To: %s, 邮件内容：%s":                            "To: %s, content: %s",
+	"To: %s, 发送成功":                               "To: %s, sent",
+	"保存到已发送邮件夹失败 %s: %s":                         "failed to save %s to the sent folder: %s",
+	"保存邮件存档失败 %s: %s":                            "failed to archive %s: %s",
+	"保存邮件存档失败：%v":                                "failed to close the archive: %v",
+	"创建邮件存档失败：%s":                                "failed to create the archive: %s",
+	"%s 等待到 %s 发送":                               "%s waiting until %s to send",
+
+	// 图片上传
+	"上传图片 %s 失败：%s": "failed to upload image %s: %s",
+	"无效的上传地址 %s，格式为 s3://bucket/prefix 或 oss://bucket/prefix": "invalid upload target %s, the format is s3://bucket/prefix or oss://bucket/prefix",
+	"上传 %s: %s":    "uploading %s: %s",
+	"已上传图片 %s: %s": "uploaded image %s: %s",
+
+	// 联系人和内容
+	"vCard 中没有邮件标题，请用 --subject 指定":         "vCard files have no subject, use --subject",
+	"忽略没有邮箱的联系人 %s":                         "ignoring contact %s without an email address",
+	"无效的内容类型 %s，只支持 text/plain 或 text/html": "invalid content type %s, only text/plain or text/html are supported",
+	"无效的 docx 文件，没有 word/document.xml":      "invalid docx file, word/document.xml is missing",
+	"无效的 ods 文件，没有 content.xml":             "invalid ods file, content.xml is missing",
+	"不支持的模板引擎 %s":                           "unsupported template engine %s",
+	"%s 语言的模板 %s 不存在，使用默认模板":                "template %[2]s for language %[1]s does not exist, using the default template",
+	"加载 %s 语言的模板失败：%s":                      "failed to load the template for language %s: %s",
+	"从 %s 中读取邮件内容":                          "reading email content from %s",
+	"读取邮件内容文件失败：%s":                         "failed to read the content file: %s",
+	"使用邮件内容 %s: %s":                         "using email content %s: %s",
+	"读取邮件模板文件失败：%s":                         "failed to read the template file: %s",
+	"解析邮件模板失败：%s":                           "failed to parse the template: %s",
+	"使用邮件模板 %s: %s":                         "using email template %s: %s",
+	"渲染邮件模板失败：%s":                           "failed to render the template: %s",
+	"缺少模板中引用的字段 %s":                         "missing field %s referenced in the template",
+
+	// 监控页面
+	"监控页面：http://%s":  "monitoring page: http://%s",
+	"启动监控页面失败：%s":     "failed to start the monitoring page: %s",
+	"渲染监控页面失败：%s":     "failed to render the monitoring page: %s",
+	"没有可以重试的失败邮件":     "no failed emails to retry",
+	"任务 %s：%s":        "campaign %s: %s",
+	"邮件发送任务":          "Email campaigns",
+	"暂无任务":            "No campaigns",
+	"任务":              "Campaign",
+	"状态":              "Status",
+	"进度":              "Progress",
+	"成功":              "Sent",
+	"失败":              "Failed",
+	"时间":              "Time",
+	"操作":              "Actions",
+	"暂停":              "Pause",
+	"继续":              "Resume",
+	"取消":              "Cancel",
+	"重发失败邮件":          "Resend failures",
+	"行":               "Row",
+	"收件人":             "Recipient",
+	"标题":              "Subject",
+	"错误":              "Error",
+	"加载了 %d 个一次性邮箱域名": "loaded %d disposable domains",
+
+	// doctor
+	"配置中没有发件人地址":                            "no sender address in the config",
+	"警告：无法解析 SMTP 服务器 %s：%s，不检查 SPF 是否授权\n": "warning: cannot resolve SMTP server %s: %s, not checking SPF authorization\n",
+	"警告：":             "warning: ",
+	"SPF：查询 %s 失败：%s": "SPF: lookup of %s failed: %s",
+	"SPF：%s 没有 SPF 记录，收件服务器无法确认 %s 可以代表该域名发信":        "SPF: %s has no SPF record, receiving servers cannot confirm that %s may send for the domain",
+	"SPF：需要 %d 次 DNS 查询，超过 %d 次时收件服务器会判定为 permerror": "SPF: needs %d DNS lookups, receiving servers return permerror above %d",
+	"SPF：%s (%s) 已授权": "SPF: %s (%s) is authorized",
+	"SPF：无法判断 %s (%s) 是否授权（记录中有宏或 exists 机制）":                                      "SPF: cannot tell whether %s (%s) is authorized (the record uses macros or exists)",
+	"SPF：%s (%s) 结果为 %s；服务商使用不同的出站 IP 时可以忽略，否则需要在 SPF 中加入该服务器，例如 include 服务商的 SPF": "SPF: %s (%s) results in %s; ignore this when the provider uses different outbound IPs, otherwise add the server to SPF, e.g. include the provider's SPF",
+	"SPF：%s": "SPF: %s",
+	"DKIM：选择器 %s 的公钥为空，表示已撤销":                                   "DKIM: the key of selector %s is empty, meaning it was revoked",
+	"DKIM：没有找到选择器 %s 的 DKIM 记录，可以用 --dkim-selector 指定服务商使用的选择器": "DKIM: no DKIM record found for selectors %s, use --dkim-selector to give the selector your provider uses",
+	"DKIM：找到选择器 %s；签名域名需要与 %s 一致（或为同一组织域名）才能通过 DMARC 对齐":        "DKIM: found selectors %s; the signing domain must match %s (or share its organizational domain) to align for DMARC",
+	"DMARC：查询失败：%s": "DMARC: lookup failed: %s",
+	"DMARC：没有 DMARC 记录，Gmail、Yahoo 等要求批量发件人配置 DMARC": "DMARC: no DMARC record, Gmail, Yahoo and others require DMARC for bulk senders",
+	"DMARC：%s": "DMARC: %s",
+	"DMARC：策略为 none，只监控不拦截":                                       "DMARC: the policy is none, which only monitors and never blocks",
+	"DMARC：信封发件人 %s 与 From 域名 %s 不一致，SPF 无法对齐，只能依靠 DKIM 通过 DMARC": "DMARC: the envelope sender %s does not match the From domain %s, SPF cannot align and DMARC relies on DKIM alone",
+
+	// 配置
+	"环境变量 %s 未设置":                    "environment variable %s is not set",
+	"无效的 verp 地址 %s":                 "invalid verp address %s",
+	"无效的 DSN ret %s，只能是 FULL 或 HDRS": "invalid DSN ret %s, must be FULL or HDRS",
+	"无效的 DSN notify %s，只能是 NEVER 或 SUCCESS、FAILURE、DELAY 的组合": "invalid DSN notify %s, must be NEVER or a combination of SUCCESS, FAILURE and DELAY",
+	"解析 DSN envid 失败：%s":                               "failed to parse the DSN envid: %s",
+	"渲染 DSN envid 失败：%s":                               "failed to render the DSN envid: %s",
+	"配置文件 %s 循环继承":                                     "config file %s extends itself",
+	"%s: extends 只能是文件名或文件名数组":                         "%s: extends must be a file name or an array of file names",
+	"无效的发件人 %s：%s":                                     "invalid sender %s: %s",
+	"发件人 %s 与登录账号的域名 %s 不一致":                           "sender %s does not match the domain %s of the login account",
+	"from_pool 中没有不限 segment 的发件人，需要配置 from":           "from_pool has no sender without a segment, from is required",
+	"无效的 bcc_archive 地址 %s":                            "invalid bcc_archive address %s",
+	"连接池的 min %d 不能大于 max %d":                          "pool min %d cannot be greater than max %d",
+	"无效的 local_addr %s，需要是 IP 地址或网卡名称":                 "invalid local_addr %s, must be an IP address or a network interface",
+	"网卡 %s 没有可用的 IP 地址":                                "network interface %s has no usable IP address",
+	"列 %s 的类型 %s 无效，只支持 int, number, date, email, url": "invalid type %[2]s for column %[1]s, only int, number, date, email and url are supported",
+	"无效的通知类型 %s，只能是 slack、dingtalk、wecom、email 或为空":    "invalid notify type %s, must be slack, dingtalk, wecom, email or empty",
+	"无效的通知邮箱 %s：%s":                                    "invalid notify address %s: %s",
+	"通知没有指定 url":                                       "notify has no url",
+	"无效的通知事件 %s，只能是 start、complete 或 abort":            "invalid notify event %s, must be start, complete or abort",
+	"解析通知的 report_url 失败：%s":                           "failed to parse the notify report_url: %s",
+	"spam_check 需要配置 spamd 或 rspamd":                   "spam_check needs spamd or rspamd",
+
+	// 模板函数
+	"无效的日期: %s": "invalid date: %s",
+	"无效的数字: %s": "invalid number: %s",
+
+	// pre_send_hook
+	"执行超过 %s": "ran longer than %s",
+
+	// HTML 检查
+	"第 %d 行的图片没有 alt 属性":               "image on line %d has no alt attribute",
+	"第 %d 行的 </%s> 没有对应的开始标签":          "</%[2]s> on line %[1]d has no matching start tag",
+	"第 %d 行的 <%s> 没有闭合":                "<%[2]s> on line %[1]d is not closed",
+	"无效的链接 %s":                         "invalid link %s",
+	"第 %d 行 %s 的邮件没有通过 HTML 检查：\n\t%s": "the email of row %d %s failed the HTML check:\n\t%s",
+	"HTML 检查通过，检查了 %d 个链接":             "HTML check passed, %d links checked",
+
+	// 日历邀请
+	"日历邀请必须配置 summary, start, end": "calendar invitations need summary, start and end",
+	"无效的时区 %s：%s":                  "invalid time zone %s: %s",
+	"无效的日历邀请组织者 %s：%s":             "invalid invitation organizer %s: %s",
+	"解析日历邀请 %s 失败：%s":              "failed to parse invitation %s: %s",
+	"渲染日历邀请 %s 失败：%s":              "failed to render invitation %s: %s",
+	"无效的日历邀请开始时间 %s":               "invalid invitation start time %s",
+	"无效的日历邀请结束时间 %s":               "invalid invitation end time %s",
+	"日历邀请结束时间必须晚于开始时间":             "the invitation must end after it starts",
+
+	// IMAP
+	"IMAP 服务器拒绝连接：%s":  "the IMAP server refused the connection: %s",
+	"IMAP 登录失败：%s":     "IMAP login failed: %s",
+	"连接 IMAP 服务器失败：%s": "failed to connect to the IMAP server: %s",
+
+	// lint
+	"处理 Excel 文件失败：%s\n": "failed to process the Excel file: %s\n",
+	"处理 Excel 文件失败：%s":   "failed to process the Excel file: %s",
+	"空表格":                "empty sheet",
+	"数据文件没有表头，只有 SendTo、Subject、Content 三列": "the data file has no header, only the SendTo, Subject and Content columns",
+	"读取模板 %s 失败：%s\n":                       "failed to read template %s: %s\n",
+	"没有需要检查的模板，请用 --template 指定":            "no template to check, use --template",
+	"%s: 解析模板失败：%s\n":                       "%s: failed to parse the template: %s\n",
+	"%s: 引用的 %s 没有对应的列，会渲染为空或 <no value>":   "%s: %s has no matching column and renders as empty or <no value>",
+	"%s: %s 列不会传给模板":                        "%s: the %s column is not passed to templates",
+	"，是否是 %s？":                              ", did you mean %s?",
+	"模板中没有使用的列：%s\n":                        "columns not used by the templates: %s\n",
+	"模板中引用的字段都有对应的列":                        "every field referenced in the templates has a column",
+
+	// 命令行
+	"无法解析 xls 文件：%v":                                "cannot parse the xls file: %v",
+	"参数列表: %s":                                      "arguments: %s",
+	"切换工作目录失败：%s":                                   "failed to change the working directory: %s",
+	"安装服务失败：%s":                                     "failed to install the service: %s",
+	"服务 %s 已安装":                                     "service %s installed",
+	"卸载服务失败：%s":                                     "failed to uninstall the service: %s",
+	"服务 %s 已卸载":                                     "service %s uninstalled",
+	"请指定 service 操作：install, uninstall, run":        "please give a service action: install, uninstall, run",
+	"请提供 Excel 数据文件":                                "please provide an Excel data file",
+	"请指定配置文件":                                       "please give a config file",
+	"读取配置文件失败：%s":                                   "failed to read the config file: %s",
+	"读取一次性邮箱域名列表失败：%s":                              "failed to read the disposable domain list: %s",
+	"加载脚本失败：%s":                                     "failed to load the script: %s",
+	"无效的 --on-missing %s，只能是 error、empty 或 default": "invalid --on-missing %s, must be error, empty or default",
+	"--header 和 --no-header 不能同时使用":                 "--header and --no-header cannot be used together",
+	"无效的 --send-local-time %s，格式为 15:04":            "invalid --send-local-time %s, the format is 15:04",
+	"读取预热进度失败：%s":                                   "failed to read the warm-up progress: %s",
+	"读取发送记录失败：%s":                                   "failed to read the sending history: %s",
+	"请提供之前的报告文件和 Excel 数据文件":                        "please provide the previous report and the Excel data file",
+	"读取报告文件失败：%s":                                   "failed to read the report: %s",
+	"%s 中没有失败的收件人":                                  "no failed recipients in %s",
+	"运行服务失败：%s":                                     "failed to run the service: %s",
+	"渲染邮件失败：%s":                                     "failed to render the emails: %s",
+	"解析完配置内容：%+v":                                   "parsed config: %+v",
+	"处理完成，有 %d 条待发送邮件":                              "processed, %d emails to send",
+	"创建 Sender 失败：%s":                               "failed to create the sender: %s",
+	"解析第 %d 行出错，%s":                                 "failed to parse row %d, %s",
+	"跳过：%s":                                         "skipped: %s",
+	"共跳过 %d 行有问题的数据，继续发送其余 %d 行":                    "skipped %d bad rows, sending the other %d rows",
+	"脚本跳过第 %d 行":                                    "the script skipped row %d",
+	"脚本跳过":                                          "skipped by the script",
+	"警告：第一行包含 %s，但没有 SendTo 和 Subject 列，按没有表头处理，可以用 --header 或 --no-header 指定": "warning: the first row contains %s but not both SendTo and Subject, treating it as data without a header; use --header or --no-header to override",
+	"最少需要两列(SendTo, Subject)": "at least two columns are needed (SendTo, Subject)",
+	"缺少必需的列 %s":               "missing required column %s",
+	"无效的收件人: %s":              "invalid recipient: %s",
+	"标题不能为空":                  "the subject cannot be empty",
+	"数据与表头对不上":                "the data does not match the header",
+	"收件人不能为空":                 "the recipient cannot be empty",
+	"邮件内容或邮件模板必须指定一个":         "either the email content or the email template is required",
+	"邮件内容或邮件模板只能指定一个":         "only one of the email content and the email template can be given",
+	"写入统计数据失败：%s":             "failed to write the metrics: %s",
+
+	// 字段检查
+	"……共 %d 行有问题":    "... %d rows with problems in total",
+	"模板检查不通过：\n":     "template check failed:\n",
+	"数据检查不通过：\n":     "data check failed:\n",
+	"整数":             "integer",
+	"数字":             "number",
+	"日期":             "date",
+	"邮箱地址":           "email address",
+	"网址":             "URL",
+	"%s 不能为空":        "%s cannot be empty",
+	"%s 不是有效的%s: %s": "%s is not a valid %s: %s",
+
+	// 通知
+	"发送通知邮件给 %s 失败：%v":                 "failed to email the notification to %s: %v",
+	"发送通知失败 %s：%v":                     "failed to send the notification %s: %v",
+	"邮件发送任务 %s 开始，共 %d 封":              "email campaign %s started, %d emails",
+	"邮件发送任务 %s 完成":                     "email campaign %s completed",
+	"邮件发送任务 %s 中止":                     "email campaign %s aborted",
+	"：已取消":                             ": cancelled",
+	"\n共 %d 封，成功 %d 封，失败 %d 封，跳过 %d 封": "\n%d total, %d sent, %d failed, %d skipped",
+	"，用时 %s":                           ", took %s",
+	"\n报告：":                            "\nReport: ",
+
+	// PDF
+	"从 %s 中读取 PDF 模板":     "reading the PDF template from %s",
+	"读取 PDF 模板文件失败：%s":    "failed to read the PDF template file: %s",
+	"解析 PDF 模板失败：%s":      "failed to parse the PDF template: %s",
+	"解析 PDF 文件名失败：%s":     "failed to parse the PDF file name: %s",
+	"渲染 PDF 模板失败：%s":      "failed to render the PDF template: %s",
+	"渲染 PDF 文件名失败：%s":     "failed to render the PDF file name: %s",
+	"生成 PDF 失败：%s":        "failed to generate the PDF: %s",
+	"执行 PDF 转换命令：%s":      "running the PDF converter: %s",
+	"使用 Chrome 转换 PDF：%s": "converting the PDF with Chrome: %s",
+	"Chrome 没有输出内容":       "Chrome produced no output",
+	"启动 Chrome 用于生成 PDF":  "starting Chrome to generate PDFs",
+	"启动 Chrome 失败，请安装 Chrome 或使用 pdf_converter 指定转换命令：%s": "failed to start Chrome, install Chrome or set a converter command with pdf_converter: %s",
+	"转换命令没有输出内容": "the converter produced no output",
+
+	// 发送前检查
+	"%.1f / %.1f，命中规则：%s":   "%.1f / %.1f, rules hit: %s",
+	"估算邮件大小失败：%s":           "failed to estimate the email size: %s",
+	"生成样例邮件失败：%s":           "failed to generate the sample email: %s",
+	"垃圾邮件检查失败：%s":           "spam check failed: %s",
+	"垃圾邮件评分 %s":             "spam score %s",
+	"垃圾邮件评分 %.1f 超过阈值 %.1f": "spam score %.1f reaches the threshold %.1f",
+	"spamd 返回 %s":           "spamd returned %s",
+	"邮件大小 %s 超过限制 %s":       "message size %s exceeds the limit %s",
+	"邮件大小：最小 %s，中位数 %s，95%% %s，最大 %s（第 %d 行），共 %d 封，总计 %s": "message sizes: min %s, median %s, 95%% %s, max %s (row %d), %d emails, %s in total",
+	"警告：第 %d 行 %s 的邮件为 %s，是中位数的 %.1f 倍":                    "warning: the email of row %d %s is %s, %.1f times the median",
+	"警告：共 %d 封邮件明显偏大":                                      "warning: %d emails are unusually large",
+	"警告：有邮件超过 max_message_size %s，这些邮件不会发送":                "warning: some emails exceed max_message_size %s and will not be sent",
+
+	// 二维码
+	"二维码尺寸必须大于 0":      "the QR code size must be greater than 0",
+	"解析二维码内容失败：%s":     "failed to parse the QR code content: %s",
+	"渲染二维码内容失败：%s":     "failed to render the QR code content: %s",
+	"二维码内容为空":          "the QR code content is empty",
+	"To: %s, 二维码内容：%s": "To: %s, QR code content: %s",
+	"生成二维码失败：%s":       "failed to generate the QR code: %s",
+
+	// 没有发送的行
+	"写入没有发送的行失败：%s":   "failed to write the rejected rows: %s",
+	"%d 行没有发送，已写入 %s": "%d rows were not sent, written to %s",
+
+	// 远程文件
+	"下载文件 %s 失败：%s":                "failed to download %s: %s",
+	"无效的地址 %s，格式为 %s://bucket/key": "invalid address %s, the format is %s://bucket/key",
+	"下载 %s: %s":                    "downloading %s: %s",
+	"SHA-256 校验失败，实际为 %x":          "SHA-256 mismatch, got %x",
+	"不支持的校验方式 %s，只支持 sha256":       "unsupported checksum %s, only sha256 is supported",
+	"无效的 SHA-256 校验值 %s":           "invalid SHA-256 checksum %s",
+
+	// 渲染
+	"已渲染 %s":             "rendered %s",
+	"已渲染 %d 封邮件到 %s":     "rendered %d emails to %s",
+	"从 %s 读取了 %d 行发送记录":  "read %[2]d lines of sending history from %[1]s",
+	"按模板引用的字段 %s 缓存邮件内容": "caching email bodies by the template fields %s",
+
+	// 报告
+	"报告中的收件人地址已哈希，需要在配置中指定生成报告时使用的 report_salt": "recipient addresses in the report are hashed, the config needs the report_salt used to write it",
+	"第 %d 行 %s 在数据文件中移到了第 %d 行":                 "row %d %s moved to row %d in the data file",
+	"数据文件中找不到第 %d 行 %s，跳过":                      "row %d %s not found in the data file, skipping",
+	"数据文件中没有找到报告里失败的收件人":                        "none of the failed recipients in the report were found in the data file",
+	"报告中有 %d 个失败的收件人，重新发送其中 %d 个":               "%d failed recipients in the report, resending %d of them",
+
+	// run
+	"读取任务文件失败：%s":       "failed to read the job file: %s",
+	"解析任务文件失败：%s":       "failed to parse the job file: %s",
+	"任务文件 %s 中没有任务":     "no campaigns in the job file %s",
+	"%s 检查不通过：\n%s":     "%s failed the checks:\n%s",
+	"没有指定数据文件":          "no data file given",
+	"解析标题失败：%s":         "failed to parse the subject: %s",
+	"无效的开始时间 %s，格式为 %s": "invalid start time %s, the format is %s",
+	"%s 将于 %s 开始":       "%s will start at %s",
+	"开始任务 %s":           "starting campaign %s",
+
+	// 发送时间
+	"无效的时区: %s":   "invalid time zone: %s",
+	"无效的时区偏移: %s": "invalid time zone offset: %s",
+	"无效的发送时间: %s": "invalid sending time: %s",
+
+	// 服务
+	"服务 %s 未安装":     "service %s is not installed",
+	"收到 %s 信号，停止服务": "received %s, stopping the service",
+	"服务 %s 已存在":     "service %s already exists",
+	"注册事件日志失败：%s":   "failed to register the event log: %s",
+	"停止服务":          "stopping the service",
+
+	// SMTP
+	"SMTP 已连接 %s": "SMTP connected to %s",
+	"服务器 %s 不支持 DSN，不使用 DSN 参数":        "server %s does not support DSN, not using DSN parameters",
+	"SMTP 连接已断开，重新连接":                  "the SMTP connection was closed, reconnecting",
+	"服务器 %s 不支持 SMTPUTF8，无法发送国际化地址 %s": "server %s does not support SMTPUTF8, cannot send to the internationalized address %s",
+	"使用 SMTPUTF8 发送国际化地址 %s":           "using SMTPUTF8 for the internationalized address %s",
+	"未加密的连接不支持 LOGIN 认证":               "LOGIN authentication is not supported on unencrypted connections",
+	"SMTP 服务器地址不匹配":                    "the SMTP server name does not match",
+	"未知的 LOGIN 认证响应：%s":                "unknown LOGIN authentication challenge: %s",
+	"连接池中的连接已不可用：%s":                   "the pooled connection is no longer usable: %s",
+	"SMTP %s > <邮件内容 %s>":              "SMTP %s > <message content %s>",
+
+	// replay
+	"保存失败的邮件失败：%s":           "failed to save the failed email: %s",
+	"%s 中没有需要重新发送的邮件":        "no emails to resend in %s",
+	"读取 %s 的信封信息失败，跳过":       "failed to read the envelope of %s, skipping",
+	"重新发送失败 %s: %s":          "failed to resend %s: %s",
+	"更新 %s 失败：%s":            "failed to update %s: %s",
+	"重新发送成功 %s":              "resent %s",
+	"重新发送完成，成功 %d 封，失败 %d 封": "resend finished, %d sent, %d failed",
+
+	// 标题
+	"--force-subject 需要同时指定 --subject": "--force-subject needs --subject",
+	"解析 --subject 失败：%s":               "failed to parse --subject: %s",
+	"渲染标题失败：%s":                        "failed to render the subject: %s",
+
+	// test-send
+	"请用 --to 指定有效的测试收件人":                         "please give a valid test recipient with --to",
+	"数据文件中没有第 %d 行":                              "there is no row %d in the data file",
+	"用 %s 中的数据发送测试邮件到 %s":                        "sending a test email to %[2]s with the data from %[1]s",
+	"用第 %d 行 %s 的数据发送测试邮件到 %s":                   "sending a test email to %[3]s with the data of row %[1]d %[2]s",
+	"测试邮件没有发送成功":                                 "the test email was not sent",
+	"测试邮件已发送到 %s":                                "test email sent to %s",
+	"没有邮件标题，请在 JSON 中指定 Subject 或用 --subject 指定": "no subject, set Subject in the JSON or use --subject",
+
+	// transform
+	"脚本中止处理：%s":          "the script aborted: %s",
+	"脚本中没有定义 %s(row) 函数": "the script does not define a %s(row) function",
+	"%s 返回了无效的值 %s":      "%s returned an invalid value %s",
+	"字段 %s 不能是 table":    "field %s cannot be a table",
+
+	// tui
+	"不是终端，不使用交互界面":                    "not a terminal, not using the interactive UI",
+	"邮件发送 %s  [%s]":                   "Sending %s  [%s]",
+	"%s%s %3d%%  %d/%d  成功 %d  失败 %d": "%s%s %3d%%  %d/%d  sent %d  failed %d",
+	"正在发送：%s":                         "Sending: %s",
+	"最近发送：":                           "Recent:",
+	"失败列表（%d）：":                       "Failures (%d):",
+	"失败列表（%d）":                        "Failures (%d)",
+	"日志：":                             "Log:",
+	"p 暂停/继续  q 取消  ↑/↓ 滚动失败列表":       "p pause/resume  q cancel  ↑/↓ scroll failures",
+
+	// validate
+	"第 %d 行 %s:\n":     "row %d %s:\n",
+	"第 %d 行:\n":        "row %d:\n",
+	"共 %d 行，%d 行有问题\n": "%d rows, %d with problems\n",
+
+	// vCard
+	"从 %s 中读取 vCard":   "reading the vCard from %s",
+	"读取 vCard 文件失败：%s": "failed to read the vCard file: %s",
+	"解析 vCard 模板失败：%s": "failed to parse the vCard template: %s",
+	"渲染 vCard 失败：%s":   "failed to render the vCard: %s",
+	"无效的 vCard 内容：%s":  "invalid vCard content: %s",
+
+	// watch
+	"开始监视目录 %s":               "watching directory %s",
+	"读取目录失败：%s":               "failed to read the directory: %s",
+	"停止监视目录 %s":               "stopped watching directory %s",
+	"重新加载 %s 失败，继续使用原来的版本：%s": "failed to reload %s, keeping the previous version: %s",
+	"已重新加载 %s":                "reloaded %s",
+	"处理文件 %s":                 "processing %s",
+	"读取配置文件 %s 失败：%s\n":       "failed to read the config file %s: %s\n",
+	"%s 使用配置文件 %s":            "%s uses config file %s",
+	"%s 处理失败：%s":              "processing %s failed: %s",
+	"移动文件 %s 失败：%s":           "failed to move %s: %s",
+	"任务已取消，已发送的收件人见 %s\n":     "the campaign was cancelled, see %s for the recipients already sent\n",
+	"%s 处理失败":                 "processing %s failed",
+
+	// 命令和选项
+	"send [选项] <数据文件>":     "send [options] <data file>",
+	"发送邮件，默认命令":            "sends the emails, this is the default command",
+	"validate [选项] <数据文件>": "validate [options] <data file>",
+	"只检查数据不发送邮件：校验所有收件人地址、必需的列，并用模板渲染每一行，按行列出所有问题": "only checks the data without sending: validates every recipient address and the required columns, renders every row with the template and lists all problems by row",
+	"preview [选项] <数据文件>": "preview [options] <data file>",
+	"渲染邮件但不发送：指定 --render-out 时把每个收件人的邮件写入目录，否则输出 --row 指定的一行（默认第一行）完整的邮件": "renders the emails without sending: with --render-out writes every recipient's email to the directory, otherwise prints the complete message of the row given by --row (the first row by default)",
+	"report [选项] <报告文件>...": "report [options] <report file>...",
+	"汇总 --report 生成的报告：各状态的数量、失败的 SMTP 响应码和失败的收件人，同一个收件人以最后一次结果为准": "summarizes reports written by --report: counts per status, SMTP codes of failures and the failed recipients, using the last result of each recipient",
+	"serve [选项] <目录>": "serve [options] <directory>",
+	"监视目录并自动发送，同时在 --http 指定的地址（默认 localhost:8080）提供监控页面": "watches a directory and sends automatically, serving the dashboard on the address given by --http (localhost:8080 by default)",
+	"config [show | check] [选项]": "config [show | check] [options]",
+	"show 输出合并 extends、替换环境变量之后的配置，密码等敏感字段以 **** 显示；check 只检查配置是否有效": "show prints the config after merging extends and substituting environment variables, with secrets such as passwords shown as ****; check only checks that the config is valid",
+	"watch [选项] <目录>":                             "watch [options] <directory>",
+	"监视目录，出现新的数据文件后检查并发送":                         "watches a directory, checking and sending new data files as they appear",
+	"service install | uninstall | run [选项] <目录>": "service install | uninstall | run [options] <directory>",
+	"以系统服务方式运行 watch 命令":                          "runs the watch command as a system service",
+	"run [选项] <任务文件>":                             "run [options] <campaign file>",
+	"按任务文件执行多个发送任务":                               "runs the campaigns listed in a campaign file",
+	"replay [选项] <目录>":                            "replay [options] <directory>",
+	"重新发送 --spool 目录中保存的发送失败的邮件":                  "resends the failed emails saved in a --spool directory",
+	"resend-failures [选项] <报告文件> <数据文件>":          "resend-failures [options] <report file> <data file>",
+	"只重新发送之前报告中失败的收件人":                            "resends only the recipients that failed in a previous report",
+	"doctor [选项]": "doctor [options]",
+	"检查发件人域名的 SPF、DKIM 和 DMARC 记录":    "checks the SPF, DKIM and DMARC records of the sender domains",
+	"test-send [选项] [<数据文件>]":         "test-send [options] [<data file>]",
+	"用一行数据渲染邮件，只发送一封到 --to 指定的测试地址":   "renders one row and sends a single email to the test address given by --to",
+	"approve [选项] <目录>":               "approve [options] <directory>",
+	"审批 --hold 提交的发送任务":               "approves a campaign staged with --hold",
+	"lint [选项] <数据文件>":                "lint [options] <data file>",
+	"检查模板中引用的字段与数据文件的列是否一致":           "checks that the fields referenced in the templates match the columns of the data file",
+	"用法：email-sender.exe %s\n\n":      "Usage: email-sender.exe %s\n\n",
+	"选项：":                             "Options:",
+	"值":                               "value",
+	"（默认 %s）":                         " (default %s)",
+	"email-sender.exe --help 查看完整的说明": "see email-sender.exe --help for the full documentation",
+	"用法：email-sender.exe <命令> [选项] <参数>\n\n命令：\n%s\nemail-sender.exe help <命令> 查看命令的选项，email-sender.exe --help 查看完整的说明\n": "Usage: email-sender.exe <command> [options] <arguments>\n\nCommands:\n%s\nsee email-sender.exe help <command> for the options of a command, and email-sender.exe --help for the full documentation\n",
+	"请提供报告文件":                   "please provide the report files",
+	"请指定 config 操作：show, check": "please specify the config action: show, check",
+	"配置无效：%s":                   "invalid config: %s",
+	"配置文件 %s 有效":                "the config file %s is valid",
+	"没有指定 host":                 "host is not set",
+	"收件人：%d\n":                  "recipients: %d\n",
+	"失败的响应码：":                   "failure codes:",
+	"失败的收件人：":                   "failed recipients:",
+	"  第 %d 行 %s：%s\n":          "  row %d %s: %s\n",
+
+	// 选项说明
+	"配置文件":      "config file",
+	"邮件内容":      "email content",
+	"邮件模板":      "email template",
+	"PDF 附件模板":  "PDF attachment template",
+	"PDF 附件文件名": "PDF attachment file name",
+	"所有邮件都附带的文件，可以是 http(s):// 地址，可以指定多次": "file attached to every email, may be an http(s):// URL, can be given multiple times",
+	"vCard 名片附件":  "vCard attachment",
+	"二维码内容模板":     "QR code content template",
+	"二维码图片尺寸(像素)": "QR code image size (pixels)",
+	"多语言模板文件命名规则": "naming pattern of language variant templates",
+	"Subject 列为空或没有时使用的邮件标题，支持模板语法":          "subject used when the Subject column is empty or missing, supports template syntax",
+	"忽略 Subject 列，全部使用 --subject":            "ignore the Subject column and always use --subject",
+	"邮件内容类型：text/plain 或 text/html，默认自动判断":   "content type: text/plain or text/html, detected by default",
+	"数据文件第一行是表头":                             "the first row of the data file is a header",
+	"数据文件没有表头":                               "the data file has no header",
+	"模板引擎：go, mustache, handlebars, pongo2":  "template engine: go, mustache, handlebars, pongo2",
+	"处理每一行数据的 Lua 脚本":                        "Lua script that transforms every row",
+	"发送前检查 HTML 和链接":                         "check the HTML and links before sending",
+	"发送前统计所有邮件的大小":                           "estimate the size of all emails before sending",
+	"发送前清理 HTML 中的脚本、事件属性和危险链接":              "strip scripts, event attributes and dangerous links from the HTML before sending",
+	"渲染邮件到指定目录，不发送":                          "render the emails to the directory without sending",
+	"发送结果报告文件(JSON Lines)":                   "report file of the results (JSON Lines)",
+	"保存发送失败邮件的目录":                            "directory that keeps the failed emails",
+	"保存发送成功邮件的目录或 .zip 文件":                   "directory or .zip file that keeps the sent emails",
+	"模板引用的字段不存在时的处理方式：error、empty 或 default": "what to do when a template field is missing: error, empty or default",
+	"发送结束后写入统计数据的 JSON 文件":                   "JSON file the metrics are written to after sending",
+	"发送前需要审批，预览和审批记录保存在该目录":                  "require approval before sending, previews and approvals are kept in this directory",
+	"审批的有效期":                                 "how long an approval stays valid",
+	"approve 命令的审批人，默认为当前用户":                 "approver of the approve command, defaults to the current user",
+	"doctor 命令检查的 DKIM 选择器，可以指定多次":           "DKIM selector checked by doctor, can be given multiple times",
+	"test-send 命令的测试收件人":                     "test recipient of test-send",
+	"test-send 和 preview 命令使用的行号，默认第一行数据":    "row used by test-send and preview, defaults to the first data row",
+	"test-send 命令使用的 JSON 数据文件，替代 Excel":     "JSON data file used by test-send instead of Excel",
+	"任务标识，写入邮件头、日志和报告":                       "campaign identifier, written to headers, logs and reports",
+	"跳过报告文件中已发送成功的收件人，可以指定多次":                "skip recipients already sent in the report file, can be given multiple times",
+	"跳过一次性邮箱":                                "skip disposable mailboxes",
+	"一次性邮箱域名列表文件":                            "file listing disposable domains",
+	"跳过重复的收件人":                               "skip duplicate recipients",
+	"判断重复时忽略 Gmail 地址中的 . 和 + 后缀":            "ignore . and + suffixes in Gmail addresses when detecting duplicates",
+	"跳过有问题的行继续发送":                            "skip bad rows and keep sending",
+	"没有发送的行及原因写入的文件(.csv 或 .xlsx)":           "file the unsent rows and reasons are written to (.csv or .xlsx)",
+	"预热进度文件，按每天的发送量逐步增加":                     "warm-up state file, increasing the daily volume gradually",
+	"按收件人所在时区的时间发送，例如 09:00":                 "send at this time in each recipient's time zone, e.g. 09:00",
+	"监视目录的检查间隔":                              "how often the watched directory is checked",
+	"监控页面监听地址":                               "listen address of the dashboard",
+	"终端交互界面":                                 "terminal user interface",
+	"工作目录":                                   "working directory",
+	"调试模式，输出详细日志":                            "debug mode, print detailed logs",
+	"日志中隐藏收件人地址":                             "hide recipient addresses in logs",
+	"显示帮助信息":                                 "show help",
+	"界面语言：zh 或 en":                           "interface language: zh or en",
+	"报告和通知中错误信息的语言，默认与 --lang 相同":            "language of errors in reports and notifications, defaults to --lang",
+
+	// setup
+	"交互式地选择邮件服务商、输入账号和发件人，测试连接后写入配置文件": "interactively chooses the mail provider, asks for the account and sender, tests the connection and writes the config file",
+	"配置失败：%s": "setup failed: %s",
+	"163 邮箱需要在网页版设置中开启 SMTP 服务，密码填写授权码而不是登录密码":           "163 mail requires enabling SMTP in the web settings; use the authorization code as the password, not the login password",
+	"QQ 邮箱需要在网页版设置中开启 SMTP 服务，密码填写授权码而不是 QQ 密码":          "QQ mail requires enabling SMTP in the web settings; use the authorization code as the password, not the QQ password",
+	"Gmail 需要开启两步验证并创建应用专用密码，密码填写应用专用密码":                 "Gmail requires 2-Step Verification and an app password; use the app password as the password",
+	"Office365 需要管理员为该邮箱开启 SMTP AUTH，开启了多重身份验证时需要使用应用密码": "Office365 requires an administrator to enable SMTP AUTH for the mailbox; use an app password when multi-factor authentication is on",
+	"其他":           "other",
+	"输入已结束":        "end of input",
+	"%s 已存在，是否覆盖":  "%s already exists, overwrite it",
+	"选择邮件服务商：":     "Choose the mail provider:",
+	"服务商":          "Provider",
+	"请输入 1 到 %d\n": "please enter 1 to %d\n",
+	"SMTP 服务器":     "SMTP server",
+	"端口（465 为 SSL，587 / 25 使用 STARTTLS）": "Port (465 for SSL, 587 / 25 for STARTTLS)",
+	"无效的端口":                            "invalid port",
+	"用户名（通常为邮箱地址）":                     "Username (usually the email address)",
+	"密码":                               "Password",
+	"正在连接 %s:%d ...\n":                 "connecting to %s:%d ...\n",
+	"连接和认证成功":                          "connected and authenticated",
+	"连接失败：%s\n":                        "connection failed: %s\n",
+	"重新输入用户名和密码":                       "Enter the username and password again",
+	"仍然保存配置":                           "Save the config anyway",
+	"没有保存配置":                           "the config was not saved",
+	"发件人，例如 张三 <zhangsan@example.com>": "From, e.g. John Smith <john@example.com>",
+	"无效的发件人地址":                         "invalid sender address",
+	"把密码保存到配置文件中（否则从环境变量 %s 读取）":                                               "Save the password in the config file (otherwise it is read from the %s environment variable)",
+	"已写入 %s，可以用 email-sender.exe test-send --config %s --to 你的邮箱 ... 发送测试邮件\n": "wrote %s, send a test email with email-sender.exe test-send --config %s --to your-address ...\n",
+
+	// 标题前缀
+	"加在所有邮件标题前面的文本，例如 \"[TEST] \"": "text added before every subject, e.g. \"[TEST] \"",
+	"加在所有邮件标题后面的文本":                "text added after every subject",
+
+	// 页脚
+	"渲染页脚失败：%s": "failed to render the footer: %s",
+
+	// 退订
+	"unsubscribe 配置了 url 时必须配置 secret": "unsubscribe requires secret when url is set",
+	"读取退订名单失败：%s":                      "failed to read the unsubscribe list: %s",
+	"退订":                               "Unsubscribe",
+	"退订链接无效":                           "invalid unsubscribe link",
+	"%s 将不再收到这类邮件":                     "%s will no longer receive these emails",
+	"确认退订":                             "Confirm unsubscribe",
+	"写入退订名单失败：%s":                      "failed to write the unsubscribe list: %s",
+	"退订失败，请稍后再试":                       "unsubscribing failed, please try again later",
+	"%s 已退订，任务 %s":                     "%s unsubscribed, campaign %s",
+	"退订名单中有 %d 个地址":                    "the unsubscribe list has %d addresses",
+	"已退订，%s 将不再收到这类邮件":                 "unsubscribed, %s will no longer receive these emails",
+	"已退订":                              "unsubscribed",
+	"%s 跳过 %d 个已退订的收件人":                "%s skipped %d unsubscribed recipients",
+
+	// 签名
+	"页脚：%s":       "footer: %s",
+	"解析 %s 失败：%s": "failed to parse %s: %s",
+	"渲染签名失败：%s":   "failed to render the signature: %s",
+	"签名 %s：%s":    "signature %s: %s",
+	"没有名为 %s 的签名": "there is no signature named %s",
+	"签名检查不通过：\n":  "signature check failed:\n",
+
+	// 会话
+	"之前任务的报告文件，跟进邮件回复其中发给同一个收件人的邮件，可以指定多次": "report of a previous campaign; follow-ups reply to the email sent to the same recipient, can be given multiple times",
+
+	// 跟进
+	"follow-up 命令使用的打开过邮件的收件人列表，可以指定多次":                "recipients who opened the email, used by follow-up, can be given multiple times",
+	"follow-up 命令只跟进发送后超过这么多天的收件人":                     "follow-up only targets recipients sent more than this many days ago",
+	"请提供原来任务的报告文件和 Excel 数据文件":                         "please provide the report of the original campaign and the Excel data file",
+	"%s 中没有需要跟进的收件人":                                   "there are no recipients to follow up in %s",
+	"读取打开过邮件的收件人失败：%s":                                 "failed to read the recipients who opened: %s",
+	"follow-up [选项] <原来的报告文件> <数据文件>":                  "follow-up [options] <original report file> <data file>",
+	"用另一个模板跟进原来的任务中发送成功、超过 --after-days 天且没有打开过邮件的收件人": "sends a variant template to recipients of the original campaign sent successfully more than --after-days days ago who did not open it",
+	"%d 个收件人发送后还不到 %d 天，这次不发送":                         "%d recipients were sent less than %d days ago and are not followed up this time",
+	"从 %s 读取了打开过邮件的收件人":                                "read the recipients who opened from %s",
+	"没有需要跟进的收件人":                                       "there are no recipients to follow up",
+	"报告中有 %d 个可以跟进的收件人，其中 %d 个打开过邮件，跟进其余 %d 个":         "the report has %d recipients to follow up, %d of them opened the email, following up the other %d",
+
+	// 发送记录
+	"配置了 frequency_caps 时必须配置 history":     "history must be set when frequency_caps is set",
+	"无效的 frequency_caps：max 和 days 必须大于 0": "invalid frequency_caps: max and days must be greater than 0",
+	"打开发送记录失败：%s":                          "failed to open the send history: %s",
+	"%d 天内已收到 %d 封邮件，达到限制 %d 封":            "received %[2]d emails in %[1]d days, reaching the cap of %[3]d",
+	"保存发送记录失败：%s":                          "failed to save the send history: %s",
+
+	// 发送记录查询
+	"history 命令只列出这些状态的记录，多个状态用逗号分隔，例如 failed,frequency_capped": "the history command only lists records with these statuses, separated by commas, e.g. failed,frequency_capped",
+	"请用 --history 或配置文件中的 history 指定发送记录文件":                     "please give the send history file with --history or history in the config file",
+	"无效的 --since %s，格式为 30d、12h 或 2006-01-02":                   "invalid --since %s, the format is 30d, 12h or 2006-01-02",
+	"共 %d 条记录\n":   "%d records\n",
+	"请提供要查询的收件人地址": "please provide the recipient address to look up",
+
+	// 暂停
+	"收到 SIGUSR1 信号，发送完当前的邮件后暂停": "received SIGUSR1, pausing after the current email",
+	"收到 SIGUSR2 信号，继续发送":        "received SIGUSR2, resuming",
+	"输入 p 回车暂停或继续发送":            "type p and Enter to pause or resume sending",
+	"继续发送":                      "resuming",
+	"发送完当前的邮件后暂停，输入 p 回车继续":     "pausing after the current email, type p and Enter to resume",
+
+	// 远程控制
+	"campaign 命令连接的 serve / --http 地址":        "the serve / --http address the campaign command connects to",
+	"campaign rate 设置的新发送间隔，例如 2s、500ms 或毫秒数": "the new sending interval set by campaign rate, e.g. 2s, 500ms or milliseconds",
+	"连接 %s 失败：%s": "failed to connect to %s: %s",
+	"campaign [list | pause | resume | cancel | retry | rate] [选项] [<任务编号>]": "campaign [list | pause | resume | cancel | retry | rate] [options] [<number>]",
+	"控制 serve 或 --http 中正在发送的任务：列出任务、暂停、继续、取消、重发失败的邮件或用 --interval 调整发送间隔":   "controls running campaigns of serve or --http: list, pause, resume, cancel, resend failures or change the sending interval with --interval",
+	"无效的发送间隔 %s，例如 2s、500ms 或毫秒数":                                            "invalid sending interval %s, e.g. 2s, 500ms or milliseconds",
+	"没有任务 %s": "there is no campaign %s",
+	"%-4s %-10s %d/%d  成功 %d  失败 %d  %s\n":                     "%-4s %-10s %d/%d  sent %d  failed %d  %s\n",
+	"请指定 campaign 操作：list, pause, resume, cancel, retry, rate": "please specify the campaign action: list, pause, resume, cancel, retry, rate",
+	"请提供任务编号，可以用 campaign list 查看":                             "please provide the campaign number, see campaign list",
+	"rate 需要用 --interval 指定新的发送间隔，例如 --interval 2s":            "rate needs the new sending interval given by --interval, e.g. --interval 2s",
+	"%s 发送间隔调整为 %v":                                            "%s sending interval changed to %v",
+
+	// 分布式发送
+	"无效的 Redis 地址，格式为 redis://[:密码@]host[:port][/db]": "invalid Redis address, the format is redis://[:password@]host[:port][/db]",
+	"Redis 认证失败：%s": "Redis authentication failed: %s",
+	"Redis 应答格式错误":  "malformed Redis reply",
+	"分布式发送需要用 --campaign-id 指定任务标识，所有机器使用相同的标识": "distributed sending needs a campaign ID given by --campaign-id, the same on all machines",
+	"连接 Redis 失败：%s":         "failed to connect to Redis: %s",
+	"分布式发送，机器标识 %s":          "distributed sending, worker %s",
+	"读取分布式发送进度失败：%s":         "failed to read the distributed progress: %s",
+	"领取第 %d 段失败：%s":          "failed to lease range %d: %s",
+	"第 %d 段的领取已过期":           "the lease of range %d has expired",
+	"分布式限速失败：%s":             "distributed rate limiting failed: %s",
+	"接着发送其他机器没有发送完的 %d 个收件人": "sending %d recipients left unsent by other machines",
+	"等待其他机器发送完 %d 段":         "waiting for other machines to finish %d ranges",
+	"保存分布式发送结果失败：%s":         "failed to save the distributed result: %s",
+	"写入合并的报告失败：%s":           "failed to write the combined report: %s",
+
+	// 消息队列
+	"queue [选项]": "queue [options]",
+	"作为发送服务运行：不停地从配置文件中 queue 指定的消息队列读取发送请求并发送，临时失败的请求稍后重试，永久失败的放到 dead_letter":                        "run as a delivery worker: continuously read send requests from the message queue given by queue in the config file and send them, retrying temporary failures later and moving permanent failures to dead_letter",
+	"不支持的队列地址，url 以 redis://、rediss://、kafka://、kafkas://、nats://、tls://、amqp://、amqps:// 或 sqs:// 开头": "unsupported queue address, url must start with redis://, rediss://, kafka://, kafkas://, nats://, tls://, amqp://, amqps:// or sqs://",
+	"queue 命令需要在配置文件中配置 queue 的 url 和 name":                                                            "the queue command needs url and name of queue in the config file",
+	"开始处理队列 %s 中的发送请求":           "processing send requests from queue %s",
+	"读取队列失败：%s":                  "failed to read the queue: %s",
+	"无法解析的发送请求：%s":               "unparseable send request: %s",
+	"%s 发送失败：%s":                 "%s failed to send: %s",
+	"数据检查未通过":                    "failed data checks",
+	"确认发送请求失败：%s":                "failed to acknowledge send request: %s",
+	"重新投递发送请求失败：%s":              "failed to requeue send request: %s",
+	"发送请求 %s 放到 dead_letter：%s":  "send request %s moved to dead_letter: %s",
+	"写入 dead_letter 失败：%s":       "failed to write to dead_letter: %s",
+	"发送请求 %s 第 %d 次失败，%v 后重试：%s": "send request %s failed %d time(s), retrying in %v: %s",
+	"收到 %s 信号，发送完当前这一批后退出":       "received %s, exiting after the current batch",
+
+	// Kafka
+	"Kafka 应答格式错误":                "malformed Kafka response",
+	"Kafka 认证失败：%s":               "Kafka authentication failed: %s",
+	"Kafka 错误码 %d":                "Kafka error code %d",
+	"不支持的 Kafka 消息格式 %d":          "unsupported Kafka message format %d",
+	"不支持的 Kafka 压缩格式 %d，只支持 gzip": "unsupported Kafka compression %d, only gzip is supported",
+	"无效的 Kafka 地址，格式为 kafka://[用户名:密码@]host[:port][,host[:port]...]": "invalid Kafka address, the format is kafka://[user:password@]host[:port][,host[:port]...]",
+	"连接 Kafka 失败：%s":                    "failed to connect to Kafka: %s",
+	"Kafka topic %s 不可用：%s":             "Kafka topic %s is unavailable: %s",
+	"Kafka topic %s 没有分区 %d":            "Kafka topic %s has no partition %d",
+	"分区 %d 的 offset %d 已经不存在，从 %d 开始读取": "offset %[2]d of partition %[1]d no longer exists, reading from %[3]d",
+	"Kafka topic %s 不存在":                "Kafka topic %s does not exist",
+
+	// NATS
+	"NATS 应答格式错误":  "malformed NATS response",
+	"NATS 连接失败：%s": "NATS connection failed: %s",
+	"NATS 错误：%s":   "NATS error: %s",
+	"NATS 没有服务响应请求，检查是否开启了 JetStream":             "no NATS responders for the request, check that JetStream is enabled",
+	"等待 NATS 回复超时：%s":                             "timed out waiting for NATS reply: %s",
+	"无效的 NATS 地址，格式为 nats://[用户名:密码@]host[:port]": "invalid NATS address, the format is nats://[user:password@]host[:port]",
+	"连接 NATS 失败：%s":                               "failed to connect to NATS: %s",
+	"创建 JetStream consumer 失败：%s":                 "failed to create JetStream consumer: %s",
+	"NATS 连接已断开：%s":                               "NATS connection lost: %s",
+
+	// AMQP
+	"AMQP 应答格式错误": "malformed AMQP response",
+	"无效的 AMQP 地址，格式为 amqp://[用户名:密码@]host[:port][/vhost]": "invalid AMQP address, the format is amqp://[user:password@]host[:port][/vhost]",
+	"AMQP 服务器不支持 PLAIN 认证：%s":                             "the AMQP server does not support PLAIN authentication: %s",
+	"AMQP 认证失败":              "AMQP authentication failed",
+	"AMQP 服务器关闭了连接：%d %s":    "the AMQP server closed the connection: %d %s",
+	"等待 AMQP 应答超时":           "timed out waiting for AMQP response",
+	"AMQP 服务器拒绝保存消息":         "the AMQP server refused to store the message",
+	"AMQP 服务器取消了消费，队列可能已被删除": "the AMQP server cancelled the consumer, the queue may have been deleted",
+	"AMQP 连接已断开：%s":          "AMQP connection lost: %s",
+
+	// SQS
+	"无效的 SQS 地址，格式为 sqs://[access key id:secret@]region/account id": "invalid SQS address, the format is sqs://[access key id:secret@]region/account id",
+	"打开 SQS 队列失败：%s":                      "failed to open SQS queue: %s",
+	"延长 SQS 消息的 visibility timeout 失败：%s": "failed to extend the visibility timeout of an SQS message: %s",
+
+	// import
+	"import [选项] <mbox 文件 | .eml 目录>":      "import [options] <mbox file | .eml directory>",
+	"把 mbox 文件或目录中的 .eml 邮件原样重新发给邮件头中的收件人": "resends the emails in an mbox file or a directory of .eml files, as they are, to the recipients in their headers",
+	"无法解析 %s 中的地址：%s":                      "cannot parse the addresses in %s: %s",
+	"邮件中没有收件人":                             "the email has no recipients",
+	"跳过 %s：%s":                             "skipping %s: %s",
+	"没有发件人地址，请在配置文件中指定 from":               "no sender address, set from in the config file",
+
+	// dashboard access token
+	"监听 %s 时必须用 --http-token 设置访问令牌": "an access token must be set with --http-token to listen on %s",
+	"需要访问令牌":       "access token required",
+	"不接受其他网站提交的请求": "requests submitted from other sites are not accepted",
+	"监控页面和 API 的访问令牌，默认为环境变量 EMAIL_SENDER_HTTP_TOKEN": "access token of the dashboard and the API, defaults to the environment variable EMAIL_SENDER_HTTP_TOKEN",
+
+	// campaign access token
+	"访问令牌错误，请用 --http-token 或环境变量 EMAIL_SENDER_HTTP_TOKEN 指定 serve 使用的令牌": "wrong access token, give the token used by serve with --http-token or the environment variable EMAIL_SENDER_HTTP_TOKEN",
+
+	// lang value
+	"无效的语言 %q，使用默认模板": "invalid language %q, using the default template",
+
+	// history
+
+	// history
+	"history [lookup <邮件地址>] [选项]": "history [lookup <email address>] [options]",
+	"查询 history 发送记录数据库中所有任务的发送记录，可以按时间、状态和 --campaign-id 筛选；lookup 列出发给一个收件人的所有邮件": "queries the sends of all campaigns in the history database, filtered by time, status and --campaign-id; lookup lists every email sent to one recipient",
+	"history 命令读取的发送记录数据库，默认为配置文件中的 history":                                        "the send history database read by the history command, defaults to history in the config file",
+	"history 命令只列出这个时间之后的记录，例如 30d、12h 或 2026-01-02":                                "the history command only lists records after this time, e.g. 30d, 12h or 2026-01-02",
+	"未知的 history 操作 %s，只能是 lookup":                                                  "unknown history action %s, only lookup is supported",
+}
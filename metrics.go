@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// SMTP 错误中的响应码，例如 550 5.1.1
+var smtpCodePattern = regexp.MustCompile(`\b([245]\d\d)\b(?:[ -]([245]\.\d{1,3}\.\d{1,3}))?`)
+
+// MetricsBucket 为每分钟的发送量
+type MetricsBucket struct {
+	Start  time.Time `json:"start"`
+	Sent   int       `json:"sent"`
+	Failed int       `json:"failed"`
+}
+
+// runMetrics 汇总所有任务的发送结果，结束时写入 --metrics-out 指定的 JSON 文件
+type runMetrics struct {
+	mu sync.Mutex
+
+	StartedAt       time.Time        `json:"started_at"`
+	FinishedAt      time.Time        `json:"finished_at"`
+	DurationSeconds float64          `json:"duration_seconds"`
+	Total           int              `json:"total"`
+	ByStatus        map[string]int   `json:"by_status"`
+	ErrorCodes      map[string]int   `json:"error_codes"`
+	Campaigns       map[string]int   `json:"campaigns,omitempty"`
+	PerMinute       float64          `json:"messages_per_minute"`
+	Throughput      []*MetricsBucket `json:"throughput"`
+}
+
+var metrics *runMetrics
+
+func newRunMetrics() *runMetrics {
+	return &runMetrics{
+		StartedAt:  time.Now(),
+		ByStatus:   map[string]int{},
+		ErrorCodes: map[string]int{},
+		Campaigns:  map[string]int{},
+		Throughput: []*MetricsBucket{},
+	}
+}
+
+// record 统计一条结果，失败的按错误中的 SMTP 响应码分类，没有响应码的记为 other
+func (m *runMetrics) record(result *Result) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Total++
+	m.ByStatus[result.Status]++
+	if len(result.CampaignID) > 0 {
+		m.Campaigns[result.CampaignID]++
+	}
+	if result.Status == statusFailed {
+		m.ErrorCodes[smtpErrorCode(result.Error)]++
+	}
+
+	if result.Status != statusSent && result.Status != statusFailed {
+		return
+	}
+	start := result.Time.Truncate(time.Minute)
+	var bucket *MetricsBucket
+	if n := len(m.Throughput); n > 0 && m.Throughput[n-1].Start.Equal(start) {
+		bucket = m.Throughput[n-1]
+	} else {
+		bucket = &MetricsBucket{Start: start}
+		m.Throughput = append(m.Throughput, bucket)
+	}
+	if result.Status == statusSent {
+		bucket.Sent++
+	} else {
+		bucket.Failed++
+	}
+}
+
+// smtpErrorCode 返回错误信息中的 SMTP 响应码和扩展状态码，没有响应码时返回 other
+func smtpErrorCode(text string) string {
+	match := smtpCodePattern.FindStringSubmatch(text)
+	if match == nil {
+		return "other"
+	}
+	code := match[1]
+	if len(match[2]) > 0 {
+		code += " " + match[2]
+	}
+	return code
+}
+
+func (m *runMetrics) save(file string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.FinishedAt = time.Now()
+	duration := m.FinishedAt.Sub(m.StartedAt)
+	m.DurationSeconds = duration.Seconds()
+	if minutes := duration.Minutes(); minutes > 0 {
+		m.PerMinute = float64(m.ByStatus[statusSent]+m.ByStatus[statusFailed]) / minutes
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0644)
+}
+
+func saveMetrics() {
+	if metrics == nil {
+		return
+	}
+	if err := metrics.save(metricsFile); err != nil {
+		log.Printf(tr("写入统计数据失败：%s"), err)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"strings"
+)
+
+const (
+	onMissingError   = "error"
+	onMissingEmpty   = "empty"
+	onMissingDefault = "default"
+)
+
+// missingKeyOption 返回 Go 模板中 --on-missing 对应的 missingkey 选项
+func missingKeyOption() string {
+	switch onMissing {
+	case onMissingError:
+		return "missingkey=error"
+	case onMissingEmpty:
+		return "missingkey=zero"
+	}
+	return "missingkey=default"
+}
+
+// checkedRenderer 在 --on-missing error 时为 mustache / handlebars / pongo2 模板检查引用的字段，
+// 这些引擎本身不会因为缺少字段出错，缺少的字段总是渲染为空
+func checkedRenderer(render renderer, text string) (renderer, error) {
+	if onMissing != onMissingError {
+		return render, nil
+	}
+	fields, err := templateFields(text, false)
+	if err != nil {
+		return nil, err
+	}
+	return func(w io.Writer, data interface{}) error {
+		meta, _ := data.(map[string]string)
+		for _, field := range fields {
+			if _, ok := meta[field]; !ok {
+				return fmt.Errorf(trErr("缺少模板中引用的字段 %s"), field)
+			}
+		}
+		return render(w, data)
+	}, nil
+}
+
+// attachmentChecks 编译 --pdf-template、--pdf-name、--vcard、--qrcode 和 invite 中的模板，
+// 返回的函数渲染一行数据但不生成附件，用于发送前检查缺少的字段
+func attachmentChecks(cfg *Config) ([]func(meta map[string]string) error, error) {
+	checks := []func(meta map[string]string) error{}
+	check := func(render renderer, fail string) {
+		checks = append(checks, func(meta map[string]string) error {
+			if err := render(ioutil.Discard, meta); err != nil {
+				return fmt.Errorf(trErr(fail), err)
+			}
+			return nil
+		})
+	}
+
+	if len(pdfTemplate) > 0 {
+		t, nt, err := compilePdfTemplates(pdfTemplate, pdfName)
+		if err != nil {
+			return nil, err
+		}
+		check(t, "渲染 PDF 模板失败：%s")
+		check(nt, "渲染 PDF 文件名失败：%s")
+	}
+	if len(vcard) > 0 {
+		t, err := compileVCard(vcard)
+		if err != nil {
+			return nil, err
+		}
+		check(t, "渲染 vCard 失败：%s")
+	}
+	if len(qrcodeContent) > 0 {
+		t, err := compileTemplate("qrcode", ".", qrcodeContent, false)
+		if err != nil {
+			return nil, fmt.Errorf(trErr("解析二维码内容失败：%s"), err)
+		}
+		check(t, "渲染二维码内容失败：%s")
+	}
+	if cfg.Invite != nil {
+		templates, err := compileInvite(cfg.Invite)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range []string{"summary", "description", "location", "start", "end"} {
+			name, t := name, templates[name]
+			checks = append(checks, func(meta map[string]string) error {
+				if err := t(ioutil.Discard, meta); err != nil {
+					return fmt.Errorf(trErr("渲染日历邀请 %s 失败：%s"), name, err)
+				}
+				return nil
+			})
+		}
+	}
+	return checks, nil
+}
+
+// enforceTemplateFields 在 --on-missing error 时发送前渲染每一封邮件和附件的模板，缺少字段的行与 enforceSchema 一样处理：
+// 指定了 --skip-bad-rows 时跳过并记入 --rejected-out，否则中止
+func enforceTemplateFields(cfg *Config, list []*Send, contentProvider ContentProvider) ([]*Send, error) {
+	if onMissing != onMissingError {
+		return list, nil
+	}
+	checks, err := attachmentChecks(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := []*Send{}
+	lines := []string{}
+	for _, s := range list {
+		_, _, err := renderBody(s, contentProvider)
+		for _, check := range checks {
+			if err != nil {
+				break
+			}
+			err = check(s.Meta)
+		}
+		if err != nil {
+			rejected.add(s.Row, err.Error())
+			lines = append(lines, fmt.Sprintf(trErr("第 %d 行 %s：%s"), s.Row, maskAddress(s.SendTo), redactText(err)))
+			continue
+		}
+		remaining = append(remaining, s)
+	}
+	if len(lines) == 0 {
+		return list, nil
+	}
+
+	if skipBadRows {
+		for _, line := range lines {
+			log.Printf(tr("跳过：%s"), line)
+		}
+		return remaining, nil
+	}
+	if len(lines) > maxSchemaErrors {
+		lines = append(lines[:maxSchemaErrors], fmt.Sprintf(trErr("……共 %d 行有问题"), len(lines)))
+	}
+	return nil, errors.New(trErr("模板检查不通过：\n") + strings.Join(lines, "\n"))
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestEnforceTemplateFieldsAttachments 检查 --on-missing error 时附件模板缺少字段的行也记入 --rejected-out
+func TestEnforceTemplateFieldsAttachments(t *testing.T) {
+	defer func(missing, content string, skip bool, r *rejectedRows) {
+		onMissing, qrcodeContent, skipBadRows, rejected = missing, content, skip, r
+	}(onMissing, qrcodeContent, skipBadRows, rejected)
+	onMissing, qrcodeContent, skipBadRows = onMissingError, "https://example.com/t/{{ .Ticket }}", true
+	rejected = &rejectedRows{reasons: map[int]string{}}
+
+	body := "hi"
+	list := []*Send{
+		{Row: 2, SendTo: "a@example.com", Content: &body, Meta: map[string]string{"Ticket": "1"}},
+		{Row: 3, SendTo: "b@example.com", Content: &body, Meta: map[string]string{}},
+	}
+	provider := func(data interface{}) (string, func(writer io.Writer) error) {
+		return "text/plain", func(w io.Writer) error { return nil }
+	}
+	remaining, err := enforceTemplateFields(&Config{}, list, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 1 || remaining[0].Row != 2 {
+		t.Fatalf("remaining = %v, want row 2", remaining)
+	}
+	if reason := rejected.reasons[3]; !strings.Contains(reason, "Ticket") {
+		t.Fatalf("rejected reason = %q", reason)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// natsConn 实现了 NATS 客户端协议中的 PUB、SUB 和 MSG / HMSG，由一个 goroutine 读取服务器发来的消息并回复 PING；
+// 地址为 nats://[用户名:密码@ | token@]host[:port]，tls:// 或服务器要求时使用 TLS
+type natsConn struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	w      *bufio.Writer
+	sid    int
+	subs   map[string]chan *natsMsg
+	done   chan struct{}
+	err    error
+	closed bool
+}
+
+// natsMsg 为收到的一条消息，status 为 JetStream 在消息头中返回的状态，例如 404、408
+type natsMsg struct {
+	Subject string
+	Reply   string
+	Status  string
+	Data    []byte
+}
+
+func dialNATS(rawurl string) (*natsConn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil || (u.Scheme != "nats" && u.Scheme != "tls") || len(u.Hostname()) == 0 {
+		return nil, errors.New(trErr("无效的 NATS 地址，格式为 nats://[用户名:密码@]host[:port]"))
+	}
+	port := u.Port()
+	if len(port) == 0 {
+		port = "4222"
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(u.Hostname(), port), 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		if err == nil {
+			err = errors.New(trErr("NATS 应答格式错误"))
+		}
+		return nil, err
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	json.Unmarshal([]byte(line[5:]), &info)
+	if info.TLSRequired || u.Scheme == "tls" {
+		tc := tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+		if err := tc.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tc
+		r = bufio.NewReader(conn)
+	}
+
+	options := map[string]interface{}{
+		"verbose": false, "pedantic": false, "name": "email-sender", "lang": "go", "version": "1",
+		"protocol": 1, "headers": true, "no_responders": true,
+	}
+	if u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			options["user"] = u.User.Username()
+			options["pass"] = password
+		} else {
+			options["auth_token"] = u.User.Username()
+		}
+	}
+	data, _ := json.Marshal(options)
+	c := &natsConn{conn: conn, w: bufio.NewWriter(conn), subs: map[string]chan *natsMsg{}, done: make(chan struct{})}
+	fmt.Fprintf(c.w, "CONNECT %s\r\nPING\r\n", data)
+	if err := c.w.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// 认证失败时服务器返回 -ERR 而不是 PONG
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if strings.HasPrefix(line, "-ERR") {
+			conn.Close()
+			return nil, fmt.Errorf(trErr("NATS 连接失败：%s"), strings.TrimSpace(line[4:]))
+		}
+		if strings.HasPrefix(line, "PONG") {
+			break
+		}
+	}
+	conn.SetDeadline(time.Time{})
+	go c.read(r)
+	return c, nil
+}
+
+// subscribe 订阅 subject，queue 不为空时同一个 queue 中只有一个订阅者收到消息，返回订阅的编号
+func (c *natsConn) subscribe(subject, queue string) (string, chan *natsMsg, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sid++
+	sid := strconv.Itoa(c.sid)
+	ch := make(chan *natsMsg, 1024)
+	c.subs[sid] = ch
+	if len(queue) > 0 {
+		fmt.Fprintf(c.w, "SUB %s %s %s\r\n", subject, queue, sid)
+	} else {
+		fmt.Fprintf(c.w, "SUB %s %s\r\n", subject, sid)
+	}
+	return sid, ch, c.w.Flush()
+}
+
+func (c *natsConn) unsubscribe(sid string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.subs, sid)
+	fmt.Fprintf(c.w, "UNSUB %s\r\n", sid)
+	return c.w.Flush()
+}
+
+func (c *natsConn) publish(subject, reply string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	if len(reply) > 0 {
+		fmt.Fprintf(c.w, "PUB %s %s %d\r\n", subject, reply, len(data))
+	} else {
+		fmt.Fprintf(c.w, "PUB %s %d\r\n", subject, len(data))
+	}
+	c.w.Write(data)
+	c.w.WriteString("\r\n")
+	return c.w.Flush()
+}
+
+func (c *natsConn) read(r *bufio.Reader) {
+	err := c.readLoop(r)
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	c.mu.Unlock()
+	close(c.done)
+}
+
+func (c *natsConn) readLoop(r *bufio.Reader) error {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "PING":
+			c.mu.Lock()
+			c.w.WriteString("PONG\r\n")
+			c.w.Flush()
+			c.mu.Unlock()
+		case "-ERR":
+			return fmt.Errorf(trErr("NATS 错误：%s"), strings.TrimSpace(line[4:]))
+		case "MSG", "HMSG":
+			// MSG <subject> <sid> [reply] <size>，HMSG 在 size 前多一个消息头的长度
+			headers := 0
+			if fields[0] == "HMSG" {
+				if len(fields) < 5 {
+					return errors.New(trErr("NATS 应答格式错误"))
+				}
+				headers, _ = strconv.Atoi(fields[len(fields)-2])
+				fields = append(fields[:len(fields)-2], fields[len(fields)-1])
+			}
+			if len(fields) < 4 {
+				return errors.New(trErr("NATS 应答格式错误"))
+			}
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil || headers > size {
+				return errors.New(trErr("NATS 应答格式错误"))
+			}
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return err
+			}
+			m := &natsMsg{Subject: fields[1], Data: data[headers:size]}
+			if len(fields) == 5 {
+				m.Reply = fields[3]
+			}
+			if headers > 0 {
+				// NATS/1.0 404 No Messages
+				status := strings.Fields(string(bytes.SplitN(data[:headers], []byte("\r\n"), 2)[0]))
+				if len(status) > 1 {
+					m.Status = status[1]
+				}
+			}
+			c.mu.Lock()
+			ch := c.subs[fields[2]]
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- m
+			}
+		}
+	}
+}
+
+// request 发布一条消息并等待 inbox 上的回复
+func (c *natsConn) request(subject string, data []byte, timeout time.Duration) (*natsMsg, error) {
+	inbox := natsInbox()
+	sid, ch, err := c.subscribe(inbox, "")
+	if err != nil {
+		return nil, err
+	}
+	defer c.unsubscribe(sid)
+	if err := c.publish(subject, inbox, data); err != nil {
+		return nil, err
+	}
+	select {
+	case m := <-ch:
+		if m.Status == "503" {
+			return nil, errors.New(trErr("NATS 没有服务响应请求，检查是否开启了 JetStream"))
+		}
+		return m, nil
+	case <-c.done:
+		return nil, c.err
+	case <-time.After(timeout):
+		return nil, fmt.Errorf(trErr("等待 NATS 回复超时：%s"), subject)
+	}
+}
+
+func natsInbox() string {
+	random := make([]byte, 8)
+	rand.Read(random)
+	return "_INBOX." + hex.EncodeToString(random)
+}
+
+func (c *natsConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.conn.Close()
+}
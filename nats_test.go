@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNATSReadLoop(t *testing.T) {
+	format := trErr("NATS 应答格式错误")
+	tests := []struct {
+		name string
+		in   string
+		msgs []natsMsg
+		out  string
+		err  string
+	}{
+		{
+			name: "ok and ping",
+			in:   "+OK\r\nPING\r\n\r\nPING\r\n",
+			out:  "PONG\r\nPONG\r\n",
+			err:  io.EOF.Error(),
+		},
+		{
+			name: "msg",
+			in:   "MSG mail.send 1 5\r\nhello\r\nMSG mail.send 1 _INBOX.abc 0\r\n\r\n",
+			msgs: []natsMsg{{Subject: "mail.send", Data: []byte("hello")}, {Subject: "mail.send", Reply: "_INBOX.abc", Data: []byte{}}},
+			err:  io.EOF.Error(),
+		},
+		{
+			// 消息体中的换行不影响读取
+			name: "msg with crlf in payload",
+			in:   "MSG mail.send 1 7\r\nhe\r\nllo\r\n",
+			msgs: []natsMsg{{Subject: "mail.send", Data: []byte("he\r\nllo")}},
+			err:  io.EOF.Error(),
+		},
+		{
+			name: "msg for another subscription",
+			in:   "MSG other 2 1\r\nx\r\nMSG mail.send 1 1\r\ny\r\n",
+			msgs: []natsMsg{{Subject: "mail.send", Data: []byte("y")}},
+			err:  io.EOF.Error(),
+		},
+		{
+			name: "hmsg status",
+			in:   "HMSG _INBOX.abc 1 28 28\r\nNATS/1.0 404 No Messages\r\n\r\n\r\n",
+			msgs: []natsMsg{{Subject: "_INBOX.abc", Status: "404", Data: []byte{}}},
+			err:  io.EOF.Error(),
+		},
+		{
+			name: "hmsg with reply and data",
+			in:   "HMSG mail.send 1 $JS.ACK.MAIL.worker.3.10.4.1700000000000000000.0 18 20\r\nNATS/1.0\r\nK: v\r\n\r\nhi\r\n",
+			msgs: []natsMsg{{Subject: "mail.send", Reply: "$JS.ACK.MAIL.worker.3.10.4.1700000000000000000.0", Data: []byte("hi")}},
+			err:  io.EOF.Error(),
+		},
+		{
+			name: "err",
+			in:   "-ERR 'Authorization Violation'\r\nPING\r\n",
+			err:  fmt.Sprintf(trErr("NATS 错误：%s"), "'Authorization Violation'"),
+		},
+		{
+			name: "msg missing size",
+			in:   "MSG mail.send 1\r\n",
+			err:  format,
+		},
+		{
+			name: "msg bad size",
+			in:   "MSG mail.send 1 x\r\n",
+			err:  format,
+		},
+		{
+			name: "hmsg headers larger than size",
+			in:   "HMSG mail.send 1 10 5\r\n",
+			err:  format,
+		},
+		{
+			name: "hmsg missing fields",
+			in:   "HMSG mail.send 1 5\r\n",
+			err:  format,
+		},
+		{
+			name: "truncated payload",
+			in:   "MSG mail.send 1 5\r\nhel",
+			err:  io.ErrUnexpectedEOF.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			ch := make(chan *natsMsg, 10)
+			c := &natsConn{w: bufio.NewWriter(&out), subs: map[string]chan *natsMsg{"1": ch}}
+			err := c.readLoop(bufio.NewReader(strings.NewReader(tt.in)))
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("err = %v, want %s", err, tt.err)
+			}
+			close(ch)
+			msgs := []natsMsg{}
+			for m := range ch {
+				msgs = append(msgs, *m)
+			}
+			if tt.msgs == nil {
+				tt.msgs = []natsMsg{}
+			}
+			if !reflect.DeepEqual(msgs, tt.msgs) {
+				t.Fatalf("msgs = %+v, want %+v", msgs, tt.msgs)
+			}
+			if out.String() != tt.out {
+				t.Fatalf("wrote %q, want %q", out.String(), tt.out)
+			}
+		})
+	}
+}
+
+func TestNATSDelivered(t *testing.T) {
+	tests := []struct {
+		reply string
+		want  int
+	}{
+		{"$JS.ACK.MAIL.worker.3.10.4.1700000000000000000.0", 3},
+		{"$JS.ACK.hub.ACCOUNT.MAIL.worker.5.10.4.1700000000000000000.0.abc", 5},
+		{"$JS.ACK.MAIL.worker.0.10.4.1700000000000000000.0", 1},
+		{"_INBOX.abc", 1},
+		{"", 1},
+	}
+	for _, tt := range tests {
+		if got := natsDelivered(tt.reply); got != tt.want {
+			t.Errorf("natsDelivered(%q) = %d, want %d", tt.reply, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/mail"
+	"strings"
+)
+
+// normalizeAddress 去掉首尾空白并把域名转为小写，local part 保持不变
+func normalizeAddress(addr string) string {
+	addr = strings.TrimSpace(addr)
+	at := strings.LastIndex(addr, "@")
+	if at < 0 {
+		return addr
+	}
+	return addr[:at] + strings.ToLower(addr[at:])
+}
+
+// dedupeKey 返回判断重复收件人用的地址；开启 --dedupe-gmail 时 Gmail 地址去掉 local part 中的 . 和 + 之后的部分
+func dedupeKey(addr string) string {
+	if a, err := mail.ParseAddress(addr); err == nil {
+		addr = a.Address
+	}
+	addr = strings.ToLower(asciiDomainAddress(addr))
+
+	at := strings.LastIndex(addr, "@")
+	if !dedupeGmail || at < 0 {
+		return addr
+	}
+	local, domain := addr[:at], addr[at+1:]
+	if domain != "gmail.com" && domain != "googlemail.com" {
+		return addr
+	}
+	if plus := strings.Index(local, "+"); plus >= 0 {
+		local = local[:plus]
+	}
+	return strings.ReplaceAll(local, ".", "") + "@gmail.com"
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+const (
+	notifyStart    = "start"
+	notifyComplete = "complete"
+	notifyAbort    = "abort"
+
+	notifyTimeout = 10 * time.Second
+)
+
+// NotifyConfig 为任务开始、完成、中止时发送通知的 webhook，type 为 slack、dingtalk、wecom，为空时 POST 通用的 JSON；
+// type 为 email 时用同样的 SMTP 配置发邮件给 to，附带报告文件；
+// events 为空时所有事件都通知；report_url 支持模板语法，可以使用 {{ .Report }} 和 {{ .CampaignID }}
+type NotifyConfig struct {
+	Type      string   `json:"type"`
+	URL       string   `json:"url"`
+	To        string   `json:"to"`
+	Secret    string   `json:"secret"`
+	Events    []string `json:"events"`
+	ReportURL string   `json:"report_url"`
+}
+
+// notifyPayload 是通用 webhook 收到的 JSON
+type notifyPayload struct {
+	Event      string     `json:"event"`
+	Campaign   string     `json:"campaign"`
+	CampaignID string     `json:"campaign_id,omitempty"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	Total      int        `json:"total"`
+	Sent       int        `json:"sent"`
+	Failed     int        `json:"failed"`
+	Skipped    int        `json:"skipped"`
+	Started    time.Time  `json:"started"`
+	Finished   *time.Time `json:"finished,omitempty"`
+	Report     string     `json:"report,omitempty"`
+	Text       string     `json:"text"`
+}
+
+func checkNotifyConfig(cfg *Config) error {
+	for _, n := range cfg.Notify {
+		switch n.Type {
+		case "", "slack", "dingtalk", "wecom", "email":
+		default:
+			return fmt.Errorf(trErr("无效的通知类型 %s，只能是 slack、dingtalk、wecom、email 或为空"), n.Type)
+		}
+		if n.Type == "email" {
+			if _, err := mail.ParseAddressList(n.To); err != nil {
+				return fmt.Errorf(trErr("无效的通知邮箱 %s：%s"), n.To, err)
+			}
+		} else if len(n.URL) == 0 {
+			return fmt.Errorf(trErr("通知没有指定 url"))
+		}
+		for _, event := range n.Events {
+			if event != notifyStart && event != notifyComplete && event != notifyAbort {
+				return fmt.Errorf(trErr("无效的通知事件 %s，只能是 start、complete 或 abort"), event)
+			}
+		}
+		if _, err := texttemplate.New("report_url").Parse(n.ReportURL); err != nil {
+			return fmt.Errorf(trErr("解析通知的 report_url 失败：%s"), err)
+		}
+	}
+	return nil
+}
+
+// notifyCampaign 把任务的进度发送到配置的所有 webhook，失败时只记录日志
+func notifyCampaign(cfg *Config, c *Campaign, event string) {
+	if len(cfg.Notify) == 0 {
+		return
+	}
+	status := c.Snapshot()
+	c.mu.Lock()
+	skipped := c.reporter.Skipped
+	report := ""
+	if c.reporter.file != nil {
+		report, _ = filepath.Abs(c.reporter.file.Name())
+	}
+	c.mu.Unlock()
+
+	for _, n := range cfg.Notify {
+		if !notifyEvent(n, event) {
+			continue
+		}
+		link := report
+		if len(n.ReportURL) > 0 && len(report) > 0 {
+			var b bytes.Buffer
+			t := texttemplate.Must(texttemplate.New("report_url").Parse(n.ReportURL))
+			if err := t.Execute(&b, map[string]string{"Report": filepath.Base(report), "CampaignID": status.CampaignID}); err == nil {
+				link = b.String()
+			}
+		}
+
+		payload := notifyPayload{
+			Event:      event,
+			Campaign:   status.Name,
+			CampaignID: status.CampaignID,
+			Status:     status.Status,
+			Error:      status.Error,
+			Total:      status.Total,
+			Sent:       status.Sent,
+			Failed:     status.Failed,
+			Skipped:    skipped,
+			Started:    status.Started,
+			Finished:   status.Finished,
+			Report:     link,
+		}
+		payload.Text = notifyText(&payload)
+		if n.Type == "email" {
+			if err := emailNotification(cfg, n, &payload, report); err != nil {
+				c.logf("发送通知邮件给 %s 失败：%v", n.To, err)
+			}
+		} else if err := postNotification(n, &payload); err != nil {
+			c.logf("发送通知失败 %s：%v", n.URL, err)
+		}
+	}
+}
+
+func notifyEvent(n *NotifyConfig, event string) bool {
+	if len(n.Events) == 0 {
+		return true
+	}
+	for _, e := range n.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func notifyText(p *notifyPayload) string {
+	name := p.Campaign
+	if len(p.CampaignID) > 0 {
+		name = fmt.Sprintf("%s [%s]", name, p.CampaignID)
+	}
+	var text string
+	switch p.Event {
+	case notifyStart:
+		return fmt.Sprintf(tr("邮件发送任务 %s 开始，共 %d 封"), name, p.Total)
+	case notifyComplete:
+		text = fmt.Sprintf(tr("邮件发送任务 %s 完成"), name)
+	default:
+		text = fmt.Sprintf(tr("邮件发送任务 %s 中止"), name)
+		if len(p.Error) > 0 {
+			text += "：" + p.Error
+		} else if p.Status == campaignCancelled {
+			text += tr("：已取消")
+		}
+	}
+	text += fmt.Sprintf(tr("\n共 %d 封，成功 %d 封，失败 %d 封，跳过 %d 封"), p.Total, p.Sent, p.Failed, p.Skipped)
+	if p.Finished != nil {
+		text += fmt.Sprintf(tr("，用时 %s"), p.Finished.Sub(p.Started).Round(time.Second))
+	}
+	if len(p.Report) > 0 {
+		text += tr("\n报告：") + p.Report
+	}
+	return text
+}
+
+func postNotification(n *NotifyConfig, p *notifyPayload) error {
+	var body interface{}
+	target := n.URL
+	switch n.Type {
+	case "slack":
+		body = map[string]string{"text": p.Text}
+	case "dingtalk", "wecom":
+		body = map[string]interface{}{"msgtype": "text", "text": map[string]string{"content": p.Text}}
+		if n.Type == "dingtalk" && len(n.Secret) > 0 {
+			target = dingtalkSignedURL(n.URL, n.Secret)
+		}
+	default:
+		body = p
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: notifyTimeout}
+	resp, err := client.Post(target, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("HTTP %s", resp.Status)
+	}
+	// 钉钉和企业微信出错时也返回 200，错误码在 errcode 中
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if json.NewDecoder(resp.Body).Decode(&result) == nil && result.ErrCode != 0 {
+		return fmt.Errorf("%d %s", result.ErrCode, result.ErrMsg)
+	}
+	return nil
+}
+
+// emailNotification 用发送任务的 SMTP 配置把通知发给 to，任务结束时附带报告文件
+func emailNotification(cfg *Config, n *NotifyConfig, p *notifyPayload, report string) error {
+	sender, err := getSender(cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closer, ok := sender.(io.Closer); ok {
+			closer.Close()
+		}
+	}()
+
+	addrs, err := mail.ParseAddressList(n.To)
+	if err != nil {
+		return err
+	}
+	to := []string{}
+	for _, a := range addrs {
+		to = append(to, formatAddress(a))
+	}
+
+	m := gomail.NewMessage()
+	setAddressHeader(m, "From", cfg.From)
+	m.SetHeader("To", to...)
+	m.SetHeader("Subject", encodeHeaderText(strings.SplitN(p.Text, "\n", 2)[0]))
+	m.SetBody("text/plain", p.Text)
+	if p.Event != notifyStart && len(report) > 0 {
+		data, err := ioutil.ReadFile(report)
+		if err != nil {
+			return err
+		}
+		attachBytes(m, filepath.Base(report), data)
+	}
+	return gomail.Send(sender, m)
+}
+
+// dingtalkSignedURL 为开启了加签的钉钉机器人添加 timestamp 和 sign 参数
+func dingtalkSignedURL(webhook, secret string) string {
+	timestamp := strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	sign := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	sep := "?"
+	if strings.Contains(webhook, "?") {
+		sep = "&"
+	}
+	return webhook + sep + "timestamp=" + timestamp + "&sign=" + url.QueryEscape(sign)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	odsTableNS  = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	odsTextNS   = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+	odsOfficeNS = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+)
+
+// readODSRows 读取 OpenDocument 表格(.ods)的第一个工作表；
+// 日期单元格使用 office:date-value 中的值，不受显示格式影响
+func readODSRows(file string) ([][]string, error) {
+	r, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if f.Name == "content.xml" {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			return parseODSContent(rc)
+		}
+	}
+	return nil, errors.New(trErr("无效的 ods 文件，没有 content.xml"))
+}
+
+func parseODSContent(r io.Reader) ([][]string, error) {
+	d := xml.NewDecoder(r)
+
+	rows := [][]string{}
+	var row []string
+	var cell *strings.Builder
+	var cellValue string
+	inTable := false
+	// 只读取段落中的文字，跳过批注
+	paragraph, annotation := 0, 0
+	rowRepeat, cellRepeat := 1, 1
+
+	attr := func(e xml.StartElement, space, local string) string {
+		for _, a := range e.Attr {
+			if a.Name.Space == space && a.Name.Local == local {
+				return a.Value
+			}
+		}
+		return ""
+	}
+	repeat := func(e xml.StartElement, local string) int {
+		n, err := strconv.Atoi(attr(e, odsTableNS, local))
+		if err != nil || n < 1 {
+			return 1
+		}
+		return n
+	}
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Space == odsTableNS && t.Name.Local == "table":
+				inTable = true
+			case !inTable:
+			case t.Name.Space == odsTableNS && t.Name.Local == "table-row":
+				row = []string{}
+				rowRepeat = repeat(t, "number-rows-repeated")
+			case t.Name.Space == odsTableNS && (t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell"):
+				cell = &strings.Builder{}
+				cellRepeat = repeat(t, "number-columns-repeated")
+				cellValue = odsCellValue(attr(t, odsOfficeNS, "value-type"), attr(t, odsOfficeNS, "date-value"))
+			case t.Name.Space == odsOfficeNS && t.Name.Local == "annotation":
+				annotation++
+			case t.Name.Space == odsTextNS && t.Name.Local == "p" && cell != nil:
+				paragraph++
+				if cell.Len() > 0 && annotation == 0 {
+					cell.WriteString("\n")
+				}
+			case t.Name.Space == odsTextNS && t.Name.Local == "s" && cell != nil && annotation == 0:
+				n, err := strconv.Atoi(attr(t, odsTextNS, "c"))
+				if err != nil || n < 1 {
+					n = 1
+				}
+				cell.WriteString(strings.Repeat(" ", n))
+			case t.Name.Space == odsTextNS && t.Name.Local == "tab" && cell != nil && annotation == 0:
+				cell.WriteString("\t")
+			case t.Name.Space == odsTextNS && t.Name.Local == "line-break" && cell != nil && annotation == 0:
+				cell.WriteString("\n")
+			}
+
+		case xml.CharData:
+			if cell != nil && paragraph > 0 && annotation == 0 {
+				cell.Write(t)
+			}
+
+		case xml.EndElement:
+			switch {
+			case t.Name.Space == odsTableNS && t.Name.Local == "table":
+				// 只读取第一个工作表
+				return trimODSRows(rows), nil
+			case !inTable:
+			case t.Name.Space == odsOfficeNS && t.Name.Local == "annotation":
+				annotation--
+			case t.Name.Space == odsTextNS && t.Name.Local == "p" && cell != nil:
+				paragraph--
+			case t.Name.Space == odsTableNS && (t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell"):
+				value := cellValue
+				if len(value) == 0 {
+					value = cell.String()
+				}
+				// 行尾的空单元格常常重复到最后一列，不展开
+				if len(value) > 0 || cellRepeat < 256 {
+					for i := 0; i < cellRepeat; i++ {
+						row = append(row, value)
+					}
+				}
+				cell = nil
+			case t.Name.Space == odsTableNS && t.Name.Local == "table-row":
+				row = trimEmptyCells(row)
+				if len(row) == 0 && rowRepeat >= 256 {
+					break
+				}
+				for i := 0; i < rowRepeat; i++ {
+					rows = append(rows, row)
+				}
+			}
+		}
+	}
+	return trimODSRows(rows), nil
+}
+
+// odsCellValue 日期单元格转换为 2006-01-02 或 2006-01-02 15:04:05 格式
+func odsCellValue(valueType, dateValue string) string {
+	if valueType != "date" || len(dateValue) == 0 {
+		return ""
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05.999999999", "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, dateValue); err == nil {
+			if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+				return t.Format("2006-01-02")
+			}
+			return t.Format("2006-01-02 15:04:05")
+		}
+	}
+	return dateValue
+}
+
+func trimEmptyCells(row []string) []string {
+	for len(row) > 0 && len(row[len(row)-1]) == 0 {
+		row = row[:len(row)-1]
+	}
+	return row
+}
+
+func trimODSRows(rows [][]string) [][]string {
+	for len(rows) > 0 && len(rows[len(rows)-1]) == 0 {
+		rows = rows[:len(rows)-1]
+	}
+	return rows
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"gopkg.in/gomail.v2"
+)
+
+// pdfTimeout 为 Chrome 加载并打印一个 PDF 的最长时间
+const pdfTimeout = time.Minute
+
+// pdfBrowser 为没有配置 pdf_converter 时使用的 headless Chrome，第一次生成 PDF 时启动，之后的收件人共用
+var pdfBrowser struct {
+	sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// compilePdfTemplates 与邮件模板一样按 --engine 和 --on-missing 编译 PDF 模板和文件名模板
+func compilePdfTemplates(template, name string) (renderer, renderer, error) {
+	logDebug("从 %s 中读取 PDF 模板", template)
+	data, err := readFileContent(template)
+	if err != nil {
+		return nil, nil, fmt.Errorf(trErr("读取 PDF 模板文件失败：%s"), err)
+	}
+	t, err := compileTemplate("pdf", filepath.Dir(template), string(data), true)
+	if err != nil {
+		return nil, nil, fmt.Errorf(trErr("解析 PDF 模板失败：%s"), err)
+	}
+	nt, err := compileTemplate("pdf-name", ".", name, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf(trErr("解析 PDF 文件名失败：%s"), err)
+	}
+	return t, nt, nil
+}
+
+func getPdfDecorator(cfg *Config, template, name string) (Decorator, error) {
+	t, nt, err := compilePdfTemplates(template, name)
+	if err != nil {
+		return nil, err
+	}
+
+	converter := cfg.PdfConverter
+
+	archive, err := getAttachmentArchiver(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(m *gomail.Message, send *Send) error {
+		var html, filename bytes.Buffer
+		if err := t(&html, send.Meta); err != nil {
+			return fmt.Errorf(trErr("渲染 PDF 模板失败：%s"), err)
+		}
+		if err := nt(&filename, send.Meta); err != nil {
+			return fmt.Errorf(trErr("渲染 PDF 文件名失败：%s"), err)
+		}
+		pdf, err := htmlToPdf(converter, html.Bytes())
+		if err != nil {
+			return fmt.Errorf(trErr("生成 PDF 失败：%s"), err)
+		}
+		name, data, err := archive(send, filename.String(), pdf)
+		if err != nil {
+			return err
+		}
+		attachBytes(m, name, data)
+		return nil
+	}, nil
+}
+
+// htmlToPdf 配置了 pdf_converter 时执行外部命令，否则用 headless Chrome 转换
+func htmlToPdf(converter []string, html []byte) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "email-sender-pdf")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input.html")
+	output := filepath.Join(dir, "output.pdf")
+	if err := ioutil.WriteFile(input, html, 0600); err != nil {
+		return nil, err
+	}
+
+	if len(converter) == 0 {
+		return chromeToPdf(input)
+	}
+
+	args := make([]string, len(converter))
+	for i, arg := range converter {
+		arg = strings.ReplaceAll(arg, "{input}", input)
+		args[i] = strings.ReplaceAll(arg, "{output}", output)
+	}
+
+	logDebug("执行 PDF 转换命令：%s", args)
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	pdf, err := readFileContent(output)
+	if err != nil {
+		return nil, err
+	}
+	if len(pdf) == 0 {
+		return nil, errors.New(trErr("转换命令没有输出内容"))
+	}
+	return pdf, nil
+}
+
+// chromeToPdf 在共用的 Chrome 中打开一个新标签页加载 HTML 文件，等页面加载完后打印为 PDF
+func chromeToPdf(input string) ([]byte, error) {
+	browser, err := startPdfBrowser()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := chromedp.NewContext(browser)
+	defer cancel()
+	ctx, cancelTimeout := context.WithTimeout(ctx, pdfTimeout)
+	defer cancelTimeout()
+
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(input)}
+	if !strings.HasPrefix(u.Path, "/") {
+		u.Path = "/" + u.Path
+	}
+	logDebug("使用 Chrome 转换 PDF：%s", u.String())
+
+	var pdf []byte
+	err = chromedp.Run(ctx, chromedp.Navigate(u.String()), chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		pdf, _, err = page.PrintToPDF().WithPrintBackground(true).Do(ctx)
+		return err
+	}))
+	if err != nil {
+		return nil, err
+	}
+	if len(pdf) == 0 {
+		return nil, errors.New(trErr("Chrome 没有输出内容"))
+	}
+	return pdf, nil
+}
+
+// startPdfBrowser 返回共用的 Chrome，没有启动或者已经退出时重新启动
+func startPdfBrowser() (context.Context, error) {
+	pdfBrowser.Lock()
+	defer pdfBrowser.Unlock()
+
+	if pdfBrowser.ctx != nil && pdfBrowser.ctx.Err() == nil {
+		return pdfBrowser.ctx, nil
+	}
+	if pdfBrowser.cancel != nil {
+		pdfBrowser.cancel()
+	}
+
+	logDebug("启动 Chrome 用于生成 PDF")
+	allocator, cancelAllocator := chromedp.NewExecAllocator(context.Background(), chromedp.DefaultExecAllocatorOptions[:]...)
+	ctx, cancel := chromedp.NewContext(allocator)
+	pdfBrowser.ctx, pdfBrowser.cancel = nil, nil
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
+		cancelAllocator()
+		return nil, fmt.Errorf(trErr("启动 Chrome 失败，请安装 Chrome 或使用 pdf_converter 指定转换命令：%s"), err)
+	}
+	pdfBrowser.ctx = ctx
+	pdfBrowser.cancel = func() {
+		cancel()
+		cancelAllocator()
+	}
+	return ctx, nil
+}
+
+// closePdfBrowser 关闭 startPdfBrowser 启动的 Chrome 并删除它的临时目录
+func closePdfBrowser() {
+	pdfBrowser.Lock()
+	defer pdfBrowser.Unlock()
+
+	if pdfBrowser.cancel != nil {
+		pdfBrowser.cancel()
+		pdfBrowser.ctx, pdfBrowser.cancel = nil, nil
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PoolConfig 配置 SMTP 连接池，同一个服务器（host、port、username 相同）的发送任务共用连接，
+// idle_timeout 单位为秒，max_messages 为每个连接最多发送的邮件数，超过后重新连接
+type PoolConfig struct {
+	Min         int   `json:"min"`
+	MaxIdle     int   `json:"max_idle"`
+	Max         int   `json:"max"`
+	IdleTimeout int64 `json:"idle_timeout"`
+	MaxMessages int   `json:"max_messages"`
+}
+
+// 空闲超过这个时间的连接使用前先用 NOOP 检查是否还可用
+const poolCheckAfter = 30 * time.Second
+
+type pooledConn struct {
+	sender   *smtpSender
+	messages int
+	idleAt   time.Time
+}
+
+// smtpPool 保存空闲的连接，连接数达到 max 时等待其他发送任务用完
+type smtpPool struct {
+	cfg  *Config
+	pool PoolConfig
+
+	mu    sync.Mutex
+	cond  *sync.Cond
+	idle  []*pooledConn
+	open  int
+	users int
+}
+
+var (
+	poolsMu sync.Mutex
+	pools   = map[string]*smtpPool{}
+)
+
+// getSMTPPool 返回服务器对应的连接池，第一次使用时建立 min 个连接
+func getSMTPPool(cfg *Config) (*smtpPool, error) {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+
+	key := cfg.Host + ":" + strconv.Itoa(cfg.Port) + ":" + cfg.Username
+	if p, ok := pools[key]; ok {
+		return p, nil
+	}
+
+	c := *cfg.Pool
+	if c.Max <= 0 {
+		c.Max = 4
+	}
+	if c.Min > c.Max {
+		return nil, fmt.Errorf(trErr("连接池的 min %d 不能大于 max %d"), c.Min, c.Max)
+	}
+	if c.MaxIdle <= 0 || c.MaxIdle > c.Max {
+		c.MaxIdle = c.Max
+	}
+	if c.MaxIdle < c.Min {
+		c.MaxIdle = c.Min
+	}
+	if c.IdleTimeout <= 0 {
+		c.IdleTimeout = 300
+	}
+	p := &smtpPool{cfg: cfg, pool: c}
+	p.cond = sync.NewCond(&p.mu)
+	for i := 0; i < c.Min; i++ {
+		s, err := dialSMTP(cfg)
+		if err != nil {
+			p.trim(0)
+			return nil, err
+		}
+		p.idle = append(p.idle, &pooledConn{sender: s, idleAt: time.Now()})
+		p.open++
+	}
+	pools[key] = p
+	return p, nil
+}
+
+// get 取一个可用的连接：空闲超时的关闭，空闲较久的先检查，没有空闲连接时新建，已达到 max 时等待
+func (p *smtpPool) get() (*pooledConn, error) {
+	p.mu.Lock()
+	for {
+		for len(p.idle) > 0 {
+			c := p.idle[len(p.idle)-1]
+			p.idle = p.idle[:len(p.idle)-1]
+			idle := time.Since(c.idleAt)
+			if idle > time.Duration(p.pool.IdleTimeout)*time.Second {
+				p.discard(c)
+				continue
+			}
+			if idle > poolCheckAfter {
+				p.mu.Unlock()
+				err := c.sender.client.Noop()
+				p.mu.Lock()
+				if err != nil {
+					logDebug("连接池中的连接已不可用：%s", err)
+					p.discard(c)
+					continue
+				}
+			}
+			p.mu.Unlock()
+			return c, nil
+		}
+		if p.open < p.pool.Max {
+			break
+		}
+		p.cond.Wait()
+	}
+	p.open++
+	p.mu.Unlock()
+
+	s, err := dialSMTP(p.cfg)
+	if err != nil {
+		p.mu.Lock()
+		p.open--
+		p.cond.Signal()
+		p.mu.Unlock()
+		return nil, err
+	}
+	return &pooledConn{sender: s}, nil
+}
+
+// put 归还连接，出错断开的、发送数达到 max_messages 的或空闲连接已满时关闭
+func (p *smtpPool) put(c *pooledConn, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	defer p.cond.Signal()
+
+	if err != nil && closesConnection(err) ||
+		p.pool.MaxMessages > 0 && c.messages >= p.pool.MaxMessages ||
+		len(p.idle) >= p.pool.MaxIdle {
+		p.discard(c)
+		return
+	}
+	c.idleAt = time.Now()
+	p.idle = append(p.idle, c)
+}
+
+// discard 关闭连接，调用时需要持有 mu
+func (p *smtpPool) discard(c *pooledConn) {
+	p.open--
+	go c.sender.Close()
+}
+
+// trim 关闭多余的空闲连接，只保留 keep 个
+func (p *smtpPool) trim(keep int) {
+	for len(p.idle) > keep {
+		c := p.idle[0]
+		p.idle = p.idle[1:]
+		p.open--
+		c.sender.Close()
+	}
+}
+
+// pooledSender 每次发送时从连接池中取一个连接，发送后归还；每个发送任务使用单独的 pooledSender
+type pooledSender struct {
+	pool *smtpPool
+	next *envelope
+}
+
+func newPooledSender(cfg *Config) (*pooledSender, error) {
+	p, err := getSMTPPool(cfg)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.users++
+	p.mu.Unlock()
+	return &pooledSender{pool: p}, nil
+}
+
+func (s *pooledSender) setEnvelope(e *envelope) {
+	s.next = e
+}
+
+func (s *pooledSender) Send(from string, to []string, msg io.WriterTo) error {
+	e := s.next
+	s.next = nil
+	c, err := s.pool.get()
+	if err != nil {
+		return err
+	}
+	err = c.sender.send(e, from, to, msg)
+	c.messages++
+	s.pool.put(c, err)
+	return err
+}
+
+func (s *pooledSender) maxMessageSize() int64 {
+	c, err := s.pool.get()
+	if err != nil {
+		return 0
+	}
+	defer s.pool.put(c, nil)
+	return c.sender.maxMessageSize()
+}
+
+func (s *pooledSender) supportsSMTPUTF8() bool {
+	c, err := s.pool.get()
+	if err != nil {
+		return false
+	}
+	defer s.pool.put(c, nil)
+	return c.sender.supportsSMTPUTF8()
+}
+
+// Close 在没有其他发送任务使用连接池时关闭多余的空闲连接，保留 min 个给之后的任务（例如 watch 模式）
+func (s *pooledSender) Close() error {
+	p := s.pool
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.users--
+	if p.users == 0 {
+		p.trim(p.pool.Min)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+const preflightTimeout = 30 * time.Second
+
+// SpamCheckConfig 配置发送前用 SpamAssassin(spamd) 或 Rspamd 检查样例邮件
+type SpamCheckConfig struct {
+	Spamd     string  `json:"spamd"`
+	Rspamd    string  `json:"rspamd"`
+	Password  string  `json:"password"`
+	Threshold float64 `json:"threshold"`
+}
+
+type spamReport struct {
+	Score    float64
+	Required float64
+	Rules    []string
+}
+
+func (r *spamReport) String() string {
+	return fmt.Sprintf(tr("%.1f / %.1f，命中规则：%s"), r.Score, r.Required, strings.Join(r.Rules, ", "))
+}
+
+// renderMessage 按发送时的方式生成完整的邮件
+func renderMessage(cfg *Config, s *Send, contentProvider ContentProvider, decorators []Decorator) ([]byte, error) {
+	selectFrom, err := getFromSelector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	m := gomail.NewMessage()
+	setAddressHeader(m, "From", selectFrom(s))
+	setAddressHeader(m, "To", s.SendTo)
+	m.SetHeader("Subject", encodeHeaderText(s.Subject))
+	contentType, body, err := renderBody(s, contentProvider)
+	if err != nil {
+		return nil, err
+	}
+	m.SetBody(contentType, string(body))
+	if err := decorate(m, s, decorators); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// preflight 用第一个收件人的邮件做发送前检查：
+// 开启 --check-html 时检查 HTML 和其中的链接，配置了 spam_check 时评分，分数达到 threshold 返回错误
+func preflight(cfg *Config, list []*Send, contentProvider ContentProvider, decorators []Decorator) error {
+	if len(list) == 0 {
+		return nil
+	}
+	if estimateSize {
+		if err := estimateSizes(cfg, list, contentProvider, decorators); err != nil {
+			return fmt.Errorf(trErr("估算邮件大小失败：%s"), err)
+		}
+	}
+	if checkHTMLLinks {
+		contentType, body, err := renderBody(list[0], contentProvider)
+		if err != nil {
+			return err
+		}
+		if contentType == "text/html" {
+			problems, links := checkHTML(body)
+			problems = append(problems, checkLinks(links)...)
+			if len(problems) > 0 {
+				return fmt.Errorf(trErr("第 %d 行 %s 的邮件没有通过 HTML 检查：\n\t%s"),
+					list[0].Row, list[0].SendTo, strings.Join(problems, "\n\t"))
+			}
+			log.Printf(tr("HTML 检查通过，检查了 %d 个链接"), len(links))
+		}
+	}
+	if cfg.SpamCheck == nil {
+		return nil
+	}
+
+	msg, err := renderMessage(cfg, list[0], contentProvider, decorators)
+	if err != nil {
+		return fmt.Errorf(trErr("生成样例邮件失败：%s"), err)
+	}
+	report, err := checkSpam(cfg.SpamCheck, msg)
+	if err != nil {
+		return fmt.Errorf(trErr("垃圾邮件检查失败：%s"), err)
+	}
+	log.Printf(tr("垃圾邮件评分 %s"), report)
+	if cfg.SpamCheck.Threshold > 0 && report.Score >= cfg.SpamCheck.Threshold {
+		return fmt.Errorf(trErr("垃圾邮件评分 %.1f 超过阈值 %.1f"), report.Score, cfg.SpamCheck.Threshold)
+	}
+	return nil
+}
+
+func checkSpam(c *SpamCheckConfig, msg []byte) (*spamReport, error) {
+	switch {
+	case len(c.Rspamd) > 0:
+		return checkRspamd(c, msg)
+	case len(c.Spamd) > 0:
+		return checkSpamd(c.Spamd, msg)
+	default:
+		return nil, errors.New(trErr("spam_check 需要配置 spamd 或 rspamd"))
+	}
+}
+
+// checkSpamd 使用 SPAMC/1.5 协议的 SYMBOLS 命令
+func checkSpamd(addr string, msg []byte) (*spamReport, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "783")
+	}
+	conn, err := net.DialTimeout("tcp", addr, preflightTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(preflightTimeout))
+
+	if _, err := fmt.Fprintf(conn, "SYMBOLS SPAMC/1.5\r\nContent-length: %d\r\n\r\n", len(msg)); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.CloseWrite()
+	}
+
+	r := bufio.NewReader(conn)
+	status, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if fields := strings.Fields(status); len(fields) < 3 || fields[1] != "0" {
+		return nil, fmt.Errorf(trErr("spamd 返回 %s"), strings.TrimSpace(status))
+	}
+
+	report := &spamReport{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			break
+		}
+		// Spam: True ; 15.3 / 5.0
+		if value := strings.TrimPrefix(line, "Spam:"); value != line {
+			if i := strings.Index(value, ";"); i >= 0 {
+				scores := strings.Split(value[i+1:], "/")
+				if len(scores) == 2 {
+					report.Score, _ = strconv.ParseFloat(strings.TrimSpace(scores[0]), 64)
+					report.Required, _ = strconv.ParseFloat(strings.TrimSpace(scores[1]), 64)
+				}
+			}
+		}
+	}
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range strings.Split(strings.TrimSpace(string(body)), ",") {
+		if rule = strings.TrimSpace(rule); len(rule) > 0 {
+			report.Rules = append(report.Rules, rule)
+		}
+	}
+	return report, nil
+}
+
+// checkRspamd 调用 Rspamd 的 /checkv2 接口
+func checkRspamd(c *SpamCheckConfig, msg []byte) (*spamReport, error) {
+	req, err := http.NewRequest("POST", strings.TrimSuffix(c.Rspamd, "/")+"/checkv2", bytes.NewReader(msg))
+	if err != nil {
+		return nil, err
+	}
+	if len(c.Password) > 0 {
+		req.Header.Set("Password", c.Password)
+	}
+	client := &http.Client{Timeout: preflightTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var result struct {
+		Score         float64 `json:"score"`
+		RequiredScore float64 `json:"required_score"`
+		Symbols       map[string]struct {
+			Score float64 `json:"score"`
+		} `json:"symbols"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	report := &spamReport{Score: result.Score, Required: result.RequiredScore}
+	for name, symbol := range result.Symbols {
+		report.Rules = append(report.Rules, fmt.Sprintf("%s(%.1f)", name, symbol.Score))
+	}
+	sort.Strings(report.Rules)
+	return report, nil
+}
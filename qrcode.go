@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/skip2/go-qrcode"
+	"gopkg.in/gomail.v2"
+)
+
+const qrcodeName = "qrcode.png"
+
+func getQRCodeDecorator(content string, size int) (Decorator, error) {
+	if size <= 0 {
+		return nil, errors.New(trErr("二维码尺寸必须大于 0"))
+	}
+	t, err := compileTemplate("qrcode", ".", content, false)
+	if err != nil {
+		return nil, fmt.Errorf(trErr("解析二维码内容失败：%s"), err)
+	}
+
+	return func(m *gomail.Message, send *Send) error {
+		var buf bytes.Buffer
+		if err := t(&buf, send.Meta); err != nil {
+			return fmt.Errorf(trErr("渲染二维码内容失败：%s"), err)
+		}
+		if buf.Len() == 0 {
+			return errors.New(trErr("二维码内容为空"))
+		}
+		logDebug("To: %s, 二维码内容：%s", send.SendTo, buf.String())
+
+		png, err := qrcode.Encode(buf.String(), qrcode.Medium, size)
+		if err != nil {
+			return fmt.Errorf(trErr("生成二维码失败：%s"), err)
+		}
+		m.Embed(qrcodeName, gomail.SetCopyFunc(func(w io.Writer) error {
+			_, err := w.Write(png)
+			return err
+		}))
+		return nil
+	}, nil
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// QueueConfig 配置 queue 命令读取发送请求的消息队列，url 的协议决定使用的队列，name 为队列名；
+// retries 为临时失败（4xx、连接失败等）最多重试的次数，retry_delay 为第一次重试前等待的秒数，之后每次加倍，
+// 超过重试次数或永久失败（5xx、数据有问题）的请求放到 dead_letter
+type QueueConfig struct {
+	URL        string  `json:"url"`
+	Name       string  `json:"name"`
+	Consumer   string  `json:"consumer"`
+	Group      string  `json:"group"`
+	Partitions []int32 `json:"partitions"`
+	Stream     string  `json:"stream"`
+	Prefetch   int     `json:"prefetch"`
+	Batch      int     `json:"batch"`
+	Retries    int     `json:"retries"`
+	RetryDelay int64   `json:"retry_delay"`
+	DeadLetter string  `json:"dead_letter"`
+}
+
+// QueueJob 为队列中的一个发送请求，data 为模板使用的字段，与数据文件中的列相同，
+// 例如 SendAt、Attachments 等列也可以写在 data 中；content 不为空时代替模板作为正文
+type QueueJob struct {
+	ID       string                 `json:"id,omitempty"`
+	To       string                 `json:"to"`
+	Subject  string                 `json:"subject,omitempty"`
+	Content  string                 `json:"content,omitempty"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+	Attempts int                    `json:"attempts,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+}
+
+// queueMessage 为从队列中取出的一条消息，handle 为队列自己使用的数据（例如 offset、receipt handle）
+type queueMessage struct {
+	Body     []byte
+	Job      QueueJob
+	Attempts int
+	handle   interface{}
+	due      time.Time
+}
+
+// delayedMessages 为等待重试的消息，用于不能延迟重新投递的队列，保存在内存中
+type delayedMessages []*queueMessage
+
+func (d *delayedMessages) add(m *queueMessage, delay time.Duration) {
+	m.Body = queueBody(m)
+	m.Attempts = m.Job.Attempts
+	m.due = time.Now().Add(delay)
+	*d = append(*d, m)
+}
+
+// take 取出最多 n 个到了重试时间的消息
+func (d *delayedMessages) take(n int) []*queueMessage {
+	now := time.Now()
+	due := []*queueMessage{}
+	rest := delayedMessages{}
+	for _, m := range *d {
+		if len(due) < n && !m.due.After(now) {
+			due = append(due, m)
+		} else {
+			rest = append(rest, m)
+		}
+	}
+	*d = rest
+	return due
+}
+
+// wait 返回到下一个消息的重试时间最多还要等待多久，不超过 max
+func (d delayedMessages) wait(max time.Duration) time.Duration {
+	for _, m := range d {
+		if left := time.Until(m.due); left < max {
+			max = left
+		}
+	}
+	return max
+}
+
+// jobQueue 为 queue 命令支持的消息队列：
+// receive 最多等待 wait，返回最多 n 条消息；ack 确认发送完成；retry 在 delay 之后重新投递；
+// deadLetter 放到 dead_letter，Job.Error 为原因
+type jobQueue interface {
+	receive(n int, wait time.Duration) ([]*queueMessage, error)
+	ack(m *queueMessage) error
+	retry(m *queueMessage, delay time.Duration) error
+	deadLetter(m *queueMessage) error
+	Close() error
+}
+
+func openQueue(c *QueueConfig) (jobQueue, error) {
+	switch {
+	case strings.HasPrefix(c.URL, "redis://"), strings.HasPrefix(c.URL, "rediss://"):
+		return openRedisQueue(c)
+	case strings.HasPrefix(c.URL, "kafka://"), strings.HasPrefix(c.URL, "kafkas://"):
+		return openKafkaQueue(c)
+	case strings.HasPrefix(c.URL, "nats://"), strings.HasPrefix(c.URL, "tls://"):
+		return openNATSQueue(c)
+	case strings.HasPrefix(c.URL, "amqp://"), strings.HasPrefix(c.URL, "amqps://"):
+		return openAMQPQueue(c)
+	case strings.HasPrefix(c.URL, "sqs://"):
+		return openSQSQueue(c)
+	}
+	return nil, errors.New(trErr("不支持的队列地址，url 以 redis://、rediss://、kafka://、kafkas://、nats://、tls://、amqp://、amqps:// 或 sqs:// 开头"))
+}
+
+func queueDefaults(cfg *Config) (*QueueConfig, error) {
+	if cfg.Queue == nil || len(cfg.Queue.URL) == 0 || len(cfg.Queue.Name) == 0 {
+		return nil, errors.New(trErr("queue 命令需要在配置文件中配置 queue 的 url 和 name"))
+	}
+	c := *cfg.Queue
+	if len(c.Consumer) == 0 {
+		c.Consumer, _ = os.Hostname()
+	}
+	if len(c.Group) == 0 {
+		c.Group = "email-sender"
+	}
+	if c.Batch <= 0 {
+		c.Batch = 20
+	}
+	if c.Retries <= 0 {
+		c.Retries = 3
+	}
+	if c.RetryDelay <= 0 {
+		c.RetryDelay = 60
+	}
+	return &c, nil
+}
+
+// runQueue 不停地从队列中取出发送请求，每次最多 batch 个作为一个任务发送，stop 关闭后发送完当前这一批再返回
+func runQueue(cfg *Config, content, template string, contentProvider ContentProvider, decorators []Decorator, reporter *Reporter, stop <-chan struct{}) error {
+	qc, err := queueDefaults(cfg)
+	if err != nil {
+		return err
+	}
+	q, err := openQueue(qc)
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+	log.Printf(tr("开始处理队列 %s 中的发送请求"), qc.Name)
+
+	for batch := 1; ; batch++ {
+		select {
+		case <-stop:
+			return nil
+		default:
+		}
+		messages, err := q.receive(qc.Batch, 5*time.Second)
+		if err != nil {
+			log.Printf(tr("读取队列失败：%s"), redactText(err))
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		if len(messages) > 0 {
+			sendQueueBatch(cfg, qc, q, fmt.Sprintf("%s #%d", qc.Name, batch), messages, content, template, contentProvider, decorators, reporter)
+		}
+	}
+}
+
+// sendQueueBatch 把一批发送请求转换成表格，与数据文件一样检查、渲染和发送，然后按每个请求的结果确认、重试或放到 dead_letter
+func sendQueueBatch(cfg *Config, qc *QueueConfig, q jobQueue, name string, messages []*queueMessage, content, template string, contentProvider ContentProvider, decorators []Decorator, reporter *Reporter) {
+	jobs := []*queueMessage{}
+	for _, m := range messages {
+		decoder := json.NewDecoder(bytes.NewReader(m.Body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&m.Job); err != nil {
+			queueFailed(qc, q, m, fmt.Sprintf(trErr("无法解析的发送请求：%s"), err), true)
+			continue
+		}
+		jobs = append(jobs, m)
+	}
+	if len(jobs) == 0 {
+		return
+	}
+
+	// 每一批的问题行单独记录，用于把原因写入 dead_letter
+	saved := rejected
+	rejected = &rejectedRows{reasons: map[int]string{}}
+	defer func() {
+		rejected = saved
+	}()
+
+	list, _, err := parseRows(cfg, queueRows(jobs), nil)
+	if err == nil {
+		list, contentProvider, err = prepareList(cfg, list, content, template, contentProvider, nil)
+	}
+	if err != nil {
+		for _, m := range jobs {
+			queueFailed(qc, q, m, redactText(err), false)
+		}
+		return
+	}
+
+	sends := map[int]*Send{}
+	for _, s := range list {
+		sends[s.Row] = s
+	}
+	campaign := newCampaign(name, list, reporter)
+	err = sendEmails(cfg, list, contentProvider, decorators, campaign)
+	if err != nil {
+		log.Printf(tr("%s 发送失败：%s"), name, redactText(err))
+	}
+
+	for i, m := range jobs {
+		row := i + 2
+		s, ok := sends[row]
+		if !ok {
+			reason := rejected.reasons[row]
+			if len(reason) == 0 {
+				reason = trErr("数据检查未通过")
+			}
+			queueFailed(qc, q, m, redactText(reason), true)
+			continue
+		}
+		done, failure := campaign.outcome(s)
+		switch {
+		case len(failure) > 0:
+			queueFailed(qc, q, m, failure, strings.HasPrefix(smtpErrorCode(failure), "5"))
+		case done:
+			if err := q.ack(m); err != nil {
+				log.Printf(tr("确认发送请求失败：%s"), redactText(err))
+			}
+		case err != nil:
+			queueFailed(qc, q, m, redactText(err), false)
+		default:
+			// 任务被取消或者预热限制了发送量，不计入重试次数
+			if err := q.retry(m, 0); err != nil {
+				log.Printf(tr("重新投递发送请求失败：%s"), redactText(err))
+			}
+		}
+	}
+}
+
+// queueFailed 按重试次数重新投递，永久失败或超过 retries 次时放到 dead_letter
+func queueFailed(qc *QueueConfig, q jobQueue, m *queueMessage, reason string, permanent bool) {
+	m.Job.Attempts = m.Attempts + 1
+	m.Job.Error = reason
+	if permanent || m.Job.Attempts > qc.Retries {
+		log.Printf(tr("发送请求 %s 放到 dead_letter：%s"), queueJobName(m), reason)
+		if err := q.deadLetter(m); err != nil {
+			log.Printf(tr("写入 dead_letter 失败：%s"), redactText(err))
+		}
+		return
+	}
+	delay := time.Duration(qc.RetryDelay) * time.Second << uint(m.Job.Attempts-1)
+	logDebug("发送请求 %s 第 %d 次失败，%v 后重试：%s", queueJobName(m), m.Job.Attempts, delay, reason)
+	if err := q.retry(m, delay); err != nil {
+		log.Printf(tr("重新投递发送请求失败：%s"), redactText(err))
+	}
+}
+
+// queueBody 返回重试或放到 dead_letter 时保存的消息，加上了 attempts 和 error；无法解析的消息保持原样
+func queueBody(m *queueMessage) []byte {
+	if len(m.Job.To) == 0 {
+		return m.Body
+	}
+	data, err := json.Marshal(m.Job)
+	if err != nil {
+		return m.Body
+	}
+	return data
+}
+
+func queueJobName(m *queueMessage) string {
+	if len(m.Job.ID) > 0 {
+		return m.Job.ID
+	}
+	if len(m.Job.To) > 0 {
+		return maskAddress(m.Job.To)
+	}
+	return "-"
+}
+
+// queueRows 把发送请求转换成带表头的表格，列为 SendTo、Subject、Content 和所有请求 data 中出现的字段
+func queueRows(jobs []*queueMessage) [][]string {
+	fields := map[string]bool{}
+	for _, m := range jobs {
+		for k := range m.Job.Data {
+			fields[k] = true
+		}
+	}
+	delete(fields, "SendTo")
+	delete(fields, "Subject")
+	delete(fields, "Content")
+	header := []string{"SendTo", "Subject", "Content"}
+	for k := range fields {
+		header = append(header, k)
+	}
+	sort.Strings(header[3:])
+
+	rows := [][]string{header}
+	for _, m := range jobs {
+		row := []string{m.Job.To, m.Job.Subject, m.Job.Content}
+		for _, k := range header[3:] {
+			row = append(row, queueValue(m.Job.Data[k]))
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func queueValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number, bool:
+		return fmt.Sprint(v)
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// stopSignal 在收到 SIGINT / SIGTERM 时关闭返回的通道，queue 命令发送完当前这一批后退出，再次收到信号时直接退出
+func stopSignal() <-chan struct{} {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	stop := make(chan struct{})
+	go func() {
+		sig := <-signals
+		log.Printf(tr("收到 %s 信号，发送完当前这一批后退出"), sig)
+		close(stop)
+		<-signals
+		os.Exit(1)
+	}()
+	return stop
+}
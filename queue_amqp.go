@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// amqpMaxPrefetch 为 prefetch 的上限，不超过接收消息的缓冲区
+const amqpMaxPrefetch = 1000
+
+// amqpQueue 从 AMQP（RabbitMQ）队列 name 读取发送请求，手动确认，prefetch 限制没有确认的消息数量，默认与 batch 相同；
+// 等待重试的消息发布到 <name>.retry，过期后由服务器通过 dead-letter 放回 name，重新启动后也不会丢失；
+// dead_letter 为放失败请求的队列，默认为 <name>.dead；连接断开后没有确认的消息由服务器重新投递
+type amqpQueue struct {
+	cfg      *QueueConfig
+	conn     *amqpConn
+	prefetch int
+	retryTo  string
+	dead     string
+}
+
+// amqpHandle 记录消息来自哪个连接，delivery tag 只在这个连接上有效
+type amqpHandle struct {
+	conn *amqpConn
+	tag  uint64
+}
+
+func openAMQPQueue(c *QueueConfig) (jobQueue, error) {
+	q := &amqpQueue{cfg: c, prefetch: c.Prefetch, retryTo: c.Name + ".retry", dead: c.DeadLetter}
+	if q.prefetch <= 0 {
+		q.prefetch = c.Batch
+	}
+	if q.prefetch > amqpMaxPrefetch {
+		q.prefetch = amqpMaxPrefetch
+	}
+	if len(q.dead) == 0 {
+		q.dead = c.Name + ".dead"
+	}
+	if err := q.connect(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *amqpQueue) connect() error {
+	conn, err := dialAMQP(q.cfg.URL)
+	if err != nil {
+		return err
+	}
+	if err := q.setup(conn); err != nil {
+		conn.Close()
+		return err
+	}
+	q.conn = conn
+	return nil
+}
+
+// setup 设置 prefetch、开启 publisher confirm，声明 retry 和 dead_letter 队列后开始消费
+func (q *amqpQueue) setup(conn *amqpConn) error {
+	if _, err := conn.call(amqpClassBasic, 10, amqpClassBasic, 11, func(w *amqpWriter) {
+		w.putLong(0)
+		w.putShort(uint16(q.prefetch))
+		w.putOctet(0)
+	}); err != nil {
+		return err
+	}
+	if _, err := conn.call(amqpClassConfirm, 10, amqpClassConfirm, 11, func(w *amqpWriter) {
+		w.putOctet(0)
+	}); err != nil {
+		return err
+	}
+
+	declare := func(name string, args map[string]interface{}) error {
+		_, err := conn.call(amqpClassQueue, 10, amqpClassQueue, 11, func(w *amqpWriter) {
+			w.putShort(0)
+			w.putShortstr(name)
+			w.putOctet(2) // durable
+			w.putTable(args)
+		})
+		return err
+	}
+	if err := declare(q.retryTo, map[string]interface{}{
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": q.cfg.Name,
+	}); err != nil {
+		return err
+	}
+	if err := declare(q.dead, nil); err != nil {
+		return err
+	}
+
+	_, err := conn.call(amqpClassBasic, 20, amqpClassBasic, 21, func(w *amqpWriter) {
+		w.putShort(0)
+		w.putShortstr(q.cfg.Name)
+		w.putShortstr(q.cfg.Consumer)
+		w.putOctet(0) // 需要确认
+		w.putTable(nil)
+	})
+	return err
+}
+
+func (q *amqpQueue) receive(n int, wait time.Duration) ([]*queueMessage, error) {
+	select {
+	case <-q.conn.done:
+		logDebug("AMQP 连接已断开：%s", q.conn.err)
+		q.conn.Close()
+		if err := q.connect(); err != nil {
+			return nil, err
+		}
+	default:
+	}
+
+	messages := []*queueMessage{}
+	timeout := time.After(wait)
+	for len(messages) < n {
+		select {
+		case d := <-q.conn.deliveries:
+			m := &queueMessage{Body: d.Body, handle: &amqpHandle{conn: q.conn, tag: d.Tag}}
+			var job QueueJob
+			if json.Unmarshal(m.Body, &job) == nil {
+				m.Attempts = job.Attempts
+			}
+			messages = append(messages, m)
+			// 收到消息后只再等一会儿同一批的其他消息
+			timeout = time.After(100 * time.Millisecond)
+		case <-timeout:
+			return messages, nil
+		case <-q.conn.done:
+			return messages, q.conn.err
+		}
+	}
+	return messages, nil
+}
+
+// current 返回消息在当前连接上的 delivery tag，连接断开重连后旧的消息已经由服务器重新投递，不能再确认
+func (q *amqpQueue) current(m *queueMessage) (uint64, bool) {
+	h, ok := m.handle.(*amqpHandle)
+	if !ok || h.conn != q.conn {
+		return 0, false
+	}
+	return h.tag, true
+}
+
+func (q *amqpQueue) ack(m *queueMessage) error {
+	tag, ok := q.current(m)
+	if !ok {
+		return nil
+	}
+	return q.conn.cast(amqpClassBasic, 80, func(w *amqpWriter) {
+		w.putLonglong(tag)
+		w.putOctet(0)
+	})
+}
+
+// forward 把加上了重试次数和原因的消息发布到另一个队列，服务器确认保存后再确认原来的消息
+func (q *amqpQueue) forward(m *queueMessage, to string, expiration time.Duration) error {
+	if _, ok := q.current(m); !ok {
+		return nil
+	}
+	if err := q.conn.publish("", to, queueBody(m), expiration); err != nil {
+		return err
+	}
+	if err := q.conn.confirm(); err != nil {
+		return err
+	}
+	return q.ack(m)
+}
+
+// retry 在 delay 为 0 时让服务器立即重新投递，否则发布到 retry 队列，delay 之后过期回到 name
+func (q *amqpQueue) retry(m *queueMessage, delay time.Duration) error {
+	if delay > 0 {
+		return q.forward(m, q.retryTo, delay)
+	}
+	tag, ok := q.current(m)
+	if !ok {
+		return nil
+	}
+	return q.conn.cast(amqpClassBasic, 120, func(w *amqpWriter) {
+		w.putLonglong(tag)
+		w.putOctet(2) // requeue
+	})
+}
+
+func (q *amqpQueue) deadLetter(m *queueMessage) error {
+	return q.forward(m, q.dead, 0)
+}
+
+func (q *amqpQueue) Close() error {
+	return q.conn.Close()
+}
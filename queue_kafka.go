@@ -0,0 +1,501 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// kafkaQueue 从 Kafka topic 读取发送请求：name 为 topic，group 为保存 offset 的 consumer group，
+// partitions 为这个进程读取的分区，默认为所有分区，多个进程处理同一个 topic 时各自指定不同的分区；
+// offset 只提交到最早的还没有确认的消息，进程退出后从那里重新读取；等待重试的消息保存在内存中，
+// dead_letter 为另一个 topic，默认为 <name>.dead
+type kafkaQueue struct {
+	cfg       *QueueConfig
+	seeds     []string
+	tls       bool
+	user      string
+	password  string
+	dead      string
+	conns     map[string]*kafkaConn
+	brokers   map[int32]string
+	leaders   map[int32]string
+	deadAddr  string
+	coord     string
+	next      map[int32]int64
+	committed map[int32]int64
+	pending   map[int32]map[int64]bool
+	fetched   []*queueMessage
+	delayed   delayedMessages
+}
+
+type kafkaHandle struct {
+	partition int32
+	offset    int64
+}
+
+func openKafkaQueue(c *QueueConfig) (jobQueue, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil || len(u.Host) == 0 {
+		return nil, errors.New(trErr("无效的 Kafka 地址，格式为 kafka://[用户名:密码@]host[:port][,host[:port]...]"))
+	}
+	q := &kafkaQueue{
+		cfg:       c,
+		tls:       u.Scheme == "kafkas",
+		dead:      c.DeadLetter,
+		conns:     map[string]*kafkaConn{},
+		committed: map[int32]int64{},
+		pending:   map[int32]map[int64]bool{},
+	}
+	for _, host := range strings.Split(u.Host, ",") {
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(host, "9092")
+		}
+		q.seeds = append(q.seeds, host)
+	}
+	if u.User != nil {
+		q.user = u.User.Username()
+		q.password, _ = u.User.Password()
+	}
+	if len(q.dead) == 0 {
+		q.dead = c.Name + ".dead"
+	}
+	if err := q.connect(); err != nil {
+		q.Close()
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *kafkaQueue) conn(addr string) (*kafkaConn, error) {
+	if c, ok := q.conns[addr]; ok {
+		return c, nil
+	}
+	c, err := dialKafka(addr, q.tls, q.user, q.password)
+	if err != nil {
+		return nil, err
+	}
+	q.conns[addr] = c
+	return c, nil
+}
+
+// call 发送请求到 addr，出错时断开所有连接，下次读取时重新获取分区的 leader
+func (q *kafkaQueue) call(addr string, key, version int16, body []byte, wait time.Duration) (*kafkaReader, error) {
+	c, err := q.conn(addr)
+	if err == nil {
+		var r *kafkaReader
+		if r, err = c.call(key, version, body, wait); err == nil {
+			return r, nil
+		}
+	}
+	q.reset()
+	return nil, err
+}
+
+func (q *kafkaQueue) reset() {
+	for addr, c := range q.conns {
+		c.Close()
+		delete(q.conns, addr)
+	}
+	q.leaders = nil
+}
+
+// connect 从 seeds 中的一个 broker 读取分区的 leader 和 consumer group 的 coordinator，
+// 第一次连接时从 group 提交的 offset 开始读取，新的 group 从最新的消息开始
+func (q *kafkaQueue) connect() error {
+	var seed string
+	var err error
+	for _, seed = range q.seeds {
+		if err = q.metadata(seed); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf(trErr("连接 Kafka 失败：%s"), err)
+	}
+
+	var w kafkaWriter
+	w.putString(q.cfg.Group)
+	r, err := q.call(seed, kafkaFindCoordinator, 0, w.Bytes(), 0)
+	if err != nil {
+		return err
+	}
+	if code := r.int16(); code != 0 {
+		return kafkaError(code)
+	}
+	r.int32()
+	q.coord = net.JoinHostPort(r.str(), strconv.Itoa(int(r.int32())))
+	if r.err != nil {
+		return r.err
+	}
+
+	if q.next == nil {
+		return q.fetchOffsets()
+	}
+	return nil
+}
+
+func (q *kafkaQueue) metadata(seed string) error {
+	var w kafkaWriter
+	w.putInt32(2)
+	w.putString(q.cfg.Name)
+	w.putString(q.dead)
+	r, err := q.call(seed, kafkaMetadata, 1, w.Bytes(), 0)
+	if err != nil {
+		return err
+	}
+
+	q.brokers = map[int32]string{}
+	for i := r.int32(); i > 0; i-- {
+		id := r.int32()
+		host := r.str()
+		port := r.int32()
+		r.str() // rack
+		q.brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	r.int32() // controller
+
+	leaders := map[int32]string{}
+	for i := r.int32(); i > 0 && r.err == nil; i-- {
+		code := r.int16()
+		topic := r.str()
+		r.int8() // internal
+		if topic == q.cfg.Name && code != 0 {
+			return fmt.Errorf(trErr("Kafka topic %s 不可用：%s"), topic, kafkaError(code))
+		}
+		for j := r.int32(); j > 0 && r.err == nil; j-- {
+			r.int16()
+			partition := r.int32()
+			leader := q.brokers[r.int32()]
+			for k := r.int32(); k > 0; k-- {
+				r.int32() // replica
+			}
+			for k := r.int32(); k > 0; k-- {
+				r.int32() // isr
+			}
+			if topic == q.cfg.Name {
+				leaders[partition] = leader
+			} else if partition == 0 {
+				q.deadAddr = leader
+			}
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	if len(q.cfg.Partitions) > 0 {
+		selected := map[int32]string{}
+		for _, p := range q.cfg.Partitions {
+			if _, ok := leaders[p]; !ok {
+				return fmt.Errorf(trErr("Kafka topic %s 没有分区 %d"), q.cfg.Name, p)
+			}
+			selected[p] = leaders[p]
+		}
+		leaders = selected
+	}
+	q.leaders = leaders
+	return nil
+}
+
+func (q *kafkaQueue) partitions() []int32 {
+	list := []int32{}
+	for p := range q.leaders {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i] < list[j]
+	})
+	return list
+}
+
+func (q *kafkaQueue) fetchOffsets() error {
+	var w kafkaWriter
+	w.putString(q.cfg.Group)
+	w.putInt32(1)
+	w.putString(q.cfg.Name)
+	partitions := q.partitions()
+	w.putInt32(int32(len(partitions)))
+	for _, p := range partitions {
+		w.putInt32(p)
+	}
+	r, err := q.call(q.coord, kafkaOffsetFetch, 1, w.Bytes(), 0)
+	if err != nil {
+		return err
+	}
+
+	next := map[int32]int64{}
+	for i := r.int32(); i > 0 && r.err == nil; i-- {
+		r.str()
+		for j := r.int32(); j > 0 && r.err == nil; j-- {
+			p := r.int32()
+			offset := r.int64()
+			r.str() // metadata
+			if code := r.int16(); code != 0 {
+				return kafkaError(code)
+			}
+			if offset >= 0 {
+				next[p] = offset
+				q.committed[p] = offset
+			}
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+	for _, p := range partitions {
+		if _, ok := next[p]; !ok {
+			offset, err := q.listOffset(p, -1)
+			if err != nil {
+				return err
+			}
+			next[p] = offset
+		}
+	}
+	q.next = next
+	return nil
+}
+
+// listOffset 返回分区最早（timestamp 为 -2）或最新（-1）的 offset
+func (q *kafkaQueue) listOffset(p int32, timestamp int64) (int64, error) {
+	var w kafkaWriter
+	w.putInt32(-1)
+	w.putInt32(1)
+	w.putString(q.cfg.Name)
+	w.putInt32(1)
+	w.putInt32(p)
+	w.putInt64(timestamp)
+	r, err := q.call(q.leaders[p], kafkaListOffsets, 1, w.Bytes(), 0)
+	if err != nil {
+		return 0, err
+	}
+	r.int32()
+	r.str()
+	r.int32()
+	r.int32()
+	if code := r.int16(); code != 0 {
+		return 0, kafkaError(code)
+	}
+	r.int64()
+	offset := r.int64()
+	return offset, r.err
+}
+
+func (q *kafkaQueue) receive(n int, wait time.Duration) ([]*queueMessage, error) {
+	messages := q.delayed.take(n)
+	for len(messages) < n && len(q.fetched) > 0 {
+		messages = append(messages, q.fetched[0])
+		q.fetched = q.fetched[1:]
+	}
+	if len(messages) > 0 {
+		return messages, nil
+	}
+
+	wait = q.delayed.wait(wait)
+	if q.leaders == nil {
+		if err := q.connect(); err != nil {
+			return nil, err
+		}
+	}
+	if err := q.fetch(wait); err != nil {
+		return nil, err
+	}
+	for len(messages) < n && len(q.fetched) > 0 {
+		messages = append(messages, q.fetched[0])
+		q.fetched = q.fetched[1:]
+	}
+	return messages, nil
+}
+
+// fetch 从每个 leader 读取这个进程的分区中的新消息，每个 leader 最多等待 wait 平分后的时间
+func (q *kafkaQueue) fetch(wait time.Duration) error {
+	byLeader := map[string][]int32{}
+	for _, p := range q.partitions() {
+		byLeader[q.leaders[p]] = append(byLeader[q.leaders[p]], p)
+	}
+	if len(byLeader) > 0 {
+		wait /= time.Duration(len(byLeader))
+	}
+
+	for addr, partitions := range byLeader {
+		var w kafkaWriter
+		w.putInt32(-1)
+		w.putInt32(int32(wait / time.Millisecond))
+		w.putInt32(1)
+		w.putInt32(4 << 20)
+		w.putInt8(1) // read committed
+		w.putInt32(1)
+		w.putString(q.cfg.Name)
+		w.putInt32(int32(len(partitions)))
+		for _, p := range partitions {
+			w.putInt32(p)
+			w.putInt64(q.next[p])
+			w.putInt32(1 << 20)
+		}
+		r, err := q.call(addr, kafkaFetch, 4, w.Bytes(), wait)
+		if err != nil {
+			return err
+		}
+
+		r.int32() // throttle
+		for i := r.int32(); i > 0 && r.err == nil; i-- {
+			r.str()
+			for j := r.int32(); j > 0 && r.err == nil; j-- {
+				p := r.int32()
+				code := r.int16()
+				r.int64() // high watermark
+				r.int64() // last stable offset
+				for k := r.int32(); k > 0; k-- {
+					r.int64()
+					r.int64()
+				}
+				data := r.blob()
+				if r.err != nil {
+					return r.err
+				}
+				switch code {
+				case 0:
+				case kafkaOffsetOutOfRange:
+					// 消息已经过期删除
+					offset, err := q.listOffset(p, -2)
+					if err != nil {
+						return err
+					}
+					logDebug("分区 %d 的 offset %d 已经不存在，从 %d 开始读取", p, q.next[p], offset)
+					q.next[p] = offset
+					continue
+				default:
+					q.reset()
+					return kafkaError(code)
+				}
+				records, err := parseKafkaRecords(data)
+				if err != nil {
+					return err
+				}
+				for _, rec := range records {
+					if rec.Offset < q.next[p] {
+						continue
+					}
+					q.next[p] = rec.Offset + 1
+					if q.pending[p] == nil {
+						q.pending[p] = map[int64]bool{}
+					}
+					q.pending[p][rec.Offset] = true
+					q.fetched = append(q.fetched, &queueMessage{Body: rec.Value, handle: &kafkaHandle{partition: p, offset: rec.Offset}})
+				}
+			}
+		}
+		if r.err != nil {
+			return r.err
+		}
+	}
+	return nil
+}
+
+// commit 把每个分区的 offset 提交到最早的还没有确认的消息
+func (q *kafkaQueue) commit() error {
+	offsets := map[int32]int64{}
+	for p, next := range q.next {
+		for offset := range q.pending[p] {
+			if offset < next {
+				next = offset
+			}
+		}
+		if next != q.committed[p] {
+			offsets[p] = next
+		}
+	}
+	if len(offsets) == 0 {
+		return nil
+	}
+
+	var w kafkaWriter
+	w.putString(q.cfg.Group)
+	w.putInt32(-1) // generation
+	w.putString("")
+	w.putInt64(-1) // retention
+	w.putInt32(1)
+	w.putString(q.cfg.Name)
+	w.putInt32(int32(len(offsets)))
+	for p, offset := range offsets {
+		w.putInt32(p)
+		w.putInt64(offset)
+		w.putInt16(-1) // metadata
+	}
+	r, err := q.call(q.coord, kafkaOffsetCommit, 2, w.Bytes(), 0)
+	if err != nil {
+		return err
+	}
+	for i := r.int32(); i > 0 && r.err == nil; i-- {
+		r.str()
+		for j := r.int32(); j > 0 && r.err == nil; j-- {
+			p := r.int32()
+			if code := r.int16(); code != 0 {
+				return kafkaError(code)
+			}
+			q.committed[p] = offsets[p]
+		}
+	}
+	return r.err
+}
+
+func (q *kafkaQueue) ack(m *queueMessage) error {
+	h := m.handle.(*kafkaHandle)
+	delete(q.pending[h.partition], h.offset)
+	return q.commit()
+}
+
+// retry 把消息留在内存中，delay 之后再发送，这期间这个分区的 offset 不会提交到这条消息之后
+func (q *kafkaQueue) retry(m *queueMessage, delay time.Duration) error {
+	q.delayed.add(m, delay)
+	return nil
+}
+
+func (q *kafkaQueue) deadLetter(m *queueMessage) error {
+	if len(q.deadAddr) == 0 || q.leaders == nil {
+		if err := q.connect(); err != nil {
+			return err
+		}
+		if len(q.deadAddr) == 0 {
+			return fmt.Errorf(trErr("Kafka topic %s 不存在"), q.dead)
+		}
+	}
+
+	var w kafkaWriter
+	w.putInt16(-1) // transactional id
+	w.putInt16(-1) // acks: all
+	w.putInt32(10000)
+	w.putInt32(1)
+	w.putString(q.dead)
+	w.putInt32(1)
+	w.putInt32(0)
+	w.putBytes(kafkaRecordBatch(queueBody(m)))
+	r, err := q.call(q.deadAddr, kafkaProduce, 3, w.Bytes(), 10*time.Second)
+	if err != nil {
+		return err
+	}
+	r.int32()
+	r.str()
+	r.int32()
+	r.int32()
+	if code := r.int16(); code != 0 {
+		if code == kafkaNotLeader {
+			q.reset()
+		}
+		return kafkaError(code)
+	}
+	if r.err != nil {
+		return r.err
+	}
+	return q.ack(m)
+}
+
+func (q *kafkaQueue) Close() error {
+	q.reset()
+	return nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// natsAckWait 为 JetStream 等待确认的时间，发送期间每隔 natsAckWait/3 告诉服务器消息还在处理
+const natsAckWait = 30 * time.Second
+
+// natsQueue 从 NATS 读取发送请求：没有配置 stream 时订阅 subject name，group 为 queue group，
+// 同一个 group 的多个进程分担消息，消息没有持久化，等待重试的消息保存在内存中；
+// 配置了 stream 时使用 JetStream 上名为 group 的 durable pull consumer，确认后服务器才删除消息，
+// 重试用 -NAK 延迟重新投递；dead_letter 为发布失败请求的 subject，默认为 <name>.dead
+type natsQueue struct {
+	cfg      *QueueConfig
+	conn     *natsConn
+	msgs     chan *natsMsg
+	inbox    string
+	dead     string
+	mu       sync.Mutex
+	inflight map[string]bool
+	delayed  delayedMessages
+	stop     chan struct{}
+}
+
+func openNATSQueue(c *QueueConfig) (jobQueue, error) {
+	q := &natsQueue{cfg: c, dead: c.DeadLetter, inflight: map[string]bool{}, stop: make(chan struct{})}
+	if len(q.dead) == 0 {
+		q.dead = c.Name + ".dead"
+	}
+	if err := q.connect(); err != nil {
+		return nil, err
+	}
+	if len(c.Stream) > 0 {
+		go q.progress()
+	}
+	return q, nil
+}
+
+func (q *natsQueue) connect() error {
+	conn, err := dialNATS(q.cfg.URL)
+	if err != nil {
+		return fmt.Errorf(trErr("连接 NATS 失败：%s"), err)
+	}
+	if len(q.cfg.Stream) == 0 {
+		if _, q.msgs, err = conn.subscribe(q.cfg.Name, q.cfg.Group); err != nil {
+			conn.Close()
+			return err
+		}
+		q.setConn(conn)
+		return nil
+	}
+
+	config, _ := json.Marshal(map[string]interface{}{
+		"stream_name": q.cfg.Stream,
+		"config": map[string]interface{}{
+			"durable_name":    q.cfg.Group,
+			"ack_policy":      "explicit",
+			"ack_wait":        int64(natsAckWait),
+			"filter_subject":  q.cfg.Name,
+			"deliver_policy":  "all",
+			"max_ack_pending": 1000,
+		},
+	})
+	reply, err := conn.request("$JS.API.CONSUMER.DURABLE.CREATE."+q.cfg.Stream+"."+q.cfg.Group, config, 10*time.Second)
+	if err == nil {
+		var resp struct {
+			Error *struct {
+				Code        int    `json:"code"`
+				Description string `json:"description"`
+			} `json:"error"`
+		}
+		if err = json.Unmarshal(reply.Data, &resp); err == nil && resp.Error != nil {
+			err = fmt.Errorf("%d %s", resp.Error.Code, resp.Error.Description)
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf(trErr("创建 JetStream consumer 失败：%s"), err)
+	}
+	q.inbox = natsInbox()
+	if _, q.msgs, err = conn.subscribe(q.inbox, ""); err != nil {
+		conn.Close()
+		return err
+	}
+	q.setConn(conn)
+	return nil
+}
+
+func (q *natsQueue) setConn(conn *natsConn) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.conn = conn
+}
+
+// progress 定期告诉服务器正在发送的消息还在处理，发送时间超过 ack_wait 时不会重新投递
+func (q *natsQueue) progress() {
+	ticker := time.NewTicker(natsAckWait / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-ticker.C:
+		}
+		q.mu.Lock()
+		for reply := range q.inflight {
+			q.conn.publish(reply, "", []byte("+WPI"))
+		}
+		q.mu.Unlock()
+	}
+}
+
+func (q *natsQueue) receive(n int, wait time.Duration) ([]*queueMessage, error) {
+	select {
+	case <-q.conn.done:
+		logDebug("NATS 连接已断开：%s", q.conn.err)
+		q.conn.Close()
+		q.mu.Lock()
+		q.inflight = map[string]bool{}
+		q.mu.Unlock()
+		if err := q.connect(); err != nil {
+			return nil, err
+		}
+	default:
+	}
+
+	messages := q.delayed.take(n)
+	if len(messages) > 0 {
+		return messages, nil
+	}
+	wait = q.delayed.wait(wait)
+	if len(q.cfg.Stream) > 0 {
+		request, _ := json.Marshal(map[string]interface{}{"batch": n, "expires": int64(wait)})
+		if err := q.conn.publish("$JS.API.CONSUMER.MSG.NEXT."+q.cfg.Stream+"."+q.cfg.Group, q.inbox, request); err != nil {
+			return nil, err
+		}
+		wait += time.Second
+	}
+
+	timeout := time.After(wait)
+	for len(messages) < n {
+		select {
+		case m := <-q.msgs:
+			if len(m.Status) > 0 {
+				// 404 没有消息、408 等待超时，这次读取结束
+				return messages, nil
+			}
+			messages = append(messages, q.message(m))
+			// 收到消息后只再等一会儿同一批的其他消息
+			timeout = time.After(100 * time.Millisecond)
+		case <-timeout:
+			return messages, nil
+		case <-q.conn.done:
+			return messages, q.conn.err
+		}
+	}
+	return messages, nil
+}
+
+func (q *natsQueue) message(m *natsMsg) *queueMessage {
+	msg := &queueMessage{Body: m.Data, handle: m.Reply}
+	if len(q.cfg.Stream) == 0 {
+		var job QueueJob
+		if json.Unmarshal(m.Data, &job) == nil {
+			msg.Attempts = job.Attempts
+		}
+		return msg
+	}
+	msg.Attempts = natsDelivered(m.Reply) - 1
+	q.mu.Lock()
+	q.inflight[m.Reply] = true
+	q.mu.Unlock()
+	return msg
+}
+
+// natsDelivered 从 JetStream 消息的回复地址中读取投递次数：
+// $JS.ACK.<stream>.<consumer>.<次数>.<...>，或者新格式 $JS.ACK.<domain>.<account>.<stream>.<consumer>.<次数>.<...>
+func natsDelivered(reply string) int {
+	tokens := strings.Split(reply, ".")
+	i := 4
+	if len(tokens) >= 11 {
+		i = 6
+	}
+	if len(tokens) <= i {
+		return 1
+	}
+	n, err := strconv.Atoi(tokens[i])
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// reply 回复 JetStream 消息：+ACK 确认，-NAK 重新投递，+TERM 不再投递
+func (q *natsQueue) reply(m *queueMessage, data string) error {
+	reply, _ := m.handle.(string)
+	if len(q.cfg.Stream) == 0 || len(reply) == 0 {
+		return nil
+	}
+	q.mu.Lock()
+	delete(q.inflight, reply)
+	q.mu.Unlock()
+	return q.conn.publish(reply, "", []byte(data))
+}
+
+func (q *natsQueue) ack(m *queueMessage) error {
+	return q.reply(m, "+ACK")
+}
+
+func (q *natsQueue) retry(m *queueMessage, delay time.Duration) error {
+	if len(q.cfg.Stream) == 0 {
+		q.delayed.add(m, delay)
+		return nil
+	}
+	return q.reply(m, fmt.Sprintf(`-NAK {"delay": %d}`, int64(delay)))
+}
+
+func (q *natsQueue) deadLetter(m *queueMessage) error {
+	if err := q.conn.publish(q.dead, "", queueBody(m)); err != nil {
+		return err
+	}
+	return q.reply(m, "+TERM")
+}
+
+func (q *natsQueue) Close() error {
+	close(q.stop)
+	return q.conn.Close()
+}
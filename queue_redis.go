@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// redisQueue 使用 Redis 列表作为队列：生产者 LPUSH 到 name，取出的消息放在 name:processing:<consumer> 直到确认，
+// 进程退出后重新启动时放回 name；等待重试的消息放在有序集合 name:delayed，分数为重新投递的时间
+type redisQueue struct {
+	cfg        *QueueConfig
+	client     *redisClient
+	processing string
+	delayed    string
+	dead       string
+}
+
+func openRedisQueue(c *QueueConfig) (jobQueue, error) {
+	q := &redisQueue{
+		cfg:        c,
+		processing: c.Name + ":processing:" + c.Consumer,
+		delayed:    c.Name + ":delayed",
+		dead:       c.DeadLetter,
+	}
+	if len(q.dead) == 0 {
+		q.dead = c.Name + ":dead"
+	}
+	if err := q.dial(); err != nil {
+		return nil, err
+	}
+
+	// 上次退出时没有处理完的消息
+	for {
+		v, err := q.client.do("RPOPLPUSH", q.processing, c.Name)
+		if err != nil {
+			q.client.Close()
+			return nil, err
+		}
+		if v == nil {
+			break
+		}
+	}
+	return q, nil
+}
+
+func (q *redisQueue) dial() error {
+	client, err := dialRedis(q.cfg.URL)
+	if err != nil {
+		return err
+	}
+	q.client = client
+	return nil
+}
+
+// do 执行命令，连接断开时重新连接一次
+func (q *redisQueue) do(args ...string) (interface{}, error) {
+	v, err := q.client.do(args...)
+	if _, ok := err.(redisError); err == nil || ok {
+		return v, err
+	}
+	q.client.Close()
+	if err := q.dial(); err != nil {
+		return nil, err
+	}
+	return q.client.do(args...)
+}
+
+// promote 把到了重试时间的消息放回队列，ZREM 成功的消费者负责放回，多个消费者同时检查时不会重复
+func (q *redisQueue) promote() error {
+	v, err := q.do("ZRANGEBYSCORE", q.delayed, "-inf", strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10), "LIMIT", "0", "100")
+	if err != nil {
+		return err
+	}
+	items, _ := v.([]interface{})
+	for _, item := range items {
+		body, ok := item.(string)
+		if !ok {
+			continue
+		}
+		if n, err := redisInt(q.do("ZREM", q.delayed, body)); err != nil || n == 0 {
+			continue
+		}
+		if _, err := q.do("RPUSH", q.cfg.Name, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *redisQueue) receive(n int, wait time.Duration) ([]*queueMessage, error) {
+	if err := q.promote(); err != nil {
+		return nil, err
+	}
+	seconds := strconv.Itoa(int(wait / time.Second))
+	messages := []*queueMessage{}
+	for len(messages) < n {
+		var v interface{}
+		var err error
+		if len(messages) == 0 {
+			v, err = q.do("BRPOPLPUSH", q.cfg.Name, q.processing, seconds)
+		} else {
+			v, err = q.do("RPOPLPUSH", q.cfg.Name, q.processing)
+		}
+		if err != nil {
+			return messages, err
+		}
+		body, ok := v.(string)
+		if !ok {
+			break
+		}
+		m := &queueMessage{Body: []byte(body), handle: body}
+		var job QueueJob
+		if json.Unmarshal(m.Body, &job) == nil {
+			m.Attempts = job.Attempts
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+func (q *redisQueue) remove(m *queueMessage) error {
+	_, err := q.do("LREM", q.processing, "1", m.handle.(string))
+	return err
+}
+
+func (q *redisQueue) ack(m *queueMessage) error {
+	return q.remove(m)
+}
+
+// retry 保存加上了重试次数和原因的消息，delay 之后由 promote 放回队列
+func (q *redisQueue) retry(m *queueMessage, delay time.Duration) error {
+	body := string(queueBody(m))
+	at := time.Now().Add(delay).UnixNano() / int64(time.Millisecond)
+	if _, err := q.do("ZADD", q.delayed, strconv.FormatInt(at, 10), body); err != nil {
+		return err
+	}
+	return q.remove(m)
+}
+
+func (q *redisQueue) deadLetter(m *queueMessage) error {
+	body := string(queueBody(m))
+	if _, err := q.do("LPUSH", q.dead, body); err != nil {
+		return err
+	}
+	return q.remove(m)
+}
+
+func (q *redisQueue) Close() error {
+	return q.client.Close()
+}
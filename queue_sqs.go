@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sqsVisibility 为取出的消息对其他消费者隐藏的时间，发送期间每隔 sqsVisibility/3 延长一次
+const sqsVisibility = 60 * time.Second
+
+// sqsMaxDelay 为 ChangeMessageVisibility 允许的最长时间
+const sqsMaxDelay = 12 * time.Hour
+
+// sqsQueue 从 AWS SQS 队列 name 读取发送请求，使用 SQS 的 JSON 协议：
+// 发送完成后删除消息，重试时修改 visibility timeout，到时间后 SQS 重新投递，重试次数为 ApproximateReceiveCount；
+// dead_letter 为放失败请求的另一个队列的名字或完整的 queue url，默认为 <name>-dead
+type sqsQueue struct {
+	cfg      ObjectStorageConfig
+	client   *http.Client
+	url      string
+	dead     string
+	mu       sync.Mutex
+	inflight map[string]bool
+	stop     chan struct{}
+}
+
+// sqsError 为 SQS 返回的错误，例如 AWS.SimpleQueueService.NonExistentQueue
+type sqsError struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+func (e *sqsError) Error() string {
+	if i := strings.LastIndex(e.Type, "#"); i >= 0 {
+		return e.Type[i+1:] + ": " + e.Message
+	}
+	return e.Type + ": " + e.Message
+}
+
+// openSQSQueue 的 url 为 sqs://[access key id:secret@]region/account id，没有配置密钥时从环境变量读取，
+// 环境变量 AWS_ENDPOINT_URL 可以指定其他兼容 SQS 的服务
+func openSQSQueue(c *QueueConfig) (jobQueue, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil || u.Scheme != "sqs" || len(u.Host) == 0 || len(strings.Trim(u.Path, "/")) == 0 {
+		return nil, errors.New(trErr("无效的 SQS 地址，格式为 sqs://[access key id:secret@]region/account id"))
+	}
+	s := ObjectStorageConfig{Region: u.Host}
+	if u.User != nil {
+		s.AccessKeyID = u.User.Username()
+		s.AccessKeySecret, _ = u.User.Password()
+	} else {
+		s.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		s.AccessKeySecret = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		s.SecurityToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	s.Endpoint = strings.TrimSuffix(os.Getenv("AWS_ENDPOINT_URL"), "/")
+	if len(s.Endpoint) == 0 {
+		s.Endpoint = "https://sqs." + s.Region + ".amazonaws.com"
+	}
+
+	account := strings.Trim(u.Path, "/")
+	q := &sqsQueue{
+		cfg:      s,
+		client:   &http.Client{Timeout: time.Minute},
+		url:      s.Endpoint + "/" + account + "/" + c.Name,
+		dead:     c.DeadLetter,
+		inflight: map[string]bool{},
+		stop:     make(chan struct{}),
+	}
+	if len(q.dead) == 0 {
+		q.dead = c.Name + "-dead"
+	}
+	if !strings.Contains(q.dead, "://") {
+		q.dead = s.Endpoint + "/" + account + "/" + q.dead
+	}
+
+	// 检查队列是否存在以及密钥是否正确
+	if err := q.call("GetQueueAttributes", map[string]interface{}{"QueueUrl": q.url, "AttributeNames": []string{"VisibilityTimeout"}}, nil); err != nil {
+		return nil, fmt.Errorf(trErr("打开 SQS 队列失败：%s"), err)
+	}
+	go q.extend()
+	return q, nil
+}
+
+// call 调用 SQS 的一个 action，应答解析到 resp
+func (q *sqsQueue) call(action string, params map[string]interface{}, resp interface{}) error {
+	body, _ := json.Marshal(params)
+	req, err := http.NewRequest("POST", q.cfg.Endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	header := map[string]string{
+		"Content-Type": "application/x-amz-json-1.0",
+		"X-Amz-Target": "AmazonSQS." + action,
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	if len(q.cfg.AccessKeyID) > 0 {
+		signAWSRequest(req, q.cfg, "sqs", "/", sha256Hex(body), header)
+	}
+
+	r, err := q.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if r.StatusCode != http.StatusOK {
+		e := &sqsError{}
+		if json.Unmarshal(data, e) != nil || len(e.Type) == 0 {
+			return fmt.Errorf("HTTP %d: %s", r.StatusCode, strings.TrimSpace(string(data)))
+		}
+		return e
+	}
+	if resp == nil {
+		return nil
+	}
+	return json.Unmarshal(data, resp)
+}
+
+// extend 定期延长正在发送的消息的 visibility timeout，发送时间较长时不会被其他消费者取出
+func (q *sqsQueue) extend() {
+	ticker := time.NewTicker(sqsVisibility / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-ticker.C:
+		}
+		q.mu.Lock()
+		handles := make([]string, 0, len(q.inflight))
+		for handle := range q.inflight {
+			handles = append(handles, handle)
+		}
+		q.mu.Unlock()
+		for _, handle := range handles {
+			if err := q.visibility(handle, sqsVisibility); err != nil {
+				logDebug("延长 SQS 消息的 visibility timeout 失败：%s", err)
+			}
+		}
+	}
+}
+
+func (q *sqsQueue) visibility(handle string, timeout time.Duration) error {
+	return q.call("ChangeMessageVisibility", map[string]interface{}{
+		"QueueUrl":          q.url,
+		"ReceiptHandle":     handle,
+		"VisibilityTimeout": int(timeout / time.Second),
+	}, nil)
+}
+
+// receive 每次 ReceiveMessage 最多取出 10 条消息，第一次使用长轮询等待 wait
+func (q *sqsQueue) receive(n int, wait time.Duration) ([]*queueMessage, error) {
+	messages := []*queueMessage{}
+	for len(messages) < n {
+		max := n - len(messages)
+		if max > 10 {
+			max = 10
+		}
+		seconds := 0
+		if len(messages) == 0 {
+			seconds = int(wait / time.Second)
+		}
+		var resp struct {
+			Messages []struct {
+				ReceiptHandle string            `json:"ReceiptHandle"`
+				Body          string            `json:"Body"`
+				Attributes    map[string]string `json:"Attributes"`
+			} `json:"Messages"`
+		}
+		err := q.call("ReceiveMessage", map[string]interface{}{
+			"QueueUrl":                    q.url,
+			"MaxNumberOfMessages":         max,
+			"WaitTimeSeconds":             seconds,
+			"VisibilityTimeout":           int(sqsVisibility / time.Second),
+			"AttributeNames":              []string{"ApproximateReceiveCount"},
+			"MessageSystemAttributeNames": []string{"ApproximateReceiveCount"},
+		}, &resp)
+		if err != nil {
+			return messages, err
+		}
+		for _, m := range resp.Messages {
+			count, _ := strconv.Atoi(m.Attributes["ApproximateReceiveCount"])
+			if count < 1 {
+				count = 1
+			}
+			messages = append(messages, &queueMessage{Body: []byte(m.Body), Attempts: count - 1, handle: m.ReceiptHandle})
+			q.mu.Lock()
+			q.inflight[m.ReceiptHandle] = true
+			q.mu.Unlock()
+		}
+		if len(resp.Messages) < max {
+			break
+		}
+	}
+	return messages, nil
+}
+
+func (q *sqsQueue) done(m *queueMessage) string {
+	handle := m.handle.(string)
+	q.mu.Lock()
+	delete(q.inflight, handle)
+	q.mu.Unlock()
+	return handle
+}
+
+func (q *sqsQueue) ack(m *queueMessage) error {
+	return q.call("DeleteMessage", map[string]interface{}{"QueueUrl": q.url, "ReceiptHandle": q.done(m)}, nil)
+}
+
+// retry 把消息的 visibility timeout 改为 delay，最长 12 小时
+func (q *sqsQueue) retry(m *queueMessage, delay time.Duration) error {
+	if delay > sqsMaxDelay {
+		delay = sqsMaxDelay
+	}
+	return q.visibility(q.done(m), delay)
+}
+
+func (q *sqsQueue) deadLetter(m *queueMessage) error {
+	if err := q.call("SendMessage", map[string]interface{}{"QueueUrl": q.dead, "MessageBody": string(queueBody(m))}, nil); err != nil {
+		return err
+	}
+	return q.ack(m)
+}
+
+func (q *sqsQueue) Close() error {
+	close(q.stop)
+	return nil
+}
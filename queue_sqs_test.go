@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSQSCallSignature 检查 SQS 请求的签名：X-Amz-Target 一起签名，x-amz-content-sha256 为请求体的哈希
+func TestSQSCallSignature(t *testing.T) {
+	cfg := awsExampleConfig
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		now, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+		if err != nil {
+			t.Errorf("x-amz-date: %v", err)
+		}
+		headers := map[string]string{
+			"host":                 r.Host,
+			"x-amz-content-sha256": r.Header.Get("X-Amz-Content-Sha256"),
+			"x-amz-date":           r.Header.Get("X-Amz-Date"),
+			"x-amz-target":         r.Header.Get("X-Amz-Target"),
+		}
+		if got := headers["x-amz-content-sha256"]; got != sha256Hex(body) {
+			t.Errorf("x-amz-content-sha256 = %s, want %s", got, sha256Hex(body))
+		}
+		want := awsAuthorization(cfg, "sqs", "POST", "/", headers, sha256Hex(body), now)
+		if got := r.Header.Get("Authorization"); got != want {
+			t.Errorf("authorization =\n%s\nwant\n%s", got, want)
+		}
+		if !strings.Contains(want, "SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-target,") {
+			t.Errorf("signed headers: %s", want)
+		}
+
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		if r.Header.Get("X-Amz-Target") == "AmazonSQS.DeleteMessage" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"com.amazonaws.sqs#ReceiptHandleIsInvalid","message":"bad handle"}`))
+			return
+		}
+		w.Write([]byte(`{"Attributes":{"VisibilityTimeout":"30"}}`))
+	}))
+	defer server.Close()
+
+	cfg.Endpoint = server.URL
+	q := &sqsQueue{cfg: cfg, client: server.Client(), url: server.URL + "/000000000000/mail"}
+	var resp struct {
+		Attributes map[string]string
+	}
+	if err := q.call("GetQueueAttributes", map[string]interface{}{"QueueUrl": q.url}, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Attributes["VisibilityTimeout"] != "30" {
+		t.Fatalf("attributes = %v", resp.Attributes)
+	}
+
+	err := q.call("DeleteMessage", map[string]interface{}{"QueueUrl": q.url, "ReceiptHandle": "x"}, nil)
+	if err == nil || err.Error() != "ReceiptHandleIsInvalid: bad handle" {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var addressPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// maskAddress 在 --redact 模式下隐藏地址的用户名部分，例如 alice@example.com 显示为 a***@example.com
+func maskAddress(addr string) string {
+	if !redact {
+		return addr
+	}
+	return addressPattern.ReplaceAllStringFunc(addr, maskMatch)
+}
+
+// redactText 在 --redact 模式下隐藏错误信息等文本中出现的邮件地址，服务器返回的错误通常包含收件人
+func redactText(v interface{}) string {
+	var text string
+	if v != nil {
+		text = fmt.Sprint(v)
+	}
+	if !redact {
+		return text
+	}
+	return addressPattern.ReplaceAllStringFunc(text, maskMatch)
+}
+
+func maskMatch(addr string) string {
+	i := strings.LastIndex(addr, "@")
+	if i <= 0 {
+		return addr
+	}
+	return addr[:1] + "***" + addr[i:]
+}
+
+func maskAddresses(addrs []string) []string {
+	masked := make([]string, len(addrs))
+	for i, addr := range addrs {
+		masked[i] = maskAddress(addr)
+	}
+	return masked
+}
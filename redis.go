@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// redisClient 只实现了 RESP 协议的命令和应答，足够分布式发送和任务队列使用；
+// 地址为 redis://[:密码@]host[:port][/db]，rediss:// 使用 TLS
+type redisClient struct {
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// redisError 为服务器返回的 -ERR 等错误应答
+type redisError string
+
+func (e redisError) Error() string {
+	return string(e)
+}
+
+func dialRedis(rawurl string) (*redisClient, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || len(u.Hostname()) == 0 {
+		return nil, errors.New(trErr("无效的 Redis 地址，格式为 redis://[:密码@]host[:port][/db]"))
+	}
+	port := u.Port()
+	if len(port) == 0 {
+		port = "6379"
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(u.Hostname(), port), 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "rediss" {
+		conn = tls.Client(conn, &tls.Config{ServerName: u.Hostname()})
+	}
+	c := &redisClient{conn: conn, r: bufio.NewReader(conn)}
+
+	if u.User != nil {
+		args := []string{"AUTH"}
+		password, ok := u.User.Password()
+		if user := u.User.Username(); len(user) > 0 && ok {
+			args = append(args, user)
+		} else if !ok {
+			password = u.User.Username()
+		}
+		if _, err := c.do(append(args, password)...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf(trErr("Redis 认证失败：%s"), err)
+		}
+	}
+	if db := strings.Trim(u.Path, "/"); len(db) > 0 && db != "0" {
+		if _, err := c.do("SELECT", db); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// do 发送一个命令并读取应答：简单字符串和批量字符串为 string，整数为 int64，空应答为 nil，数组为 []interface{}
+func (c *redisClient) do(args ...string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return nil, err
+	}
+	return c.read()
+}
+
+func (c *redisClient) read() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) == 0 {
+		return nil, errors.New(trErr("Redis 应答格式错误"))
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			// 数组中的错误应答不影响读取其他元素
+			if items[i], err = c.read(); err != nil {
+				if _, ok := err.(redisError); !ok {
+					return nil, err
+				}
+				items[i] = err
+			}
+		}
+		return items, nil
+	}
+	return nil, errors.New(trErr("Redis 应答格式错误"))
+}
+
+// redisString 返回字符串应答，空应答为 ""
+func redisString(v interface{}, err error) (string, error) {
+	if err != nil || v == nil {
+		return "", err
+	}
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	}
+	return "", errors.New(trErr("Redis 应答格式错误"))
+}
+
+func redisInt(v interface{}, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	if n, ok := v.(int64); ok {
+		return n, nil
+	}
+	s, err := redisString(v, nil)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+func (c *redisClient) Close() error {
+	return c.conn.Close()
+}
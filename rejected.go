@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/csv"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tealeg/xlsx"
+)
+
+const rejectedReasonColumn = "Reason"
+
+// rejectedRows 收集没有发送的行，连同原因写入 --rejected-out 指定的文件，修改后可以直接重新发送
+type rejectedRows struct {
+	mu      sync.Mutex
+	header  []string
+	raw     map[int][]string
+	reasons map[int]string
+}
+
+var rejected *rejectedRows
+
+// source 记录数据文件的表头和原始数据，没有表头时使用固定格式的列名
+func (r *rejectedRows) source(header []string, rows [][]string, offset int) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if header == nil {
+		header = []string{"SendTo", "Subject", "Content"}
+	}
+	r.header = header
+	r.raw = map[int][]string{}
+	for i, row := range rows {
+		r.raw[i+offset] = row
+	}
+}
+
+func (r *rejectedRows) add(row int, reason string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.reasons[row]; !ok {
+		r.reasons[row] = reason
+	}
+}
+
+// save 按行号顺序写入文件，扩展名为 .xlsx 时写 Excel，否则写 CSV
+func (r *rejectedRows) save(file string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rows := []int{}
+	for row := range r.reasons {
+		rows = append(rows, row)
+	}
+	sort.Ints(rows)
+
+	width := len(r.header)
+	for _, row := range rows {
+		if len(r.raw[row]) > width {
+			width = len(r.raw[row])
+		}
+	}
+	records := [][]string{append(pad(r.header, width), rejectedReasonColumn)}
+	for _, row := range rows {
+		records = append(records, append(pad(r.raw[row], width), r.reasons[row]))
+	}
+
+	if strings.ToLower(filepath.Ext(file)) == ".xlsx" {
+		excel := xlsx.NewFile()
+		sheet, err := excel.AddSheet("Sheet1")
+		if err != nil {
+			return err
+		}
+		for _, record := range records {
+			row := sheet.AddRow()
+			for _, val := range record {
+				row.AddCell().SetString(val)
+			}
+		}
+		return excel.Save(file)
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	if err := w.WriteAll(records); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func pad(row []string, width int) []string {
+	padded := make([]string, width)
+	copy(padded, row)
+	return padded
+}
+
+func saveRejectedRows() {
+	if rejected == nil {
+		return
+	}
+	if err := rejected.save(rejectedFile); err != nil {
+		log.Printf(tr("写入没有发送的行失败：%s"), err)
+		return
+	}
+	if len(rejected.reasons) > 0 {
+		log.Printf(tr("%d 行没有发送，已写入 %s"), len(rejected.reasons), rejectedFile)
+	}
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ObjectStorageConfig 为 s3:// 与 oss:// 文件的访问配置，未配置的字段从环境变量读取
+type ObjectStorageConfig struct {
+	Endpoint        string `json:"endpoint"`
+	Region          string `json:"region"`
+	AccessKeyID     string `json:"access_key_id"`
+	AccessKeySecret string `json:"access_key_secret"`
+	SecurityToken   string `json:"security_token"`
+	PathStyle       bool   `json:"path_style"`
+}
+
+var (
+	remoteDir   string
+	remoteFiles = map[string]string{}
+)
+
+func isRemoteFile(name string) bool {
+	for _, scheme := range []string{"s3://", "oss://", "http://", "https://"} {
+		if strings.HasPrefix(name, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchRemoteInputs 下载命令行中指定的远程文件，并把参数替换为本地路径
+func fetchRemoteInputs(cfg *Config, names ...*string) error {
+	for _, name := range names {
+		if !isRemoteFile(*name) {
+			continue
+		}
+		local, err := fetchRemoteFile(cfg, *name)
+		if err != nil {
+			return fmt.Errorf(trErr("下载文件 %s 失败：%s"), *name, err)
+		}
+		*name = local
+	}
+	return nil
+}
+
+// fetchRemoteFile 把远程文件下载到临时目录，保留原文件名以便按扩展名识别格式
+func fetchRemoteFile(cfg *Config, name string) (string, error) {
+	if local, ok := remoteFiles[name]; ok {
+		return local, nil
+	}
+
+	u, err := url.Parse(name)
+	if err != nil {
+		return "", err
+	}
+	checksum, err := parseChecksum(u.Fragment)
+	if err != nil {
+		return "", err
+	}
+	u.Fragment = ""
+
+	var req *http.Request
+	switch u.Scheme {
+	case "s3", "oss":
+		bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+		if len(bucket) == 0 || len(key) == 0 {
+			return "", fmt.Errorf(trErr("无效的地址 %s，格式为 %s://bucket/key"), name, u.Scheme)
+		}
+		if u.Scheme == "s3" {
+			req, err = newS3Request(s3Config(cfg.S3), "GET", bucket, key, nil, nil)
+		} else {
+			req, err = newOSSRequest(ossConfig(cfg.OSS), "GET", bucket, key, nil, nil)
+		}
+	default:
+		req, err = http.NewRequest("GET", u.String(), nil)
+		if err == nil {
+			for k, v := range cfg.HTTPHeaders[u.Hostname()] {
+				req.Header.Set(k, v)
+			}
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	logDebug("下载 %s: %s", name, req.URL)
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	if len(remoteDir) == 0 {
+		if remoteDir, err = ioutil.TempDir("", "email-sender-remote"); err != nil {
+			return "", err
+		}
+	}
+	dir := filepath.Join(remoteDir, strconv.Itoa(len(remoteFiles)))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	base := path.Base(u.Path)
+	if base == "/" || base == "." {
+		base = "index"
+	}
+	local := filepath.Join(dir, base)
+
+	f, err := os.Create(local)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, hash), resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && len(checksum) > 0 && hex.EncodeToString(hash.Sum(nil)) != checksum {
+		err = fmt.Errorf(trErr("SHA-256 校验失败，实际为 %x"), hash.Sum(nil))
+	}
+	if err != nil {
+		os.Remove(local)
+		return "", err
+	}
+
+	remoteFiles[name] = local
+	return local, nil
+}
+
+// parseChecksum 解析地址中 #sha256=<hex> 形式的校验值
+func parseChecksum(fragment string) (string, error) {
+	if len(fragment) == 0 {
+		return "", nil
+	}
+	checksum := strings.TrimPrefix(fragment, "sha256=")
+	if checksum == fragment {
+		return "", fmt.Errorf(trErr("不支持的校验方式 %s，只支持 sha256"), fragment)
+	}
+	if _, err := hex.DecodeString(checksum); err != nil || len(checksum) != sha256.Size*2 {
+		return "", fmt.Errorf(trErr("无效的 SHA-256 校验值 %s"), checksum)
+	}
+	return strings.ToLower(checksum), nil
+}
+
+func removeRemoteFiles() {
+	if len(remoteDir) > 0 {
+		os.RemoveAll(remoteDir)
+	}
+}
+
+func s3Config(c *ObjectStorageConfig) ObjectStorageConfig {
+	var s ObjectStorageConfig
+	if c != nil {
+		s = *c
+	}
+	if len(s.AccessKeyID) == 0 {
+		s.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		s.AccessKeySecret = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		s.SecurityToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if len(s.Region) == 0 {
+		s.Region = os.Getenv("AWS_REGION")
+	}
+	if len(s.Region) == 0 {
+		s.Region = "us-east-1"
+	}
+	if len(s.Endpoint) == 0 {
+		s.Endpoint = os.Getenv("AWS_ENDPOINT_URL")
+	}
+	if len(s.Endpoint) == 0 {
+		s.Endpoint = "s3." + s.Region + ".amazonaws.com"
+	}
+	return s
+}
+
+func ossConfig(c *ObjectStorageConfig) ObjectStorageConfig {
+	var s ObjectStorageConfig
+	if c != nil {
+		s = *c
+	}
+	if len(s.AccessKeyID) == 0 {
+		s.AccessKeyID = os.Getenv("OSS_ACCESS_KEY_ID")
+		s.AccessKeySecret = os.Getenv("OSS_ACCESS_KEY_SECRET")
+		s.SecurityToken = os.Getenv("OSS_SESSION_TOKEN")
+	}
+	if len(s.Endpoint) == 0 {
+		s.Endpoint = os.Getenv("OSS_ENDPOINT")
+	}
+	if len(s.Endpoint) == 0 {
+		s.Endpoint = "oss-cn-hangzhou.aliyuncs.com"
+	}
+	return s
+}
+
+// objectURL 默认使用 bucket.endpoint 形式的地址，path_style 时使用 endpoint/bucket
+func objectURL(c ObjectStorageConfig, bucket, key string) *url.URL {
+	scheme, host := "https", c.Endpoint
+	if i := strings.Index(host, "://"); i >= 0 {
+		scheme, host = host[:i], host[i+3:]
+	}
+	host = strings.TrimSuffix(host, "/")
+
+	u := &url.URL{Scheme: scheme, Host: bucket + "." + host, Path: "/" + key}
+	if c.PathStyle {
+		u.Host, u.Path = host, "/"+bucket+"/"+key
+	}
+	u.RawPath = uriEncodePath(u.Path)
+	return u
+}
+
+// newS3Request 使用 AWS Signature Version 4 签名，header 中的 x-amz-* 头一起签名
+func newS3Request(c ObjectStorageConfig, method, bucket, key string, body []byte, header map[string]string) (*http.Request, error) {
+	u := objectURL(c, bucket, key)
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	if len(c.AccessKeyID) == 0 {
+		return req, nil
+	}
+
+	signAWSRequest(req, c, "s3", u.EscapedPath(), "UNSIGNED-PAYLOAD", header)
+	return req, nil
+}
+
+// signAWSRequest 使用 AWS Signature Version 4 为 service 签名，header 中的 x-amz-* 头一起签名，请求没有 query 参数
+func signAWSRequest(req *http.Request, c ObjectStorageConfig, service, path, payloadHash string, header map[string]string) {
+	now := time.Now().UTC()
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           now.Format("20060102T150405Z"),
+	}
+	if len(c.SecurityToken) > 0 {
+		headers["x-amz-security-token"] = c.SecurityToken
+	}
+	for k, v := range header {
+		if k = strings.ToLower(k); strings.HasPrefix(k, "x-amz-") {
+			headers[k] = v
+		}
+	}
+	for name, v := range headers {
+		if name != "host" {
+			req.Header.Set(name, v)
+		}
+	}
+	req.Header.Set("Authorization", awsAuthorization(c, service, req.Method, path, headers, payloadHash, now))
+}
+
+// awsAuthorization 计算 Signature Version 4 的 Authorization 头，headers 为全部要签名的头，名字为小写
+func awsAuthorization(c ObjectStorageConfig, service, method, path string, headers map[string]string, payloadHash string, now time.Time) string {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		path,
+		"",
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.Region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key4 := []byte("AWS4" + c.AccessKeySecret)
+	for _, part := range []string{date, c.Region, service, "aws4_request"} {
+		key4 = hmacSHA256(key4, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key4, stringToSign))
+
+	return fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.AccessKeyID, scope, signedHeaders, signature)
+}
+
+// newOSSRequest 使用 OSS 的 HMAC-SHA1 签名，header 中的 x-oss-* 头一起签名
+func newOSSRequest(c ObjectStorageConfig, method, bucket, key string, body []byte, header map[string]string) (*http.Request, error) {
+	req, err := http.NewRequest(method, objectURL(c, bucket, key).String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	if len(c.AccessKeyID) == 0 {
+		return req, nil
+	}
+
+	date := time.Now().UTC().Format(http.TimeFormat)
+	req.Header.Set("Date", date)
+
+	ossHeaders := map[string]string{}
+	if len(c.SecurityToken) > 0 {
+		req.Header.Set("x-oss-security-token", c.SecurityToken)
+		ossHeaders["x-oss-security-token"] = c.SecurityToken
+	}
+	for k, v := range header {
+		if k = strings.ToLower(k); strings.HasPrefix(k, "x-oss-") {
+			ossHeaders[k] = v
+		}
+	}
+	names := make([]string, 0, len(ossHeaders))
+	for name := range ossHeaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + ossHeaders[name] + "\n"
+	}
+
+	stringToSign := method + "\n\n" + req.Header.Get("Content-Type") + "\n" + date + "\n" + canonicalHeaders + "/" + bucket + "/" + key
+	mac := hmac.New(sha1.New, []byte(c.AccessKeySecret))
+	mac.Write([]byte(stringToSign))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	req.Header.Set("Authorization", "OSS "+c.AccessKeyID+":"+signature)
+	return req, nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// uriEncodePath 按 RFC 3986 编码路径，只保留非保留字符和 /
+func uriEncodePath(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^\w.@+-]+`)
+
+// renderBody 渲染单个收件人的邮件内容，Content 列不为空时直接使用
+func renderBody(s *Send, contentProvider ContentProvider) (string, []byte, error) {
+	if s.Content != nil {
+		contentType := rowContentType(s, detectContentType([]byte(*s.Content)))
+		if sanitize && contentType == "text/html" {
+			return contentType, sanitizeHTML([]byte(*s.Content)), nil
+		}
+		return contentType, []byte(*s.Content), nil
+	}
+	var buf bytes.Buffer
+	contentType, render := contentProvider(s.Meta)
+	contentType = rowContentType(s, contentType)
+	if err := sanitizeContent(contentType, render)(&buf); err != nil {
+		return "", nil, fmt.Errorf(trErr("渲染邮件模板失败：%s"), err)
+	}
+	return contentType, buf.Bytes(), nil
+}
+
+func renderSendList(dir string, list []*Send, contentProvider ContentProvider) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for _, s := range list {
+		contentType, body, err := renderBody(s, contentProvider)
+		if err != nil {
+			return fmt.Errorf(trErr("第 %d 行 %s：%s"), s.Row, maskAddress(s.SendTo), redactText(err))
+		}
+
+		var buf bytes.Buffer
+		ext := ".txt"
+		if contentType == "text/html" {
+			ext = ".html"
+			fmt.Fprintf(&buf, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n</head>\n<body>\n", html.EscapeString(s.Subject))
+			fmt.Fprintf(&buf, "<div style=\"font-family: monospace; background: #f5f5f5; padding: 8px;\">To: %s<br>Subject: %s</div>\n<hr>\n",
+				html.EscapeString(s.SendTo), html.EscapeString(s.Subject))
+			buf.Write(body)
+			buf.WriteString("\n</body>\n</html>\n")
+		} else {
+			fmt.Fprintf(&buf, "To: %s\nSubject: %s\n\n", s.SendTo, s.Subject)
+			buf.Write(body)
+		}
+
+		name := fmt.Sprintf("%05d-%s%s", s.Row, unsafeFileChars.ReplaceAllString(s.SendTo, "_"), ext)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0644); err != nil {
+			return err
+		}
+		logDebug("已渲染 %s", name)
+	}
+
+	log.Printf(tr("已渲染 %d 封邮件到 %s"), len(list), dir)
+	return nil
+}
+
+// previewMessage 按发送时的方式生成 --row 指定的一行（默认第一行）的完整邮件，写入 w
+func previewMessage(cfg *Config, list []*Send, contentProvider ContentProvider, w io.Writer) error {
+	var s *Send
+	for _, row := range list {
+		if testRow == 0 || row.Row == testRow {
+			s = row
+			break
+		}
+	}
+	if s == nil {
+		return fmt.Errorf(trErr("数据文件中没有第 %d 行"), testRow)
+	}
+	decorators, err := getDecorators(cfg)
+	if err != nil {
+		return err
+	}
+	data, err := renderMessage(cfg, s, contentProvider, decorators)
+	if err != nil {
+		return fmt.Errorf(trErr("第 %d 行 %s：%s"), s.Row, maskAddress(s.SendTo), redactText(err))
+	}
+	_, err = w.Write(data)
+	return err
+}
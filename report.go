@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	statusSent         = "sent"
+	statusFailed       = "failed"
+	statusAlreadySent  = "already_sent"
+	statusDisposable   = "disposable"
+	statusDuplicate    = "duplicate"
+	statusHookRejected = "hook_rejected"
+	statusTooLarge     = "too_large"
+)
+
+type Result struct {
+	CampaignID string    `json:"campaign_id,omitempty"`
+	Row        int       `json:"row"`
+	SendTo     string    `json:"send_to,omitempty"`
+	SendToHash string    `json:"send_to_hash,omitempty"`
+	Subject    string    `json:"subject,omitempty"`
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	Seed       bool      `json:"seed,omitempty"`
+	MessageID  string    `json:"message_id,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// Reporter 统计发送结果，指定了报告文件时每个收件人写入一行 JSON
+// 配置了 report_salt 时报告中的收件人地址换成加盐的哈希
+type Reporter struct {
+	name string
+	file *os.File
+	enc  *json.Encoder
+	salt string
+
+	Sent    int
+	Failed  int
+	Skipped int
+}
+
+func newReporter(file, salt string) (*Reporter, error) {
+	r := &Reporter{name: file, salt: salt}
+	if len(file) == 0 {
+		return r, nil
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+	r.file = f
+	r.enc = json.NewEncoder(f)
+	return r, nil
+}
+
+// reopen 返回追加写入同一个报告文件的 Reporter，用于重试失败的收件人，统计从零开始；
+// 报告中同一个收件人后面的结果为准，--skip-already-sent 读取报告时可以跳过重试成功的收件人
+func (r *Reporter) reopen() (*Reporter, error) {
+	n := &Reporter{name: r.name, salt: r.salt}
+	if len(r.name) == 0 {
+		return n, nil
+	}
+	f, err := os.OpenFile(r.name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	n.file = f
+	n.enc = json.NewEncoder(f)
+	return n, nil
+}
+
+func (r *Reporter) Add(campaignID string, s *Send, err error) Result {
+	result := Result{
+		CampaignID: campaignID,
+		Row:        s.Row,
+		SendTo:     s.SendTo,
+		Subject:    s.Subject,
+		Status:     statusSent,
+		Seed:       s.Seed,
+		Time:       time.Now(),
+	}
+	if err != nil {
+		result.Status = statusFailed
+		result.Error = err.Error()
+		r.Failed++
+	} else {
+		result.MessageID = s.MessageID
+		r.Sent++
+	}
+
+	r.write(&result)
+	return result
+}
+
+// Skip 记录没有发送的收件人，status 说明原因
+func (r *Reporter) Skip(campaignID string, s *Send, status, reason string) Result {
+	result := Result{
+		CampaignID: campaignID,
+		Row:        s.Row,
+		SendTo:     s.SendTo,
+		Subject:    s.Subject,
+		Status:     status,
+		Error:      reason,
+		Seed:       s.Seed,
+		Time:       time.Now(),
+	}
+	r.Skipped++
+	// 已经发送过的和重复的收件人不需要重新发送
+	if status != statusAlreadySent && status != statusDuplicate {
+		rejected.add(s.Row, reason)
+	}
+	r.write(&result)
+	return result
+}
+
+func (r *Reporter) write(result *Result) {
+	metrics.record(result)
+	if r.enc != nil {
+		if len(r.salt) > 0 {
+			hashed := hashResult(r.salt, *result)
+			result = &hashed
+		}
+		if err := r.enc.Encode(result); err != nil {
+			logDebug("写入报告失败：%s", err)
+		}
+	}
+}
+
+func (r *Reporter) Close() error {
+	if r.file != nil {
+		return r.file.Close()
+	}
+	return nil
+}
+
+// sentHistory 记录之前的报告中已经发送成功的收件人，按 campaign ID 区分
+var sentHistory = map[string]bool{}
+
+func sentHistoryKey(campaignID, addr string) string {
+	return campaignID + "\x00" + dedupeKey(normalizeAddress(addr))
+}
+
+// sentHistoryHashKey 用于地址已哈希的报告
+func sentHistoryHashKey(campaignID, hash string) string {
+	return campaignID + "\x00#" + hash
+}
+
+// sentBefore 判断收件人是否在之前的报告中发送成功，salt 用于匹配地址已哈希的报告
+func sentBefore(campaignID, addr, salt string) bool {
+	if sentHistory[sentHistoryKey(campaignID, addr)] {
+		return true
+	}
+	return len(salt) > 0 && sentHistory[sentHistoryHashKey(campaignID, addressHash(salt, addr))]
+}
+
+// loadSentHistory 读取之前的报告文件，状态为 sent 或 already_sent 的收件人视为已发送
+func loadSentHistory(files []string) error {
+	for _, file := range files {
+		err := readReport(file, func(result Result) {
+			if result.Status == statusSent || result.Status == statusAlreadySent {
+				if len(result.SendToHash) > 0 {
+					sentHistory[sentHistoryHashKey(result.CampaignID, result.SendToHash)] = true
+				} else {
+					sentHistory[sentHistoryKey(result.CampaignID, result.SendTo)] = true
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readReport 按顺序读取报告文件中的每一条结果
+func readReport(file string, fn func(result Result)) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var result Result
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			return fmt.Errorf(trErr("%s 第 %d 行：%s"), file, line, err)
+		}
+		fn(result)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	logDebug("从 %s 读取了 %d 行发送记录", file, line)
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/mail"
+	"strings"
+)
+
+// addressHash 返回报告中代替收件人地址的哈希：HMAC-SHA256(salt, 小写的地址)，
+// 域名为国际化域名时先转换为 punycode，持有 salt 的一方可以用同样的方法计算后关联数据
+func addressHash(salt, addr string) string {
+	addr = strings.TrimSpace(addr)
+	if a, err := mail.ParseAddress(addr); err == nil {
+		addr = a.Address
+	}
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(strings.ToLower(asciiDomainAddress(addr))))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// hashResult 去掉报告中的个人信息：收件人地址换成哈希，错误信息中的地址也换成哈希，
+// 标题可能包含收件人的姓名等字段，不写入报告
+func hashResult(salt string, result Result) Result {
+	result.SendToHash = addressHash(salt, result.SendTo)
+	result.SendTo = ""
+	result.Subject = ""
+	result.Error = addressPattern.ReplaceAllStringFunc(result.Error, func(addr string) string {
+		return addressHash(salt, addr)
+	})
+	return result
+}
+
+// resultAddress 返回报告中的收件人，地址已哈希时显示哈希的前几位
+func resultAddress(result Result) string {
+	if hash := result.SendToHash; len(hash) > 0 {
+		if len(hash) > 12 {
+			hash = hash[:12]
+		}
+		return "#" + hash
+	}
+	return maskAddress(result.SendTo)
+}
+
+// matchResult 判断收件人是否为报告中的收件人，报告中的地址已哈希时需要用同一个 salt 比较
+func matchResult(s *Send, result Result, salt string) bool {
+	if len(result.SendToHash) > 0 {
+		return len(salt) > 0 && addressHash(salt, s.SendTo) == result.SendToHash
+	}
+	return dedupeKey(s.SendTo) == dedupeKey(normalizeAddress(result.SendTo))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// loadFailures 读取报告中最后一次结果为 failed 的收件人，重试后成功的不再算作失败
+func loadFailures(file string) ([]Result, error) {
+	type key struct {
+		row  int
+		addr string
+	}
+	last := map[key]Result{}
+	order := []key{}
+	err := readReport(file, func(result Result) {
+		if result.Seed {
+			return
+		}
+		k := key{result.Row, dedupeKey(normalizeAddress(result.SendTo))}
+		if len(result.SendToHash) > 0 {
+			k.addr = "#" + result.SendToHash
+		}
+		if _, ok := last[k]; !ok {
+			order = append(order, k)
+		}
+		last[k] = result
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	failures := []Result{}
+	for _, k := range order {
+		if last[k].Status == statusFailed {
+			failures = append(failures, last[k])
+		}
+	}
+	return failures, nil
+}
+
+// selectFailedRows 从数据文件中找出报告里失败的行
+func selectFailedRows(list []*Send, failures []Result, salt string) ([]*Send, error) {
+	remaining, err := matchReportRows(list, failures, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(remaining) == 0 {
+		return nil, fmt.Errorf(trErr("数据文件中没有找到报告里失败的收件人"))
+	}
+	log.Printf(tr("报告中有 %d 个失败的收件人，重新发送其中 %d 个"), len(failures), len(remaining))
+	return remaining, nil
+}
+
+// matchReportRows 从数据文件中找出报告结果对应的行，按行号和收件人匹配；
+// 数据文件修改过导致行号变化时按收件人匹配，报告中的地址已哈希时用 salt 计算哈希后匹配
+func matchReportRows(list []*Send, results []Result, salt string) ([]*Send, error) {
+	byRow := map[int]*Send{}
+	for _, s := range list {
+		byRow[s.Row] = s
+	}
+
+	selected := map[*Send]bool{}
+	unmatched := []Result{}
+	for _, f := range results {
+		if len(f.SendToHash) > 0 && len(salt) == 0 {
+			return nil, fmt.Errorf(trErr("报告中的收件人地址已哈希，需要在配置中指定生成报告时使用的 report_salt"))
+		}
+		if s, ok := byRow[f.Row]; ok && matchResult(s, f, salt) {
+			selected[s] = true
+		} else {
+			unmatched = append(unmatched, f)
+		}
+	}
+	for _, f := range unmatched {
+		found := false
+		for _, s := range list {
+			if !selected[s] && matchResult(s, f, salt) {
+				selected[s] = true
+				found = true
+				log.Printf(tr("第 %d 行 %s 在数据文件中移到了第 %d 行"), f.Row, resultAddress(f), s.Row)
+				break
+			}
+		}
+		if !found {
+			log.Printf(tr("数据文件中找不到第 %d 行 %s，跳过"), f.Row, resultAddress(f))
+		}
+	}
+
+	remaining := []*Send{}
+	for _, s := range list {
+		if selected[s] {
+			remaining = append(remaining, s)
+		}
+	}
+	return remaining, nil
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const runTimeLayout = "2006-01-02 15:04"
+
+// RunFile 定义一次执行的多个发送任务
+type RunFile struct {
+	Parallel  bool        `json:"parallel"`
+	Campaigns []*RunEntry `json:"campaigns"`
+}
+
+type RunEntry struct {
+	Name     string `json:"name"`
+	Data     string `json:"data"`
+	Config   string `json:"config"`
+	Content  string `json:"content"`
+	Template string `json:"template"`
+	Subject  string `json:"subject"`
+	Report   string `json:"report"`
+	StartAt  string `json:"start_at"`
+	// CampaignID 未指定时使用 --campaign-id
+	CampaignID string `json:"campaign_id"`
+
+	cfg             *Config
+	contentProvider ContentProvider
+	opts            listOptions
+	start           time.Time
+}
+
+// listOptions 为 run 的任务文件中每个任务自己的标题和任务标识，为 nil 时使用 --subject 和 --campaign-id
+type listOptions struct {
+	subject    renderer
+	campaignID string
+}
+
+func (o *listOptions) id() string {
+	if o == nil {
+		return campaignID
+	}
+	return o.campaignID
+}
+
+// applySubject 用任务的 subject 代替每一行的 Subject，任务没有指定时与 --subject 相同
+func (o *listOptions) applySubject(s *Send) error {
+	if o == nil || o.subject == nil {
+		return applyDefaultSubject(s)
+	}
+	var buf bytes.Buffer
+	if err := o.subject(&buf, s.Meta); err != nil {
+		return fmt.Errorf(trErr("渲染标题失败：%s"), err)
+	}
+	s.Subject = buf.String()
+	if len(s.Subject) == 0 {
+		return errors.New(trErr("标题不能为空"))
+	}
+	return nil
+}
+
+// runCampaigns 先加载并检查所有任务，全部通过后再按顺序或并行发送，返回是否全部成功
+func runCampaigns(defaultCfg *Config, file string) bool {
+	data, err := readFileContent(file)
+	if err != nil {
+		log.Printf(tr("读取任务文件失败：%s"), err)
+		return false
+	}
+	var run RunFile
+	if err := json.Unmarshal(data, &run); err != nil {
+		log.Printf(tr("解析任务文件失败：%s"), err)
+		return false
+	}
+	if len(run.Campaigns) == 0 {
+		log.Printf(tr("任务文件 %s 中没有任务"), file)
+		return false
+	}
+
+	dir := filepath.Dir(file)
+	ok := true
+	for i, entry := range run.Campaigns {
+		if len(entry.Name) == 0 {
+			entry.Name = fmt.Sprintf("#%d %s", i+1, entry.Data)
+		}
+		if err := entry.load(defaultCfg, dir); err != nil {
+			log.Printf("%s: %s", entry.Name, err)
+			ok = false
+			continue
+		}
+		var problems bytes.Buffer
+		if !validate(entry.cfg, entry.Data, entry.Content, entry.Template, entry.contentProvider, &entry.opts, &problems) {
+			log.Printf(tr("%s 检查不通过：\n%s"), entry.Name, problems.String())
+			ok = false
+		}
+	}
+	if !ok {
+		return false
+	}
+
+	if len(httpAddr) > 0 {
+		go serveDashboard(httpAddr)
+	}
+
+	results := make([]bool, len(run.Campaigns))
+	if run.Parallel {
+		var wg sync.WaitGroup
+		for i, entry := range run.Campaigns {
+			wg.Add(1)
+			go func(i int, entry *RunEntry) {
+				defer wg.Done()
+				results[i] = entry.run()
+			}(i, entry)
+		}
+		wg.Wait()
+	} else {
+		for i, entry := range run.Campaigns {
+			results[i] = entry.run()
+		}
+	}
+
+	for _, result := range results {
+		ok = ok && result
+	}
+	return ok
+}
+
+// load 处理相对路径、下载远程文件并准备配置和邮件内容，相对路径相对于任务文件所在目录
+func (e *RunEntry) load(defaultCfg *Config, dir string) error {
+	if len(e.Data) == 0 {
+		return fmt.Errorf(trErr("没有指定数据文件"))
+	}
+	if len(e.CampaignID) == 0 {
+		e.CampaignID = campaignID
+	}
+	e.opts.campaignID = e.CampaignID
+	if len(e.Content) == 0 && len(e.Template) == 0 {
+		e.Content, e.Template = content, template
+	} else {
+		e.Content, e.Template = runPath(dir, e.Content), runPath(dir, e.Template)
+	}
+	e.Data, e.Config = runPath(dir, e.Data), runPath(dir, e.Config)
+	if len(e.Report) == 0 {
+		// 默认报告放在任务文件所在目录，以数据文件命名
+		base := strings.SplitN(filepath.Base(e.Data), "#", 2)[0]
+		e.Report = strings.TrimSuffix(base, filepath.Ext(base)) + ".report.jsonl"
+	}
+	e.Report = runPath(dir, e.Report)
+
+	e.cfg = defaultCfg
+	if len(e.Config) > 0 {
+		cfg, err := loadConfig(e.Config)
+		if err != nil {
+			return fmt.Errorf(trErr("读取配置文件失败：%s"), err)
+		}
+		e.cfg = cfg
+	}
+
+	if err := fetchRemoteInputs(e.cfg, &e.Data, &e.Content, &e.Template); err != nil {
+		return err
+	}
+
+	contentProvider, err := getContentProvider(e.cfg, e.Content, e.Template)
+	if err != nil {
+		return err
+	}
+	e.contentProvider = contentProvider
+
+	if len(e.Subject) > 0 {
+		if e.opts.subject, err = compileTemplate("subject", "", e.Subject, false); err != nil {
+			return fmt.Errorf(trErr("解析标题失败：%s"), err)
+		}
+	}
+
+	if len(e.StartAt) > 0 {
+		if e.start, err = time.ParseInLocation(runTimeLayout, e.StartAt, time.Local); err != nil {
+			return fmt.Errorf(trErr("无效的开始时间 %s，格式为 %s"), e.StartAt, runTimeLayout)
+		}
+	}
+	return nil
+}
+
+func (e *RunEntry) run() bool {
+	err := func() error {
+		list, contentProvider, err := prepareSendList(e.cfg, e.Data, e.Content, e.Template, e.contentProvider, &e.opts)
+		if err != nil {
+			return err
+		}
+		decorators, err := getDecorators(e.cfg)
+		if err != nil {
+			return err
+		}
+		reporter, err := newReporter(e.Report, e.cfg.ReportSalt)
+		if err != nil {
+			return fmt.Errorf(trErr("创建报告文件失败：%s"), err)
+		}
+		defer reporter.Close()
+
+		campaign := newCampaign(e.Name, list, reporter)
+		campaign.CampaignID = e.CampaignID
+		campaign.retry = retryFailures(e.cfg, contentProvider, decorators)
+
+		// 等待期间可以在监控页面或用 campaign cancel 取消
+		if wait := time.Until(e.start); wait > 0 {
+			log.Printf(tr("%s 将于 %s 开始"), e.Name, e.start.Format(runTimeLayout))
+			if !campaign.sleep(wait) {
+				campaign.finish(nil)
+				campaign.logf("%s 已取消", e.Name)
+				return nil
+			}
+		}
+
+		log.Printf(tr("开始任务 %s"), e.Name)
+		if err := sendEmails(e.cfg, list, contentProvider, decorators, campaign); err != nil {
+			return err
+		}
+		campaign.logf("%s 发送完成，成功 %d 封，失败 %d 封", e.Name, reporter.Sent, reporter.Failed)
+		return nil
+	}()
+	if err != nil {
+		log.Printf(tr("%s 失败：%s"), e.Name, err)
+		return false
+	}
+	return true
+}
+
+func runPath(dir, name string) string {
+	if len(name) == 0 || isRemoteFile(name) || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(dir, name)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// 连同内容一起删除的元素
+var dangerousTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Noscript: true, atom.Iframe: true, atom.Frame: true, atom.Frameset: true,
+	atom.Object: true, atom.Embed: true, atom.Applet: true, atom.Base: true,
+}
+
+// 值为链接的属性
+var urlAttrs = map[string]bool{
+	"href": true, "src": true, "action": true, "formaction": true, "background": true,
+	"poster": true, "lowsrc": true, "dynsrc": true, "xlink:href": true, "cite": true,
+}
+
+// sanitizeHTML 删除脚本等危险元素、事件属性和 javascript: 之类的链接
+func sanitizeHTML(body []byte) []byte {
+	var buf bytes.Buffer
+	skip := atom.Atom(0)
+	depth := 0
+
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		raw := z.Raw()
+		t := z.Token()
+		if skip != 0 {
+			if t.DataAtom == skip {
+				switch tt {
+				case html.StartTagToken:
+					depth++
+				case html.EndTagToken:
+					if depth--; depth == 0 {
+						skip = 0
+					}
+				}
+			}
+			continue
+		}
+
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if dangerousTags[t.DataAtom] || t.DataAtom == atom.Meta && hasAttr(t, "http-equiv") {
+				if tt == html.StartTagToken && t.DataAtom != atom.Base && t.DataAtom != atom.Meta && t.DataAtom != atom.Embed {
+					skip, depth = t.DataAtom, 1
+				}
+				continue
+			}
+			attrs := t.Attr[:0]
+			for _, attr := range t.Attr {
+				if safeAttr(attr) {
+					attrs = append(attrs, attr)
+				}
+			}
+			t.Attr = attrs
+			buf.WriteString(t.String())
+		case html.EndTagToken:
+			if dangerousTags[t.DataAtom] {
+				continue
+			}
+			buf.Write(raw)
+		default:
+			buf.Write(raw)
+		}
+	}
+	return buf.Bytes()
+}
+
+func hasAttr(t html.Token, key string) bool {
+	for _, attr := range t.Attr {
+		if attr.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func safeAttr(attr html.Attribute) bool {
+	key := strings.ToLower(attr.Key)
+	if strings.HasPrefix(key, "on") {
+		return false
+	}
+	if key == "style" {
+		val := strings.ToLower(attr.Val)
+		return !strings.Contains(val, "expression(") && !strings.Contains(val, "javascript:") && !strings.Contains(val, "behavior:")
+	}
+	if !urlAttrs[key] {
+		return true
+	}
+	// 去掉空白和控制字符，防止 java\tscript: 这种写法
+	val := strings.ToLower(strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, attr.Val))
+	switch {
+	case strings.HasPrefix(val, "javascript:"), strings.HasPrefix(val, "vbscript:"):
+		return false
+	case strings.HasPrefix(val, "data:"):
+		return key == "src" && strings.HasPrefix(val, "data:image/") && !strings.HasPrefix(val, "data:image/svg")
+	}
+	return true
+}
+
+// sanitizeContent 开启 --sanitize 时清理 html 邮件内容
+func sanitizeContent(contentType string, render func(io.Writer) error) func(io.Writer) error {
+	if !sanitize || contentType != "text/html" {
+		return render
+	}
+	return func(w io.Writer) error {
+		var buf bytes.Buffer
+		if err := render(&buf); err != nil {
+			return err
+		}
+		_, err := w.Write(sanitizeHTML(buf.Bytes()))
+		return err
+	}
+}
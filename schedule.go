@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	// Windows 上没有系统时区数据
+	_ "time/tzdata"
+
+	"github.com/tealeg/xlsx"
+)
+
+const (
+	sendAtColumn   = "SendAt"
+	timezoneColumn = "Timezone"
+)
+
+var sendAtLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"2006-01-02",
+	"2006/01/02",
+}
+
+var locations = struct {
+	sync.Mutex
+	m map[string]*time.Location
+}{m: map[string]*time.Location{}}
+
+// scheduleSend 根据 SendAt、Timezone 列和 --send-local-time 计算发送时间：
+// SendAt 包含时间时按 Timezone 的时区解析；只有日期时在当天的 --send-local-time 发送；
+// 没有 SendAt 时在收件人时区下一个 --send-local-time 发送
+func scheduleSend(s *Send) error {
+	loc := time.Local
+	if tz := strings.TrimSpace(s.Meta[timezoneColumn]); len(tz) > 0 {
+		var err error
+		if loc, err = loadLocation(tz); err != nil {
+			return err
+		}
+	}
+
+	if val := strings.TrimSpace(s.Meta[sendAtColumn]); len(val) > 0 {
+		t, hasTime, err := parseSendAt(val, loc)
+		if err != nil {
+			return err
+		}
+		if !hasTime && len(sendLocalTime) > 0 {
+			t = atLocalTime(t, sendLocalTime)
+		}
+		s.SendAt = t
+		return nil
+	}
+
+	if len(sendLocalTime) > 0 {
+		now := time.Now().In(loc)
+		t := atLocalTime(now, sendLocalTime)
+		if t.Before(now) {
+			t = atLocalTime(now.AddDate(0, 0, 1), sendLocalTime)
+		}
+		s.SendAt = t
+	}
+	return nil
+}
+
+// atLocalTime 返回 t 所在日期的 clock 时间（15:04），使用 t 的时区
+func atLocalTime(t time.Time, clock string) time.Time {
+	c, _ := time.Parse("15:04", clock)
+	return time.Date(t.Year(), t.Month(), t.Day(), c.Hour(), c.Minute(), 0, 0, t.Location())
+}
+
+// loadLocation 支持 IANA 时区名称（Asia/Shanghai）和 UTC 偏移（+08:00、UTC+8）
+func loadLocation(tz string) (*time.Location, error) {
+	locations.Lock()
+	defer locations.Unlock()
+	if loc, ok := locations.m[tz]; ok {
+		return loc, nil
+	}
+
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		offset := strings.TrimPrefix(strings.TrimPrefix(strings.ToUpper(tz), "UTC"), "GMT")
+		if t, perr := parseUTCOffset(offset); perr == nil {
+			loc, err = time.FixedZone(tz, t), nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf(trErr("无效的时区: %s"), tz)
+	}
+	locations.m[tz] = loc
+	return loc, nil
+}
+
+func parseUTCOffset(s string) (int, error) {
+	if len(s) < 2 || (s[0] != '+' && s[0] != '-') {
+		return 0, fmt.Errorf(trErr("无效的时区偏移: %s"), s)
+	}
+	sign := 1
+	if s[0] == '-' {
+		sign = -1
+	}
+	hours, minutes := s[1:], "0"
+	if i := strings.Index(hours, ":"); i >= 0 {
+		hours, minutes = hours[:i], hours[i+1:]
+	} else if len(hours) == 4 {
+		hours, minutes = hours[:2], hours[2:]
+	}
+	h, err := strconv.Atoi(hours)
+	if err != nil || h > 14 {
+		return 0, fmt.Errorf(trErr("无效的时区偏移: %s"), s)
+	}
+	m, err := strconv.Atoi(minutes)
+	if err != nil || m >= 60 {
+		return 0, fmt.Errorf(trErr("无效的时区偏移: %s"), s)
+	}
+	return sign * (h*3600 + m*60), nil
+}
+
+// parseSendAt 解析 SendAt 列，支持常见的日期时间格式、RFC 3339 和 Excel 日期单元格的数值，hasTime 表示是否包含时间
+func parseSendAt(val string, loc *time.Location) (t time.Time, hasTime bool, err error) {
+	if t, err := time.Parse(time.RFC3339, val); err == nil {
+		return t, true, nil
+	}
+	for _, layout := range sendAtLayouts {
+		if t, err := time.ParseInLocation(layout, val, loc); err == nil {
+			return t, strings.Contains(layout, "15"), nil
+		}
+	}
+	if f, err := strconv.ParseFloat(val, 64); err == nil && f > 0 {
+		t := xlsx.TimeFromExcelTime(f, false)
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc), f != float64(int64(f)), nil
+	}
+	return time.Time{}, false, fmt.Errorf(trErr("无效的发送时间: %s"), val)
+}
+
+// sortBySendAt 按发送时间排序，没有指定时间的排在最前面，相同时间保持原来的顺序
+func sortBySendAt(list []*Send) {
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].SendAt.Before(list[j].SendAt)
+	})
+}
+
+// waitSendAt 等到邮件的发送时间，任务被取消时返回 false
+func waitSendAt(campaign *Campaign, s *Send) bool {
+	wait := time.Until(s.SendAt)
+	if wait <= 0 {
+		return true
+	}
+	campaign.logf("%s 等待到 %s 发送", maskAddress(s.SendTo), s.SendAt.Format("2006-01-02 15:04:05 -07:00"))
+	campaign.waiting(s)
+	return campaign.sleep(wait)
+}
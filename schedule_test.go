@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestParseRowsScheduleDefaults 检查 defaults 中的 SendAt、Timezone 也用于计算发送时间
+func TestParseRowsScheduleDefaults(t *testing.T) {
+	sendAt := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Minute)
+	cfg := &Config{Defaults: map[string]string{
+		sendAtColumn:   sendAt.Format("2006-01-02 15:04"),
+		timezoneColumn: "UTC",
+	}}
+	rows := [][]string{
+		{"SendTo", "Subject", "SendAt"},
+		{"a@example.com", "hi", ""},
+		{"b@example.com", "hi", "2030-01-02 08:00"},
+		{"c@example.com", "hi", "tomorrow"},
+	}
+	list, rowErrors, err := parseRows(cfg, rows, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("%d rows, want 2", len(list))
+	}
+	if !list[0].SendAt.Equal(sendAt) {
+		t.Fatalf("SendAt = %v, want %v from defaults", list[0].SendAt, sendAt)
+	}
+	if want := time.Date(2030, 1, 2, 8, 0, 0, 0, time.UTC); !list[1].SendAt.Equal(want) {
+		t.Fatalf("SendAt = %v, want %v", list[1].SendAt, want)
+	}
+	if len(rowErrors) != 1 || rowErrors[0].Row != 4 {
+		t.Fatalf("row errors = %v, want row 4", rowErrors)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxSchemaErrors = 20
+
+var columnTypes = map[string]func(val string) bool{
+	"int": func(val string) bool {
+		_, err := strconv.ParseInt(val, 10, 64)
+		return err == nil
+	},
+	"number": func(val string) bool {
+		_, err := strconv.ParseFloat(strings.Replace(val, ",", "", -1), 64)
+		return err == nil
+	},
+	"date": func(val string) bool {
+		_, _, err := parseSendAt(val, time.Local)
+		return err == nil
+	},
+	"email": func(val string) bool {
+		return validEmailAddress(val)
+	},
+	"url": func(val string) bool {
+		u, err := url.Parse(val)
+		return err == nil && (u.Scheme == "http" || u.Scheme == "https") && len(u.Host) > 0
+	},
+}
+
+var columnTypeNames = map[string]string{
+	"int": "整数", "number": "数字", "date": "日期", "email": "邮箱地址", "url": "网址",
+}
+
+func checkSchemaConfig(cfg *Config) error {
+	for column, typ := range cfg.ColumnTypes {
+		if _, ok := columnTypes[typ]; !ok {
+			return fmt.Errorf(trErr("列 %s 的类型 %s 无效，只支持 int, number, date, email, url"), column, typ)
+		}
+	}
+	return nil
+}
+
+func columnValue(s *Send, column string) string {
+	switch column {
+	case "SendTo":
+		return s.SendTo
+	case "Subject":
+		return s.Subject
+	case "Content":
+		if s.Content != nil {
+			return *s.Content
+		}
+		return ""
+	}
+	return s.Meta[column]
+}
+
+// applyDefaults 为空的列使用 defaults 中的默认值
+func applyDefaults(cfg *Config, s *Send) {
+	for column, val := range cfg.Defaults {
+		if len(s.Meta[column]) > 0 {
+			continue
+		}
+		if s.Meta == nil {
+			s.Meta = map[string]string{}
+		}
+		s.Meta[column] = val
+	}
+}
+
+// checkSchema 检查 require 中的列是否为空以及 column_types 中的列格式是否正确
+func checkSchema(cfg *Config, s *Send) []string {
+	problems := []string{}
+	empty := []string{}
+	for _, column := range cfg.Require {
+		if len(strings.TrimSpace(columnValue(s, column))) == 0 {
+			empty = append(empty, column)
+		}
+	}
+	if len(empty) > 0 {
+		problems = append(problems, fmt.Sprintf(trErr("%s 不能为空"), strings.Join(empty, ", ")))
+	}
+
+	columns := []string{}
+	for column := range cfg.ColumnTypes {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		typ := cfg.ColumnTypes[column]
+		val := strings.TrimSpace(columnValue(s, column))
+		if len(val) > 0 && !columnTypes[typ](val) {
+			problems = append(problems, fmt.Sprintf(trErr("%s 不是有效的%s: %s"), column, trErr(columnTypeNames[typ]), val))
+		}
+	}
+	return problems
+}
+
+// enforceSchema 发送前检查所有行，有问题时列出所有有问题的行并返回错误，开启 --skip-bad-rows 时跳过这些行
+func enforceSchema(cfg *Config, list []*Send) ([]*Send, error) {
+	if len(cfg.Require) == 0 && len(cfg.ColumnTypes) == 0 {
+		return list, nil
+	}
+
+	remaining := []*Send{}
+	lines := []string{}
+	for _, s := range list {
+		problems := checkSchema(cfg, s)
+		if len(problems) == 0 {
+			remaining = append(remaining, s)
+			continue
+		}
+		reason := strings.Join(problems, trErr("；"))
+		rejected.add(s.Row, reason)
+		lines = append(lines, fmt.Sprintf(trErr("第 %d 行 %s：%s"), s.Row, maskAddress(s.SendTo), redactText(reason)))
+	}
+	if len(lines) == 0 {
+		return list, nil
+	}
+
+	if skipBadRows {
+		for _, line := range lines {
+			log.Printf(tr("跳过：%s"), line)
+		}
+		return remaining, nil
+	}
+	if len(lines) > maxSchemaErrors {
+		lines = append(lines[:maxSchemaErrors], fmt.Sprintf(trErr("……共 %d 行有问题"), len(lines)))
+	}
+	return nil, errors.New(trErr("数据检查不通过：\n") + strings.Join(lines, "\n"))
+}
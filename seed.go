@@ -0,0 +1,34 @@
+package main
+
+// SeedConfig 为收件箱监控地址，发送过程中每 Every 封邮件插入一次，Every 为 0 时只在开始时插入一次
+type SeedConfig struct {
+	Addresses []string `json:"addresses"`
+	Every     int      `json:"every"`
+}
+
+// insertSeeds 在第一封以及之后每 Every 封邮件后面插入所有监控地址，内容与前一封相同，报告中 seed 为 true
+func (c *Campaign) insertSeeds(seeds *SeedConfig, list []*Send) []*Send {
+	if seeds == nil || len(seeds.Addresses) == 0 || len(list) == 0 || c.retrying {
+		return list
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := []*Send{}
+	inserted := 0
+	for i, s := range list {
+		result = append(result, s)
+		if i == 0 || seeds.Every > 0 && i%seeds.Every == 0 {
+			for _, addr := range seeds.Addresses {
+				seed := *s
+				seed.SendTo = normalizeAddress(addr)
+				seed.Seed = true
+				result = append(result, &seed)
+				inserted++
+			}
+		}
+	}
+	c.Total += inserted
+	c.logf("%s 插入 %d 封监控邮件", c.Name, inserted)
+	return result
+}
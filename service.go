@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const (
+	serviceName        = "email-sender"
+	serviceDescription = "批量邮件发送助手，监视目录并自动发送邮件"
+)
+
+// serviceCommand 返回服务启动时执行的程序和参数，工作目录固定为安装时的当前目录
+func serviceCommand(args []string) (string, []string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", nil, err
+	}
+	exe, err = filepath.Abs(exe)
+	if err != nil {
+		return "", nil, err
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", nil, err
+	}
+	return exe, append([]string{"service", "run", "--workdir", dir}, args...), nil
+}
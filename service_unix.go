@@ -0,0 +1,113 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+const systemdUnit = "/etc/systemd/system/" + serviceName + ".service"
+
+func installService(args []string) error {
+	exe, serviceArgs, err := serviceCommand(args)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(systemdUnit); err == nil {
+		return fmt.Errorf(trErr("%s 已存在"), systemdUnit)
+	}
+
+	command := []string{systemdQuote(exe)}
+	for _, arg := range serviceArgs {
+		command = append(command, systemdQuote(arg))
+	}
+
+	unit := fmt.Sprintf(`[Unit]
+Description=%s
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+ExecStart=%s
+Restart=on-failure
+RestartSec=10
+KillSignal=SIGTERM
+TimeoutStopSec=120
+
+[Install]
+WantedBy=multi-user.target
+`, serviceDescription, strings.Join(command, " "))
+
+	if err := ioutil.WriteFile(systemdUnit, []byte(unit), 0644); err != nil {
+		return err
+	}
+	logDebug("已写入 %s", systemdUnit)
+
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return systemctl("enable", "--now", serviceName)
+}
+
+func uninstallService() error {
+	if _, err := os.Stat(systemdUnit); err != nil {
+		return fmt.Errorf(trErr("服务 %s 未安装"), serviceName)
+	}
+	if err := systemctl("disable", "--now", serviceName); err != nil {
+		return err
+	}
+	if err := os.Remove(systemdUnit); err != nil {
+		return err
+	}
+	return systemctl("daemon-reload")
+}
+
+// runService 运行到收到 SIGTERM / SIGINT，先取消正在进行的任务，等 run 返回后退出；
+// 日志输出到标准错误，由 systemd 写入 journal
+func runService(run func(stop <-chan struct{})) error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		run(stop)
+		close(done)
+	}()
+
+	select {
+	case sig := <-signals:
+		log.Printf(tr("收到 %s 信号，停止服务"), sig)
+		cancelAllCampaigns()
+		close(stop)
+		<-done
+	case <-done:
+	}
+	return nil
+}
+
+func systemctl(args ...string) error {
+	out, err := exec.Command("systemctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %s: %s %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func systemdQuote(s string) string {
+	if len(s) > 0 && !strings.ContainsAny(s, " \t\"'\\$%;") {
+		return s
+	}
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", "$$", "%", "%%").Replace(s)
+	return `"` + s + `"`
+}
@@ -0,0 +1,151 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+func installService(args []string) error {
+	exe, serviceArgs, err := serviceCommand(args)
+	if err != nil {
+		return err
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(serviceName); err == nil {
+		s.Close()
+		return fmt.Errorf(trErr("服务 %s 已存在"), serviceName)
+	}
+
+	s, err := m.CreateService(serviceName, exe, mgr.Config{
+		DisplayName: serviceName,
+		Description: serviceDescription,
+		StartType:   mgr.StartAutomatic,
+	}, serviceArgs...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	if err := eventlog.InstallAsEventCreate(serviceName, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		s.Delete()
+		return fmt.Errorf(trErr("注册事件日志失败：%s"), err)
+	}
+
+	return s.Start()
+}
+
+func uninstallService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf(trErr("服务 %s 未安装"), serviceName)
+	}
+	defer s.Close()
+
+	if status, err := s.Control(svc.Stop); err == nil {
+		for i := 0; i < 120 && status.State != svc.Stopped; i++ {
+			time.Sleep(time.Second)
+			if status, err = s.Query(); err != nil {
+				break
+			}
+		}
+	}
+
+	if err := s.Delete(); err != nil {
+		return err
+	}
+	return eventlog.Remove(serviceName)
+}
+
+// runService 由服务管理器启动时日志写入事件日志，在命令行中直接运行时和 watch 命令一样
+func runService(run func(stop <-chan struct{})) error {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		return err
+	}
+	if !isService {
+		run(nil)
+		return nil
+	}
+
+	elog, err := eventlog.Open(serviceName)
+	if err != nil {
+		return err
+	}
+	defer elog.Close()
+	log.SetFlags(0)
+	log.SetOutput(&eventLogWriter{elog})
+
+	return svc.Run(serviceName, &windowsService{run: run})
+}
+
+type windowsService struct {
+	run func(stop <-chan struct{})
+}
+
+func (ws *windowsService) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		ws.run(stop)
+		close(done)
+	}()
+
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case r := <-requests:
+			switch r.Cmd {
+			case svc.Interrogate:
+				changes <- r.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				log.Printf(tr("停止服务"))
+				changes <- svc.Status{State: svc.StopPending}
+				cancelAllCampaigns()
+				close(stop)
+				<-done
+				return false, 0
+			}
+		case <-done:
+			return false, 1
+		}
+	}
+}
+
+type eventLogWriter struct {
+	elog *eventlog.Log
+}
+
+func (w *eventLogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSpace(string(p))
+	var err error
+	if strings.Contains(msg, "失败") || strings.Contains(strings.ToLower(msg), "fail") {
+		err = w.elog.Error(1, msg)
+	} else {
+		err = w.elog.Info(1, msg)
+	}
+	return len(p), err
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// setupPassword 为不把密码写入配置文件时使用的环境变量
+const setupPassword = "EMAIL_SENDER_PASSWORD"
+
+type setupPreset struct {
+	name string
+	host string
+	port int
+	// hint 为认证失败时的提示
+	hint string
+}
+
+var setupPresets = []setupPreset{
+	{"163", "smtp.163.com", 465, "163 邮箱需要在网页版设置中开启 SMTP 服务，密码填写授权码而不是登录密码"},
+	{"QQ", "smtp.qq.com", 465, "QQ 邮箱需要在网页版设置中开启 SMTP 服务，密码填写授权码而不是 QQ 密码"},
+	{"Gmail", "smtp.gmail.com", 587, "Gmail 需要开启两步验证并创建应用专用密码，密码填写应用专用密码"},
+	{"Office365", "smtp.office365.com", 587, "Office365 需要管理员为该邮箱开启 SMTP AUTH，开启了多重身份验证时需要使用应用密码"},
+	{"其他", "", 0, ""},
+}
+
+// setupConfig 为 setup 命令写入的配置，只包含连接服务器需要的字段
+type setupConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	From     string `json:"from"`
+}
+
+type setupPrompt struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask 显示问题并读取一行，直接回车时使用默认值
+func (p *setupPrompt) ask(question, def string) (string, error) {
+	if len(def) > 0 {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+	line, err := p.in.ReadString('\n')
+	line = strings.TrimSpace(line)
+	if err != nil && (err != io.EOF || len(line) == 0) {
+		if err == io.EOF {
+			return "", fmt.Errorf(trErr("输入已结束"))
+		}
+		return "", err
+	}
+	if len(line) == 0 {
+		return def, nil
+	}
+	return line, nil
+}
+
+func (p *setupPrompt) confirm(question string, def bool) (bool, error) {
+	d := "y/N"
+	if def {
+		d = "Y/n"
+	}
+	answer, err := p.ask(question, d)
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true, nil
+	case "n", "no":
+		return false, nil
+	}
+	return def, nil
+}
+
+// password 在终端中输入时不回显
+func (p *setupPrompt) password(question string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return p.ask(question, "")
+	}
+	fmt.Fprintf(p.out, "%s: ", question)
+	data, err := term.ReadPassword(fd)
+	fmt.Fprintln(p.out)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// runSetup 交互式地询问服务器、账号和发件人，测试连接后写入配置文件
+func runSetup(in io.Reader, out io.Writer, file string) error {
+	p := &setupPrompt{in: bufio.NewReader(in), out: out}
+
+	if _, err := os.Stat(file); err == nil {
+		ok, err := p.confirm(fmt.Sprintf(tr("%s 已存在，是否覆盖"), file), false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+	}
+
+	fmt.Fprintln(out, tr("选择邮件服务商："))
+	for i, preset := range setupPresets {
+		if len(preset.host) > 0 {
+			fmt.Fprintf(out, "  %d) %s (%s:%d)\n", i+1, preset.name, preset.host, preset.port)
+		} else {
+			fmt.Fprintf(out, "  %d) %s\n", i+1, tr(preset.name))
+		}
+	}
+	var preset setupPreset
+	for {
+		answer, err := p.ask(tr("服务商"), "1")
+		if err != nil {
+			return err
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(setupPresets) {
+			preset = setupPresets[n-1]
+			break
+		}
+		fmt.Fprintf(out, tr("请输入 1 到 %d\n"), len(setupPresets))
+	}
+
+	cfg := &Config{Host: preset.host, Port: preset.port, GreylistRetries: 2}
+	var err error
+	if len(preset.host) == 0 {
+		if cfg.Host, err = p.ask(tr("SMTP 服务器"), ""); err != nil {
+			return err
+		}
+		for {
+			answer, err := p.ask(tr("端口（465 为 SSL，587 / 25 使用 STARTTLS）"), "465")
+			if err != nil {
+				return err
+			}
+			if cfg.Port, err = strconv.Atoi(answer); err == nil && cfg.Port > 0 && cfg.Port < 65536 {
+				break
+			}
+			fmt.Fprintln(out, tr("无效的端口"))
+		}
+	}
+	if len(preset.hint) > 0 {
+		fmt.Fprintf(out, "%s\n", tr(preset.hint))
+	}
+
+	for {
+		if cfg.Username, err = p.ask(tr("用户名（通常为邮箱地址）"), cfg.Username); err != nil {
+			return err
+		}
+		if cfg.Password, err = p.password(tr("密码")); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, tr("正在连接 %s:%d ...\n"), cfg.Host, cfg.Port)
+		sender, err := dialSMTP(cfg)
+		if err == nil {
+			sender.Close()
+			fmt.Fprintln(out, tr("连接和认证成功"))
+			break
+		}
+		fmt.Fprintf(out, tr("连接失败：%s\n"), redactText(err))
+		if len(preset.hint) > 0 {
+			fmt.Fprintf(out, "%s\n", tr(preset.hint))
+		}
+		retry, err := p.confirm(tr("重新输入用户名和密码"), true)
+		if err != nil {
+			return err
+		}
+		if !retry {
+			save, err := p.confirm(tr("仍然保存配置"), false)
+			if err != nil {
+				return err
+			}
+			if !save {
+				return fmt.Errorf(trErr("没有保存配置"))
+			}
+			break
+		}
+	}
+
+	def := ""
+	if validEmailAddress(cfg.Username) {
+		def = cfg.Username
+	}
+	for {
+		if cfg.From, err = p.ask(tr("发件人，例如 张三 <zhangsan@example.com>"), def); err != nil {
+			return err
+		}
+		if validEmailAddress(cfg.From) {
+			break
+		}
+		fmt.Fprintln(out, tr("无效的发件人地址"))
+	}
+
+	saved := setupConfig{Host: cfg.Host, Port: cfg.Port, Username: cfg.Username, Password: cfg.Password, From: cfg.From}
+	inFile, err := p.confirm(fmt.Sprintf(tr("把密码保存到配置文件中（否则从环境变量 %s 读取）"), setupPassword), true)
+	if err != nil {
+		return err
+	}
+	if !inFile {
+		saved.Password = "${" + setupPassword + "}"
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(saved); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(file, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, tr("已写入 %s，可以用 email-sender.exe test-send --config %s --to 你的邮箱 ... 发送测试邮件\n"), file, file)
+	return nil
+}
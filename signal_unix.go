@@ -0,0 +1,29 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchPauseSignals 收到 SIGUSR1 时暂停所有任务，收到 SIGUSR2 时继续；
+// 暂停期间连接可能被服务器断开，继续后发送第一封邮件时会重新连接
+func watchPauseSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGUSR1 {
+				log.Printf(tr("收到 SIGUSR1 信号，发送完当前的邮件后暂停"))
+				pauseAllCampaigns()
+			} else {
+				log.Printf(tr("收到 SIGUSR2 信号，继续发送"))
+				resumeAllCampaigns()
+			}
+		}
+	}()
+}
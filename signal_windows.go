@@ -0,0 +1,8 @@
+//go:build windows
+// +build windows
+
+package main
+
+// watchPauseSignals Windows 没有 SIGUSR1 / SIGUSR2，可以用 --tui 的 p 键或监控页面暂停
+func watchPauseSignals() {
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+const (
+	signatureColumn = "Signature"
+	ownerColumn     = "Owner"
+	// defaultSignature 为 Signature 列和 Owner 列都为空时使用的签名
+	defaultSignature = "default"
+)
+
+// loadSignatures 读取 signatures 中配置的所有签名
+func loadSignatures(cfg *Config) (map[string]*footerBlock, error) {
+	signatures := map[string]*footerBlock{}
+	for name, c := range cfg.Signatures {
+		if c == nil {
+			continue
+		}
+		b, err := loadFooterBlock(c)
+		if err != nil {
+			return nil, fmt.Errorf(trErr("签名 %s：%s"), name, err)
+		}
+		signatures[name] = b
+	}
+	return signatures, nil
+}
+
+// signatureName 返回行使用的签名：Signature 列，没有时为 Owner 列，都为空时为 default；
+// 名称先按原样查找，找不到时忽略大小写查找
+func signatureName(signatures map[string]*footerBlock, meta map[string]string) string {
+	name := strings.TrimSpace(meta[signatureColumn])
+	if len(name) == 0 {
+		name = strings.TrimSpace(meta[ownerColumn])
+	}
+	if len(name) == 0 {
+		return defaultSignature
+	}
+	if _, ok := signatures[name]; ok {
+		return name
+	}
+	for key := range signatures {
+		if strings.EqualFold(key, name) {
+			return key
+		}
+	}
+	return name
+}
+
+// enforceSignatures 检查每一行选择的签名是否存在，Signature / Owner 列为空且没有 default 签名的行不加签名
+func enforceSignatures(list []*Send, signatures map[string]*footerBlock) ([]*Send, error) {
+	if len(signatures) == 0 {
+		return list, nil
+	}
+
+	remaining := []*Send{}
+	lines := []string{}
+	for _, s := range list {
+		name := signatureName(signatures, s.Meta)
+		if _, ok := signatures[name]; !ok && name != defaultSignature {
+			reason := fmt.Sprintf(trErr("没有名为 %s 的签名"), name)
+			rejected.add(s.Row, reason)
+			lines = append(lines, fmt.Sprintf(trErr("第 %d 行 %s：%s"), s.Row, maskAddress(s.SendTo), reason))
+			continue
+		}
+		remaining = append(remaining, s)
+	}
+	if len(lines) == 0 {
+		return list, nil
+	}
+
+	if skipBadRows {
+		for _, line := range lines {
+			log.Printf(tr("跳过：%s"), line)
+		}
+		return remaining, nil
+	}
+	if len(lines) > maxSchemaErrors {
+		lines = append(lines[:maxSchemaErrors], fmt.Sprintf(trErr("……共 %d 行有问题"), len(lines)))
+	}
+	return nil, errors.New(trErr("签名检查不通过：\n") + strings.Join(lines, "\n"))
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 超过中位数 outlierRatio 倍且不小于 outlierMinSize 的邮件视为明显偏大
+const (
+	outlierRatio   = 3
+	outlierMinSize = 100 << 10
+	maxOutliers    = 10
+)
+
+// sizeLimiter 由可以获取服务器 SIZE 扩展的 Sender 实现
+type sizeLimiter interface {
+	maxMessageSize() int64
+}
+
+// MessageTooLargeError 为邮件超过 max_message_size 或服务器 SIZE 限制，这样的邮件不会发送
+type MessageTooLargeError struct {
+	Size  int64
+	Limit int64
+}
+
+func (e *MessageTooLargeError) Error() string {
+	return fmt.Sprintf(trErr("邮件大小 %s 超过限制 %s"), formatSize(e.Size), formatSize(e.Limit))
+}
+
+// maxMessageSize 返回服务器 EHLO 中 SIZE 扩展声明的大小，没有声明或为 0 时不限制
+func (s *smtpSender) maxMessageSize() int64 {
+	ok, param := s.client.Extension("SIZE")
+	if !ok {
+		return 0
+	}
+	size, _ := strconv.ParseInt(strings.TrimSpace(param), 10, 64)
+	return size
+}
+
+// messageSizeLimit 取 max_message_size 与服务器 SIZE 中较小的一个
+func messageSizeLimit(cfg *Config, sender interface{}) int64 {
+	limit := cfg.MaxMessageSize
+	if l, ok := sender.(sizeLimiter); ok {
+		if size := l.maxMessageSize(); size > 0 && (limit <= 0 || size < limit) {
+			limit = size
+		}
+	}
+	return limit
+}
+
+type countingWriter int64
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
+
+func messageSize(msg io.WriterTo) (int64, error) {
+	var w countingWriter
+	_, err := msg.WriteTo(&w)
+	return int64(w), err
+}
+
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
+
+// estimateSizes 渲染所有邮件，统计大小分布和总传输量，列出明显偏大的邮件
+func estimateSizes(cfg *Config, list []*Send, contentProvider ContentProvider, decorators []Decorator) error {
+	type rowSize struct {
+		s    *Send
+		size int64
+	}
+	sizes := []rowSize{}
+	var total int64
+	for _, s := range list {
+		msg, err := renderMessage(cfg, s, contentProvider, decorators)
+		if err != nil {
+			return fmt.Errorf(trErr("第 %d 行 %s：%s"), s.Row, maskAddress(s.SendTo), redactText(err))
+		}
+		sizes = append(sizes, rowSize{s, int64(len(msg))})
+		total += int64(len(msg))
+	}
+	if len(sizes) == 0 {
+		return nil
+	}
+	sort.SliceStable(sizes, func(i, j int) bool { return sizes[i].size < sizes[j].size })
+
+	percentile := func(p int) int64 {
+		return sizes[(len(sizes)-1)*p/100].size
+	}
+	median := percentile(50)
+	log.Printf(tr("邮件大小：最小 %s，中位数 %s，95%% %s，最大 %s（第 %d 行），共 %d 封，总计 %s"),
+		formatSize(sizes[0].size), formatSize(median), formatSize(percentile(95)),
+		formatSize(sizes[len(sizes)-1].size), sizes[len(sizes)-1].s.Row, len(sizes), formatSize(total))
+
+	outliers := 0
+	for i := len(sizes) - 1; i >= 0; i-- {
+		r := sizes[i]
+		if r.size < outlierMinSize || r.size < median*outlierRatio {
+			break
+		}
+		if outliers++; outliers <= maxOutliers {
+			log.Printf(tr("警告：第 %d 行 %s 的邮件为 %s，是中位数的 %.1f 倍"), r.s.Row, maskAddress(r.s.SendTo), formatSize(r.size), float64(r.size)/float64(median))
+		}
+	}
+	if outliers > maxOutliers {
+		log.Printf(tr("警告：共 %d 封邮件明显偏大"), outliers)
+	}
+	if cfg.MaxMessageSize > 0 && sizes[len(sizes)-1].size > cfg.MaxMessageSize {
+		log.Printf(tr("警告：有邮件超过 max_message_size %s，这些邮件不会发送"), formatSize(cfg.MaxMessageSize))
+	}
+	return nil
+}
@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// smtpSender 替代 gomail.Dialer，便于控制 SMTP 扩展（SMTPUTF8 等）
+type smtpSender struct {
+	cfg    *Config
+	client *smtp.Client
+	next   *envelope
+}
+
+func dialSMTP(cfg *Config) (*smtpSender, error) {
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if len(cfg.LocalAddr) > 0 {
+		local, err := localTCPAddr(cfg.LocalAddr)
+		if err != nil {
+			return nil, err
+		}
+		dialer.LocalAddr = local
+	}
+	conn, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{ServerName: cfg.Host, InsecureSkipVerify: true}
+
+	ssl := cfg.Port == 465
+	if ssl {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	transcript := &smtpTranscript{host: cfg.Host}
+	logDebug("SMTP 已连接 %s", addr)
+	traceSMTP(c, transcript)
+
+	if len(cfg.LocalName) > 0 {
+		if err := c.Hello(cfg.LocalName); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	if !ssl {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				c.Close()
+				return nil, err
+			}
+			traceSMTP(c, transcript)
+		}
+	}
+
+	if len(cfg.Username) > 0 {
+		if ok, auths := c.Extension("AUTH"); ok {
+			var auth smtp.Auth
+			if strings.Contains(auths, "CRAM-MD5") {
+				auth = smtp.CRAMMD5Auth(cfg.Username, cfg.Password)
+			} else if strings.Contains(auths, "LOGIN") && !strings.Contains(auths, "PLAIN") {
+				auth = &loginAuth{username: cfg.Username, password: cfg.Password, host: cfg.Host}
+			} else {
+				auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+			}
+			if err := c.Auth(auth); err != nil {
+				c.Close()
+				return nil, err
+			}
+		}
+	}
+
+	return &smtpSender{cfg: cfg, client: c}, nil
+}
+
+// localTCPAddr 解析 local_addr，可以是 IP 地址或网卡名称，网卡有多个地址时优先使用 IPv4
+func localTCPAddr(value string) (*net.TCPAddr, error) {
+	if ip := net.ParseIP(value); ip != nil {
+		return &net.TCPAddr{IP: ip}, nil
+	}
+	iface, err := net.InterfaceByName(value)
+	if err != nil {
+		return nil, fmt.Errorf(trErr("无效的 local_addr %s，需要是 IP 地址或网卡名称"), value)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	var ip net.IP
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if ipnet.IP.To4() != nil {
+			return &net.TCPAddr{IP: ipnet.IP}, nil
+		}
+		if ip == nil {
+			ip = ipnet.IP
+		}
+	}
+	if ip == nil {
+		return nil, fmt.Errorf(trErr("网卡 %s 没有可用的 IP 地址"), value)
+	}
+	return &net.TCPAddr{IP: ip}, nil
+}
+
+func (s *smtpSender) setEnvelope(e *envelope) {
+	s.next = e
+}
+
+func (s *smtpSender) Send(from string, to []string, msg io.WriterTo) error {
+	e := s.next
+	s.next = nil
+	return s.send(e, from, to, msg)
+}
+
+func (s *smtpSender) send(e *envelope, from string, to []string, msg io.WriterTo) error {
+	if e != nil && len(e.from) > 0 {
+		from = e.from
+	}
+
+	// 信封中的国际化域名使用 punycode，邮件头由调用者决定
+	from = asciiDomainAddress(from)
+	rcpts := make([]string, len(to))
+	for i, addr := range to {
+		rcpts[i] = asciiDomainAddress(addr)
+	}
+	to = rcpts
+
+	if err := s.checkSMTPUTF8(from, to); err != nil {
+		return err
+	}
+
+	var mailParams []string
+	var rcptParams func(addr string) []string
+	if e != nil && (len(e.mailParams) > 0 || e.rcptParams != nil) {
+		if ok, _ := s.client.Extension("DSN"); ok {
+			mailParams, rcptParams = e.mailParams, e.rcptParams
+		} else {
+			logDebug("服务器 %s 不支持 DSN，不使用 DSN 参数", s.cfg.Host)
+		}
+	}
+
+	mailFailed, err := s.transaction(from, to, mailParams, rcptParams, msg)
+	if mailFailed && isConnectionClosed(err) {
+		// 连接可能已超时断开（例如等待 SendAt 的时间较长），重新连接后只再试一次
+		logDebug("SMTP 连接已断开，重新连接")
+		c, derr := dialSMTP(s.cfg)
+		if derr != nil {
+			return err
+		}
+		s.client.Close()
+		s.client = c.client
+		_, err = s.transaction(from, to, mailParams, rcptParams, msg)
+	}
+	return err
+}
+
+// transaction 发送一封邮件，mailFailed 表示 MAIL FROM 失败；
+// 服务器支持 PIPELINING 时 MAIL FROM 和所有 RCPT TO 一次发出，支持 CHUNKING 时用 BDAT 代替 DATA，减少往返次数。
+// DATA / BDAT 不和 RCPT TO 一起发出，否则部分收件人被拒绝时无法取消这封邮件
+func (s *smtpSender) transaction(from string, to []string, mailParams []string, rcptParams func(addr string) []string, msg io.WriterTo) (bool, error) {
+	cmds := []string{}
+	cmd, err := mailCommand(from, s.mailParams(mailParams))
+	if err != nil {
+		return true, err
+	}
+	cmds = append(cmds, cmd)
+	for _, addr := range to {
+		var params []string
+		if rcptParams != nil {
+			params = rcptParams(addr)
+		}
+		cmd, err := rcptCommand(addr, params)
+		if err != nil {
+			return false, err
+		}
+		cmds = append(cmds, cmd)
+	}
+
+	if ok, _ := s.client.Extension("PIPELINING"); ok {
+		if err := s.pipeline(cmds); err != nil {
+			return true, err
+		}
+		// 读取所有响应，保持与服务器同步
+		var first error
+		failed := 0
+		for i := range cmds {
+			code := 25
+			if i == 0 {
+				code = 250
+			}
+			if _, _, err := s.client.Text.ReadResponse(code); err != nil {
+				if first == nil {
+					first, failed = err, i
+				}
+				if closesConnection(err) {
+					break
+				}
+			}
+		}
+		if first != nil {
+			// 结束这次事务，下一封邮件才能重新 MAIL FROM
+			s.client.Reset()
+			return failed == 0, first
+		}
+	} else {
+		if err := s.cmd(250, cmds[0]); err != nil {
+			return true, err
+		}
+		for _, cmd := range cmds[1:] {
+			if err := s.cmd(25, cmd); err != nil {
+				s.client.Reset()
+				return false, err
+			}
+		}
+	}
+
+	if ok, _ := s.client.Extension("CHUNKING"); ok {
+		return false, s.bdat(msg)
+	}
+
+	w, err := s.client.Data()
+	if err != nil {
+		s.client.Reset()
+		return false, err
+	}
+	if _, err = msg.WriteTo(w); err != nil {
+		w.Close()
+		return false, err
+	}
+	return false, w.Close()
+}
+
+// mailParams 在扩展参数前加上服务器支持的 BODY=8BITMIME 和 SMTPUTF8
+func (s *smtpSender) mailParams(params []string) []string {
+	result := []string{}
+	if ok, _ := s.client.Extension("8BITMIME"); ok {
+		result = append(result, "BODY=8BITMIME")
+	}
+	if ok, _ := s.client.Extension("SMTPUTF8"); ok {
+		result = append(result, "SMTPUTF8")
+	}
+	return append(result, params...)
+}
+
+// mailCommand 与 smtp.Client.Mail 发出的命令相同，另外可以附带扩展参数
+func mailCommand(from string, params []string) (string, error) {
+	if strings.ContainsAny(from, "\r\n") {
+		return "", errors.New("smtp: A line must not contain CR or LF")
+	}
+	cmd := "MAIL FROM:<" + from + ">"
+	for _, p := range params {
+		cmd += " " + p
+	}
+	return cmd, nil
+}
+
+func rcptCommand(to string, params []string) (string, error) {
+	if strings.ContainsAny(to, "\r\n") {
+		return "", errors.New("smtp: A line must not contain CR or LF")
+	}
+	cmd := "RCPT TO:<" + to + ">"
+	for _, p := range params {
+		cmd += " " + p
+	}
+	return cmd, nil
+}
+
+func (s *smtpSender) cmd(expectCode int, cmd string) error {
+	id, err := s.client.Text.Cmd("%s", cmd)
+	if err != nil {
+		return err
+	}
+	s.client.Text.StartResponse(id)
+	defer s.client.Text.EndResponse(id)
+	_, _, err = s.client.Text.ReadResponse(expectCode)
+	return err
+}
+
+// pipeline 一次写出多个命令，响应由调用者按顺序读取（RFC 2920）
+func (s *smtpSender) pipeline(cmds []string) error {
+	for _, cmd := range cmds {
+		if _, err := s.client.Text.W.WriteString(cmd + "\r\n"); err != nil {
+			return err
+		}
+	}
+	return s.client.Text.W.Flush()
+}
+
+// bdat 用一个 BDAT LAST 发送整封邮件（RFC 3030），不需要点号转义，gomail 生成的邮件已经使用 CRLF 换行；
+// 邮件必须以 CRLF 结束，DATA 时由 dotWriter 补上。出错时 RSET 结束这次事务
+func (s *smtpSender) bdat(msg io.WriterTo) error {
+	var buf bytes.Buffer
+	if _, err := msg.WriteTo(&buf); err != nil {
+		s.client.Reset()
+		return err
+	}
+	if !bytes.HasSuffix(buf.Bytes(), []byte("\r\n")) {
+		buf.WriteString("\r\n")
+	}
+	err := s.writeBDAT(buf.Bytes())
+	if err == nil {
+		_, _, err = s.client.Text.ReadResponse(250)
+	}
+	if err != nil {
+		s.client.Reset()
+	}
+	return err
+}
+
+func (s *smtpSender) writeBDAT(data []byte) error {
+	if _, err := fmt.Fprintf(s.client.Text.W, "BDAT %d LAST\r\n", len(data)); err != nil {
+		return err
+	}
+	if _, err := s.client.Text.W.Write(data); err != nil {
+		return err
+	}
+	return s.client.Text.W.Flush()
+}
+
+func (s *smtpSender) supportsSMTPUTF8() bool {
+	ok, _ := s.client.Extension("SMTPUTF8")
+	return ok
+}
+
+// checkSMTPUTF8 包含非 ASCII 字符的地址必须由服务器支持 SMTPUTF8 扩展（RFC 6531）
+func (s *smtpSender) checkSMTPUTF8(from string, to []string) error {
+	addrs := append([]string{from}, to...)
+	for _, addr := range addrs {
+		if isASCII(addr) {
+			continue
+		}
+		if ok, _ := s.client.Extension("SMTPUTF8"); !ok {
+			return fmt.Errorf(trErr("服务器 %s 不支持 SMTPUTF8，无法发送国际化地址 %s"), s.cfg.Host, addr)
+		}
+		logDebug("使用 SMTPUTF8 发送国际化地址 %s", addr)
+	}
+	return nil
+}
+
+// isConnectionClosed 判断连接是否已经断开，只有这种情况可以重新连接后重发；超时等其他网络错误时服务器可能已经收到邮件
+func isConnectionClosed(err error) bool {
+	return err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
+
+// closesConnection 判断这个错误之后连接是否不能再使用：连接已断开，或者服务器返回 421 后将关闭连接（RFC 5321 3.8）
+func closesConnection(err error) bool {
+	var e *textproto.Error
+	if errors.As(err, &e) {
+		return e.Code == 421
+	}
+	return isConnectionClosed(err)
+}
+
+// isGreylisted 判断是否是灰名单常用的 450 / 451 临时拒绝
+func isGreylisted(err error) bool {
+	var e *textproto.Error
+	if errors.As(err, &e) {
+		return e.Code == 450 || e.Code == 451
+	}
+	return false
+}
+
+func (s *smtpSender) Close() error {
+	return s.client.Quit()
+}
+
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS {
+		advertised := false
+		for _, mechanism := range server.Auth {
+			if mechanism == "LOGIN" {
+				advertised = true
+				break
+			}
+		}
+		if !advertised {
+			return "", nil, errors.New(trErr("未加密的连接不支持 LOGIN 认证"))
+		}
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New(trErr("SMTP 服务器地址不匹配"))
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	switch {
+	case strings.EqualFold(string(fromServer), "Username:"):
+		return []byte(a.username), nil
+	case strings.EqualFold(string(fromServer), "Password:"):
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf(trErr("未知的 LOGIN 认证响应：%s"), fromServer)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SpooledMessage 为发送失败的邮件保存在 .eml 旁边的信息，replay 时使用其中的信封地址
+type SpooledMessage struct {
+	CampaignID string    `json:"campaign_id,omitempty"`
+	Row        int       `json:"row"`
+	SendTo     string    `json:"send_to"`
+	Subject    string    `json:"subject"`
+	From       string    `json:"from"`
+	To         []string  `json:"to"`
+	Error      string    `json:"error"`
+	Attempts   int       `json:"attempts"`
+	Time       time.Time `json:"time"`
+}
+
+var spoolMu sync.Mutex
+
+// spoolFailure 把发送失败的邮件原样写入 --spool 目录，供 replay 命令重新发送
+func spoolFailure(campaignID string, s *Send, capture *captureSender, err error) {
+	if len(spoolDir) == 0 || capture.data == nil {
+		return
+	}
+	spoolMu.Lock()
+	defer spoolMu.Unlock()
+
+	if err := os.MkdirAll(spoolDir, 0700); err != nil {
+		log.Printf(tr("保存失败的邮件失败：%s"), err)
+		return
+	}
+	name := fmt.Sprintf("%s-%05d-%s", time.Now().Format("20060102-150405.000"), s.Row, unsafeFileChars.ReplaceAllString(s.SendTo, "_"))
+	msg := SpooledMessage{
+		CampaignID: campaignID,
+		Row:        s.Row,
+		SendTo:     s.SendTo,
+		Subject:    s.Subject,
+		From:       capture.from,
+		To:         capture.to,
+		Error:      err.Error(),
+		Attempts:   1,
+		Time:       time.Now(),
+	}
+	if err := writeSpooled(filepath.Join(spoolDir, name), capture.data, &msg); err != nil {
+		log.Printf(tr("保存失败的邮件失败：%s"), err)
+	}
+}
+
+func writeSpooled(base string, data []byte, msg *SpooledMessage) error {
+	meta, err := json.MarshalIndent(msg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if data != nil {
+		if err := ioutil.WriteFile(base+".eml", data, 0600); err != nil {
+			return err
+		}
+	}
+	return ioutil.WriteFile(base+".json", meta, 0600)
+}
+
+// replay 重新发送 spool 目录中的邮件，成功的从目录中删除，返回是否全部成功
+func replay(cfg *Config, dir string) bool {
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) == 0 {
+		log.Printf(tr("%s 中没有需要重新发送的邮件"), dir)
+		return err == nil
+	}
+	sort.Strings(files)
+
+	sender, err := getSender(cfg)
+	if err != nil {
+		log.Printf(tr("创建 Sender 失败：%s"), err)
+		return false
+	}
+	defer func() {
+		if closer, ok := sender.(io.Closer); ok {
+			closer.Close()
+		}
+	}()
+
+	reporter, err := newReporter(reportFile, cfg.ReportSalt)
+	if err != nil {
+		log.Printf(tr("创建报告文件失败：%s"), err)
+		return false
+	}
+	defer reporter.Close()
+
+	for i, file := range files {
+		base := strings.TrimSuffix(file, ".eml")
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Printf(tr("读取 %s 失败：%s"), file, err)
+			reporter.Failed++
+			continue
+		}
+		var msg SpooledMessage
+		meta, err := ioutil.ReadFile(base + ".json")
+		if err == nil {
+			err = json.Unmarshal(meta, &msg)
+		}
+		if err != nil || len(msg.From) == 0 || len(msg.To) == 0 {
+			log.Printf(tr("读取 %s 的信封信息失败，跳过"), file)
+			reporter.Failed++
+			continue
+		}
+
+		s := &Send{Row: msg.Row, SendTo: msg.SendTo, Subject: msg.Subject}
+		err = sender.Send(msg.From, msg.To, bytes.NewBuffer(data))
+		reporter.Add(msg.CampaignID, s, err)
+		if err != nil {
+			log.Printf(tr("重新发送失败 %s: %s"), maskAddress(msg.SendTo), redactText(err))
+			msg.Error, msg.Attempts, msg.Time = err.Error(), msg.Attempts+1, time.Now()
+			if err := writeSpooled(base, nil, &msg); err != nil {
+				log.Printf(tr("更新 %s 失败：%s"), base+".json", err)
+			}
+		} else {
+			logDebug("重新发送成功 %s", msg.SendTo)
+			os.Remove(file)
+			os.Remove(base + ".json")
+		}
+
+		if cfg.Interval > 0 && i < len(files)-1 {
+			time.Sleep(time.Millisecond * time.Duration(cfg.Interval))
+		}
+	}
+
+	log.Printf(tr("重新发送完成，成功 %d 封，失败 %d 封"), reporter.Sent, reporter.Failed)
+	return reporter.Failed == 0
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// subjectTemplate 为 --subject 编译后的模板
+var subjectTemplate renderer
+
+func compileSubject() error {
+	if len(subject) == 0 {
+		if forceSubject {
+			return errors.New(trErr("--force-subject 需要同时指定 --subject"))
+		}
+		return nil
+	}
+	t, err := compileTemplate("subject", "", subject, false)
+	if err != nil {
+		return fmt.Errorf(trErr("解析 --subject 失败：%s"), err)
+	}
+	subjectTemplate = t
+	return nil
+}
+
+// applyDefaultSubject 标题为空或指定了 --force-subject 时用 --subject 渲染标题，没有 --subject 时标题不能为空
+func applyDefaultSubject(s *Send) error {
+	if subjectTemplate == nil || len(s.Subject) > 0 && !forceSubject {
+		if len(s.Subject) == 0 {
+			return errors.New(trErr("标题不能为空"))
+		}
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := subjectTemplate(&buf, s.Meta); err != nil {
+		return fmt.Errorf(trErr("渲染标题失败：%s"), err)
+	}
+	s.Subject = buf.String()
+	if len(s.Subject) == 0 {
+		return errors.New(trErr("标题不能为空"))
+	}
+	return nil
+}
+
+// subjectTags 返回标题的前缀和后缀，--subject-prefix / --subject-suffix 优先于配置中的 subject_prefix / subject_suffix
+func subjectTags(cfg *Config) (string, string) {
+	prefix, suffix := cfg.SubjectPrefix, cfg.SubjectSuffix
+	if len(subjectPrefix) > 0 {
+		prefix = subjectPrefix
+	}
+	if len(subjectSuffix) > 0 {
+		suffix = subjectSuffix
+	}
+	return prefix, suffix
+}
+
+// tagSubject 给标题加上前缀和后缀，标题中已经有的不重复添加
+func tagSubject(cfg *Config, subject string) string {
+	prefix, suffix := subjectTags(cfg)
+	if len(prefix) > 0 && !strings.HasPrefix(subject, prefix) {
+		subject = prefix + subject
+	}
+	if len(suffix) > 0 && !strings.HasSuffix(subject, suffix) {
+		subject += suffix
+	}
+	return subject
+}
+
+func tagSubjects(cfg *Config, list []*Send) {
+	for _, s := range list {
+		s.Subject = tagSubject(cfg, s.Subject)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// summarizeReports 汇总报告文件，同一个收件人（行号和地址）发送过多次时以最后一次结果为准，
+// 多个报告按参数顺序读取，可以把重发的报告放在后面
+func summarizeReports(files []string, w io.Writer) error {
+	type key struct {
+		row  int
+		addr string
+	}
+	last := map[key]Result{}
+	order := []key{}
+	for _, file := range files {
+		err := readReport(file, func(result Result) {
+			k := key{result.Row, dedupeKey(normalizeAddress(result.SendTo))}
+			if len(result.SendToHash) > 0 {
+				k.addr = "#" + result.SendToHash
+			}
+			if _, ok := last[k]; !ok {
+				order = append(order, k)
+			}
+			last[k] = result
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	byStatus := map[string]int{}
+	codes := map[string]int{}
+	failures := []Result{}
+	for _, k := range order {
+		result := last[k]
+		byStatus[result.Status]++
+		if result.Status == statusFailed {
+			codes[smtpErrorCode(result.Error)]++
+			failures = append(failures, result)
+		}
+	}
+
+	fmt.Fprintf(w, tr("收件人：%d\n"), len(order))
+	for _, status := range sortedKeys(byStatus) {
+		fmt.Fprintf(w, "  %-16s %d\n", status, byStatus[status])
+	}
+	if len(codes) > 0 {
+		fmt.Fprintln(w, tr("失败的响应码："))
+		for _, code := range sortedKeys(codes) {
+			fmt.Fprintf(w, "  %-16s %d\n", code, codes[code])
+		}
+	}
+	if len(failures) > 0 {
+		fmt.Fprintln(w, tr("失败的收件人："))
+		for _, result := range failures {
+			fmt.Fprintf(w, tr("  第 %d 行 %s：%s\n"), result.Row, resultAddress(result), redactText(result.Error))
+		}
+	}
+	return nil
+}
+
+func sortedKeys(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// testSend 用数据文件中的一行（或 --fixture 中的数据）渲染邮件，只发送一封到 --to 指定的地址
+func testSend(cfg *Config, file, content, template string, contentProvider ContentProvider) error {
+	to := normalizeAddress(testTo)
+	if len(to) == 0 || !validEmailAddress(to) {
+		return fmt.Errorf(trErr("请用 --to 指定有效的测试收件人"))
+	}
+
+	var s *Send
+	if len(testFixture) > 0 {
+		var err error
+		if s, err = loadFixture(cfg, testFixture); err != nil {
+			return fmt.Errorf(trErr("读取 %s 失败：%s"), testFixture, err)
+		}
+	} else {
+		list, err := loadSendList(cfg, file, nil)
+		if err != nil {
+			return fmt.Errorf(trErr("处理 Excel 文件失败：%s"), err)
+		}
+		for _, row := range list {
+			if testRow == 0 || row.Row == testRow {
+				s = row
+				break
+			}
+		}
+		if s == nil {
+			return fmt.Errorf(trErr("数据文件中没有第 %d 行"), testRow)
+		}
+	}
+	if len(testFixture) > 0 {
+		log.Printf(tr("用 %s 中的数据发送测试邮件到 %s"), testFixture, to)
+	} else {
+		log.Printf(tr("用第 %d 行 %s 的数据发送测试邮件到 %s"), s.Row, s.SendTo, to)
+	}
+	s.SendTo = to
+	s.Subject = tagSubject(cfg, s.Subject)
+
+	list := []*Send{s}
+	contentProvider, err := getLangContentProvider(cfg, contentProvider, content, template, list)
+	if err != nil {
+		return err
+	}
+	if list, contentProvider, err = applyFooter(cfg, list, contentProvider); err != nil {
+		return err
+	}
+	decorators, err := getDecorators(cfg)
+	if err != nil {
+		return err
+	}
+	reporter, err := newReporter("", "")
+	if err != nil {
+		return err
+	}
+	campaign := newCampaign("test-send", list, reporter)
+	if err := sendEmails(cfg, list, contentProvider, decorators, campaign); err != nil {
+		return err
+	}
+	if reporter.Sent == 0 {
+		return errors.New(trErr("测试邮件没有发送成功"))
+	}
+	log.Printf(tr("测试邮件已发送到 %s"), to)
+	return nil
+}
+
+// loadFixture 读取 JSON 对象作为一行数据，Subject、Content 为标题和内容，其他字段可以在模板中使用
+func loadFixture(cfg *Config, file string) (*Send, error) {
+	data, err := readFileContent(file)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var fields map[string]interface{}
+	if err := d.Decode(&fields); err != nil {
+		return nil, err
+	}
+
+	s := &Send{}
+	for k, v := range fields {
+		if v == nil {
+			continue
+		}
+		val := fmt.Sprint(v)
+		switch k {
+		case "SendTo":
+			s.SendTo = val
+		case "Subject":
+			s.Subject = val
+		case "Content":
+			s.Content = &val
+		default:
+			if s.Meta == nil {
+				s.Meta = map[string]string{}
+			}
+			s.Meta[k] = val
+		}
+	}
+	applyDefaults(cfg, s)
+	if len(s.Subject) == 0 && subjectTemplate == nil {
+		return nil, errors.New(trErr("没有邮件标题，请在 JSON 中指定 Subject 或用 --subject 指定"))
+	}
+	if err := applyDefaultSubject(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+const (
+	inReplyToColumn  = "InReplyTo"
+	referencesColumn = "References"
+)
+
+// threadHistory 为 --thread-report 中每个收件人最后一次发送成功的 Message-ID，
+// 键与 loadFailures 相同：地址，或报告中地址已哈希时为 "#" + 哈希
+var threadHistory = map[string]string{}
+
+// newMessageID 生成邮件的 Message-ID，域名使用发件人地址的域名
+func newMessageID(from string) string {
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if i := strings.LastIndex(addr.Address, "@"); i >= 0 && i < len(addr.Address)-1 {
+			domain = addr.Address[i+1:]
+		}
+	}
+	random := make([]byte, 8)
+	rand.Read(random)
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(random), domain)
+}
+
+// loadThreadHistory 读取之前任务的报告，后面的报告和后面的结果优先
+func loadThreadHistory(files []string) error {
+	for _, file := range files {
+		err := readReport(file, func(result Result) {
+			if result.Status != statusSent || len(result.MessageID) == 0 || result.Seed {
+				return
+			}
+			if len(result.SendToHash) > 0 {
+				threadHistory["#"+result.SendToHash] = result.MessageID
+			} else {
+				threadHistory[dedupeKey(normalizeAddress(result.SendTo))] = result.MessageID
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func previousMessageID(addr, salt string) string {
+	if id, ok := threadHistory[dedupeKey(normalizeAddress(addr))]; ok {
+		return id
+	}
+	if len(salt) > 0 {
+		return threadHistory["#"+addressHash(salt, addr)]
+	}
+	return ""
+}
+
+// getThreadDecorator 按 InReplyTo / References 列或 --thread-report 中的 Message-ID 设置 In-Reply-To 和 References，
+// 让跟进邮件在收件人的邮箱中与之前的邮件显示在同一个会话中；列优先于报告
+func getThreadDecorator(cfg *Config) Decorator {
+	return func(m *gomail.Message, s *Send) error {
+		parent := strings.TrimSpace(s.Meta[inReplyToColumn])
+		if len(parent) == 0 {
+			parent = previousMessageID(s.SendTo, cfg.ReportSalt)
+		}
+		if len(parent) == 0 {
+			return nil
+		}
+		if !strings.HasPrefix(parent, "<") {
+			parent = "<" + parent + ">"
+		}
+		references := strings.Fields(s.Meta[referencesColumn])
+		found := false
+		for _, id := range references {
+			found = found || id == parent
+		}
+		if !found {
+			references = append(references, parent)
+		}
+		m.SetHeader("In-Reply-To", parent)
+		m.SetHeader("References", strings.Join(references, " "))
+		return nil
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"net/textproto"
+	"time"
+)
+
+// ThrottleConfig 配置根据服务器的限速响应自动调整发送间隔，max_interval 单位为毫秒
+type ThrottleConfig struct {
+	MaxInterval int64   `json:"max_interval"`
+	Backoff     float64 `json:"backoff"`
+	Recover     int     `json:"recover"`
+	Retries     int     `json:"retries"`
+}
+
+// throttle 为当前的发送间隔：收到 421 / 450 / 451 时按 backoff 倍增加，连续成功 recover 封后按同样的倍数减少，
+// 最少为配置的 interval
+type throttle struct {
+	cfg       *ThrottleConfig
+	base      time.Duration
+	current   time.Duration
+	successes int
+}
+
+func newThrottle(cfg *Config) *throttle {
+	base := time.Millisecond * time.Duration(cfg.Interval)
+	t := &throttle{base: base, current: base}
+	if cfg.Throttle != nil {
+		c := *cfg.Throttle
+		if c.MaxInterval <= 0 {
+			c.MaxInterval = 60000
+		}
+		if c.Backoff <= 1 {
+			c.Backoff = 2
+		}
+		if c.Recover <= 0 {
+			c.Recover = 20
+		}
+		if c.Retries <= 0 {
+			c.Retries = 3
+		}
+		t.cfg = &c
+	}
+	return t
+}
+
+// setInterval 把 interval 换成 d，当前的间隔从 d 开始重新计算
+func (t *throttle) setInterval(d time.Duration) {
+	t.base, t.current, t.successes = d, d, 0
+}
+
+// delay 返回发送下一封邮件前需要等待的时间
+func (t *throttle) delay() time.Duration {
+	return t.current
+}
+
+// limited 判断是否为需要降低速度的限速响应，retries 为这封邮件已经重试的次数；
+// 421 之后服务器会断开连接，重试时 smtpSender 重新连接
+func (t *throttle) limited(err error, retries int) bool {
+	if t.cfg == nil || retries >= t.cfg.Retries {
+		return false
+	}
+	var e *textproto.Error
+	if errors.As(err, &e) {
+		return e.Code == 421 || e.Code == 450 || e.Code == 451
+	}
+	return false
+}
+
+// slowDown 增加发送间隔，返回新的间隔
+func (t *throttle) slowDown() time.Duration {
+	t.successes = 0
+	next := time.Duration(float64(t.current) * t.cfg.Backoff)
+	if next < time.Second {
+		next = time.Second
+	}
+	if max := time.Millisecond * time.Duration(t.cfg.MaxInterval); next > max {
+		next = max
+	}
+	t.current = next
+	return next
+}
+
+// record 记录发送结果，连续成功足够多封后逐步恢复发送速度，恢复时返回 true
+func (t *throttle) record(err error) bool {
+	if t.cfg == nil || t.current <= t.base {
+		return false
+	}
+	if err != nil {
+		t.successes = 0
+		return false
+	}
+	t.successes++
+	if t.successes < t.cfg.Recover {
+		return false
+	}
+	t.successes = 0
+	t.current = time.Duration(float64(t.current) / t.cfg.Backoff)
+	if t.current < t.base || t.current < time.Second {
+		t.current = t.base
+	}
+	return true
+}
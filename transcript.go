@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// smtpTranscript 在 --debug 时记录 SMTP 会话，AUTH 的凭据和邮件内容不记录
+type smtpTranscript struct {
+	mu   sync.Mutex
+	host string
+
+	// authPending 为服务器返回 334 后客户端的下一行是凭据
+	authPending bool
+	// data 为服务器对 DATA 返回 354 之后的邮件内容，bdat 为 BDAT 之后还没有写完的字节数
+	dataSent bool
+	data     bool
+	dataSize int
+	bdat     int
+
+	in, out []byte
+}
+
+// traceSMTP 替换 smtp.Client 读写用的缓冲区，经过的每一行都写入 debug 日志；STARTTLS 之后需要重新调用
+func traceSMTP(c *smtp.Client, t *smtpTranscript) {
+	if !debug {
+		return
+	}
+	c.Text.Reader.R = bufio.NewReader(io.TeeReader(c.Text.Reader.R, transcriptWriter(t.received)))
+	c.Text.Writer.W = bufio.NewWriter(flushWriter{c.Text.Writer.W, t})
+}
+
+type transcriptWriter func(p []byte)
+
+func (f transcriptWriter) Write(p []byte) (int, error) {
+	f(p)
+	return len(p), nil
+}
+
+// flushWriter 记录后写入原来的缓冲区并立即发出
+type flushWriter struct {
+	w *bufio.Writer
+	t *smtpTranscript
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	f.t.sent(p)
+	n, err := f.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, f.w.Flush()
+}
+
+func (t *smtpTranscript) received(p []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.in = append(t.in, p...)
+	for {
+		i := bytes.IndexByte(t.in, '\n')
+		if i < 0 {
+			return
+		}
+		line := strings.TrimRight(string(t.in[:i]), "\r")
+		t.in = t.in[i+1:]
+		if strings.HasPrefix(line, "334") {
+			t.authPending = true
+		}
+		if t.dataSent {
+			t.dataSent = false
+			t.data, t.dataSize = strings.HasPrefix(line, "354"), 0
+		}
+		logDebug("SMTP %s < %s", t.host, line)
+	}
+}
+
+func (t *smtpTranscript) sent(p []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for len(p) > 0 {
+		if t.bdat > 0 {
+			n := t.bdat
+			if n > len(p) {
+				n = len(p)
+			}
+			t.bdat -= n
+			p = p[n:]
+			if t.bdat == 0 {
+				logDebug("SMTP %s > <邮件内容 %s>", t.host, formatSize(int64(t.dataSize)))
+			}
+			continue
+		}
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			t.out = append(t.out, p...)
+			return
+		}
+		t.out = append(t.out, p[:i+1]...)
+		p = p[i+1:]
+		line := strings.TrimRight(string(t.out), "\r\n")
+		t.out = t.out[:0]
+		t.sentLine(line)
+	}
+}
+
+func (t *smtpTranscript) sentLine(line string) {
+	if t.data {
+		if line == "." {
+			t.data = false
+			logDebug("SMTP %s > <邮件内容 %s>", t.host, formatSize(int64(t.dataSize)))
+			logDebug("SMTP %s > .", t.host)
+		} else {
+			t.dataSize += len(line) + 2
+		}
+		return
+	}
+	upper := strings.ToUpper(line)
+	switch {
+	case t.authPending:
+		t.authPending = false
+		line = "****"
+	case strings.HasPrefix(upper, "AUTH "):
+		// 只保留认证方式，AUTH PLAIN 的初始响应中包含密码
+		if fields := strings.Fields(line); len(fields) > 2 {
+			line = fields[0] + " " + fields[1] + " ****"
+		}
+	case upper == "DATA":
+		t.dataSent = true
+	case strings.HasPrefix(upper, "BDAT "):
+		if fields := strings.Fields(line); len(fields) > 1 {
+			t.bdat, _ = strconv.Atoi(fields[1])
+			t.dataSize = t.bdat
+		}
+	}
+	logDebug("SMTP %s > %s", t.host, line)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+const transformFunction = "transform"
+
+// rowTransform 在渲染前调用 Lua 脚本中的 transform(row) 函数改写每一行，
+// 返回 false 时跳过该行，调用 error() 时中止
+type rowTransform struct {
+	mu    sync.Mutex
+	state *lua.LState
+	fn    lua.LValue
+}
+
+var transform *rowTransform
+
+// TransformAbort 为脚本调用 error() 中止处理
+type TransformAbort struct {
+	Err error
+}
+
+func (e *TransformAbort) Error() string {
+	return fmt.Sprintf(trErr("脚本中止处理：%s"), e.Err)
+}
+
+func loadTransform(file string) (*rowTransform, error) {
+	L := lua.NewState()
+	L.SetGlobal("parse_time", L.NewFunction(luaParseTime))
+	if err := L.DoFile(file); err != nil {
+		L.Close()
+		return nil, err
+	}
+	fn := L.GetGlobal(transformFunction)
+	if fn.Type() != lua.LTFunction {
+		L.Close()
+		return nil, fmt.Errorf(trErr("脚本中没有定义 %s(row) 函数"), transformFunction)
+	}
+	return &rowTransform{state: L, fn: fn}, nil
+}
+
+// apply 把 SendTo、Subject、Content 和其他列作为 row 的字段传给脚本，返回是否保留该行；
+// 脚本出错时返回 *TransformAbort，改写后的数据无效时返回普通错误
+func (t *rowTransform) apply(s *Send) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	L := t.state
+	row := L.NewTable()
+	for k, v := range s.Meta {
+		row.RawSetString(k, lua.LString(v))
+	}
+	row.RawSetString("SendTo", lua.LString(s.SendTo))
+	row.RawSetString("Subject", lua.LString(s.Subject))
+	if s.Content != nil {
+		row.RawSetString("Content", lua.LString(*s.Content))
+	}
+
+	if err := L.CallByParam(lua.P{Fn: t.fn, NRet: 1, Protect: true}, row); err != nil {
+		var apiErr *lua.ApiError
+		if errors.As(err, &apiErr) {
+			err = errors.New(apiErr.Object.String())
+		}
+		return false, &TransformAbort{Err: err}
+	}
+	ret := L.Get(-1)
+	L.Pop(1)
+
+	switch ret := ret.(type) {
+	case *lua.LNilType:
+	case lua.LBool:
+		if !ret {
+			return false, nil
+		}
+	case *lua.LTable:
+		row = ret
+	default:
+		return false, &TransformAbort{Err: fmt.Errorf(trErr("%s 返回了无效的值 %s"), transformFunction, ret.Type())}
+	}
+
+	s.Meta = map[string]string{}
+	s.Content = nil
+	s.SendTo, s.Subject = "", ""
+	var err error
+	row.ForEach(func(k, v lua.LValue) {
+		key, ok := k.(lua.LString)
+		if !ok || v == lua.LNil || err != nil {
+			return
+		}
+		val := v.String()
+		if _, ok := v.(*lua.LTable); ok {
+			err = fmt.Errorf(trErr("字段 %s 不能是 table"), key)
+			return
+		}
+		switch key {
+		case "SendTo":
+			s.SendTo = normalizeAddress(val)
+		case "Subject":
+			s.Subject = val
+		case "Content":
+			if len(val) > 0 {
+				s.Content = &val
+			}
+		default:
+			if len(val) > 0 {
+				s.Meta[string(key)] = val
+			}
+		}
+	})
+	if err != nil {
+		return false, err
+	}
+	if !validEmailAddress(s.SendTo) {
+		return false, fmt.Errorf(trErr("无效的收件人: %s"), maskAddress(s.SendTo))
+	}
+	if len(s.Subject) == 0 {
+		return false, errors.New(trErr("标题不能为空"))
+	}
+	return true, nil
+}
+
+// luaParseTime 按 SendAt 支持的格式解析时间，返回 Unix 时间戳，便于脚本计算天数
+func luaParseTime(L *lua.LState) int {
+	t, _, err := parseSendAt(L.CheckString(1), time.Local)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+	L.Push(lua.LNumber(t.Unix()))
+	return 1
+}
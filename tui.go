@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// tuiLog 收集界面运行期间的日志，避免打乱界面
+type tuiLog struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *tuiLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		l.lines = append(l.lines, line)
+	}
+	if len(l.lines) > 500 {
+		l.lines = l.lines[len(l.lines)-500:]
+	}
+	return len(p), nil
+}
+
+func (l *tuiLog) tail(n int) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.lines) < n {
+		n = len(l.lines)
+	}
+	return append([]string{}, l.lines[len(l.lines)-n:]...)
+}
+
+// watchPauseKeys 在不使用 --tui 且标准输入为终端时，输入 p 回车暂停或继续发送
+func watchPauseKeys(campaign *Campaign) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return
+	}
+	log.Printf(tr("输入 p 回车暂停或继续发送"))
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if strings.TrimSpace(scanner.Text()) != "p" {
+				continue
+			}
+			switch campaign.Snapshot().Status {
+			case campaignPaused:
+				campaign.Resume()
+				log.Printf(tr("继续发送"))
+			case campaignRunning:
+				campaign.Pause()
+				log.Printf(tr("发送完当前的邮件后暂停，输入 p 回车继续"))
+			}
+		}
+	}()
+}
+
+// runTUI 在终端界面中执行 run，直到发送结束
+func runTUI(campaign *Campaign, run func() error) error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		log.Printf(tr("不是终端，不使用交互界面"))
+		return run()
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+
+	logs := &tuiLog{}
+	log.SetOutput(logs)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- run()
+	}()
+
+	keys := make(chan []byte)
+	go func() {
+		buf := make([]byte, 16)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				return
+			}
+			keys <- append([]byte{}, buf[:n]...)
+		}
+	}()
+
+	scroll := 0
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	fmt.Print("\x1b[?25l")
+	defer func() {
+		fmt.Print("\x1b[?25h\r\n")
+		term.Restore(fd, state)
+		log.SetOutput(os.Stderr)
+		for _, line := range logs.tail(20) {
+			fmt.Fprintln(os.Stderr, line)
+		}
+	}()
+
+	for {
+		select {
+		case err := <-done:
+			drawTUI(campaign, logs, scroll)
+			return err
+		case key := <-keys:
+			switch {
+			case bytes.Equal(key, []byte("p")):
+				if campaign.Snapshot().Status == campaignPaused {
+					campaign.Resume()
+				} else {
+					campaign.Pause()
+				}
+			case bytes.Equal(key, []byte("q")), bytes.Equal(key, []byte{3}):
+				campaign.Cancel()
+			case bytes.Equal(key, []byte("\x1b[A")), bytes.Equal(key, []byte("k")):
+				if scroll > 0 {
+					scroll--
+				}
+			case bytes.Equal(key, []byte("\x1b[B")), bytes.Equal(key, []byte("j")):
+				scroll++
+			}
+			drawTUI(campaign, logs, scroll)
+		case <-ticker.C:
+			drawTUI(campaign, logs, scroll)
+		}
+	}
+}
+
+func drawTUI(campaign *Campaign, logs *tuiLog, scroll int) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width < 40 || height < 16 {
+		width, height = 80, 24
+	}
+
+	s := campaign.Snapshot()
+	var b strings.Builder
+	line := func(format string, v ...interface{}) {
+		text := fmt.Sprintf(format, v...)
+		b.WriteString(truncateWidth(text, width))
+		b.WriteString("\x1b[K\r\n")
+	}
+
+	done := s.Sent + s.Failed
+	percent := 0
+	if s.Total > 0 {
+		percent = done * 100 / s.Total
+	}
+	barWidth := width - 40
+	if barWidth < 10 {
+		barWidth = 10
+	}
+	filled := barWidth * percent / 100
+
+	b.WriteString("\x1b[H")
+	line(tr("邮件发送 %s  [%s]"), s.Name, s.Status)
+	line(tr("%s%s %3d%%  %d/%d  成功 %d  失败 %d"), strings.Repeat("█", filled), strings.Repeat("░", barWidth-filled), percent, done, s.Total, s.Sent, s.Failed)
+	if len(s.Current) > 0 {
+		line(tr("正在发送：%s"), s.Current)
+	} else {
+		line("")
+	}
+	line("")
+
+	// 剩余的行按 最近发送 / 失败列表 / 日志 分配
+	rows := height - 8
+	recentRows := rows / 2
+	failureRows := (rows - recentRows) / 2
+	logRows := rows - recentRows - failureRows
+
+	line(tr("最近发送："))
+	recent := s.Recent
+	if len(recent) > recentRows-1 {
+		recent = recent[len(recent)-(recentRows-1):]
+	}
+	for i := 0; i < recentRows-1; i++ {
+		if i < len(recent) {
+			r := recent[i]
+			line("  %5d  %-6s  %s  %s", r.Row, r.Status, r.SendTo, r.Subject)
+		} else {
+			line("")
+		}
+	}
+
+	if scroll > len(s.Failures)-1 {
+		scroll = len(s.Failures) - 1
+	}
+	if scroll < 0 {
+		scroll = 0
+	}
+	line(tr("失败列表（%d）："), len(s.Failures))
+	for i := 0; i < failureRows-1; i++ {
+		if scroll+i < len(s.Failures) {
+			r := s.Failures[scroll+i]
+			line("  %5d  %s  %s", r.Row, r.SendTo, r.Error)
+		} else {
+			line("")
+		}
+	}
+
+	line(tr("日志："))
+	tail := logs.tail(logRows - 1)
+	for i := 0; i < logRows-1; i++ {
+		if i < len(tail) {
+			line("  %s", tail[i])
+		} else {
+			line("")
+		}
+	}
+
+	line("")
+	b.WriteString(truncateWidth(tr("p 暂停/继续  q 取消  ↑/↓ 滚动失败列表"), width))
+	b.WriteString("\x1b[K")
+
+	os.Stdout.WriteString(b.String())
+}
+
+// truncateWidth 按显示宽度截断，中文等宽字符按两列计算
+func truncateWidth(s string, width int) string {
+	w := 0
+	for i, r := range s {
+		cw := 1
+		if isWideRune(r) {
+			cw = 2
+		}
+		if w+cw > width {
+			return s[:i]
+		}
+		w += cw
+	}
+	return s
+}
+
+func isWideRune(r rune) bool {
+	return r >= 0x1100 && r <= 0x115f ||
+		r >= 0x2e80 && r <= 0xa4cf ||
+		r >= 0xac00 && r <= 0xd7a3 ||
+		r >= 0xf900 && r <= 0xfaff ||
+		r >= 0xfe30 && r <= 0xfe4f ||
+		r >= 0xff00 && r <= 0xff60 ||
+		r >= 0xffe0 && r <= 0xffe6 ||
+		r >= 0x1f300 && r <= 0x1f64f ||
+		r >= 0x20000
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"gopkg.in/gomail.v2"
+)
+
+// UnsubscribeConfig 配置退订链接和退订名单，url 为收件人可以访问到的 serve / --http 地址
+type UnsubscribeConfig struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+	List   string `json:"list"`
+	Text   string `json:"text"`
+}
+
+const (
+	unsubscribeColumn  = "UnsubscribeURL"
+	statusUnsubscribed = "unsubscribed"
+)
+
+// unsubscribeList 为 --config 中配置的退订名单，发送时跳过名单中的收件人，
+// 监控页面收到退订请求后追加到名单文件中，同一个进程中之后发送的任务也会跳过
+type unsubscribeList struct {
+	mu     sync.Mutex
+	secret string
+	file   string
+	addrs  map[string]bool
+}
+
+var unsubscribes *unsubscribeList
+
+func loadUnsubscribeList(cfg *Config) error {
+	u := cfg.Unsubscribe
+	if u == nil {
+		return nil
+	}
+	if len(u.URL) > 0 && len(u.Secret) == 0 {
+		return errors.New(trErr("unsubscribe 配置了 url 时必须配置 secret"))
+	}
+	list := &unsubscribeList{secret: u.Secret, file: u.List, addrs: map[string]bool{}}
+	if len(u.List) > 0 {
+		data, err := readFileContent(u.List)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf(trErr("读取退订名单失败：%s"), err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if len(line) == 0 || strings.HasPrefix(line, "#") {
+				continue
+			}
+			list.addrs[unsubscribeKey(line)] = true
+		}
+		logDebug("退订名单中有 %d 个地址", len(list.addrs))
+	}
+	unsubscribes = list
+	return nil
+}
+
+func unsubscribeKey(addr string) string {
+	return strings.ToLower(normalizeAddress(addr))
+}
+
+func isUnsubscribed(addr string) bool {
+	if unsubscribes == nil {
+		return false
+	}
+	unsubscribes.mu.Lock()
+	defer unsubscribes.mu.Unlock()
+	return unsubscribes.addrs[unsubscribeKey(addr)]
+}
+
+// add 把地址加入退订名单，已经在名单中时返回 false
+func (l *unsubscribeList) add(addr string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := unsubscribeKey(addr)
+	if l.addrs[key] {
+		return false, nil
+	}
+	if len(l.file) > 0 {
+		f, err := os.OpenFile(l.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return false, err
+		}
+		_, err = fmt.Fprintln(f, key)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	l.addrs[key] = true
+	return true, nil
+}
+
+// unsubscribeToken 为地址和任务标识的 HMAC，防止伪造别人的退订链接
+func unsubscribeToken(secret, addr, campaignID string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsubscribeKey(addr) + "\n" + campaignID))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+func unsubscribeURL(cfg *Config, addr, campaignID string) string {
+	u := cfg.Unsubscribe
+	if u == nil || len(u.URL) == 0 {
+		return ""
+	}
+	query := url.Values{
+		"e": {base64.RawURLEncoding.EncodeToString([]byte(addr))},
+		"t": {unsubscribeToken(u.Secret, addr, campaignID)},
+	}
+	if len(campaignID) > 0 {
+		query.Set("c", campaignID)
+	}
+	return strings.TrimRight(u.URL, "/") + "/unsubscribe?" + query.Encode()
+}
+
+// tagUnsubscribe 把每个收件人的退订链接放到 {{ .UnsubscribeURL }} 中
+func tagUnsubscribe(cfg *Config, list []*Send) {
+	if cfg.Unsubscribe == nil || len(cfg.Unsubscribe.URL) == 0 {
+		return
+	}
+	for _, s := range list {
+		if s.Meta == nil {
+			s.Meta = map[string]string{}
+		}
+		s.Meta[unsubscribeColumn] = unsubscribeURL(cfg, s.SendTo, s.Meta[campaignIDColumn])
+	}
+}
+
+// unsubscribeText 返回页脚中退订链接的文本，正文中已经有这个链接时返回空
+func unsubscribeText(cfg *Config, contentType string, body []byte, data interface{}) string {
+	if cfg.Unsubscribe == nil {
+		return ""
+	}
+	meta, _ := data.(map[string]string)
+	link := meta[unsubscribeColumn]
+	escaped := html.EscapeString(link)
+	if len(link) == 0 || strings.Contains(string(body), link) || strings.Contains(string(body), escaped) {
+		return ""
+	}
+	text := cfg.Unsubscribe.Text
+	if len(text) == 0 {
+		text = "退订"
+	}
+	if contentType == "text/html" {
+		return fmt.Sprintf("<p style=\"font-size: 12px; color: #888;\"><a href=\"%s\">%s</a></p>\n", escaped, html.EscapeString(text))
+	}
+	return fmt.Sprintf("%s: %s\n", text, link)
+}
+
+func getUnsubscribeDecorator(cfg *Config) Decorator {
+	return func(m *gomail.Message, s *Send) error {
+		link := unsubscribeURL(cfg, s.SendTo, s.Meta[campaignIDColumn])
+		m.SetHeader("List-Unsubscribe", "<"+link+">")
+		m.SetHeader("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+		return nil
+	}
+}
+
+// handleUnsubscribe 处理退订链接：GET 显示确认页面，POST（包括邮箱客户端的一键退订）加入退订名单
+func handleUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	if unsubscribes == nil || len(unsubscribes.secret) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	query := r.URL.Query()
+	data, err := base64.RawURLEncoding.DecodeString(query.Get("e"))
+	addr, campaignID := string(data), query.Get("c")
+	expected := unsubscribeToken(unsubscribes.secret, addr, campaignID)
+	if err != nil || len(addr) == 0 || !hmac.Equal([]byte(query.Get("t")), []byte(expected)) {
+		http.Error(w, tr("退订链接无效"), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	switch r.Method {
+	case http.MethodGet:
+		// 邮件安全网关会预先打开链接，GET 只显示确认按钮
+		fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"><title>%s</title></head>\n<body>\n"+
+			"<p>%s</p>\n<form method=\"post\"><button type=\"submit\">%s</button></form>\n</body>\n</html>\n",
+			tr("退订"), html.EscapeString(fmt.Sprintf(tr("%s 将不再收到这类邮件"), addr)), tr("确认退订"))
+	case http.MethodPost:
+		added, err := unsubscribes.add(addr)
+		if err != nil {
+			log.Printf(tr("写入退订名单失败：%s"), err)
+			http.Error(w, tr("退订失败，请稍后再试"), http.StatusInternalServerError)
+			return
+		}
+		if added {
+			log.Printf(tr("%s 已退订，任务 %s"), maskAddress(addr), campaignID)
+		}
+		fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"><title>%s</title></head>\n<body>\n<p>%s</p>\n</body>\n</html>\n",
+			tr("退订"), html.EscapeString(fmt.Sprintf(tr("已退订，%s 将不再收到这类邮件"), addr)))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,732 @@
+package main
+
+// usageEnglish 为 --lang en 时的使用说明，与 usage() 中的中文说明保持一致
+const usageEnglish = `
+	Bulk email sender v0.1
+
+	Usage:
+		email-sender.exe [send | validate | preview | report | history | serve | queue | campaign | config | setup | watch | service | run | replay | import | resend-failures | follow-up | doctor | test-send | approve | lint] [--debug] --config config.json [--content content.txt | --template template.tpl] test.xlsx
+		email-sender.exe help [command]
+
+	Commands (the command comes before the options, and each command only accepts the options it uses;
+	see email-sender.exe <command> --help or help <command>):
+
+	send sends the emails, this is the default command
+
+	validate only checks the data without sending: validates every recipient address and the required columns,
+	  renders every row with the template and lists all problems by row
+
+	preview renders the emails without sending, e.g. email-sender.exe preview --config config.json --template t.tpl --row 5 list.xlsx
+	  prints the complete message (headers and attachments included) of the row given by --row (the first data row
+	  by default), exactly as it would be sent; with --render-out it writes every recipient's email to the directory,
+	  the same as send --render-out
+
+	report summarizes reports written by --report, e.g. email-sender.exe report list.report.jsonl retry.report.jsonl
+	  lists the number of recipients per status, the SMTP codes of failures and the failed recipients; when a recipient
+	  appears in several reports the later result wins, so put resend reports last to get the final result
+
+	history queries the send history configured by history in the config file, across all campaigns, e.g.
+	  email-sender.exe history --config config.json --since 30d --status failed
+	  --since is a duration such as 30d or 12h or a date in 2006-01-02 format, --status is one or more comma-separated
+	  statuses, --campaign-id lists only that campaign; --history gives the history database directly without a config
+	  file; every record is one line with the time, status, recipient, campaign, subject and error, followed by the
+	  number of records per status
+	  history lookup lists every email sent to one recipient, for handling complaints and data subject access
+	  requests, e.g. email-sender.exe history lookup --config config.json user@example.com
+	  with report_salt set the records only hold address hashes, which are compared using report_salt from the
+	  config file, and the records have no subjects
+
+	serve is the same as watch and also serves the dashboard, with --http defaulting to localhost:8080, which is
+	  only reachable from this machine, e.g.
+	  email-sender.exe serve --config config.json --template t.tpl inbox/
+
+	queue runs as a delivery worker, continuously reading send requests from the message queue given by queue in the
+	  config file and sending them, e.g.
+	  email-sender.exe queue --config config.json --template t.tpl --report queue.jsonl
+	  application servers put send requests (JSON) into the queue; id is optional and data holds the template fields,
+	  which may include columns such as SendAt and Attachments:
+	  {"id": "order-1001", "to": "user@example.com", "subject": "Your order has shipped", "data": {"Name": "Ann", "OrderID": 1001}}
+	  a non-empty content replaces the template as the body; up to batch requests are taken at a time and checked and
+	  sent as one campaign, the same as a data file, and acknowledged once sent; temporary failures such as connection
+	  errors and 4xx are retried after retry_delay seconds, doubling every time; permanent failures (5xx, bad data,
+	  unparseable requests) and requests failing more than retries times go to dead_letter with attempts and error
+	  added; on Ctrl+C / SIGTERM the current batch is finished before exiting, and unacknowledged requests are sent
+	  again on the next start
+
+	campaign controls running campaigns of serve or --http, with --server giving its address, localhost:8080 by
+	  default; when serve sets --http-token, give the same token with --http-token or the environment variable
+	  EMAIL_SENDER_HTTP_TOKEN, e.g.
+	  email-sender.exe campaign list
+	  email-sender.exe campaign pause 3
+	  email-sender.exe campaign rate --interval 2s 3
+	  list shows the number, status and progress of campaigns; pause pauses after the current email, resume resumes,
+	  cancel cancels, retry resends the failed emails of a finished campaign (appending the results to that
+	  campaign's report, without inserting seed emails again or counting them twice against the warm-up volume);
+	  rate changes the sending interval, with
+	  --interval a duration such as 2s or 500ms or a number of milliseconds, which also resets any interval added by
+	  throttling and takes effect before the next email; the same operations are available through the API:
+	  POST /api/campaigns/<number>/<action>, with the interval parameter for rate, returning the campaign's JSON status
+
+	config shows or checks the config file, e.g. email-sender.exe config show --config config.json
+	  show prints the config after merging extends and substituting environment variables, with fields such as
+	  password, secret and report_salt shown as ****; this is the default;
+	  check only checks that the config is valid (senders, attachments and so on) without connecting to the server
+
+	setup interactively creates the config file for first-time use, e.g. email-sender.exe setup --config config.json
+	  choose the preset server and port for 163, QQ, Gmail or Office365, or enter another server; after entering the
+	  username and password the connection and authentication are tested, and on failure a hint for the provider is shown
+	  (e.g. 163 / QQ mail need an authorization code) and you can try again;
+	  the password can be read from the EMAIL_SENDER_PASSWORD environment variable instead of being written to the file;
+	  the config file is only readable and writable by the current user
+
+	watch watches a directory, e.g. email-sender.exe watch --config config.json --template template.tpl inbox/
+	  When a new .xlsx / .xls / .ods / .csv file appears (and its size stops changing), the data is checked and then sent.
+	  A .json file with the same name (e.g. list.xlsx and list.json) is used as the config for that file,
+	  otherwise the config given by --config is used;
+	  processed files are moved to done/, files that fail the checks or cannot reach the server are moved to failed/,
+	  and the report is placed next to the data file;
+	  the files given by --content / --template are reloaded before the next data file when they change,
+	  and the previous version is kept if the new one fails to parse;
+	  language variants, --pdf-template and --vcard are read again for every data file
+
+	service runs the watch command as a system service, using systemd on Linux and a system service on Windows;
+	  logs go to the journal / event log
+	  email-sender.exe service install --config config.json --template template.tpl inbox/ installs and starts the service,
+	    the arguments are the same as for watch, and the current directory becomes the working directory of the service
+	  email-sender.exe service uninstall stops and uninstalls the service
+	  email-sender.exe service run ... is called by the service manager; on stop it waits for the current email to finish
+
+	run executes several campaigns from a job file, e.g. email-sender.exe run --config config.json monthly.json
+	  All campaigns are loaded and checked first, and sending starts only when all of them pass;
+	  with parallel set to true the campaigns are sent at the same time, otherwise one after another:
+	  {
+	    "parallel": false,
+	    "campaigns": [
+	      {
+	        "name": "Monthly bill",
+	        "data": "bill.xlsx",
+	        "template": "bill.tpl",
+	        "subject": "Your bill for {{ .Month }}",
+	        "config": "",
+	        "report": "bill.report.jsonl",
+	        "start_at": "2024-05-01 09:00",
+	        "campaign_id": "2024-05-bill"
+	      }
+	    ]
+	  }
+	  Relative paths are relative to the directory of the job file; without content / template the command line
+	  --content / --template are used, and without config --config is used; subject supports template syntax and
+	  replaces the subject from Excel when given;
+	  report defaults to a .report.jsonl file named after the data file in the directory of the job file;
+	  start_at is the time to start sending, and with --http waiting campaigns are shown on the dashboard and can be
+	  cancelled; campaign_id defaults to --campaign-id
+
+	replay resends the failed emails saved in the --spool directory, e.g. email-sender.exe replay --config config.json failed-mail/
+	  The emails are sent with the content and envelope addresses rendered originally, without the Excel file or template;
+	  emails sent successfully are removed from the directory, and those still failing are kept with the updated
+	  error and attempt count; use --report to record the results
+
+	import resends the emails in an mbox file or the .eml files in a directory as they are, e.g.
+	  email-sender.exe import --config config.json export.mbox
+	  The recipients are the addresses in the To, Cc and Bcc headers; Bcc, Return-Path, Delivered-To and
+	  X-Original-To are removed before sending and everything else is unchanged; the envelope sender is the
+	  configured from, or the email's From when none is configured; use --report to record the results, and with
+	  --spool the emails that still fail are saved to the directory to be resent later with replay
+
+	resend-failures resends only the recipients that failed in a previous report, with the same data file and template, e.g.
+	  email-sender.exe resend-failures --config config.json --template t.tpl --report retry.report.jsonl list.report.jsonl list.xlsx
+	  Failed rows are looked up in the data file by row number and recipient, or by recipient when the data file
+	  has changed and the row numbers moved;
+	  recipients that succeeded on a later retry in the report are not resent
+
+	follow-up sends a variant template to the recipients of a previous campaign who did not open it, with the same data file, e.g.
+	  email-sender.exe follow-up --config config.json --template reminder.tpl --engaged opened.csv --after-days 3 --report followup.report.jsonl may.report.jsonl list.xlsx
+	  only recipients that were sent successfully in the original report, more than --after-days days ago (3 by default),
+	  and are not in the --engaged lists get the follow-up; --engaged lists the recipients who opened or clicked,
+	  either a text file with one address per line or a CSV exported from open tracking or the mail provider, taking the
+	  first address of every line; it can be given several times; add --thread-report may.report.jsonl to show the
+	  follow-up in the same conversation as the original email
+
+	doctor checks the DNS records of the sender domains in the config (from and from_pool), e.g. email-sender.exe doctor --config config.json
+	  SPF: whether there is a record, whether the configured SMTP server is authorized (checked by the IPs the server
+	    resolves to, which may be a false alarm when the provider uses other outbound IPs), and whether it needs more
+	    than 10 DNS lookups; with verp the domain of the envelope sender is checked
+	  DKIM: whether there is a DKIM key under the common selectors (default, selector1, google, ...),
+	    use --dkim-selector to specify others
+	  DMARC: whether there is a record, its policy, and whether the envelope sender aligns with the From domain
+	  Exits with a non-zero status when there are problems
+
+	test-send renders the email for one row and sends a single email to the test address given by --to,
+	  ignoring the SendTo column, e.g.
+	  email-sender.exe test-send --config config.json --template t.tpl --to me@example.com --row 5 list.xlsx
+	  --row is the row number in Excel, defaulting to the first data row; --fixture uses a JSON file as the row data
+	  instead, without an Excel file:
+	  email-sender.exe test-send --config config.json --template t.tpl --to me@example.com --fixture sample.json
+	  sample.json is e.g. {"Subject": "Bill for May", "Name": "Alice", "Month": 5}; Subject can also be given with --subject
+
+	lint checks that the fields referenced in the templates match the columns of the data file, listing fields without
+	  a column (e.g. a misspelled {{ .Nmae }}, which renders as <no value> when sending) and columns the templates
+	  never use, e.g.
+	  email-sender.exe lint --config config.json --template t.tpl --subject "Bill for {{ .Name }}" list.xlsx
+	  --template, --subject, --pdf-template and --vcard are checked; mustache / handlebars / pongo2 templates are
+	  searched as text, so the result is only a hint
+
+	approve approves a campaign staged with --hold; it must be run by someone other than the person who staged it, e.g.
+	  email-sender.exe approve --approver lisi staged/2024-05/
+	  --approver defaults to the current login user; before approving, review the per-recipient previews under
+	  preview/ and manifest.json in the directory
+
+	Options:
+
+	--debug prints detailed logs, including the full SMTP session (EHLO, authentication result, the response to every
+	  recipient); credentials and message content are not printed
+	--redact hides recipient addresses in logs, e.g. alice@example.com is shown as a***@example.com, including addresses
+	  in errors returned by the server; message content is only printed with --debug, and the fake sender only prints
+	  the recipients and message size. Reports, the spool directory and other files keep the full addresses
+
+	--lang language of prompts, logs and this help, zh or en; defaults to en when LC_ALL / LC_MESSAGES / LANG
+	  starts with en, otherwise zh
+	--error-lang language of errors written to reports, notifications, metrics and rejected rows, zh or en,
+	  defaults to --lang; fix it when monitoring systems match on error text regardless of the operators' language
+
+	--help shows this help
+
+	--config path of the config file
+
+	--content path of the email content file, which may contain html; conflicts with --template, only one can be used
+
+	--template path of the email template file, which may contain html; conflicts with --content, only one can be used
+	  --content / --template can also be a Word document (.docx), which is converted to HTML as the email content,
+	  keeping headings, bold, italic, underline, links and tables; placeholders like {{ .Name }} can be written in
+	  the document directly; images and other content are ignored
+
+	--header / --no-header specify whether the first row of the data file is a header; by default the first row is a
+	  header when it has both SendTo and Subject columns, and a warning is given when it looks like an incomplete
+	  header (e.g. only Subject, or the wrong case in column names)
+
+	--content-type the email content type, text/plain or text/html; by default content containing < and > is html;
+	  the ContentType column in Excel can set it per row
+
+	--subject the default email subject, used when the Subject column is empty or missing; supports template syntax,
+	  e.g. --subject "{{ .Name }}, your bill for {{ .Month }}"; required when the data file is a contacts file (.vcf)
+
+	--force-subject ignores the Subject column in Excel, all emails use the subject from --subject
+
+	--subject-prefix / --subject-suffix text added before / after every subject, e.g. --subject-prefix "[TEST] " to mark
+	  test sends without editing the spreadsheet; nothing is added when the subject already starts / ends with it;
+	  previews, approvals and reports show the tagged subject too;
+	  defaults to subject_prefix / subject_suffix in the config
+
+	--pdf-template path of the HTML template for a PDF attachment, rendered for every recipient and converted to PDF
+
+	--pdf-name file name of the PDF attachment, supports template syntax, defaults to attachment.pdf
+
+	--attach a file attached to every email, can be given several times; can be an http:// or https:// URL
+	  (as well as s3:// / oss://), downloaded on the first send and reused for the following emails;
+	  append #sha256=<hex checksum> to verify the downloaded content;
+	  the Attachments column in Excel sets attachments per row in the same format, separated by ;,
+	  e.g. https://bucket.example.com/invoices/1001.pdf#sha256=9f86d0...;./terms.pdf
+
+	--vcard path of a vCard file (.vcf) sent as an attachment; the file supports template syntax and can use the
+	  custom columns in Excel
+
+	--qrcode content of a QR code, supports template syntax, e.g. "https://example.com/checkin/{{ .TicketID }}";
+	  the QR code is attached as an inline image, referenced in html templates with <img src="cid:qrcode.png">
+
+	--qrcode-size size of the QR code image, defaults to 256 pixels
+
+	--lang-pattern naming rule of language variants of templates, defaults to {dir}/{name}.{lang}{ext};
+	  when Excel has a Lang column, e.g. Lang is en, --template mail/welcome.tpl uses mail/welcome.en.tpl,
+	  falling back to the file given by --content / --template when it does not exist
+
+	--engine the template engine for --template, the subjects of run campaigns and --pdf-template, --pdf-name,
+	  --vcard, --qrcode and invite, defaults to go, also mustache, handlebars or pongo2; mustache / handlebars
+	  templates access Excel columns with {{ Name }}, e.g. {{#if Company}}{{ Company }}{{/if}};
+	  pongo2 uses Django / Jinja style syntax with filters and {% extends "base.html" %} inheritance, looking up
+	  extended and included templates relative to the directory of --template,
+	  e.g. {{ Name|title }}, {% if Company %}{{ Company }}{% endif %}
+
+	--transform a Lua script whose transform(row) function processes every row before rendering;
+	  the fields of row are SendTo, Subject, Content and the custom columns in Excel, which can be changed or added;
+	  return false to skip the row, call error("reason") to abort sending; parse_time(value) converts a date to a
+	  Unix timestamp, e.g.
+	    function transform(row)
+	      if row.Expire == nil then return false end
+	      row.DaysLeft = math.floor((parse_time(row.Expire) - os.time()) / 86400)
+	    end
+
+	--check-html checks the HTML email of the first recipient before sending (and in validate): unclosed tags,
+	  images without alt, and every link and image URL with a HEAD request; nothing is sent when there are
+	  problems (e.g. 404)
+
+	--estimate-size renders every email before sending (and in validate), prints the size distribution (min, median,
+	  95%, max) and the total transfer size, and lists emails more than 3 times the median and over 100 KB,
+	  e.g. an accidentally embedded huge image
+
+	--sanitize cleans the HTML content before sending (both the Content column and rendered templates): removes
+	  script, iframe, object and similar elements, event attributes like onclick, and dangerous links such as
+	  javascript:, vbscript: and data:; meant for content coming from user submitted data
+
+	--render-out a directory; the rendered subject and content for every recipient are written to a file each
+	  for review before sending, no email is sent
+
+	--spool a directory; failed emails (including headers) are saved as .eml files, with a .json file beside each
+	  recording the envelope addresses and the error; after fixing the problem (e.g. quota, authentication)
+	  resend them with the replay command
+
+	--archive a directory; emails sent successfully (including the final headers) are saved as .eml files, in a
+	  subdirectory named after the campaign ID and start time for every run, with files named after the row number
+	  and recipient, for auditing what a recipient actually received;
+	  when it ends with .zip a zip file is written instead, e.g. --archive archive/sent.zip creates
+	  archive/sent-<campaign ID>-<start time>.zip
+
+	--report path of the report file, one JSON line per recipient with the row number, recipient, status and error
+
+	--hold a directory; sending needs approval by someone else first: the first run only writes a preview of every
+	  email and manifest.json to the directory without sending; after the approver runs the approve command,
+	  run again with the same arguments to start sending, e.g.
+	  email-sender.exe --config config.json --template t.tpl --hold staged/2024-05/ list.xlsx
+	  when the data, template or config change after approval (the rendered emails differ) it must be approved
+	  again; each approval can only be sent once, and --hold-expiry sets how long an approval is valid,
+	  defaulting to 24h, after which it must be staged again
+
+	--on-missing what to do when a field referenced in a template does not exist (no such column or an empty cell):
+	  default keeps the default behaviour, text templates like --subject print <no value>, HTML email templates and
+	  other engines print nothing; empty always prints nothing;
+	  error fails the row, the body and attachment templates of all rows are checked before sending and nothing
+	  is sent when there are problems, with --skip-bad-rows those rows are skipped and written to --rejected-out;
+	  the validate command lists these rows
+
+	--metrics-out a JSON file; after sending, overall statistics are written for reporting tools: start and end
+	  time, duration, counts by status (by_status), the distribution of failed SMTP codes (error_codes),
+	  emails sent per minute (throughput) and the average rate; all campaigns of the run command are aggregated
+	  into one file
+
+	--campaign-id the campaign ID, written to the X-Campaign-ID header of every email, every log line and the report;
+	  templates can use it with {{ .CampaignID }}, e.g. https://example.com/track?c={{ .CampaignID }}
+
+	--skip-already-sent a previous report; recipients already sent successfully with the same campaign ID are skipped,
+	  can be given several times; skipped recipients are recorded as already_sent in the new report,
+	  which can be the same file as the previous report,
+	  e.g. --campaign-id 2024-05 --skip-already-sent report.jsonl --report report.jsonl
+
+	--thread-report a previous campaign's report; In-Reply-To and References of follow-up emails are set to the
+	  Message-ID of the email previously sent to the same recipient, so they appear in the same conversation in the
+	  recipient's mailbox; can be given several times, later reports take precedence;
+	  every email gets a Message-ID when sent, and successful sends record it as message_id in the report, e.g.
+	  email-sender.exe send --config config.json --template followup.tpl --subject "Re: May event" --thread-report may.report.jsonl list.xlsx
+
+	--skip-disposable skips recipients with disposable addresses (mailinator.com and others), recorded as disposable
+
+	--disposable-domains a file listing disposable domains, one per line, lines starting with # are comments;
+	  replaces the built-in list
+
+	--dedupe sends only once to each recipient, later duplicate rows are recorded as duplicate in the report;
+	  recipient addresses are trimmed and their domains lowercased when read, duplicates are compared case
+	  insensitively, and an internationalized domain equals its punycode form
+	  (e.g. user@例え.jp and user@xn--r8jz45g.jp)
+
+	--skip-bad-rows rows with problems in the data file (e.g. an invalid address or empty subject) no longer abort the
+	  whole run; they are skipped and listed in the log and the other rows are sent; without it sending stops at
+	  the first bad row
+
+	--rejected-out a file (.csv or .xlsx); rows that were not sent are written with their original columns plus a
+	  Reason column: rows that failed to parse, rows skipped by the --transform script, disposable addresses,
+	  emails rejected by pre_send_hook and emails over the size limit; after fixing, this file alone can be
+	  resent; validate only writes rows that failed to parse
+
+	--warmup a warm-up progress file for a new sending domain or IP: each day only the count for that day from
+	  warmup_schedule is sent (defaults to 50, 100, 250, 500, 1000, 2000, 5000, then the last count), stopping
+	  at the limit; run again every day with the same arguments and recipients already sent are skipped,
+	  e.g. email-sender.exe --config config.json --template t.tpl --warmup warmup.json list.xlsx
+	  while sending a line is appended to warmup.json.log for every email and merged into warmup.json on the next
+	  run; seed emails from seed_list do not count against the volume
+
+	--send-local-time sends at this time in the recipient's time zone (the Timezone column, or the local time zone),
+	  e.g. 09:00: rows without SendAt are sent at the next 9 o'clock in the recipient's time zone, and a SendAt
+	  with only a date is sent at 9 o'clock that day
+
+	--dedupe-gmail ignores . and anything after + in Gmail addresses when looking for duplicates,
+	  e.g. John.Doe+news@gmail.com and johndoe@gmail.com are the same recipient
+
+	--watch-interval how often the watch command checks the directory, defaults to 5s
+
+	--http listen address of the monitoring page, e.g. localhost:8080; the page shows running and finished campaigns,
+	  progress and failures, and can pause, resume or cancel campaigns or resend failed emails;
+	  mainly for the watch command
+
+	--http-token access token of the monitoring page and the API, also settable with the environment variable
+	  EMAIL_SENDER_HTTP_TOKEN; required when listening on an address reachable from other machines such as :8080;
+	  opening http://<address>/?token=<token> in a browser keeps the token in a cookie, and the API takes an
+	  Authorization: Bearer <token> header; /unsubscribe needs no token; actions such as pause and cancel only accept
+	  POST and reject forms submitted from other sites
+
+	--tui shows progress, recent recipients and failures in an interactive terminal UI;
+	  p pauses / resumes, q cancels, ↑/↓ scroll the failure list; without --tui, type p and Enter in the terminal
+	  to pause / resume
+
+	Pausing: send SIGUSR1 to the process while sending (kill -USR1 <pid>) to pause all campaigns after the current
+	  email, and SIGUSR2 to resume; the process and the report are unaffected, and connections closed by the server
+	  while paused are reopened automatically on resume (on Windows use the p key or the monitoring page)
+
+	--workdir the working directory, relative paths are relative to it
+
+	Config file example:
+	{
+	  "host": "smtp.163.com",
+	  "port": 465,
+	  "username": "helloworld_hyx@163.com",
+	  "password": "--PASSWORLD--",
+	  "from": "helloworld_hyx@163.com",
+	  "interval": 200,
+	  "sender": "fake"
+	}
+
+	* local_addr the local IP address or network interface used to connect to the SMTP server, for servers with
+	  several outbound IPs, e.g. "local_addr": "203.0.113.10" or "local_addr": "eth1"; needed when SPF only
+	  authorizes one of the addresses
+
+	* local_name the host name used in EHLO / HELO, defaults to localhost; some servers require it to match the
+	  forward and reverse DNS of the connecting IP, e.g. "local_name": "mail.ourdomain.com"
+
+	* with pool a connection pool is used: campaigns running at the same time (e.g. parallel campaigns of the run
+	  command, batches in watch mode) share connections to the same server instead of each connecting again:
+	  "pool": {"min": 1, "max_idle": 2, "max": 4, "idle_timeout": 300, "max_messages": 100}
+	  min is the number of connections always kept; max is the maximum number of open connections (default 4),
+	  after which campaigns wait for a free one; max_idle is the maximum number of idle connections kept;
+	  connections idle for more than idle_timeout seconds (default 300) are closed, and connections idle for a
+	  while are checked with NOOP before use; each connection reconnects after max_messages emails,
+	  unlimited when not set
+
+	* with notify a notification is sent to a Slack, DingTalk or WeCom bot or another webhook when a campaign starts,
+	  completes or aborts (cancelled or failed):
+	  "notify": [
+	    {"type": "dingtalk", "url": "https://oapi.dingtalk.com/robot/send?access_token=...", "secret": "SEC..."},
+	    {"type": "wecom", "url": "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=...", "events": ["complete", "abort"]},
+	    {"type": "slack", "url": "https://hooks.slack.com/services/...", "report_url": "https://files.ourcorp.com/reports/{{ .Report }}"}
+	  ]
+	  notifications include the campaign name, the total, sent, failed and skipped counts, the duration and the
+	  report file; events can be start, complete and abort, all by default;
+	  secret is the signing secret of the DingTalk bot; report_url is the link to the report, {{ .Report }} being the
+	  report file name;
+	  with an empty type a JSON with event, status, total, sent, failed, skipped, report and other fields is POSTed;
+	  with type email the notification is sent with the same SMTP config to the addresses in to (separated by ,),
+	  with the report attached when the campaign ends:
+	  {"type": "email", "to": "ops@ourcorp.com, manager@ourcorp.com", "events": ["complete", "abort"]}
+
+	* with dsn, delivery status notification parameters are added to MAIL FROM / RCPT TO when the server supports
+	  the DSN extension, and ignored otherwise:
+	  "dsn": {
+	    "ret": "HDRS",
+	    "notify": "FAILURE,DELAY",
+	    "envid": "{{ .CampaignID }}"
+	  }
+	  ret is FULL or HDRS; notify is NEVER or a combination of SUCCESS, FAILURE and DELAY;
+	  envid supports template syntax and defaults to --campaign-id; bounces include the envid and the original
+	  recipient address
+
+	* with from_pool the senders in the pool are used in turn; senders with a segment are only used for rows with the
+	  same Segment column in Excel, and other rows use the senders without a segment in turn (from is used when
+	  all of them have a segment):
+	  "from_pool": [
+	    {"from": "Account Manager <vip@example.com>", "segment": "vip"},
+	    {"from": "News <news1@example.com>"},
+	    {"from": "News <news2@example.com>"}
+	  ]
+	  the addresses in the pool must have the same domain as the login account (username, or from when username
+	  is not an email address)
+
+	* with verp every email uses its own envelope sender (Return-Path), so bounces come back to an address containing
+	  the recipient, e.g. with "verp": "bounce@ourdomain.com" the envelope sender of the email to user@example.com
+	  is bounce+user=example.com@ourdomain.com while From stays the same; the bounce mailbox must support
+	  + suffixes
+
+	* bcc_archive blind copies every email to this address for archiving, e.g. "bcc_archive": "archive@ourcorp.com";
+	  the archive address is only an envelope recipient and never appears in the headers recipients see;
+	  when the server rejects the archive address the email is recorded as failed
+
+	* throttle adjusts the sending interval from the server responses: on 421 / 450 / 451 (usually meaning too fast)
+	  the email is retried after a wait and the interval is multiplied by backoff (default 2, at least 1 second,
+	  at most max_interval milliseconds, default 60000); one email is retried at most retries times (default 3);
+	  after every recover consecutive successes (default 20) the interval is divided by backoff until it is back
+	  to interval:
+	  "throttle": {"max_interval": 60000, "backoff": 2, "recover": 20, "retries": 3}
+
+	* with greylist_delay, recipients temporarily rejected by the server with 450 / 451 (greylisting) are not recorded
+	  as failed but retried greylist_delay seconds after the other recipients were sent, at most greylist_retries
+	  times (default 2):
+	  "greylist_delay": 300,
+	  "greylist_retries": 2
+
+	* with pre_send_hook the command is run before every email, with the recipient, sender, subject and Excel columns
+	  passed as JSON on standard input; when the command exits with a non-zero status the email is skipped and
+	  its output is recorded as the reason in the report, e.g. to check the live unsubscribe status:
+	  "pre_send_hook": ["/usr/local/bin/check-unsubscribed", "--list", "news"],
+	  "pre_send_hook_timeout": 10
+	  standard input is e.g. {"row": 2, "send_to": "user@example.com", "from": "me@example.com", "subject": "...", "meta": {"Name": "..."}};
+	  the email is also skipped when the command runs longer than pre_send_hook_timeout seconds (default 10)
+	  or cannot be run
+
+	* with spam_check the email of the first recipient is scored by SpamAssassin (spamd) or Rspamd before sending,
+	  printing the score and matched rules; with threshold nothing is sent when the score reaches it,
+	  and validate checks it too:
+	  "spam_check": {"spamd": "127.0.0.1:783", "threshold": 5}
+	  "spam_check": {"rspamd": "http://127.0.0.1:11333", "password": "", "threshold": 6}
+
+	* max_message_size the maximum size of an email (including attachments) in bytes; when the server announces SIZE in
+	  EHLO the smaller one is used; emails over the limit are not sent and recorded as too_large in the report;
+	  validate also checks max_message_size:
+	  "max_message_size": 20971520
+
+	* with zip_attachments, PDF attachments larger than threshold bytes are compressed into a .zip with the same name;
+	  with password the archive is password protected (ZipCrypto); password supports template syntax so every
+	  recipient can have a different password:
+	  "zip_attachments": {"threshold": 1048576, "password": "{{ .IDCard }}"}
+	  a threshold of 0 always compresses
+
+	* --pdf-template converts to PDF with the locally installed Chrome / Chromium (headless) by default; the browser is
+	  started for the first PDF and shared by the following recipients. Without Chrome, pdf_converter switches to an
+	  external command, {input} / {output} are replaced with the HTML and PDF file paths, e.g. wkhtmltopdf:
+	  "pdf_converter": ["wkhtmltopdf", "--quiet", "{input}", "{output}"]
+
+	* with invite every email carries a calendar invitation; every field supports template syntax and can use the
+	  custom columns in Excel:
+	  "invite": {
+	    "summary": "{{ .Course }} training",
+	    "start": "2024-05-01 09:00",
+	    "end": "2024-05-01 11:00",
+	    "timezone": "Asia/Shanghai",
+	    "location": "{{ .Room }}",
+	    "description": "",
+	    "organizer": ""
+	  }
+	  organizer defaults to from, timezone defaults to the local time zone
+
+	* with imap a copy of every email sent successfully is saved to the sent folder over IMAP:
+	  "imap": {
+	    "host": "imap.163.com",
+	    "port": 993,
+	    "username": "",
+	    "password": "",
+	    "folder": "Sent"
+	  }
+	  username / password default to the SMTP ones, port defaults to 993, folder defaults to Sent
+
+	* with image_upload, local images referenced by <img src="..."> in --content / --template (relative to the template
+	  directory) are uploaded to object storage before sending, and src is replaced with the public URL under
+	  base_url instead of embedding the image, to keep emails small; files are named after the SHA-256 of their
+	  content, so changed images are uploaded as new files:
+	  "image_upload": {
+	    "target": "s3://bucket/mail-images",
+	    "base_url": "https://cdn.example.com/mail-images",
+	    "acl": "public-read"
+	  }
+	  target can also be oss://bucket/prefix, using the access keys from the s3 / oss config; without base_url the
+	  object storage URL is used; images whose URL contains template syntax are not uploaded
+
+	* extends a config file to inherit, e.g. shared SMTP settings in base.json with each campaign config only
+	  containing the fields that differ:
+	  {"extends": "base.json", "from": "news@example.com", "interval": 500}
+	  it can also be several files ["smtp.json", "dkim.json"], merged in order with later ones overriding earlier
+	  ones; fields of objects are merged one by one and arrays are replaced as a whole;
+	  relative paths are relative to the directory of the current config file
+
+	* strings in the config file can reference environment variables: ${NAME} is replaced with the value of NAME and
+	  is an error when it is not set, ${NAME:-default} uses the default when it is not set or empty,
+	  and $${ means ${ itself, e.g.:
+	  "host": "${SMTP_HOST:-smtp.example.com}",
+	  "port": "${SMTP_PORT:-465}",
+	  "password": "${SMTP_PASSWORD}"
+	  a value consisting of a single reference that is a number or true / false is treated as a number or boolean,
+	  so the config file does not need to contain the password
+
+	* seed_list internal seed mailboxes for observing whether emails land in the inbox at Gmail, Outlook, QQ and others:
+	  "seed_list": {"addresses": ["seed@gmail.com", "seed@outlook.com", "seed@qq.com"], "every": 1000}
+	  after the first email and then every every emails, one email with the same row data is sent to each seed
+	  mailbox; with every set to 0 they are only sent once at the start;
+	  these emails have seed set to true in the report and are never resent by resend-failures
+
+	* report_salt the salt for reports; with it the report contains neither recipient addresses nor subjects,
+	  send_to_hash being the hex value of HMAC-SHA256(report_salt, lowercased recipient address), and addresses in
+	  errors are replaced with hashes too; the report can be shared with analytics vendors, and anyone holding the
+	  salt can compute the hashes to join it with their own data;
+	  --skip-already-sent and resend-failures need the same report_salt to read such reports
+
+	* subject_prefix / subject_suffix text added before / after every subject, e.g. an internally required "[Marketing] "
+	  tag; --subject-prefix / --subject-suffix on the command line take precedence
+
+	* footer a footer appended to the body of every email, e.g. a legal disclaimer, without copying it into every template:
+	  "footer": {"html": "footer.html", "text": "footer.txt"}
+	  html is used for HTML emails and placed before </body>, text is used for plain text emails; when only text is set,
+	  HTML emails use the escaped text; the footer is a template itself and can use the same fields as the email
+	  template, e.g. {{ .Name }}, {{ .CampaignID }}; bodies given by the Content column get the footer too
+
+	* signatures several signatures; each row picks one by its Signature column, or by its Owner column (e.g. the account
+	  manager) when there is no Signature column or it is empty, and the signature named default when both are empty;
+	  without a default signature such rows get none; names not found are looked up again ignoring case:
+	  "signatures": {"zhangsan": {"html": "sig/zhangsan.html", "text": "sig/zhangsan.txt"}, "default": {"text": "sig/team.txt"}}
+	  signatures are templates like footer, appended after the body and before the footer; rows whose signature does
+	  not exist are not sent, use --skip-bad-rows to skip them
+
+	* unsubscribe unsubscribe links and the unsubscribe list:
+	  "unsubscribe": {"url": "https://mail.example.com", "secret": "random string", "list": "unsubscribed.txt", "text": "Unsubscribe"}
+	  each recipient's link is url/unsubscribe?e=...&t=..., where t is an HMAC of the recipient address and --campaign-id
+	  with secret, so links cannot be forged; the link is added to the footer (unless the template already places it
+	  with {{ .UnsubscribeURL }}), and the List-Unsubscribe and List-Unsubscribe-Post headers are set for one-click
+	  unsubscribe in mail clients; text is the link text in the footer, "退订" by default;
+	  url points at the dashboard of the serve command or --http, and only /unsubscribe needs to be reachable by
+	  recipients (--http then listens on an address reachable from other machines, and --http-token must protect the
+	  dashboard and the API); opening the link shows a confirm button, and after confirming the address is appended to the list
+	  file (one address per line); recipients in the list are skipped when sending with status unsubscribed in the
+	  report; with only list set, the listed recipients are skipped and no links are generated
+
+	* history the local SQLite send history database, created when missing; a record is inserted for every email
+	  sent with the campaign, recipient, Message-ID, status and time (only address hashes when report_salt is set),
+	  indexed by campaign, recipient, status and time; different campaigns on one machine can share the database,
+	  but SQLite does not support several machines writing to it on a shared directory;
+	  frequency_caps limits the emails each recipient gets according to the history, e.g.
+	  "history": "C:/mail/history.db", "frequency_caps": [{"max": 2, "days": 7}, {"max": 5, "days": 30}]
+	  means at most 2 emails in 7 days and 5 in 30 days per recipient across all campaigns; it is checked before every
+	  email, counting emails sent by other processes running at the same time, and recipients over the cap are not
+	  sent, with status frequency_capped in the report
+
+	* distributed lets several machines send one campaign together, each running the send command with the same
+	  config, data file and --campaign-id:
+	  "distributed": {"redis": "redis://:password@10.0.0.5:6379/0", "lease_size": 50, "lease_timeout": 60, "max_per_second": 20}
+	  the data is split into ranges of lease_size rows (50 by default) and each machine leases ranges nobody has sent
+	  from Redis; when a machine exits, ranges not renewed within lease_timeout seconds (60 by default) are taken over
+	  by the other machines, which send the rows not sent yet; max_per_second is the combined limit of emails per
+	  second across all machines, while interval is still each machine's own interval; every machine waits for the
+	  others to finish before exiting, and with --report the report holds the results of all machines (without the
+	  recipients skipped before sending); the progress in Redis is kept for a week, so sending the same campaign
+	  again needs a new --campaign-id
+
+	* queue the message queue the queue command reads send requests from; url supports Redis, Kafka, NATS, AMQP and SQS:
+	  "queue": {"url": "redis://:password@10.0.0.5:6379/0", "name": "mail", "batch": 20, "retries": 3, "retry_delay": 60, "dead_letter": "mail:dead"}
+	  producers LPUSH requests onto the list name; batch defaults to 20, retries to 3, retry_delay to 60 and
+	  dead_letter to name:dead; requests taken are kept in name:processing:<consumer> until acknowledged, with
+	  consumer defaulting to the host name, so several machines can work on one queue; requests waiting to be
+	  retried are kept in the sorted set name:delayed; with url kafka://[user:password@]host:9092[,host:9092...] requests
+	  are read from the topic name (kafkas:// uses TLS, and SASL PLAIN authentication is used when a user is given),
+	  with offsets committed to the consumer group (group, email-sender by default); a new group starts from the
+	  latest messages, offsets only move past a message once it is acknowledged, and after exiting reading resumes
+	  from the earliest unacknowledged message; when several machines work on one topic, give each its own
+	  partitions, e.g. "partitions": [0, 1]; messages waiting to be retried are kept in memory, dead_letter is another
+	  topic, name.dead by default, and only uncompressed and gzip-compressed messages are supported; with url
+	  nats://[user:password@]host:4222 the subject name is subscribed to (tls:// uses TLS) with group as the queue
+	  group, so machines in one group share the messages, and messages waiting to be retried are kept in memory;
+	  when stream is set JetStream is used, reading the subject name through a durable consumer named group created
+	  on the stream, messages are only removed once acknowledged and retries are redelivered by the server after
+	  the delay; dead_letter is the subject failed requests are published to, name.dead by default; with url
+	  amqp://[user:password@]host:5672[/vhost] requests are read from the RabbitMQ queue name (amqps:// uses TLS)
+	  and acknowledged once handled, with prefetch the most unacknowledged messages, the same as batch by default
+	  and at most 1000; requests waiting to be retried go to the queue name.retry, where RabbitMQ moves them back
+	  to name once they expire, dead_letter is another queue, name.dead by default, and both queues are created
+	  when missing; unacknowledged requests are redelivered by RabbitMQ after a disconnect; with url
+	  sqs://[access key id:secret@]region/account id requests are read from the AWS SQS queue name, using
+	  AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN when no key is given, and AWS_ENDPOINT_URL can
+	  point at an SQS-compatible service; the visibility timeout is extended while sending, messages are deleted
+	  once sent and retried by changing their visibility timeout (at most 12 hours), attempts are SQS's receive
+	  count, so the maxReceiveCount of the queue's redrive policy should be larger than retries; dead_letter is the
+	  name or queue url of another queue, name-dead by default
+
+	* defaults default values of columns, used when a cell is empty or the column is missing, applied before rendering
+	  templates and checking require, e.g.:
+	  "defaults": {"Name": "Valued customer", "Discount": "10%"}
+
+	* require columns that must not be empty, column_types the formats of columns (int, number, date, email, url), e.g.:
+	  "require": ["Name", "OrderID"],
+	  "column_types": {"OrderID": "int", "Amount": "number", "Expire": "date"}
+	  all rows are checked before sending, listing the empty columns and badly formatted values of every row, and
+	  nothing is sent when there are problems; with --skip-bad-rows those rows are skipped;
+	  validate checks them too
+
+	* the data file and the files given by --content / --template / --pdf-template / --vcard can be files in object
+	  storage, e.g. s3://bucket/lists/today.xlsx or oss://bucket/mail/welcome.tpl, downloaded to a temporary
+	  directory before sending:
+	  "s3": {
+	    "region": "cn-north-1",
+	    "endpoint": "",
+	    "access_key_id": "",
+	    "access_key_secret": "",
+	    "security_token": "",
+	    "path_style": false
+	  },
+	  "oss": {
+	    "endpoint": "oss-cn-hangzhou.aliyuncs.com",
+	    "access_key_id": "",
+	    "access_key_secret": ""
+	  }
+	  fields not configured are read from environment variables: s3 uses AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY,
+	  AWS_SESSION_TOKEN, AWS_REGION and AWS_ENDPOINT_URL, oss uses OSS_ACCESS_KEY_ID, OSS_ACCESS_KEY_SECRET,
+	  OSS_SESSION_TOKEN and OSS_ENDPOINT; endpoint may have an http:// prefix, and MinIO and other compatible
+	  services usually need path_style;
+	  language variants of templates must be local
+
+	* these files can also be http:// or https:// URLs; http_headers sets request headers per host name for
+	  downloading, e.g. credentials:
+	  "http_headers": {
+	    "templates.example.com": {"Authorization": "Bearer --TOKEN--"}
+	  }
+	  append #sha256=<hex checksum> to verify the downloaded content, nothing is sent when it does not match,
+	  e.g. --template "https://templates.example.com/welcome.tpl#sha256=9f86d0..."; s3:// / oss:// URLs support it too
+
+	Content file:
+
+	Template file:
+	templates can use the {{ .Xxxx }} syntax to access the other custom columns of the Excel file
+	{{ date .Expiry "January 2, 2006" }} prints a date column with a Go time layout; the column can be text like
+	2024-05-01 or an Excel date value; in handlebars / mustache it is {{date Expiry "2006-01-02"}}
+	{{ money .Amount "CNY" }} prints ¥1,234.56 (also USD, EUR, GBP, JPY, HKD and others, JPY / KRW without decimals),
+	{{ number .Amount 2 }} prints 1,234.56, {{ fixed .Rate 1 }} prints 3.5, all rounded half up
+
+	Excel source file:
+	the data file can be .xlsx, .xls (Excel 97-2003), .ods (LibreOffice) or .csv, and the first sheet is read;
+	date cells in .ods are read as 2006-01-02 15:04:05 regardless of the display format;
+	date cells in .xlsx are read as 2006-01-02, 15:04:05 or 2006-01-02 15:04:05 by the cell format,
+	instead of numbers like 45231
+
+	the data file can also be a contacts file (.vcf) exported from a phone or Outlook, with one row per contact that
+	has an email address; the subject is given with --subject (which can use the columns below):
+	  EMAIL is SendTo (the preferred one when there are several), FN is Name, N is LastName / FirstName,
+	  ORG is Org, TITLE is Title, TEL is Phone, NOTE is Note, usable in templates, e.g. {{ .Name }}, {{ .Org }}
+	two formats are supported
+	fixed format:
+	SendTo, Subject, Content
+	+-------------------------------------------------------+
+	| helloworld_hyx@163.com  | Subject1 | Optional content |
+	+-------------------------------------------------------+
+	| helloworld_hyx@qq.com  | Subject2                     |
+	+-------------------------------------------------------+
+
+	* Content is optional; when not empty it replaces the content given by --content / --template
+
+	or the format with a header:
+	+---------------+----------+---------+-----+
+	|    SendTo     | Subject  | Content | Xxx |
+	+---------------+----------+---------+-----+
+	| abc@hello.com | Subject1 |         |   1 |
+	| def@hello.com | Subject2 | abc     |   2 |
+	+---------------+----------+---------+-----+
+
+	* the header names (SendTo, Subject, Content) are built in; all except Content are required, in any order
+	* Content is optional; when not empty it replaces the content given by --content / --template
+	* Xxx can be anything, there can be several, and templates can access them
+	* the Lang column selects the language variant of the template, see --lang-pattern
+	* the Segment column selects the sender from from_pool
+	* the Attachments column sets the attachments of the row, separated by ;, which can be https:// URLs, see --attach
+	* the ContentType column sets the content type of the row, text/plain or text/html (or plain / html),
+	  replacing the automatic detection and --content-type
+	* SendTo can use internationalized domains, e.g. user@例え.jp, converted to punycode in the SMTP envelope;
+	  headers keep the Unicode form when the server supports SMTPUTF8 and use punycode otherwise;
+	  addresses with Chinese or other non-ASCII characters in the local part need a server supporting SMTPUTF8
+
+	* the SendAt column sets the sending time of the row, e.g. 2024-05-01 09:00, or an Excel date cell;
+	  emails are sorted by sending time and wait until it comes, rows without SendAt are sent immediately;
+	  in the watch / service commands every file waits separately without blocking the others
+	* the Timezone column sets the recipient's time zone, e.g. Asia/Shanghai, America/New_York or +08:00, and SendAt
+	  is parsed in that time zone, see --send-local-time
+	* the InReplyTo column sets the Message-ID the row replies to, and the References column the earlier Message-IDs of
+	  the conversation (separated by spaces), setting the In-Reply-To and References headers; they take precedence
+	  over --thread-report
+`
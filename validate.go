@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"gopkg.in/gomail.v2"
+)
+
+// validate 检查 Excel 中的每一行并渲染邮件，不连接 SMTP 服务器，返回是否全部通过
+func validate(cfg *Config, file, content, template string, contentProvider ContentProvider, opts *listOptions, out io.Writer) bool {
+	list, rowErrors, err := parseSendList(cfg, file, opts)
+	if err != nil {
+		fmt.Fprintf(out, tr("处理 Excel 文件失败：%s\n"), err)
+		return false
+	}
+
+	problems := map[int][]string{}
+	addresses := map[int]string{}
+	rows := []int{}
+	addProblem := func(row int, problem string) {
+		if _, ok := problems[row]; !ok {
+			rows = append(rows, row)
+		}
+		problems[row] = append(problems[row], problem)
+	}
+
+	tagSendList(list, opts.id())
+
+	for _, e := range rowErrors {
+		addProblem(e.Row, e.Err.Error())
+	}
+
+	contentProvider, err = getLangContentProvider(cfg, contentProvider, content, template, list)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return false
+	}
+
+	if list, contentProvider, err = applyFooter(cfg, list, contentProvider); err != nil {
+		fmt.Fprintln(out, err)
+		return false
+	}
+
+	decorators, err := getDecorators(cfg)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return false
+	}
+
+	selectFrom, err := getFromSelector(cfg)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return false
+	}
+
+	m := gomail.NewMessage()
+
+	for _, s := range list {
+		addresses[s.Row] = s.SendTo
+
+		for _, problem := range checkSchema(cfg, s) {
+			addProblem(s.Row, problem)
+		}
+
+		setAddressHeader(m, "From", selectFrom(s))
+		setAddressHeader(m, "To", s.SendTo)
+		m.SetHeader("Subject", encodeHeaderText(s.Subject))
+		contentType, body, err := renderBody(s, contentProvider)
+		if err != nil {
+			addProblem(s.Row, err.Error())
+		} else {
+			m.SetBody(contentType, string(body))
+		}
+
+		if err := decorate(m, s, decorators); err != nil {
+			addProblem(s.Row, err.Error())
+		} else if cfg.MaxMessageSize > 0 {
+			if size, err := messageSize(m); err == nil && size > cfg.MaxMessageSize {
+				addProblem(s.Row, (&MessageTooLargeError{Size: size, Limit: cfg.MaxMessageSize}).Error())
+			}
+		}
+		m.Reset()
+	}
+
+	sort.Ints(rows)
+
+	for _, row := range rows {
+		if addr, ok := addresses[row]; ok {
+			fmt.Fprintf(out, tr("第 %d 行 %s:\n"), row, maskAddress(addr))
+		} else {
+			fmt.Fprintf(out, tr("第 %d 行:\n"), row)
+		}
+		for _, problem := range problems[row] {
+			fmt.Fprintf(out, "\t%s\n", redactText(problem))
+		}
+	}
+
+	fmt.Fprintf(out, tr("共 %d 行，%d 行有问题\n"), len(list)+len(rowErrors), len(rows))
+
+	if err := preflight(cfg, list, contentProvider, decorators); err != nil {
+		fmt.Fprintln(out, err)
+		return false
+	}
+
+	return len(rows) == 0
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/gomail.v2"
+)
+
+// compileVCard 与邮件模板一样按 --engine 和 --on-missing 编译 vCard 模板
+func compileVCard(file string) (renderer, error) {
+	logDebug("从 %s 中读取 vCard", file)
+	data, err := readFileContent(file)
+	if err != nil {
+		return nil, fmt.Errorf(trErr("读取 vCard 文件失败：%s"), err)
+	}
+	t, err := compileTemplate("vcard", filepath.Dir(file), string(data), false)
+	if err != nil {
+		return nil, fmt.Errorf(trErr("解析 vCard 模板失败：%s"), err)
+	}
+	return t, nil
+}
+
+func getVCardDecorator(file string) (Decorator, error) {
+	t, err := compileVCard(file)
+	if err != nil {
+		return nil, err
+	}
+
+	name := filepath.Base(file)
+	if !strings.EqualFold(filepath.Ext(name), ".vcf") {
+		name += ".vcf"
+	}
+
+	return func(m *gomail.Message, send *Send) error {
+		var buf bytes.Buffer
+		if err := t(&buf, send.Meta); err != nil {
+			return fmt.Errorf(trErr("渲染 vCard 失败：%s"), err)
+		}
+		card := normalizeCRLF(buf.String())
+		if !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(card)), "BEGIN:VCARD") {
+			return fmt.Errorf(trErr("无效的 vCard 内容：%s"), file)
+		}
+		attachBytes(m, name, []byte(card), gomail.SetHeader(map[string][]string{
+			"Content-Type": {`text/vcard; charset=utf-8; name="` + name + `"`},
+		}))
+		return nil
+	}, nil
+}
+
+// vCard 要求使用 CRLF 换行
+func normalizeCRLF(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\n", "\r\n")
+}
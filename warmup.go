@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+var defaultWarmupSchedule = []int{50, 100, 250, 500, 1000, 2000, 5000}
+
+// warmupState 记录预热进度，保存在 --warmup 指定的文件中；每发送一封邮件在 <文件>.log 中追加一行，
+// 中断后可以继续，下次运行时把追加的记录合并到进度文件中并清空 .log
+type warmupState struct {
+	mu       sync.Mutex
+	file     string
+	schedule []int
+	log      *os.File
+
+	Day       int             `json:"day"`
+	Date      string          `json:"date"`
+	SentToday int             `json:"sent_today"`
+	Done      map[string]bool `json:"done"`
+}
+
+// warmupEntry 为 .log 中的一行，charged 为是否计入当天的发送量
+type warmupEntry struct {
+	To      string `json:"to"`
+	Sent    bool   `json:"sent"`
+	Charged bool   `json:"charged"`
+}
+
+var warmup *warmupState
+
+func loadWarmupState(file string, schedule []int) (*warmupState, error) {
+	if len(schedule) == 0 {
+		schedule = defaultWarmupSchedule
+	}
+	w := &warmupState{file: file, schedule: schedule, Done: map[string]bool{}}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, w); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.replay(); err != nil {
+		return nil, err
+	}
+
+	// 换了一天且前一天发送过邮件时进入下一天的发送量
+	today := time.Now().Format("2006-01-02")
+	if w.Date != today {
+		if w.Day == 0 || w.SentToday > 0 {
+			w.Day++
+		}
+		w.Date = today
+		w.SentToday = 0
+	}
+
+	// 先保存合并后的进度再清空 .log，保存失败时下次运行还会合并同样的记录
+	if err := w.save(); err != nil {
+		return nil, err
+	}
+	if w.log, err = os.OpenFile(file+".log", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// replay 合并上次运行追加到 .log 的记录，最后一行不完整时忽略
+func (w *warmupState) replay() error {
+	f, err := os.Open(w.file + ".log")
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e warmupEntry
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue
+		}
+		if e.Charged {
+			w.SentToday++
+		}
+		if e.Sent {
+			w.Done[e.To] = true
+		}
+	}
+	return scanner.Err()
+}
+
+// limit 返回今天的发送量
+func (w *warmupState) limit() int {
+	i := w.Day - 1
+	if i >= len(w.schedule) {
+		i = len(w.schedule) - 1
+	}
+	return w.schedule[i]
+}
+
+// take 去掉之前已经发送成功的收件人，并按今天剩余的发送量截取，同时返回今天的进度
+func (w *warmupState) take(list []*Send) (rest []*Send, day, limit, sentToday int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	left := w.limit() - w.SentToday
+	rest = []*Send{}
+	pending := 0
+	for _, s := range list {
+		if w.Done[dedupeKey(s.SendTo)] {
+			continue
+		}
+		pending++
+		if len(rest) < left {
+			rest = append(rest, s)
+		}
+	}
+	logDebug("预热第 %d 天，还有 %d 个收件人未发送", w.Day, pending)
+	return rest, w.Day, w.limit(), w.SentToday
+}
+
+// record 失败的邮件也计入当天的发送量，但下次运行时会重新发送；charge 为 false 时只记录是否发送成功，
+// 用于重试已经计入过的失败收件人；监控邮件不计入
+func (w *warmupState) record(s *Send, err error, charge bool) error {
+	if s.Seed {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	e := warmupEntry{To: dedupeKey(s.SendTo), Sent: err == nil, Charged: charge}
+	if e.Charged {
+		w.SentToday++
+	}
+	if e.Sent {
+		w.Done[e.To] = true
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = w.log.Write(append(data, '\n'))
+	return err
+}
+
+func (w *warmupState) save() error {
+	data, err := json.MarshalIndent(w, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := w.file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.file)
+}
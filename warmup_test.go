@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWarmupState(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "warmup.json")
+	w, err := loadWarmupState(file, []int{3, 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := []*Send{{SendTo: "a@example.com"}, {SendTo: "b@example.com"}, {SendTo: "c@example.com"}, {SendTo: "d@example.com"}}
+	rest, day, limit, _ := w.take(list)
+	if len(rest) != 3 || day != 1 || limit != 3 {
+		t.Fatalf("take = %d recipients, day %d, limit %d", len(rest), day, limit)
+	}
+
+	for _, r := range []struct {
+		s      *Send
+		err    error
+		charge bool
+	}{
+		{&Send{SendTo: "a@example.com"}, nil, true},
+		{&Send{SendTo: "seed@example.com", Seed: true}, nil, true},
+		{&Send{SendTo: "b@example.com"}, errors.New("550"), true},
+		// 重试的失败收件人不重复计入
+		{&Send{SendTo: "b@example.com"}, nil, false},
+	} {
+		if err := w.record(r.s, r.err, r.charge); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if w.SentToday != 2 {
+		t.Fatalf("sent today = %d, want 2", w.SentToday)
+	}
+	w.log.Close()
+
+	// 进度文件只在运行开始时重写，发送时只追加 .log
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "a@example.com") {
+		t.Fatalf("state file rewritten while sending: %s", data)
+	}
+
+	w, err = loadWarmupState(file, []int{3, 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.log.Close()
+	if w.SentToday != 2 || !w.Done["a@example.com"] || !w.Done["b@example.com"] || w.Done["seed@example.com"] {
+		t.Fatalf("replayed state = %+v", w)
+	}
+	rest, _, _, sentToday := w.take(list)
+	if len(rest) != 1 || rest[0].SendTo != "c@example.com" || sentToday != 2 {
+		t.Fatalf("take after reload = %v, sent today %d", rest, sentToday)
+	}
+	if data, err := ioutil.ReadFile(file + ".log"); err != nil || len(data) != 0 {
+		t.Fatalf(".log after reload = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	watchDoneDir   = "done"
+	watchFailedDir = "failed"
+)
+
+// watch 监视目录中新出现的数据文件，检查、发送，然后移动到 done/ 或 failed/；
+// 每个文件单独处理，包含 SendAt 的文件会一直等到最后一封邮件的发送时间，不影响其他文件；stop 关闭后等所有文件处理完返回
+func watch(cfg *Config, dir string, contentProvider ContentProvider, stop <-chan struct{}) {
+	for _, sub := range []string{watchDoneDir, watchFailedDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			log.Fatalf(tr("创建目录失败：%s"), err)
+		}
+	}
+
+	log.Printf(tr("开始监视目录 %s"), dir)
+
+	if len(httpAddr) > 0 {
+		go serveDashboard(httpAddr)
+	}
+
+	// 文件在两次检查之间大小和修改时间都没有变化才处理，避免处理还在上传的文件
+	seen := map[string]os.FileInfo{}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	processing := map[string]bool{}
+
+	reloader := newContentReloader(cfg, contentProvider)
+
+	for {
+		contentProvider = reloader.reload()
+
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			log.Printf(tr("读取目录失败：%s"), err)
+		}
+
+		current := map[string]os.FileInfo{}
+		for _, info := range infos {
+			if info.IsDir() || !isWatchedFile(info.Name()) {
+				continue
+			}
+			name := info.Name()
+			mu.Lock()
+			busy := processing[name]
+			mu.Unlock()
+			if busy {
+				continue
+			}
+			current[name] = info
+
+			if prev, ok := seen[name]; ok && prev.Size() == info.Size() && prev.ModTime().Equal(info.ModTime()) {
+				mu.Lock()
+				processing[name] = true
+				mu.Unlock()
+				delete(current, name)
+
+				wg.Add(1)
+				go func(name string, contentProvider ContentProvider) {
+					defer wg.Done()
+					processWatchedFile(cfg, dir, name, contentProvider)
+					mu.Lock()
+					delete(processing, name)
+					mu.Unlock()
+				}(name, contentProvider)
+			}
+		}
+		seen = current
+
+		select {
+		case <-stop:
+			wg.Wait()
+			log.Printf(tr("停止监视目录 %s"), dir)
+			return
+		case <-time.After(watchInterval):
+		}
+	}
+}
+
+// contentReloader 在邮件内容或模板文件修改后重新加载，解析失败时继续使用原来的版本；
+// 每个数据文件开始处理时确定使用的版本，正在发送的任务不受影响
+type contentReloader struct {
+	cfg      *Config
+	file     string
+	modTime  time.Time
+	provider ContentProvider
+}
+
+func newContentReloader(cfg *Config, provider ContentProvider) *contentReloader {
+	r := &contentReloader{cfg: cfg, file: template, provider: provider}
+	if len(content) > 0 {
+		r.file = content
+	}
+	if info, err := os.Stat(r.file); err == nil {
+		r.modTime = info.ModTime()
+	}
+	return r
+}
+
+func (r *contentReloader) reload() ContentProvider {
+	info, err := os.Stat(r.file)
+	if err != nil || info.ModTime().Equal(r.modTime) {
+		return r.provider
+	}
+	r.modTime = info.ModTime()
+
+	var provider ContentProvider
+	if len(content) > 0 {
+		provider, err = getContentProvider(r.cfg, r.file, "")
+	} else {
+		provider, err = getContentProvider(r.cfg, "", r.file)
+	}
+	if err != nil {
+		log.Printf(tr("重新加载 %s 失败，继续使用原来的版本：%s"), r.file, err)
+		return r.provider
+	}
+	log.Printf(tr("已重新加载 %s"), r.file)
+	r.provider = provider
+	return provider
+}
+
+func isWatchedFile(name string) bool {
+	if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "~$") {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".xlsx", ".xls", ".ods", ".csv", ".vcf":
+		return true
+	}
+	return false
+}
+
+func processWatchedFile(defaultCfg *Config, dir, name string, contentProvider ContentProvider) {
+	file := filepath.Join(dir, name)
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+
+	log.Printf(tr("处理文件 %s"), file)
+
+	files := []string{name}
+	cfg := defaultCfg
+
+	paired := base + ".json"
+	if _, err := os.Stat(filepath.Join(dir, paired)); err == nil {
+		c, err := loadConfig(filepath.Join(dir, paired))
+		if err != nil {
+			failWatchedFile(dir, files, base, fmt.Sprintf(trErr("读取配置文件 %s 失败：%s\n"), paired, err))
+			return
+		}
+		logDebug("%s 使用配置文件 %s", name, paired)
+		cfg = c
+		files = append(files, paired)
+	}
+
+	var problems bytes.Buffer
+	if !validate(cfg, file, content, template, contentProvider, nil, &problems) {
+		failWatchedFile(dir, files, base, problems.String())
+		return
+	}
+
+	report := base + ".report.jsonl"
+	cancelled := false
+
+	err := func() error {
+		list, contentProvider, err := prepareSendList(cfg, file, content, template, contentProvider, nil)
+		if err != nil {
+			return err
+		}
+		decorators, err := getDecorators(cfg)
+		if err != nil {
+			return err
+		}
+		reporter, err := newReporter(filepath.Join(dir, watchDoneDir, report), cfg.ReportSalt)
+		if err != nil {
+			return err
+		}
+		defer reporter.Close()
+
+		campaign := newCampaign(name, list, reporter)
+		campaign.retry = retryFailures(cfg, contentProvider, decorators)
+		if err := sendEmails(cfg, list, contentProvider, decorators, campaign); err != nil {
+			return err
+		}
+		cancelled = campaign.Snapshot().Status == campaignCancelled
+		log.Printf(tr("%s 发送完成，成功 %d 封，失败 %d 封"), name, reporter.Sent, reporter.Failed)
+		return nil
+	}()
+
+	if err != nil {
+		log.Printf(tr("%s 处理失败：%s"), name, err)
+		os.Remove(filepath.Join(dir, watchDoneDir, report))
+		failWatchedFile(dir, files, base, err.Error()+"\n")
+		return
+	}
+
+	// 取消的任务只发送了一部分，报告一起放到 failed/，重新处理前需要去掉已发送的收件人
+	if cancelled {
+		if err := os.Rename(filepath.Join(dir, watchDoneDir, report), filepath.Join(dir, watchFailedDir, report)); err != nil {
+			log.Printf(tr("移动文件 %s 失败：%s"), report, err)
+		}
+		failWatchedFile(dir, files, base, fmt.Sprintf(trErr("任务已取消，已发送的收件人见 %s\n"), report))
+		return
+	}
+
+	moveWatchedFiles(dir, watchDoneDir, files)
+}
+
+func failWatchedFile(dir string, files []string, base, report string) {
+	log.Printf(tr("%s 处理失败"), files[0])
+	if err := ioutil.WriteFile(filepath.Join(dir, watchFailedDir, base+".report.txt"), []byte(report), 0644); err != nil {
+		log.Printf(tr("写入报告失败：%s"), err)
+	}
+	moveWatchedFiles(dir, watchFailedDir, files)
+}
+
+func moveWatchedFiles(dir, sub string, files []string) {
+	for _, name := range files {
+		target := filepath.Join(dir, sub, name)
+		if _, err := os.Stat(target); err == nil {
+			ext := filepath.Ext(name)
+			target = filepath.Join(dir, sub, fmt.Sprintf("%s.%s%s", strings.TrimSuffix(name, ext), time.Now().Format("20060102150405"), ext))
+		}
+		if err := os.Rename(filepath.Join(dir, name), target); err != nil {
+			log.Printf(tr("移动文件 %s 失败：%s"), name, err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// ZipConfig 配置把超过 threshold 字节的附件压缩为 zip，password 支持模板语法，为空时不加密
+type ZipConfig struct {
+	Threshold int64  `json:"threshold"`
+	Password  string `json:"password"`
+}
+
+// attachmentArchiver 返回实际附带的文件名和内容
+type attachmentArchiver func(send *Send, name string, data []byte) (string, []byte, error)
+
+func getAttachmentArchiver(cfg *Config) (attachmentArchiver, error) {
+	c := cfg.ZipAttachments
+	if c == nil {
+		return func(send *Send, name string, data []byte) (string, []byte, error) {
+			return name, data, nil
+		}, nil
+	}
+	pt, err := texttemplate.New("zip-password").Parse(c.Password)
+	if err != nil {
+		return nil, fmt.Errorf(trErr("解析压缩包密码失败：%s"), err)
+	}
+	return func(send *Send, name string, data []byte) (string, []byte, error) {
+		if int64(len(data)) <= c.Threshold {
+			return name, data, nil
+		}
+		var password bytes.Buffer
+		if err := pt.Execute(&password, send.Meta); err != nil {
+			return "", nil, fmt.Errorf(trErr("渲染压缩包密码失败：%s"), err)
+		}
+		archive, err := zipFile(name, data, password.String())
+		if err != nil {
+			return "", nil, fmt.Errorf(trErr("压缩附件失败：%s"), err)
+		}
+		logDebug("附件 %s 从 %d 字节压缩为 %d 字节", name, len(data), len(archive))
+		return strings.TrimSuffix(name, filepath.Ext(name)) + ".zip", archive, nil
+	}, nil
+}
+
+// zipFile 生成只包含一个文件的 zip，指定密码时使用传统的 PKWARE 加密（ZipCrypto），
+// 各平台自带的解压工具都支持
+func zipFile(name string, data []byte, password string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate}
+	header.SetModTime(time.Now())
+	if len(password) > 0 {
+		header.Flags |= 0x1
+		// 使用数据描述符时，加密头的最后一个字节为修改时间的高字节
+		check := byte(header.ModifiedTime >> 8)
+		w.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+			enc, err := newZipCryptoWriter(out, password, check)
+			if err != nil {
+				return nil, err
+			}
+			return flate.NewWriter(enc, flate.DefaultCompression)
+		})
+	}
+
+	f, err := w.CreateHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// zipCryptoWriter 在第一次写入时才输出加密头，创建压缩器时 zip.Writer 还没有写入文件头
+type zipCryptoWriter struct {
+	w      io.Writer
+	keys   [3]uint32
+	header []byte
+}
+
+func newZipCryptoWriter(w io.Writer, password string, check byte) (*zipCryptoWriter, error) {
+	z := &zipCryptoWriter{w: w, keys: [3]uint32{0x12345678, 0x23456789, 0x34567890}}
+	for i := 0; i < len(password); i++ {
+		z.update(password[i])
+	}
+	header := make([]byte, 12)
+	if _, err := rand.Read(header[:11]); err != nil {
+		return nil, err
+	}
+	header[11] = check
+	z.header = z.encrypt(header)
+	return z, nil
+}
+
+func (z *zipCryptoWriter) update(b byte) {
+	z.keys[0] = (*crc32.IEEETable)[byte(z.keys[0])^b] ^ (z.keys[0] >> 8)
+	z.keys[1] = (z.keys[1]+(z.keys[0]&0xff))*134775813 + 1
+	z.keys[2] = (*crc32.IEEETable)[byte(z.keys[2])^byte(z.keys[1]>>24)] ^ (z.keys[2] >> 8)
+}
+
+func (z *zipCryptoWriter) encrypt(p []byte) []byte {
+	out := make([]byte, len(p))
+	for i, b := range p {
+		t := z.keys[2] | 2
+		out[i] = b ^ byte((t*(t^1))>>8)
+		z.update(b)
+	}
+	return out
+}
+
+func (z *zipCryptoWriter) Write(p []byte) (int, error) {
+	if z.header != nil {
+		if _, err := z.w.Write(z.header); err != nil {
+			return 0, err
+		}
+		z.header = nil
+	}
+	if _, err := z.w.Write(z.encrypt(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}